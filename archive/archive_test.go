@@ -0,0 +1,244 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bespoke/fsys"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+var allEntriesRe = regexp.MustCompile(`^(?:\./)?(.+)$`)
+
+func useMemFs(t *testing.T) {
+	t.Helper()
+	previous := fsys.FS
+	fsys.FS = afero.NewMemMapFs()
+	t.Cleanup(func() { fsys.FS = previous })
+}
+
+type entry struct {
+	name     string
+	typeflag byte
+	linkname string
+}
+
+func tarball(t *testing.T, entries []entry) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	gzipWriter := gzip.NewWriter(buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.linkname, Mode: 0644}
+		if e.typeflag == tar.TypeReg {
+			header.Size = int64(len(e.name))
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if e.typeflag == tar.TypeReg {
+			tarWriter.Write([]byte(e.name))
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+// zipball is written to the real filesystem, UnZip reads its archive with zip.OpenReader
+func zipball(t *testing.T, names []string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "archive.zip")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zipWriter := zip.NewWriter(file)
+	for _, name := range names {
+		w, err := zipWriter.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(name))
+	}
+	if err := zipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestUnTarGZ(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []entry
+		want    []string
+		unsafe  bool
+	}{
+		{"files and folders", []entry{{"a/", tar.TypeDir, ""}, {"a/b.js", tar.TypeReg, ""}, {"./c.css", tar.TypeReg, ""}}, []string{"a/b.js", "c.css"}, false},
+		{"files without folder entries", []entry{{"a/b/c.js", tar.TypeReg, ""}}, []string{"a/b/c.js"}, false},
+		{"link inside the destination", []entry{{"a/b.js", tar.TypeReg, ""}, {"a/l", tar.TypeSymlink, "b.js"}, {"h", tar.TypeLink, "a/b.js"}}, []string{"a/b.js"}, false},
+		{"parent folder", []entry{{"../evil.js", tar.TypeReg, ""}}, nil, true},
+		{"nested parent folder", []entry{{"a/../../evil.js", tar.TypeReg, ""}}, nil, true},
+		{"parent folder entry", []entry{{"a/../../evil/", tar.TypeDir, ""}}, nil, true},
+		{"backslashes", []entry{{"a\\..\\..\\evil.js", tar.TypeReg, ""}}, nil, true},
+		{"absolute", []entry{{"/etc/evil.js", tar.TypeReg, ""}}, nil, true},
+		{"absolute symlink", []entry{{"l", tar.TypeSymlink, "/etc"}}, nil, true},
+		{"symlink to the parent folder", []entry{{"a/l", tar.TypeSymlink, "../../etc"}}, nil, true},
+		{"hardlink to the parent folder", []entry{{"l", tar.TypeLink, "../etc/passwd"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFs(t)
+			dest := filepath.Join(os.TempDir(), "dest")
+			err := UnTarGZ(tarball(t, tt.entries), allEntriesRe, dest, Filter{})
+			var unsafe *UnsafeEntryError
+			if errors.As(err, &unsafe) != tt.unsafe {
+				t.Fatalf("err = %v, want unsafe %v", err, tt.unsafe)
+			}
+			if !tt.unsafe && err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tt.want {
+				if _, err := fsys.Stat(filepath.Join(dest, filepath.FromSlash(name))); err != nil {
+					t.Errorf("%s wasn't extracted: %v", name, err)
+				}
+			}
+			if tt.unsafe {
+				if found, _ := afero.Glob(fsys.FS, filepath.Join(filepath.Dir(dest), "evil*")); len(found) > 0 {
+					t.Errorf("%v were written outside of the destination", found)
+				}
+			}
+		})
+	}
+}
+
+func TestUnTarGZFilter(t *testing.T) {
+	useMemFs(t)
+	dest := filepath.Join(os.TempDir(), "dest")
+	extracted := []string{}
+	filter := Filter{Extracted: func(name string) { extracted = append(extracted, name) }}
+	entries := []entry{{"a/", tar.TypeDir, ""}, {"a/b.js", tar.TypeReg, ""}}
+	if err := UnTarGZ(tarball(t, entries), allEntriesRe, dest, filter); err != nil {
+		t.Fatal(err)
+	}
+	if len(extracted) != 1 || extracted[0] != "a/b.js" {
+		t.Errorf("extracted = %v, want [a/b.js]", extracted)
+	}
+}
+
+func TestUnZip(t *testing.T) {
+	tests := []struct {
+		name   string
+		names  []string
+		want   []string
+		unsafe bool
+	}{
+		{"files and folders", []string{"a/", "a/b.js", "c.css"}, []string{"a/b.js", "c.css"}, false},
+		{"parent folder", []string{"../evil.js"}, nil, true},
+		{"nested parent folder", []string{"a/../../evil.js"}, nil, true},
+		{"absolute", []string{"/etc/evil.js"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := zipball(t, tt.names)
+			useMemFs(t)
+			dest := filepath.Join(os.TempDir(), "dest")
+			err := UnZip(src, dest)
+			var unsafe *UnsafeEntryError
+			if errors.As(err, &unsafe) != tt.unsafe {
+				t.Fatalf("err = %v, want unsafe %v", err, tt.unsafe)
+			}
+			if !tt.unsafe && err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range tt.want {
+				if _, err := fsys.Stat(filepath.Join(dest, filepath.FromSlash(name))); err != nil {
+					t.Errorf("%s wasn't extracted: %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestEntryChecker(t *testing.T) {
+	tests := []struct {
+		name   string
+		goos   string
+		entry  string
+		dir    bool
+		unsafe bool
+		err    bool
+	}{
+		{"plain", "linux", "a/b.js", false, false, false},
+		{"parent folder", "linux", "a/../../b.js", false, true, true},
+		{"absolute", "linux", "/b.js", false, true, true},
+		{"drive letter", "windows", "C:/b.js", false, true, true},
+		{"reserved name", "windows", "a/con.js", false, false, true},
+		{"trailing dot", "windows", "a./b.js", false, false, true},
+		{"reserved name on linux", "linux", "a/con.js", false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := TargetOS
+			TargetOS = tt.goos
+			t.Cleanup(func() { TargetOS = previous })
+
+			err := newEntryChecker().check(tt.entry, os.TempDir(), tt.dir)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want an error %v", err, tt.err)
+			}
+			var unsafe *UnsafeEntryError
+			if errors.As(err, &unsafe) != tt.unsafe {
+				t.Errorf("err = %v, want unsafe %v", err, tt.unsafe)
+			}
+		})
+	}
+}
+
+func TestEntryCheckerCase(t *testing.T) {
+	previous := TargetOS
+	TargetOS = "darwin"
+	t.Cleanup(func() { TargetOS = previous })
+
+	checker := newEntryChecker()
+	if err := checker.check("a/B.js", os.TempDir(), false); err != nil {
+		t.Fatal(err)
+	}
+	if err := checker.check("a/b.js", os.TempDir(), false); err == nil {
+		t.Error("a/b.js doesn't collide with a/B.js")
+	}
+	if err := checker.check("A", os.TempDir(), true); err != nil {
+		t.Errorf("folders differing by case are merged, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package archive
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+// Filter selects the files extracted from an archive by their slash separated path relative to the destination.
+// Patterns use the path.Match syntax, ** matches any number of folders, patterns without a slash match
+// a file or folder name at any depth, and a pattern matching a folder matches everything in it
+type Filter struct {
+	// Include lists the files to extract, all of them when empty
+	Include []string
+	// Exclude lists the files to skip, even when they are included
+	Exclude []string
+	// Keep lists files that are extracted whatever the patterns
+	Keep []string
+	// MaxDepth is how many folders deep files are extracted (1 for top-level files only), unlimited when 0
+	MaxDepth int
+	// Extracted is called with the path of every file written, to report the progress of the extraction
+	Extracted func(name string)
+}
+
+func (f *Filter) IsZero() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0 && f.MaxDepth == 0
+}
+
+// Match reports whether a file passes the filter
+func (f *Filter) Match(name string) bool {
+	if slices.Contains(f.Keep, name) {
+		return true
+	}
+	segments := strings.Split(name, "/")
+	if f.MaxDepth > 0 && len(segments) > f.MaxDepth {
+		return false
+	}
+	if len(f.Include) > 0 && !matchAny(f.Include, segments) {
+		return false
+	}
+	return !matchAny(f.Exclude, segments)
+}
+
+func matchAny(patterns []string, segments []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.Trim(strings.TrimPrefix(pattern, "./"), "/")
+		if !strings.Contains(pattern, "/") {
+			for _, segment := range segments {
+				if ok, _ := path.Match(pattern, segment); ok {
+					return true
+				}
+			}
+			continue
+		}
+		patternSegments := strings.Split(pattern, "/")
+		// Matching a parent folder matches the file
+		for i := 1; i <= len(segments); i++ {
+			if matchSegments(patternSegments, segments[:i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchSegments(pattern []string, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
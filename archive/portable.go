@@ -0,0 +1,155 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package archive
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// TargetOS is the platform whose file system constraints the extracted entries are checked against
+var TargetOS = runtime.GOOS
+
+// LongPaths writes files through \\?\ paths on Windows, which aren't limited to MAX_PATH
+var LongPaths = false
+
+// maxPath is MAX_PATH without the terminating NUL
+const maxPath = 259
+
+var reservedNames = []string{"con", "prn", "aux", "nul",
+	"com1", "com2", "com3", "com4", "com5", "com6", "com7", "com8", "com9",
+	"lpt1", "lpt2", "lpt3", "lpt4", "lpt5", "lpt6", "lpt7", "lpt8", "lpt9"}
+
+// EntryError reports an archive entry that can't be written on the target platform
+type EntryError struct {
+	Entry  string
+	Reason string
+}
+
+func (e *EntryError) Error() string {
+	return "can't extract " + e.Entry + " on " + TargetOS + ": " + e.Reason
+}
+
+// UnsafeEntryError reports an archive entry that would be written outside of the destination folder,
+// the whole archive is rejected rather than the entry skipped
+type UnsafeEntryError struct {
+	Entry  string
+	Reason string
+}
+
+func (e *UnsafeEntryError) Error() string {
+	return "refusing to extract " + e.Entry + ": " + e.Reason
+}
+
+// checkInside rejects the absolute names and those with a .. segment, and makes sure the entry lands
+// under dest once joined. Backslashes are treated as separators whatever the platform
+func checkInside(name string, dest string) error {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(slashed, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" || (len(slashed) > 1 && slashed[1] == ':') {
+		return &UnsafeEntryError{name, "its path is absolute"}
+	}
+	for _, segment := range strings.Split(slashed, "/") {
+		if segment == ".." {
+			return &UnsafeEntryError{name, "its path goes up out of the destination folder"}
+		}
+	}
+	root := filepath.Clean(dest)
+	full := filepath.Join(root, filepath.FromSlash(slashed))
+	if full != root && !strings.HasPrefix(full, strings.TrimSuffix(root, string(os.PathSeparator))+string(os.PathSeparator)) {
+		return &UnsafeEntryError{name, "it resolves outside of the destination folder"}
+	}
+	return nil
+}
+
+// entryChecker validates the entries of an archive before they are extracted, remembering the previous
+// ones to find the names that only differ by case
+type entryChecker struct {
+	seen map[string]string
+}
+
+func newEntryChecker() *entryChecker {
+	return &entryChecker{seen: map[string]string{}}
+}
+
+func caseInsensitive(goos string) bool {
+	return goos == "windows" || goos == "darwin"
+}
+
+// check validates an entry, name is slash separated and relative to dest, the destination folder.
+// Folders whose names only differ by case are merged rather than colliding. Entries escaping dest
+// are reported with an UnsafeEntryError
+func (c *entryChecker) check(name string, dest string, dir bool) error {
+	if err := checkInside(name, dest); err != nil {
+		return err
+	}
+	name = strings.Trim(name, "/")
+
+	if caseInsensitive(TargetOS) && !dir {
+		key := strings.ToLower(name)
+		if other, ok := c.seen[key]; ok && other != name {
+			return &EntryError{name, "collides with " + other + " on case-insensitive file systems"}
+		}
+		c.seen[key] = name
+	}
+	if TargetOS != "windows" {
+		return nil
+	}
+
+	for _, segment := range strings.Split(name, "/") {
+		if i := strings.IndexAny(segment, "<>:\"|?*\\"); i >= 0 {
+			return &EntryError{name, "contains " + strconv.Quote(segment[i:i+1]) + ", which Windows doesn't allow in file names"}
+		}
+		if strings.IndexFunc(segment, func(r rune) bool { return r < 32 }) >= 0 {
+			return &EntryError{name, "contains control characters"}
+		}
+		if strings.HasSuffix(segment, ".") || strings.HasSuffix(segment, " ") {
+			return &EntryError{name, strconv.Quote(segment) + " ends with a dot or a space, which Windows drops"}
+		}
+		base, _, _ := strings.Cut(segment, ".")
+		for _, reserved := range reservedNames {
+			if strings.EqualFold(strings.TrimRight(base, " "), reserved) {
+				return &EntryError{name, strconv.Quote(segment) + " is a reserved device name on Windows"}
+			}
+		}
+	}
+
+	if !LongPaths {
+		full, err := filepath.Abs(filepath.Join(dest, filepath.FromSlash(name)))
+		if err != nil {
+			return err
+		}
+		if len(full) > maxPath {
+			return &EntryError{name, "its path is " + strconv.Itoa(len(full)) + " characters long, over the " + strconv.Itoa(maxPath) + " allowed by Windows (set long-paths to write it anyway)"}
+		}
+	}
+	return nil
+}
+
+// entryErrors joins the entries that were skipped
+func entryErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.Join(errs...)
+}
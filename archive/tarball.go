@@ -21,14 +21,30 @@ package archive
 
 import (
 	"archive/tar"
+	"bespoke/fsys"
 	"compress/gzip"
+	"errors"
 	"io"
-	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"strings"
+	"time"
 )
 
-func UnTarGZ(r io.Reader, src *regexp.Regexp, dest string) error {
+func UnTarGZ(r io.Reader, src *regexp.Regexp, dest string, filter Filter) error {
+	return unTarGZ(r, src, dest, filter, false)
+}
+
+// UnTarGZSubtree extracts the entries matching src from an archive listing the entries of a folder
+// contiguously (like git archive and the GitHub tarballs), it stops reading at the first entry past
+// the matching ones so that the rest of the archive doesn't have to be downloaded
+func UnTarGZSubtree(r io.Reader, src *regexp.Regexp, dest string, filter Filter) error {
+	return unTarGZ(r, src, dest, filter, true)
+}
+
+// unTarGZ extracts the entries matching src, at the path captured by its first group, that pass the filter
+func unTarGZ(r io.Reader, src *regexp.Regexp, dest string, filter Filter, contiguous bool) error {
 	gzipReader, err := gzip.NewReader(r)
 	if err != nil {
 		return err
@@ -37,6 +53,9 @@ func UnTarGZ(r io.Reader, src *regexp.Regexp, dest string) error {
 
 	tarReader := tar.NewReader(gzipReader)
 
+	checker := newEntryChecker()
+	skipped := []error{}
+	matched := false
 	for {
 		header, err := tarReader.Next()
 		if err == io.EOF {
@@ -49,18 +68,56 @@ func UnTarGZ(r io.Reader, src *regexp.Regexp, dest string) error {
 		nameRelToSrc := src.FindStringSubmatch(header.Name)
 
 		if nameRelToSrc == nil {
+			if matched && contiguous {
+				break
+			}
 			continue
 		}
+		matched = true
 
+		// An entry escaping dest fails the whole extraction, whatever its type and the filter
+		if err := checkInside(nameRelToSrc[1], dest); err != nil {
+			return err
+		}
 		tarEntryDest := filepath.Join(dest, nameRelToSrc[1])
 
 		switch header.Typeflag {
+		case tar.TypeSymlink, tar.TypeLink:
+			// Links aren't extracted, but an archive holding one that points out of dest isn't to be trusted
+			if err := checkLinkTarget(header, src, nameRelToSrc[1], dest); err != nil {
+				return err
+			}
 		case tar.TypeDir:
-			if err := os.Mkdir(tarEntryDest, 0755); err != nil {
+			// Filtered folders are created along with the files they hold, so that skipped ones don't leave empty folders
+			if !filter.IsZero() {
+				continue
+			}
+			if err := checker.check(nameRelToSrc[1], dest, true); err != nil {
+				if unsafe := (*UnsafeEntryError)(nil); errors.As(err, &unsafe) {
+					return err
+				}
+				skipped = append(skipped, err)
+				continue
+			}
+			if err := fsys.Mkdir(longPath(tarEntryDest), 0755); err != nil {
 				return err
 			}
 		case tar.TypeReg:
-			tarEntryFile, err := os.Create(tarEntryDest)
+			if !filter.IsZero() && !filter.Match(strings.Trim(nameRelToSrc[1], "/")) {
+				continue
+			}
+			if err := checker.check(nameRelToSrc[1], dest, false); err != nil {
+				if unsafe := (*UnsafeEntryError)(nil); errors.As(err, &unsafe) {
+					return err
+				}
+				skipped = append(skipped, err)
+				continue
+			}
+			// Archives written by TarGZ (and filtered extractions) have no folder entries
+			if err := fsys.MkdirAll(longPath(filepath.Dir(tarEntryDest)), 0755); err != nil {
+				return err
+			}
+			tarEntryFile, err := fsys.Create(longPath(tarEntryDest))
 			if err != nil {
 				return err
 			}
@@ -68,8 +125,95 @@ func UnTarGZ(r io.Reader, src *regexp.Regexp, dest string) error {
 				return err
 			}
 			tarEntryFile.Close()
+			if filter.Extracted != nil {
+				filter.Extracted(strings.Trim(nameRelToSrc[1], "/"))
+			}
 		}
 	}
 
+	// Every entry that can't be written is reported, not only the first one
+	return entryErrors(skipped)
+}
+
+// checkLinkTarget rejects the links whose target is outside of dest. Symbolic link targets are relative
+// to the folder of the link, hard link targets are archive entry names and must match src too
+func checkLinkTarget(header *tar.Header, src *regexp.Regexp, name string, dest string) error {
+	target := filepath.ToSlash(header.Linkname)
+	if header.Typeflag == tar.TypeLink {
+		targetRelToSrc := src.FindStringSubmatch(header.Linkname)
+		if targetRelToSrc == nil {
+			return &UnsafeEntryError{name, "it links to " + header.Linkname + ", which is outside of the extracted entries"}
+		}
+		target = targetRelToSrc[1]
+	} else {
+		if strings.HasPrefix(target, "/") || filepath.IsAbs(header.Linkname) || filepath.VolumeName(header.Linkname) != "" {
+			return &UnsafeEntryError{name, "it links to the absolute path " + header.Linkname}
+		}
+		target = path.Join(path.Dir(strings.Trim(name, "/")), target)
+	}
+	if target == ".." || strings.HasPrefix(target, "../") {
+		return &UnsafeEntryError{name, "it links to " + header.Linkname + ", which is outside of the destination folder"}
+	}
+	if err := checkInside(target, dest); err != nil {
+		return &UnsafeEntryError{name, "it links to " + header.Linkname + ", which is outside of the destination folder"}
+	}
 	return nil
 }
+
+// File is a file generated in memory to be archived along with the files of a folder
+type File struct {
+	Name string
+	Data []byte
+}
+
+// TarGZ writes the given files (relative to root, slash separated) followed by the generated ones into a
+// gzipped tarball
+func TarGZ(w io.Writer, root string, files []string, generated ...File) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, file := range files {
+		if err := addTarEntry(tarWriter, root, file); err != nil {
+			return err
+		}
+	}
+	for _, file := range generated {
+		header := &tar.Header{Typeflag: tar.TypeReg, Name: file.Name, Mode: 0644, Size: int64(len(file.Data)), ModTime: time.Now()}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tarWriter.Write(file.Data); err != nil {
+			return err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+func addTarEntry(tarWriter *tar.Writer, root string, file string) error {
+	f, err := fsys.Open(filepath.Join(root, filepath.FromSlash(file)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(fi, "")
+	if err != nil {
+		return err
+	}
+	header.Name = file
+
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = io.Copy(tarWriter, f)
+	return err
+}
@@ -21,11 +21,10 @@ package archive
 
 import (
 	"archive/zip"
-	"fmt"
+	"bespoke/fsys"
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
 )
 
 // pasta from https://stackoverflow.com/a/24792688
@@ -40,7 +39,7 @@ func UnZip(src, dest string) error {
 		}
 	}()
 
-	os.MkdirAll(dest, 0755)
+	fsys.MkdirAll(dest, 0755)
 
 	// Closure to address file descriptors issue with all the deferred .Close() methods
 	extractAndWriteFile := func(f *zip.File) error {
@@ -54,18 +53,17 @@ func UnZip(src, dest string) error {
 			}
 		}()
 
-		path := filepath.Join(dest, f.Name)
-
 		// Check for ZipSlip (Directory traversal)
-		if !strings.HasPrefix(path, filepath.Clean(dest)+string(os.PathSeparator)) {
-			return fmt.Errorf("illegal file path: %s", path)
+		if err := checkInside(f.Name, dest); err != nil {
+			return err
 		}
+		path := filepath.Join(dest, f.Name)
 
 		if f.FileInfo().IsDir() {
-			os.MkdirAll(path, f.Mode())
+			fsys.MkdirAll(path, f.Mode())
 		} else {
-			os.MkdirAll(filepath.Dir(path), f.Mode())
-			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
+			fsys.MkdirAll(filepath.Dir(path), f.Mode())
+			f, err := fsys.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode())
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,194 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/ui"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var pkgRunCmd = &cobra.Command{
+	Use:   "run id [action]",
+	Short: "Run an action of a module, or list its actions",
+	Long:  "id is author/name for the enabled version or author/name/version. Actions with a command run like the lifecycle scripts, rpc actions are sent to the module in Spotify through the daemon",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		identifier, err := module.ResolveStoreIdentifier(args[0])
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		if len(args) == 1 {
+			actions, err := module.ModuleActions(identifier)
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+			printActions(actions)
+			return
+		}
+
+		action, err := module.FindAction(identifier, args[1])
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if action.Rpc != "" {
+			err = requestDaemonAction(identifier, args[1])
+		} else {
+			var output []byte
+			output, err = module.RunAction(identifier, args[1], action)
+			os.Stdout.Write(output)
+		}
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+	},
+}
+
+func printActions(actions map[string]module.Action) {
+	if outputFormat == "json" {
+		printJSON(actions)
+		return
+	}
+	if len(actions) == 0 {
+		log.Println(i18n.T("The module has no actions"))
+		return
+	}
+
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	table := ui.NewTable("ACTION", "KIND", "DESCRIPTION")
+	for _, name := range names {
+		kind := "run"
+		if actions[name].Rpc != "" {
+			kind = "rpc"
+		}
+		table.Row(ui.Cyan(name), kind, actions[name].Description)
+	}
+	table.Render(os.Stdout)
+}
+
+// requestDaemonAction asks the daemon to send an rpc action to the Spotify clients connected to it
+func requestDaemonAction(identifier module.StoreIdentifier, name string) error {
+	query := url.Values{"module": {identifier.String()}, "action": {name}}
+	endpoint := "http://localhost:" + strconv.Itoa(viper.GetInt("daemon-port")) + "/modules/actions?" + query.Encode()
+	if dryRun {
+		log.Println(i18n.T("Would ask the daemon to send the %s action to %s", name, identifier.String()))
+		return nil
+	}
+
+	client := http.Client{Timeout: 10 * time.Second}
+	res, err := client.Post(endpoint, "", nil)
+	if err != nil {
+		return errors.New("can't reach the daemon, start it with `bespoke daemon start`: " + err.Error())
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return errors.New(strings.TrimSpace(string(body)))
+	}
+	var result actionResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return err
+	}
+	log.Println(i18n.T("Sent the %s action to %s in %d Spotify client(s)", name, identifier.String(), result.Clients))
+	return nil
+}
+
+type actionResult struct {
+	Output string `json:"output,omitempty"`
+	// Clients is the number of Spotify clients an rpc action was sent to
+	Clients int `json:"clients,omitempty"`
+}
+
+// handleModuleActions lists the actions of ?module= (GET) or runs ?action= (POST)
+func handleModuleActions(w http.ResponseWriter, r *http.Request) {
+	// Websites can't trigger actions, as they could run commands
+	if !trustedOrigin(r) {
+		http.Error(w, "untrusted origin", http.StatusForbidden)
+		return
+	}
+	identifier, err := module.ResolveStoreIdentifier(r.URL.Query().Get("module"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		actions, err := module.ModuleActions(identifier)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(actions)
+
+	case http.MethodPost:
+		name := r.URL.Query().Get("action")
+		action, err := module.FindAction(identifier, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		result := actionResult{}
+		if action.Rpc != "" {
+			result.Clients = broadcastRPC(module.ActionMessage(identifier, action))
+			if result.Clients == 0 {
+				http.Error(w, "no Spotify client is connected to the daemon, start Spotify to run "+name, http.StatusServiceUnavailable)
+				return
+			}
+		} else {
+			output, err := module.RunAction(identifier, name, action)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			result.Output = string(output)
+		}
+		log.Println(i18n.T("Ran the %s action of %s", name, identifier.String()))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func init() {
+	pkgCmd.AddCommand(pkgRunCmd)
+}
@@ -21,6 +21,9 @@ package cmd
 
 import (
 	"bespoke/archive"
+	"bespoke/i18n"
+	"bespoke/link"
+	"bespoke/notify"
 	"bespoke/paths"
 	"log"
 	"os"
@@ -30,16 +33,37 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	applyIfNeeded bool
+	applyNotify   bool
+)
+
 var applyCmd = &cobra.Command{
 	Use:   "apply",
 	Short: "Apply bespoke patch on Spotify",
 	Run: func(cmd *cobra.Command, args []string) {
+		if applyIfNeeded && isApplied() {
+			log.Println(i18n.T("Spotify is already patched"))
+			return
+		}
 		if err := execApply(); err != nil {
+			if applyNotify {
+				notify.Notify("bespoke", "Couldn't patch Spotify: "+err.Error())
+			}
 			log.Panicln(err.Error())
 		}
+		if applyNotify {
+			notify.Notify("bespoke", "Spotify was re-patched")
+		}
 	},
 }
 
+func isApplied() bool {
+	_, dest := getApps()
+	raw, err := os.ReadFile(filepath.Join(dest, "xpui", "index.html"))
+	return err == nil && strings.Contains(string(raw), "/hooks/index.js")
+}
+
 func getApps() (src string, dest string) {
 	src = paths.GetSpotifyAppsPath(spotifyDataPath)
 	if mirror {
@@ -53,13 +77,18 @@ func getApps() (src string, dest string) {
 func extractSpa(spa string, destFolder string) error {
 	basename := filepath.Base(spa)
 	extractDest := filepath.Join(destFolder, strings.TrimSuffix(basename, ".spa"))
-	log.Println("Extracting", spa, "->", extractDest)
-	if err := archive.UnZip(spa, extractDest); err != nil {
-		return err
+	log.Println(i18n.T("Extracting %s -> %s", spa, extractDest))
+	if !dryRun {
+		if err := archive.UnZip(spa, extractDest); err != nil {
+			return err
+		}
 	}
 	if !mirror {
 		spaBak := spa + ".bak"
-		log.Println("Moving", spa, "->", spaBak)
+		log.Println(i18n.T("Moving %s -> %s", spa, spaBak))
+		if dryRun {
+			return nil
+		}
 		if err := os.Rename(spa, spaBak); err != nil {
 			return err
 		}
@@ -68,6 +97,9 @@ func extractSpa(spa string, destFolder string) error {
 }
 
 func patchFile(path string, patch func(string) string) error {
+	if dryRun {
+		return nil
+	}
 	raw, err := os.ReadFile(path)
 	if err != nil {
 		return err
@@ -79,7 +111,7 @@ func patchFile(path string, patch func(string) string) error {
 }
 
 func patchIndexHtml(destXpuiPath string) error {
-	log.Println("Patching xpui/index.html")
+	log.Println(i18n.T("Patching xpui/index.html"))
 	return patchFile(filepath.Join(destXpuiPath, "index.html"), func(s string) string {
 		return strings.Replace(s, `<script defer="defer" src="/vendor~xpui.js"></script><script defer="defer" src="/xpui.js"></script>`, `<script type="module" src="/hooks/index.js"></script>`, 1)
 	})
@@ -90,8 +122,11 @@ func symlinkFiles(destXpuiPath string) error {
 	for _, folder := range folders {
 		folderSrcPath := filepath.Join(paths.ConfigPath, folder)
 		folderDestPath := filepath.Join(destXpuiPath, folder)
-		log.Println("Symlinking", folderDestPath, "->", folderSrcPath)
-		if err := os.Symlink(folderSrcPath, folderDestPath); err != nil {
+		log.Println(i18n.T("Linking (%s) %s -> %s", link.Mode.String(), folderDestPath, folderSrcPath))
+		if dryRun {
+			continue
+		}
+		if err := link.Create(folderSrcPath, folderDestPath); err != nil {
 			return err
 		}
 	}
@@ -99,7 +134,10 @@ func symlinkFiles(destXpuiPath string) error {
 }
 
 func execApply() error {
-	log.Println("Initializing bespoke")
+	log.Println(i18n.T("Initializing bespoke"))
+	if dryRun {
+		log.Println(i18n.T("Dry run, Spotify won't be modified"))
+	}
 	src, dest := getApps()
 
 	spa := filepath.Join(src, "xpui.spa")
@@ -108,12 +146,32 @@ func execApply() error {
 	}
 
 	destXpuiPath := filepath.Join(dest, "xpui")
+	if err := applyMixins(destXpuiPath); err != nil {
+		return err
+	}
 	if err := patchIndexHtml(destXpuiPath); err != nil {
 		return err
 	}
-	return symlinkFiles(destXpuiPath)
+	if err := symlinkFiles(destXpuiPath); err != nil {
+		return err
+	}
+
+	switch sandbox {
+	case paths.SandboxFlatpak:
+		log.Println(i18n.T("Spotify is sandboxed by flatpak, make sure it can access the bespoke folder with:"))
+		log.Println("\t" + paths.FlatpakOverrideHint())
+	case paths.SandboxSnap:
+		log.Println(i18n.T("Spotify is sandboxed by snap, launch it with `bespoke run` to load the mirrored client"))
+	}
+	if link.Mode == link.Copy {
+		log.Println(i18n.T("Files were copied instead of symlinked, run `bespoke apply` again after changing modules or hooks"))
+	}
+	return nil
 }
 
 func init() {
 	rootCmd.AddCommand(applyCmd)
+
+	applyCmd.Flags().BoolVar(&applyIfNeeded, "if-needed", false, "Only patch Spotify if it isn't patched already")
+	applyCmd.Flags().BoolVar(&applyNotify, "notify", false, "Send a desktop notification with the outcome")
 }
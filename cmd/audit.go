@@ -0,0 +1,86 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/ui"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var pkgAuditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "List installed modules with known vulnerabilities or retracted versions",
+	Long:  "checks the installed versions against the advisory feeds of the registries (their advisories url) and exits with status 1 when one is affected\n\n`bespoke pkg upgrade --fix` moves the affected enabled modules to the recommended version, and disables those no version fixes",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		spinner := ui.Spin("Checking advisories")
+		findings, errs := module.Audit()
+		spinner.Stop()
+		for _, err := range errs {
+			log.Println(err.Error())
+		}
+
+		if outputFormat == "json" {
+			printJSON(findings)
+		} else if len(findings) == 0 {
+			fmt.Println(i18n.T("No installed module is affected by an advisory"))
+		} else {
+			table := ui.NewTable("MODULE", "SEVERITY", "KIND", "ADVISORY", "RECOMMENDED")
+			for _, finding := range findings {
+				identifier := string(finding.Module) + "/" + string(finding.Version)
+				if !finding.Enabled {
+					identifier += " " + ui.Dim("(disabled)")
+				}
+				table.Row(identifier, formatSeverity(finding.Advisory.Severity), finding.Advisory.Kind, finding.Advisory.ID+" "+finding.Advisory.Summary, formatRecommended(finding))
+			}
+			table.Render(os.Stdout)
+		}
+		if len(findings) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func formatSeverity(severity string) string {
+	switch severity {
+	case "critical", "high":
+		return ui.Red(severity)
+	case "moderate":
+		return ui.Yellow(severity)
+	}
+	return ui.Dim(severity)
+}
+
+func formatRecommended(finding module.AuditFinding) string {
+	if finding.Recommended == "" {
+		return ui.Red("remove")
+	}
+	return ui.Green(string(finding.Recommended))
+}
+
+func init() {
+	pkgCmd.AddCommand(pkgAuditCmd)
+}
@@ -0,0 +1,76 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"errors"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// readClipboard returns the text copied to the clipboard, using the clipboard tool of the platform
+func readClipboard() (string, error) {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "windows":
+		candidates = [][]string{{"powershell", "-NoProfile", "-Command", "Get-Clipboard"}}
+	case "darwin":
+		candidates = [][]string{{"pbpaste"}}
+	default: // "linux", "freebsd", "openbsd", "netbsd"
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			candidates = append(candidates, []string{"wl-paste", "--no-newline"})
+		}
+		candidates = append(candidates, []string{"xclip", "-selection", "clipboard", "-o"}, []string{"xsel", "--clipboard", "--output"})
+	}
+
+	for _, candidate := range candidates {
+		if _, err := exec.LookPath(candidate[0]); err != nil {
+			continue
+		}
+		out, err := exec.Command(candidate[0], candidate[1:]...).Output()
+		if err != nil {
+			return "", errors.New("can't read the clipboard with " + candidate[0] + ": " + err.Error())
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		return "", errors.New("can't find " + candidates[0][0] + " to read the clipboard")
+	}
+	return "", errors.New("can't read the clipboard, install wl-clipboard, xclip or xsel")
+}
+
+// clipboardSource reads the source of a module from the first line copied to the clipboard and asks to install it,
+// ok is false when the user declined
+func clipboardSource() (source string, local bool, ok bool, err error) {
+	text, err := readClipboard()
+	if err != nil {
+		return "", false, false, err
+	}
+	line, _, _ := strings.Cut(text, "\n")
+	source, local = module.CleanSource(line)
+	if source == "" {
+		return "", false, false, errors.New("the clipboard is empty")
+	}
+	return source, local, confirm(i18n.T("Install %s from the clipboard?", source), true), nil
+}
@@ -0,0 +1,95 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+var pkgCloneCmd = &cobra.Command{
+	Use:   "clone id [dir]",
+	Short: "Fetch the source of an installed module to work on it",
+	Long:  "clones the repository of the module at the installed commit (into a folder named after the module by default), then offers to link it with `bespoke dev link`",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Without a version, the enabled one is cloned
+		identifier, ok := module.ParseStoreIdentifier(args[0])
+		if !ok {
+			identifier, ok = module.ParseStoreIdentifier(args[0] + "/")
+		}
+		if !ok {
+			log.Fatalln(i18n.T("Invalid module %s", args[0]))
+		}
+		dir := string(identifier.Name)
+		if len(args) > 1 {
+			dir = args[1]
+		}
+
+		moduleDir, err := module.CloneModule(identifier, dir)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		log.Println(i18n.T("Cloned %s into %s", identifier.ModuleIdentifier.String(), moduleDir))
+
+		if confirm(i18n.T("Link %s for development?", moduleDir), true) {
+			if err := devLink(moduleDir); err != nil {
+				log.Fatalln(err.Error())
+			}
+		}
+	},
+}
+
+var devLinkCmd = &cobra.Command{
+	Use:   "link [dir]",
+	Short: "Install and enable a module from a working copy",
+	Long:  "the module is linked as version " + string(module.DevVersion) + ", so that changes to its files show up after reloading Spotify",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		moduleDir := "."
+		if len(args) > 0 {
+			moduleDir = args[0]
+		}
+		if err := devLink(moduleDir); err != nil {
+			log.Fatalln(err.Error())
+		}
+	},
+}
+
+func devLink(moduleDir string) error {
+	identifier, err := module.LinkModuleDev(moduleDir)
+	if err != nil {
+		return err
+	}
+	if err := enableModule(identifier); err != nil {
+		return err
+	}
+	refreshMixins()
+	log.Println(i18n.T("Linked %s to %s", identifier.String(), moduleDir))
+	return nil
+}
+
+func init() {
+	pkgCmd.AddCommand(pkgCloneCmd)
+	devCmd.AddCommand(devLinkCmd)
+}
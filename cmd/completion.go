@@ -0,0 +1,169 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/module"
+	"bespoke/paths"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// completionRefreshMarker throttles the background refreshes of the registry indexes started by completions
+const completionRefreshMarker = "completion-refresh"
+
+// completeRegistryModules suggests the modules of the registries, as [<registry>:]<author>/<name>[@<version>], from
+// the cached indexes only: completions never wait for the network. Indexes older than completion.max-age are refreshed
+// in the background for the next completion
+func completeRegistryModules(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	// Completions skip most of initConfig, see isLightCommand
+	initRegistries()
+	viper.SetDefault("completion.max-age", 24*time.Hour)
+
+	results, fetched := module.CachedRegistryModules()
+	if time.Since(fetched) > viper.GetDuration("completion.max-age") {
+		refreshRegistriesInBackground()
+	}
+
+	registry, ref, qualified := strings.Cut(toComplete, ":")
+	if !qualified {
+		ref = toComplete
+	}
+	identifier, _, versioned := strings.Cut(ref, "@")
+
+	suggestions := []string{}
+	for _, result := range results {
+		if qualified && result.Registry != registry {
+			continue
+		}
+		prefix := ""
+		if qualified {
+			prefix = registry + ":"
+		}
+		if versioned {
+			if string(result.Identifier) != identifier {
+				continue
+			}
+			versions := make([]module.Version, 0, len(result.Versions))
+			for v := range result.Versions {
+				versions = append(versions, v)
+			}
+			slices.Sort(versions)
+			for _, v := range versions {
+				suggestions = append(suggestions, prefix+identifier+"@"+string(v))
+			}
+			continue
+		}
+		if !strings.HasPrefix(string(result.Identifier), identifier) {
+			continue
+		}
+		suggestion := prefix + string(result.Identifier)
+		if result.Description != "" {
+			suggestion += "\t" + result.Description
+		}
+		if !slices.Contains(suggestions, suggestion) {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	return suggestions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// refreshRegistriesInBackground fetches the registry indexes with a detached `bespoke pkg search`, at most once a minute
+func refreshRegistriesInBackground() {
+	marker := filepath.Join(paths.CachePath, completionRefreshMarker)
+	if info, err := os.Stat(marker); err == nil && time.Since(info.ModTime()) < time.Minute {
+		return
+	}
+	if err := os.MkdirAll(paths.CachePath, os.ModePerm); err != nil {
+		return
+	}
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		return
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return
+	}
+	args := []string{"pkg", "search", "", "--config", cfgFile}
+	if workspace != "" {
+		args = append(args, "--workspace", workspace)
+	}
+	refresh := exec.Command(executable, args...)
+	if refresh.Start() == nil {
+		refresh.Process.Release()
+	}
+}
+
+// completeInstalledModules suggests the installed modules, and their versions once the module is typed
+func completeInstalledModules(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	vault, err := module.GetVault()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	suggestions := []string{}
+	for _, identifier := range vault.OrderedModules() {
+		if slices.Contains(args, string(identifier)) {
+			continue
+		}
+		if strings.HasPrefix(toComplete, string(identifier)+"/") {
+			m := vault.Modules[identifier]
+			for v := range m.V {
+				suggestions = append(suggestions, string(identifier)+"/"+string(v))
+			}
+			continue
+		}
+		if strings.HasPrefix(string(identifier), toComplete) {
+			suggestions = append(suggestions, string(identifier))
+		}
+	}
+	slices.Sort(suggestions)
+	return suggestions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeInstalledModule is completeInstalledModules for commands taking a single module
+func completeInstalledModule(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeInstalledModules(cmd, args, toComplete)
+}
+
+func init() {
+	pkgInstallCmd.ValidArgsFunction = completeRegistryModules
+	pkgShowCmd.ValidArgsFunction = completeRegistryModules
+
+	for _, cmd := range []*cobra.Command{pkgDeleteCmd, pkgEnableCmd, pkgDisableCmd, pkgWhyCmd, pkgReadmeCmd, pkgChangelogCmd, pkgVerifyCmd} {
+		cmd.ValidArgsFunction = completeInstalledModule
+	}
+	for _, cmd := range []*cobra.Command{pkgOutdatedCmd, pkgUpgradeCmd} {
+		cmd.ValidArgsFunction = completeInstalledModules
+	}
+}
@@ -0,0 +1,163 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/ui"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// tokensKey holds a map keyed by host names, which contain the key delimiter
+const tokensKey = "tokens"
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Read and write bespoke settings",
+	Long:  "settings are stored in the config file and can be overridden with BESPOKE_<KEY> environment variables (e.g. BESPOKE_DAEMON_PORT)",
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get key",
+	Short: "Print the effective value of a setting",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if !viper.IsSet(args[0]) {
+			log.Fatalln(i18n.T("Unknown setting %s", args[0]))
+		}
+		fmt.Println(viper.Get(args[0]))
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set key value",
+	Short: "Persist a setting to the config file",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := saveConfig(args[0], parseConfigValue(args[1])); err != nil {
+			log.Fatalln(err.Error())
+		}
+	},
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the effective value of every setting",
+	Run: func(cmd *cobra.Command, args []string) {
+		keys := viper.AllKeys()
+		slices.Sort(keys)
+
+		settings := make(map[string]any, len(keys))
+		for _, key := range keys {
+			value := viper.Get(key)
+			if strings.HasPrefix(key, tokensKey+".") {
+				value = "********"
+			} else if d, ok := value.(time.Duration); ok {
+				value = d.String()
+			}
+			settings[key] = value
+		}
+
+		if outputFormat == "json" {
+			printJSON(settings)
+			return
+		}
+		table := ui.NewTable("KEY", "VALUE")
+		for _, key := range keys {
+			table.Row(ui.Cyan(key), fmt.Sprint(settings[key]))
+		}
+		table.Render(os.Stdout)
+	},
+}
+
+// saveConfig sets a key for the current run and writes it to the config file, leaving the other
+// persisted settings untouched (defaults and flags are not baked into the file)
+func saveConfig(key string, value any) error {
+	// Token hosts contain dots, so the file is edited with a delimiter that can't appear in keys
+	file := viper.NewWithOptions(viper.KeyDelimiter("::"))
+	file.SetConfigFile(cfgFile)
+	if err := file.ReadInConfig(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	if host, ok := strings.CutPrefix(key, tokensKey+"."); ok {
+		file.Set(tokensKey+"::"+host, value)
+		tokens := viper.GetStringMapString(tokensKey)
+		tokens[host] = fmt.Sprint(value)
+		viper.Set(tokensKey, tokens)
+	} else {
+		file.Set(strings.ReplaceAll(key, ".", "::"), value)
+		viper.Set(key, value)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfgFile), os.ModePerm); err != nil {
+		return err
+	}
+	return file.WriteConfigAs(cfgFile)
+}
+
+func parseConfigValue(value string) any {
+	if i, err := strconv.Atoi(value); err == nil {
+		return i
+	}
+	switch value {
+	case "true", "on":
+		return true
+	case "false", "off":
+		return false
+	}
+	return value
+}
+
+// getSwitch reads a boolean setting, also accepting the on/off spelling in environment variables
+func getSwitch(key string) bool {
+	switch strings.ToLower(viper.GetString(key)) {
+	case "true", "on", "yes", "1":
+		return true
+	}
+	return false
+}
+
+func printJSON(v any) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatalln(err.Error())
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+
+	configCmd.AddCommand(configGetCmd, configSetCmd, configListCmd)
+}
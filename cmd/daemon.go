@@ -20,10 +20,28 @@
 package cmd
 
 import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/notify"
 	"bespoke/paths"
+	"bespoke/service"
+	"bespoke/ui"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/cobra"
@@ -41,8 +59,8 @@ var daemonCmd = &cobra.Command{
 	Short: "Run daemon",
 	Run: func(cmd *cobra.Command, args []string) {
 		if daemon {
-			log.Println("Starting daemon")
-			startDaemon()
+			log.Println(i18n.T("Starting daemon"))
+			startDaemon(cmd.Context())
 		}
 	},
 }
@@ -51,8 +69,8 @@ var daemonStartCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Start daemon",
 	Run: func(cmd *cobra.Command, args []string) {
-		log.Println("Starting daemon")
-		startDaemon()
+		log.Println(i18n.T("Starting daemon"))
+		startDaemon(cmd.Context())
 	},
 }
 
@@ -61,12 +79,13 @@ var daemonEnableCmd = &cobra.Command{
 	Short: "Enable daemon",
 	Run: func(cmd *cobra.Command, args []string) {
 		if daemon {
-			log.Panicln("Daemon already enabled")
+			log.Panicln(i18n.T("Daemon already enabled"))
 		}
-		log.Println("Enabling daemon")
+		log.Println(i18n.T("Enabling daemon"))
 		daemon = true
-		viper.Set("daemon", daemon)
-		viper.WriteConfig()
+		if err := saveConfig("daemon", daemon); err != nil {
+			log.Fatalln(err.Error())
+		}
 	},
 }
 
@@ -74,13 +93,128 @@ var daemonDisableCmd = &cobra.Command{
 	Use:   "disable",
 	Short: "Disable daemon",
 	Run: func(cmd *cobra.Command, args []string) {
-		log.Println("Disabling daemon")
+		log.Println(i18n.T("Disabling daemon"))
 		daemon = false
-		viper.Set("daemon", daemon)
-		viper.WriteConfig()
+		if err := saveConfig("daemon", daemon); err != nil {
+			log.Fatalln(err.Error())
+		}
 	},
 }
 
+var daemonInstallServiceCmd = &cobra.Command{
+	Use:   "install-service",
+	Short: "Start the daemon with the user session and restart it when it fails",
+	Run: func(cmd *cobra.Command, args []string) {
+		exe, err := os.Executable()
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		arguments := []string{"daemon", "start"}
+		if paths.Workspace != paths.DefaultWorkspace {
+			arguments = append(arguments, "--workspace", paths.Workspace)
+		}
+		if rootCmd.PersistentFlags().Changed("config") {
+			arguments = append(arguments, "--config", cfgFile)
+		}
+
+		if err := service.Install(service.Service{Executable: exe, Args: arguments, LogPath: daemonLogPath()}); err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		// The daemon stops on its own when disabled
+		daemon = true
+		if err := saveConfig("daemon", daemon); err != nil {
+			log.Println(err.Error())
+		}
+		log.Println(i18n.T("Installed the daemon service, logging to %s", daemonLogPath()))
+	},
+}
+
+var daemonUninstallServiceCmd = &cobra.Command{
+	Use:   "uninstall-service",
+	Short: "Stop starting the daemon with the user session",
+	Run: func(cmd *cobra.Command, args []string) {
+		if !service.Installed() {
+			log.Println(i18n.T("The daemon service isn't installed"))
+			return
+		}
+		if err := service.Remove(); err != nil {
+			log.Fatalln(err.Error())
+		}
+		log.Println(i18n.T("Removed the daemon service"))
+	},
+}
+
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Check whether the daemon is installed as a service and answering",
+	Run: func(cmd *cobra.Command, args []string) {
+		report := struct {
+			Enabled bool   `json:"enabled"`
+			Service bool   `json:"service"`
+			Healthy bool   `json:"healthy"`
+			Error   string `json:"error,omitempty"`
+			Port    int    `json:"port"`
+			LogPath string `json:"logPath"`
+		}{
+			Enabled: daemon,
+			Service: service.Installed(),
+			Port:    viper.GetInt("daemon-port"),
+			LogPath: daemonLogPath(),
+		}
+		if err := pingDaemon(report.Port); err != nil {
+			report.Error = err.Error()
+		} else {
+			report.Healthy = true
+		}
+
+		if outputFormat == "json" {
+			printJSON(report)
+			return
+		}
+
+		health := ui.Green("healthy")
+		if !report.Healthy {
+			health = ui.Red("unreachable") + ui.Dim(" ("+report.Error+")")
+		}
+		installed := ui.Dim("not installed")
+		if report.Service {
+			installed = ui.Green("installed")
+		}
+		enabled := ui.Green("enabled")
+		if !report.Enabled {
+			enabled = ui.Dim("disabled")
+		}
+
+		table := ui.NewTable("", "")
+		table.Row(ui.Bold("daemon"), enabled)
+		table.Row(ui.Bold("service"), installed)
+		table.Row(ui.Bold("health"), health)
+		table.Row(ui.Bold("port"), strconv.Itoa(report.Port))
+		table.Row(ui.Bold("log"), report.LogPath)
+		table.Render(os.Stdout)
+	},
+}
+
+func daemonLogPath() string {
+	return filepath.Join(paths.LogPath, "daemon.log")
+}
+
+// pingDaemon asks the daemon listening on port for the module statuses
+func pingDaemon(port int) error {
+	client := http.Client{Timeout: 2 * time.Second}
+	res, err := client.Get("http://localhost:" + strconv.Itoa(port) + "/modules/status")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("daemon answered %s", res.Status)
+	}
+	return nil
+}
+
 func init() {
 	cobra.OnInitialize(func() {
 		viper.SetDefault("daemon", true)
@@ -89,14 +223,18 @@ func init() {
 
 	rootCmd.AddCommand(daemonCmd)
 
-	daemonCmd.AddCommand(daemonStartCmd, daemonEnableCmd, daemonDisableCmd)
+	daemonCmd.AddCommand(daemonStartCmd, daemonEnableCmd, daemonDisableCmd, daemonInstallServiceCmd, daemonUninstallServiceCmd, daemonStatusCmd)
 
 	viper.SetDefault("daemon", false)
 }
 
-func startDaemon() {
+func startDaemon(ctx context.Context) {
+	// Read by the goroutine stopping the daemon once it is disabled
+	var enabled atomic.Bool
+	enabled.Store(daemon)
 	viper.OnConfigChange(func(in fsnotify.Event) {
 		daemon = viper.GetBool("daemon")
+		enabled.Store(daemon)
 	})
 	go viper.WatchConfig()
 
@@ -106,34 +244,32 @@ func startDaemon() {
 	}
 	defer watcher.Close()
 
-	c := make(chan struct{})
-
 	go func() {
 		for {
 			select {
 			case event, ok := <-watcher.Events:
 				if !ok {
-					continue
+					return
 				}
 				log.Println("event:", event)
 				if event.Has(fsnotify.Create) {
 					if strings.HasSuffix(event.Name, "xpui.spa") {
 						if err := execApply(); err != nil {
 							log.Println(err.Error())
+							notify.Notify("bespoke", "Couldn't re-patch Spotify after it updated: "+err.Error())
+						} else {
+							notify.Notify("bespoke", "Spotify was re-patched")
 						}
 					}
 				}
 			case err, ok := <-watcher.Errors:
 				if !ok {
-					continue
+					return
 				}
 				log.Println("error:", err)
-			default:
-				if !daemon {
-					close(c)
-				}
+			case <-ctx.Done():
+				return
 			}
-
 		}
 	}()
 
@@ -141,20 +277,91 @@ func startDaemon() {
 	if err != nil {
 		log.Fatalln(err)
 	}
+	go watchVault(ctx)
 
 	http.HandleFunc("/rpc", handleWebSocketProtocol)
-	log.Panicln(http.ListenAndServe("localhost:7967", nil))
+	http.HandleFunc("/modules", handleModules)
+	http.HandleFunc("/modules/status", handleModuleStatuses)
+	http.HandleFunc("/modules/manifest.json", handleManifest)
+	http.HandleFunc("/modules/actions", handleModuleActions)
+	http.HandleFunc("/metrics", handleMetrics)
+	addr := "localhost:" + strconv.Itoa(viper.GetInt("daemon-port"))
+	server := &http.Server{Addr: addr}
+	disabled := whenDisabled(ctx, time.Second, enabled.Load)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-disabled:
+		}
+		server.Close()
+	}()
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Panicln(err)
+	}
+	log.Println(i18n.T("Daemon stopped"))
+}
 
-	<-c
+// whenDisabled returns a channel closed once the daemon is disabled in the config, enabled is checked every interval
+func whenDisabled(ctx context.Context, interval time.Duration, enabled func() bool) <-chan struct{} {
+	disabled := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if !enabled() {
+					close(disabled)
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return disabled
+}
+
+// trustedOrigins are the web origins allowed to send protocol requests to the daemon, the Spotify client by default
+var trustedOrigins = []string{"https://xpui.app.spotify.com"}
+
+// trustedOrigin tells whether a request comes from a trusted origin, requests without an origin don't come from a browser
+func trustedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return origin == "" || slices.ContainsFunc(trustedOrigins, func(trusted string) bool { return strings.EqualFold(trusted, origin) })
 }
 
 var upgrader = websocket.Upgrader{
 	CheckOrigin: func(r *http.Request) bool {
-		// TODO: improve security
-		return true
+		if trustedOrigin(r) {
+			return true
+		}
+		log.Println(i18n.T("Rejected protocol connection from %s", r.Header.Get("Origin")))
+		return false
 	},
 }
 
+// rpcClients are the Spotify clients connected to /rpc, rpcMutex serializes the writes to them
+var (
+	rpcClients = map[*websocket.Conn]bool{}
+	rpcMutex   sync.Mutex
+)
+
+// broadcastRPC sends a message to every connected Spotify client, returning how many were reached
+func broadcastRPC(message string) int {
+	rpcMutex.Lock()
+	defer rpcMutex.Unlock()
+	sent := 0
+	for c := range rpcClients {
+		if err := c.WriteMessage(websocket.TextMessage, []byte(message)); err != nil {
+			log.Println("!write:", err)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
 func handleWebSocketProtocol(w http.ResponseWriter, r *http.Request) {
 	c, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -163,6 +370,15 @@ func handleWebSocketProtocol(w http.ResponseWriter, r *http.Request) {
 	}
 	defer c.Close()
 
+	rpcMutex.Lock()
+	rpcClients[c] = true
+	rpcMutex.Unlock()
+	defer func() {
+		rpcMutex.Lock()
+		delete(rpcClients, c)
+		rpcMutex.Unlock()
+	}()
+
 	for {
 		_, p, err := c.ReadMessage()
 		if err != nil {
@@ -172,14 +388,80 @@ func handleWebSocketProtocol(w http.ResponseWriter, r *http.Request) {
 
 		incoming := string(p)
 		log.Println("recv:", incoming)
-		res, err := HandleProtocol(incoming)
+		var res string
+		err = module.RunAs("rpc "+incoming, func() error {
+			res, err = HandleProtocol(incoming)
+			return err
+		})
 		if err != nil {
 			log.Println("!handle:", err)
 		}
+		rpcMutex.Lock()
 		c.WriteMessage(websocket.TextMessage, []byte(res))
+		rpcMutex.Unlock()
 	}
 }
 
+func handleModules(w http.ResponseWriter, r *http.Request) {
+	state, err := currentState()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	enabled := state.Enabled
+	broken := map[string]string{}
+	for _, status := range state.Statuses() {
+		if status.State == module.StateBroken || status.State == module.StateUpdating {
+			broken[status.Module] = string(status.State)
+		}
+	}
+
+	// Broken modules aren't served, the client would fail to load them
+	identifiers := make([]string, 0, len(enabled))
+	for _, identifier := range enabled {
+		if state, ok := broken[identifier.String()]; ok {
+			log.Println(i18n.T("Not serving %s: %s", identifier.String(), state))
+			continue
+		}
+		identifiers = append(identifiers, identifier.String())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(identifiers)
+}
+
+func handleModuleStatuses(w http.ResponseWriter, r *http.Request) {
+	state, err := currentState()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	statuses, err := json.Marshal(state.Statuses())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The states of the stores change without the vault changing, they are part of the tag
+	sum := sha256.Sum256(statuses)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", strconv.Quote(state.Revision+"-"+hex.EncodeToString(sum[:4])))
+	w.Write(append(statuses, '\n'))
+}
+
+func handleManifest(w http.ResponseWriter, r *http.Request) {
+	state, err := currentState()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", strconv.Quote(state.Revision))
+	json.NewEncoder(w).Encode(state.Manifest)
+}
+
 /*
 func startDaemon() {
 	viper.OnConfigChange(func(in fsnotify.Event) {
@@ -0,0 +1,51 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWhenDisabled(t *testing.T) {
+	var enabled atomic.Bool
+	enabled.Store(true)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := whenDisabled(ctx, time.Millisecond, enabled.Load)
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case <-stopped:
+		t.Fatal("the daemon was reported disabled while enabled")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	enabled.Store(false)
+	disabled := whenDisabled(context.Background(), time.Millisecond, enabled.Load)
+	select {
+	case <-disabled:
+	case <-time.After(time.Second):
+		t.Fatal("disabling the daemon wasn't noticed")
+	}
+	// Later checks must not close the channel again
+	time.Sleep(10 * time.Millisecond)
+}
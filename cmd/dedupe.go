@@ -0,0 +1,279 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/ui"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	e "bespoke/errors"
+
+	"github.com/spf13/cobra"
+)
+
+var pkgDedupeCmd = &cobra.Command{
+	Use:     "dedupe-metadata",
+	Aliases: []string{"dedupe"},
+	Short:   "Share a single version of each module other modules depend on",
+	Long:    "picks, for every module installed modules depend on, the version satisfying the ranges declared by all of them, installs and enables it, records the shared range in the vault and removes the other versions installed as dependencies\n\nmodules no version satisfies are reported with the competing ranges, unless settled with --resolution or interactively",
+	Args:    cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		plan, upgrades, conflicts, err := planDependencies()
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		if outputFormat == "json" {
+			printJSON(struct {
+				Upgrades  []module.Upgrade          `json:"upgrades"`
+				Shared    []module.SharedDependency `json:"shared"`
+				Conflicts []string                  `json:"conflicts"`
+			}{upgrades, plan, errorStrings(conflicts)})
+		} else {
+			printDependentUpgrades(upgrades)
+			printSharedDependencies(plan)
+		}
+		for _, conflict := range conflicts {
+			log.Println(conflict.Error())
+		}
+
+		changed := len(upgrades) > 0
+		for _, shared := range plan {
+			changed = changed || shared.Changed
+		}
+		if changed && (dryRun || confirm(i18n.T("Proceed?"), false)) {
+			err = module.Batch(func() error {
+				if err := applyDependentUpgrades(upgrades); err != nil {
+					return err
+				}
+				return module.FlattenDependencies(plan, false)
+			})
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+		}
+		if len(conflicts) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func printDependentUpgrades(upgrades []module.Upgrade) {
+	if len(upgrades) == 0 {
+		return
+	}
+	table := ui.NewTable("MODULE", "FROM", "TO")
+	for _, upgrade := range upgrades {
+		table.Row(ui.Cyan(upgrade.Module.String()), string(upgrade.From), string(upgrade.To))
+	}
+	table.Render(os.Stdout)
+}
+
+func printSharedDependencies(plan []module.SharedDependency) {
+	if len(plan) == 0 {
+		log.Println(i18n.T("No module depends on another"))
+		return
+	}
+	table := ui.NewTable("MODULE", "RANGE", "SHARED", "CHANGES")
+	for _, shared := range plan {
+		changes := []string{}
+		if shared.Source != "" {
+			changes = append(changes, "install from "+shared.Source)
+		}
+		for _, duplicate := range shared.Duplicates {
+			changes = append(changes, "remove "+string(duplicate))
+		}
+		table.Row(ui.Cyan(string(shared.Module)), shared.Constraint, string(shared.Version), strings.Join(changes, ", "))
+	}
+	table.Render(os.Stdout)
+}
+
+func errorStrings(errs []error) []string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return messages
+}
+
+// installSharedDependencies installs and enables a single version of the dependencies of the installed modules,
+// leaving the duplicates to `bespoke pkg dedupe-metadata`
+func installSharedDependencies() error {
+	plan, upgrades, conflicts, err := planDependencies()
+	if err != nil {
+		return err
+	}
+	for _, conflict := range conflicts {
+		log.Println(conflict.Error())
+	}
+	if err := applyDependentUpgrades(upgrades); err != nil {
+		return err
+	}
+	return module.FlattenDependencies(plan, true)
+}
+
+// resolution settles dependency conflicts without asking, set by --resolution
+var resolution string
+
+// planDependencies plans the shared dependencies, settling the conflicts with --resolution or by asking the user.
+// Conflicts are left unsettled when nobody is there to answer. Nothing is changed: the upgrades of dependents
+// settling conflicts are planned along, to be applied before the shared dependencies
+func planDependencies() ([]module.SharedDependency, []module.Upgrade, []error, error) {
+	preferred, err := module.ParseResolution(resolution)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Choices are remembered across the plans following upgrades of dependents
+	choices := map[module.ModuleIdentifierStr]string{}
+	upgraded := map[module.ModuleIdentifierStr]bool{}
+	upgrades := []module.Upgrade{}
+	for {
+		plan, errs, err := module.PlanDependencies(upgrades)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		unsettled := []error{}
+		replan := false
+		for _, err := range errs {
+			conflict, ok := err.(*module.DependencyConflict)
+			if !ok {
+				unsettled = append(unsettled, err)
+				continue
+			}
+
+			choice, ok := choices[conflict.Module]
+			if !ok {
+				choice = string(preferred)
+				if choice == "" {
+					choice = askResolution(conflict, upgraded[conflict.Module])
+				}
+				choices[conflict.Module] = choice
+			}
+
+			if choice == resolveUpgrade || choice == string(module.PreferNewest) {
+				if !upgraded[conflict.Module] {
+					upgraded[conflict.Module] = true
+					if planned := planDependentUpgrades(conflict, upgrades); len(planned) > 0 {
+						upgrades = append(upgrades, planned...)
+						replan = true
+						continue
+					}
+				}
+				if choice == resolveUpgrade {
+					log.Println(i18n.T("No newer version of the modules depending on %s", conflict.Module))
+					delete(choices, conflict.Module)
+					replan = true
+					continue
+				}
+			}
+
+			switch choice {
+			case string(module.PreferInstalled), string(module.PreferNewest):
+				shared, err := conflict.Settle(module.Resolution(choice))
+				if err != nil {
+					unsettled = append(unsettled, err)
+					continue
+				}
+				plan = append(plan, shared)
+			case resolveAbort:
+				return nil, nil, nil, e.ErrCancelled
+			default:
+				unsettled = append(unsettled, conflict)
+			}
+		}
+		if !replan {
+			slices.SortFunc(plan, func(a, b module.SharedDependency) int {
+				return strings.Compare(string(a.Module), string(b.Module))
+			})
+			return plan, upgrades, unsettled, nil
+		}
+	}
+}
+
+const (
+	resolveUpgrade = "upgrade-dependents"
+	resolveAbort   = "abort"
+)
+
+// askResolution offers to keep the installed version, upgrade the dependents or abort,
+// it returns an empty choice when there is nothing to read
+func askResolution(conflict *module.DependencyConflict, upgraded bool) string {
+	if outputFormat == "json" {
+		return ""
+	}
+	log.Println(conflict.Error())
+
+	choices := []string{}
+	options := []string{}
+	if installed, err := conflict.Settle(module.PreferInstalled); err == nil {
+		choices = append(choices, string(module.PreferInstalled))
+		options = append(options, i18n.T("Keep the installed version %s", installed.Version))
+	}
+	if !upgraded {
+		choices = append(choices, resolveUpgrade)
+		options = append(options, i18n.T("Upgrade the modules depending on it"))
+	}
+	choices = append(choices, resolveAbort)
+	options = append(options, i18n.T("Abort"))
+
+	i, ok := chooseOne(i18n.T("How should %s be resolved?", conflict.Module), options)
+	if !ok {
+		return ""
+	}
+	return choices[i]
+}
+
+// planDependentUpgrades lists the upgrades of the modules taking part in a conflict that aren't planned yet,
+// in case their newer versions agree
+func planDependentUpgrades(conflict *module.DependencyConflict, planned []module.Upgrade) []module.Upgrade {
+	upgrades, errs := conflict.DependentUpgrades()
+	for _, err := range errs {
+		log.Println(err.Error())
+	}
+	return slices.DeleteFunc(upgrades, func(upgrade module.Upgrade) bool {
+		return slices.ContainsFunc(planned, func(other module.Upgrade) bool { return other.Module == upgrade.Module })
+	})
+}
+
+// applyDependentUpgrades upgrades the dependents planned by planDependencies
+func applyDependentUpgrades(upgrades []module.Upgrade) error {
+	for _, upgrade := range upgrades {
+		if err := module.ApplyUpgrade(upgrade); err != nil {
+			return err
+		}
+		log.Println(i18n.T("Upgraded %s %s -> %s", upgrade.Module, upgrade.From, upgrade.To))
+	}
+	return nil
+}
+
+func init() {
+	pkgCmd.AddCommand(pkgDedupeCmd)
+
+	for _, cmd := range []*cobra.Command{pkgDedupeCmd, pkgInstallCmd} {
+		cmd.Flags().StringVar(&resolution, "resolution", "", "Settle dependency conflicts without asking: prefer-newest upgrades the modules depending on a conflicting module, else picks its newest version, prefer-installed keeps the installed version")
+	}
+}
@@ -20,7 +20,10 @@
 package cmd
 
 import (
+	"bespoke/i18n"
+	"bespoke/module"
 	"bytes"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -34,13 +37,96 @@ var devCmd = &cobra.Command{
 	Short: "Patch Spotify to open in app-developer mode next time it launches",
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := execDev(); err == nil {
-			log.Println("Mode app-developer enabled for next launch")
+			log.Println(i18n.T("Mode app-developer enabled for next launch"))
 		} else {
 			log.Fatalln(err.Error())
 		}
 	},
 }
 
+var (
+	bundleOut     string
+	bundleNoBuild bool
+)
+
+var devBundleCmd = &cobra.Command{
+	Use:   "bundle [dir]",
+	Short: "Package a module for distribution",
+	Long:  "validates metadata.json, runs its build script and packs the entries and assets into a tar.gz with checksums",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		moduleDir := "."
+		if len(args) > 0 {
+			moduleDir = args[0]
+		}
+		artifact, err := module.Bundle(moduleDir, bundleOut, !bundleNoBuild)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		log.Println(i18n.T("Bundled %s", artifact))
+	},
+}
+
+var (
+	publishOptions module.PublishOptions
+	signingKey     string
+	signSources    []string
+	signCommit     string
+)
+
+var devPublishCmd = &cobra.Command{
+	Use:   "publish [dir]",
+	Short: "Release a new version of a module on GitHub",
+	Long:  "bumps the metadata version, tags and pushes it, then creates a GitHub release with the bundle attached",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		moduleDir := "."
+		if len(args) > 0 {
+			moduleDir = args[0]
+		}
+		publishOptions.OutDir = bundleOut
+		publishOptions.Build = !bundleNoBuild
+		metadataURL, err := module.Publish(moduleDir, publishOptions)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		log.Println(i18n.T("Published, install with:"))
+		fmt.Println("bespoke pkg install " + metadataURL)
+	},
+}
+
+var devKeygenCmd = &cobra.Command{
+	Use:   "keygen key-file",
+	Short: "Create a key to sign module metadata",
+	Long:  "writes the private key to key-file and prints the public key, which a registry lists under authors.<author>.publicKey to verify you",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		publicKey, err := module.GenerateSigningKey(args[0])
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		log.Println(i18n.T("Private key written to %s, public key:", args[0]))
+		fmt.Println(publicKey)
+	},
+}
+
+var devSignCmd = &cobra.Command{
+	Use:   "sign [dir]",
+	Short: "Sign the files of a module",
+	Long:  "writes metadata.json.sig, the signature of every file of the module, which must be published next to metadata.json and updated whenever a file changes. --source restricts the installs to the given metadata URLs or sources, --commit to a commit",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		moduleDir := "."
+		if len(args) > 0 {
+			moduleDir = args[0]
+		}
+		if err := module.SignModule(moduleDir, signingKey, signSources, signCommit); err != nil {
+			log.Fatalln(err.Error())
+		}
+		log.Println(i18n.T("Signed %s", filepath.Join(moduleDir, "metadata.json")))
+	},
+}
+
 func execDev() error {
 	offlineBnkPath := filepath.Join(spotifyConfigPath, "offline.bnk")
 
@@ -66,4 +152,18 @@ func execDev() error {
 
 func init() {
 	rootCmd.AddCommand(devCmd)
+
+	devCmd.AddCommand(devBundleCmd, devPublishCmd, devKeygenCmd, devSignCmd)
+
+	for _, c := range []*cobra.Command{devBundleCmd, devPublishCmd} {
+		c.Flags().StringVarP(&bundleOut, "out", "o", "dist", "Output folder")
+		c.Flags().BoolVar(&bundleNoBuild, "no-build", false, "Skip the build script")
+	}
+	devPublishCmd.Flags().StringVar(&publishOptions.Bump, "bump", "patch", "Version part to bump: major, minor or patch")
+	devPublishCmd.Flags().StringVar(&publishOptions.Version, "version", "", "Explicit version to publish (overrides --bump)")
+	devPublishCmd.Flags().StringVar(&publishOptions.SigningKey, "key", "", "Sign the published metadata.json with this key")
+	devSignCmd.Flags().StringVar(&signingKey, "key", "", "Key file created by bespoke dev keygen")
+	devSignCmd.MarkFlagRequired("key")
+	devSignCmd.Flags().StringSliceVar(&signSources, "source", nil, "Metadata URL or source the module is published at (repeatable)")
+	devSignCmd.Flags().StringVar(&signCommit, "commit", "", "Commit the module is published from")
 }
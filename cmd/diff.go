@@ -0,0 +1,122 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/ui"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var diffStat bool
+
+var pkgDiffCmd = &cobra.Command{
+	Use:   "diff id [version]",
+	Short: "Show the changes between an installed module and its source or another installed version",
+	Long:  "without a version, the store copy is compared with the files it was installed from to show local edits",
+	Args:  cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		// Without a version, the enabled one is compared
+		identifier, ok := module.ParseStoreIdentifier(args[0])
+		if !ok {
+			identifier, ok = module.ParseStoreIdentifier(args[0] + "/")
+		}
+		if !ok {
+			log.Fatalln(i18n.T("Invalid module %s", args[0]))
+		}
+		var other module.Version
+		if len(args) > 1 {
+			other = module.Version(args[1])
+		}
+
+		diffs, err := module.DiffModule(identifier, other)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		if outputFormat == "json" {
+			if diffStat {
+				for i := range diffs {
+					diffs[i].Patch = ""
+				}
+			}
+			printJSON(diffs)
+			return
+		}
+		if diffStat {
+			printDiffStat(diffs)
+			return
+		}
+		for _, d := range diffs {
+			printPatch(d)
+		}
+	},
+}
+
+func printPatch(d module.FileDiff) {
+	if d.Binary {
+		fmt.Println(ui.Bold(i18n.T("Binary files differ: %s", d.File)))
+		return
+	}
+	for _, line := range strings.SplitAfter(d.Patch, "\n") {
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "---"), strings.HasPrefix(line, "+++"):
+			fmt.Println(ui.Bold(line))
+		case strings.HasPrefix(line, "@@"):
+			fmt.Println(ui.Cyan(line))
+		case strings.HasPrefix(line, "+"):
+			fmt.Println(ui.Green(line))
+		case strings.HasPrefix(line, "-"):
+			fmt.Println(ui.Red(line))
+		case line != "":
+			fmt.Println(line)
+		}
+	}
+}
+
+func printDiffStat(diffs []module.FileDiff) {
+	added, removed := 0, 0
+	table := ui.NewTable("FILE", "STATUS", "CHANGES")
+	for _, d := range diffs {
+		changes := ui.Green("+"+strconv.Itoa(d.Added)) + " " + ui.Red("-"+strconv.Itoa(d.Removed))
+		if d.Binary {
+			changes = ui.Dim("binary")
+		}
+		table.Row(d.File, string(d.Status), changes)
+		added += d.Added
+		removed += d.Removed
+	}
+	table.Render(os.Stdout)
+	fmt.Println(i18n.T("%d files changed, %d insertions(+), %d deletions(-)", len(diffs), added, removed))
+}
+
+func init() {
+	pkgCmd.AddCommand(pkgDiffCmd)
+
+	pkgDiffCmd.Flags().BoolVar(&diffStat, "stat", false, "Only show the number of changed lines per file")
+}
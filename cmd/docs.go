@@ -0,0 +1,93 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/module"
+	"bespoke/ui"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var changelogSince string
+
+var pkgReadmeCmd = &cobra.Command{
+	Use:   "readme id[/version]",
+	Short: "Show the README of an installed module",
+	Long:  "defaults to the enabled version, the README is read from the store or fetched from the module repository at the installed commit",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		printModuleDoc(args[0], module.DocReadme, "")
+	},
+}
+
+var pkgChangelogCmd = &cobra.Command{
+	Use:   "changelog id[/version]",
+	Short: "Show the CHANGELOG of an installed module",
+	Long:  "defaults to the enabled version, --since hides the entries of that version and older ones",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		printModuleDoc(args[0], module.DocChangelog, changelogSince)
+	},
+}
+
+func printModuleDoc(arg string, name string, since string) {
+	identifier, err := module.ResolveInstalled(arg)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	spinner := ui.Spin("Fetching " + name)
+	doc, err := module.ModuleDoc(identifier, name)
+	spinner.Stop()
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	if since != "" {
+		doc = truncateChangelog(doc, since)
+	}
+
+	if outputFormat == "json" {
+		printJSON(map[string]string{"module": identifier.String(), "file": name, "content": doc})
+		return
+	}
+	fmt.Print(ui.RenderMarkdown(doc))
+}
+
+// truncateChangelog keeps the entries above the first heading that mentions version
+func truncateChangelog(changelog string, version string) string {
+	versionRe := regexp.MustCompile(`(^|[^\w.])v?` + regexp.QuoteMeta(strings.TrimPrefix(version, "v")) + `($|[^\w.])`)
+	lines := strings.Split(changelog, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "#") && versionRe.MatchString(line) {
+			return strings.Join(lines[:i], "\n")
+		}
+	}
+	return changelog
+}
+
+func init() {
+	pkgCmd.AddCommand(pkgReadmeCmd, pkgChangelogCmd)
+
+	pkgChangelogCmd.Flags().StringVar(&changelogSince, "since", "", "Only show the changes made after this version")
+}
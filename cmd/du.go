@@ -0,0 +1,179 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/paths"
+	"bespoke/ui"
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	duSort      string
+	duThreshold string
+)
+
+var pkgDuCmd = &cobra.Command{
+	Use:   "du",
+	Short: "Show the disk usage of installed modules, the cache and the hooks",
+	Run: func(cmd *cobra.Command, args []string) {
+		threshold, err := parseSize(duThreshold)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		spinner := ui.Spin("Measuring disk usage")
+		usages, err := module.StoreDiskUsage()
+		if err != nil {
+			log.Println(err.Error())
+		}
+		others, err := module.DirSizes([]string{paths.CachePath, filepath.Join(paths.ConfigPath, "hooks")})
+		spinner.Stop()
+		if err != nil {
+			log.Println(err.Error())
+		}
+		switch duSort {
+		case "size":
+			slices.SortStableFunc(usages, func(a, b module.DiskUsage) int { return cmp.Compare(b.Size, a.Size) })
+		case "name":
+			slices.SortStableFunc(usages, func(a, b module.DiskUsage) int { return strings.Compare(a.Identifier.String(), b.Identifier.String()) })
+		default:
+			log.Fatalln(i18n.T("Unknown sort order %s", duSort))
+		}
+
+		var storeTotal int64
+		for _, usage := range usages {
+			storeTotal += usage.Size
+		}
+
+		if outputFormat == "json" {
+			modules := []map[string]any{}
+			for _, usage := range usages {
+				modules = append(modules, map[string]any{"identifier": usage.Identifier.String(), "size": usage.Size, "compressed": usage.Compressed})
+			}
+			printJSON(map[string]any{"modules": modules, "store": storeTotal, "cache": others[0], "hooks": others[1]})
+			return
+		}
+
+		vault, _ := module.GetVault()
+		table := ui.NewTable("MODULE", "VERSION", "SIZE", "STATUS")
+		for _, usage := range usages {
+			size := formatSize(usage.Size)
+			if threshold > 0 && usage.Size >= threshold {
+				size = ui.Red(size)
+			}
+			status := ui.Dim("disabled")
+			if vault.Modules[module.ModuleIdentifierStr(usage.Identifier.ModuleIdentifier.String())].Enabled == usage.Identifier.Version {
+				status = ui.Green("enabled")
+			} else if usage.Compressed {
+				status = ui.Dim("compressed")
+			}
+			table.Row(usage.Identifier.ModuleIdentifier.String(), string(usage.Identifier.Version), size, status)
+		}
+		table.Render(os.Stdout)
+
+		fmt.Println()
+		totals := ui.NewTable("TOTAL", "SIZE")
+		totals.Row("store", formatSize(storeTotal))
+		totals.Row("cache", formatSize(others[0]))
+		totals.Row("hooks", formatSize(others[1]))
+		totals.Render(os.Stdout)
+	},
+}
+
+var pkgCompactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "Compress the versions of installed modules that aren't enabled",
+	Long:  "disabled versions are kept compressed in the store unless store.compress is off, compact compresses those left extracted (e.g. from before the setting was turned on)\n\nenabling a version extracts it again",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		spinner := ui.Spin("Compressing disabled versions")
+		report, err := module.CompactStore()
+		spinner.Stop()
+
+		if outputFormat == "json" {
+			printJSON(report)
+		} else if len(report.Compressed) == 0 {
+			log.Println(i18n.T("No version to compress"))
+		} else if report.Saved > 0 {
+			log.Println(i18n.T("Compressed %d versions, saving %s", len(report.Compressed), formatSize(report.Saved)))
+		} else {
+			log.Println(i18n.T("Compressed %d versions", len(report.Compressed)))
+		}
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+	},
+}
+
+var sizeUnits = []string{"B", "KiB", "MiB", "GiB", "TiB"}
+
+func formatSize(size int64) string {
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(sizeUnits)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return strconv.FormatInt(size, 10) + " B"
+	}
+	return strconv.FormatFloat(value, 'f', 1, 64) + " " + sizeUnits[unit]
+}
+
+// parseSize reads sizes such as 512, 200K, 10MB or 1GiB (in powers of 1024)
+func parseSize(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	number := strings.TrimRight(strings.ToUpper(s), "IB")
+	if number == "" {
+		return 0, errors.New("invalid size " + s)
+	}
+	multiplier := int64(1)
+	if unit := strings.IndexByte("KMGT", number[len(number)-1]); unit >= 0 {
+		multiplier <<= 10 * (unit + 1)
+		number = number[:len(number)-1]
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || value < 0 {
+		return 0, errors.New("invalid size " + s)
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+func init() {
+	pkgCmd.AddCommand(pkgDuCmd, pkgCompactCmd)
+
+	pkgDuCmd.Flags().StringVar(&duSort, "sort", "size", "Sort modules by size or name")
+	pkgDuCmd.Flags().StringVar(&duThreshold, "threshold", "", "Highlight versions larger than this size (e.g. 10MB)")
+}
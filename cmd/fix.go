@@ -20,6 +20,7 @@
 package cmd
 
 import (
+	"bespoke/i18n"
 	"bespoke/paths"
 	"log"
 	"os"
@@ -33,7 +34,7 @@ var fixCmd = &cobra.Command{
 	Use:   "fix",
 	Short: "Fix your spotify installation",
 	Run: func(cmd *cobra.Command, args []string) {
-		log.Println("Restoring Spotify to stock state")
+		log.Println(i18n.T("Restoring Spotify to stock state"))
 		execFix()
 	},
 }
@@ -48,7 +49,7 @@ func execFix() {
 			log.Fatalln(err.Error())
 		}
 		if len(spaBaks) == 0 {
-			log.Println("Spotify is already in stock state!")
+			log.Println(i18n.T("Spotify is already in stock state!"))
 			return
 		}
 
@@ -0,0 +1,133 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var installFile string
+
+var pkgFreezeCmd = &cobra.Command{
+	Use:   "freeze",
+	Short: "Print the enabled modules pinned to their exact versions and commits",
+	Long:  "the output can be shared and reinstalled with `bespoke pkg install -f <file>`",
+	Run: func(cmd *cobra.Command, args []string) {
+		frozen, errs := module.Freeze()
+		for _, err := range errs {
+			log.Println(err.Error())
+		}
+		if outputFormat == "json" {
+			printJSON(frozen)
+			return
+		}
+		fmt.Println("# Install with `bespoke pkg install -f <file>`")
+		for _, m := range frozen {
+			fmt.Println(m.Module, m.Source)
+		}
+	},
+}
+
+// installFrozen installs and enables the modules of a freeze file, in order.
+// Each line is "<author>/<name>/<version> <source>", versions that are already installed are only enabled
+func installFrozen(file string) error {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	frozen := []module.FrozenModule{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return fmt.Errorf("%s:%d: expected \"<author>/<name>/<version> <source>\"", file, line)
+		}
+		if _, ok := module.ParseStoreIdentifier(fields[0]); !ok {
+			return fmt.Errorf("%s:%d: invalid module %s", file, line, fields[0])
+		}
+		frozen = append(frozen, module.FrozenModule{Module: fields[0], Source: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return installFrozenModules(frozen)
+}
+
+// installFrozenModules installs and enables frozen modules in order
+func installFrozenModules(frozen []module.FrozenModule) error {
+	for _, m := range frozen {
+		identifier, ok := module.ParseStoreIdentifier(m.Module)
+		if !ok {
+			return errors.New("invalid module " + m.Module)
+		}
+
+		installed, err := isInstalled(identifier)
+		if err != nil {
+			return err
+		}
+		if !installed {
+			log.Println(i18n.T("Installing %s from %s", identifier.String(), m.Source))
+			if err := module.InstallModuleMURL(m.Source); err != nil {
+				return errors.New(identifier.String() + ": " + err.Error())
+			}
+		}
+		if err := enableModule(identifier); err != nil {
+			return errors.New(identifier.String() + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+func isInstalled(identifier module.StoreIdentifier) (bool, error) {
+	vault, err := module.GetVault()
+	if err != nil {
+		return false, err
+	}
+	m, ok := vault.Modules[module.ModuleIdentifierStr(identifier.ModuleIdentifier.String())]
+	if !ok {
+		return false, nil
+	}
+	_, ok = m.V[identifier.Version]
+	return ok, nil
+}
+
+func init() {
+	pkgCmd.AddCommand(pkgFreezeCmd)
+}
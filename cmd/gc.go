@@ -0,0 +1,99 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+var gcOrphans bool
+
+var pkgGcCmd = &cobra.Command{
+	Use:   "gc --orphans",
+	Short: "Remove the modules that are no longer needed",
+	Long:  "--orphans removes the versions installed as dependencies that no explicitly installed module depends on anymore",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if !gcOrphans {
+			log.Fatalln(i18n.T("Nothing to collect, use --orphans to remove the modules no longer needed as dependencies"))
+		}
+
+		orphans, err := module.Orphans()
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if len(orphans) == 0 {
+			log.Println(i18n.T("No orphaned module"))
+			return
+		}
+		if !confirmMatches("uninstalled", orphans) {
+			return
+		}
+
+		err = module.Batch(func() error {
+			for _, identifier := range orphans {
+				if err := module.DeleteModule(identifier); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+	},
+}
+
+var pkgMarkCmd = &cobra.Command{
+	Use:       "mark id[/version] explicit|dependency",
+	Short:     "Record whether a module was installed explicitly or as a dependency",
+	Long:      "versions marked as dependencies are removed by `bespoke pkg gc --orphans` once no explicitly installed module depends on them",
+	Args:      cobra.ExactArgs(2),
+	ValidArgs: []string{"explicit", "dependency"},
+	Run: func(cmd *cobra.Command, args []string) {
+		var explicit bool
+		switch args[1] {
+		case "explicit":
+			explicit = true
+		case "dependency":
+			explicit = false
+		default:
+			log.Fatalln(i18n.T("Unknown mark %s, expected explicit or dependency", args[1]))
+		}
+
+		identifier, err := module.ResolveInstalled(args[0])
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if err := module.MarkModule(identifier, explicit); err != nil {
+			log.Fatalln(err.Error())
+		}
+	},
+}
+
+func init() {
+	pkgCmd.AddCommand(pkgGcCmd, pkgMarkCmd)
+
+	pkgGcCmd.Flags().BoolVar(&gcOrphans, "orphans", false, "Remove the modules only installed as dependencies of modules that were removed since")
+}
@@ -0,0 +1,84 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var lang string
+
+// usageHeadings are the English headings of cobra's usage template
+var usageHeadings = []string{
+	"Usage:",
+	"Aliases:",
+	"Examples:",
+	"Available Commands:",
+	"Additional Commands:",
+	"Global Flags:",
+	"Flags:",
+	"Additional help topics:",
+	`Use "{{.CommandPath}} [command] --help" for more information about a command.`,
+}
+
+// initLang picks the language from --lang, then the lang setting or BESPOKE_LANG, then the locale of the system
+func initLang(setting string) {
+	if lang == "" {
+		lang = setting
+	}
+	i18n.Use(lang)
+	localizeCommands(rootCmd)
+
+	replacements := []string{}
+	for _, heading := range usageHeadings {
+		replacements = append(replacements, heading, i18n.T(heading))
+	}
+	rootCmd.SetUsageTemplate(strings.NewReplacer(replacements...).Replace(rootCmd.UsageTemplate()))
+}
+
+// localizeCommands translates the descriptions and flag usages of a command and its subcommands
+func localizeCommands(cmd *cobra.Command) {
+	cmd.Short = i18n.T(cmd.Short)
+	cmd.Long = i18n.T(cmd.Long)
+	localizeFlag := func(flag *pflag.Flag) {
+		flag.Usage = i18n.T(flag.Usage)
+	}
+	cmd.Flags().VisitAll(localizeFlag)
+	cmd.PersistentFlags().VisitAll(localizeFlag)
+	for _, sub := range cmd.Commands() {
+		localizeCommands(sub)
+	}
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&lang, "lang", "", "Language of the messages, e.g. fr (defaults to LC_ALL, LC_MESSAGES or LANG)")
+
+	// Help is printed before the config is read, so only --lang, BESPOKE_LANG and the locale apply to it
+	help := rootCmd.HelpFunc()
+	rootCmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		initLang(os.Getenv("BESPOKE_LANG"))
+		help(cmd, args)
+	})
+}
@@ -20,13 +20,12 @@
 package cmd
 
 import (
+	"bespoke/i18n"
 	"bespoke/module"
 	"bespoke/uri"
 	"log"
-	"runtime"
 
 	"github.com/spf13/cobra"
-	"golang.org/x/sys/windows/registry"
 )
 
 var initCmd = &cobra.Command{
@@ -35,7 +34,7 @@ var initCmd = &cobra.Command{
 	Long:  "required to be ran at least once per installation",
 	Run: func(cmd *cobra.Command, args []string) {
 		if err := execInit(); err != nil {
-			log.Println("Error occurred! Try running this command (and only this command) in an elevated shell; error:")
+			log.Println(i18n.T("Error occurred! Try running this command (and only this command) in an elevated shell; error:"))
 			log.Panicln(err.Error())
 		}
 	},
@@ -45,24 +44,8 @@ func init() {
 	rootCmd.AddCommand(initCmd)
 }
 
-func enableDeveloperModeOnWindows() error {
-	if runtime.GOOS != "windows" {
-		return nil
-	}
-
-	access := uint32(registry.QUERY_VALUE | registry.SET_VALUE)
-	key := registry.LOCAL_MACHINE
-
-	key, err := registry.OpenKey(key, `Software\Microsoft\Windows\CurrentVersion\AppModelUnlock`, access)
-	if err != nil {
-		return err
-	}
-
-	return key.SetDWordValue("AllowDevelopmentWithoutDevLicense", 1)
-}
-
 func execInit() error {
-	if err := enableDeveloperModeOnWindows(); err != nil {
+	if err := enableDeveloperMode(); err != nil {
 		return err
 	}
 
@@ -70,6 +53,10 @@ func execInit() error {
 		log.Println(err.Error())
 	}
 
+	return initVault()
+}
+
+func initVault() error {
 	return module.SetVault(&module.Vault{Modules: map[module.ModuleIdentifierStr]module.Module{
 		"official/stdlib": module.Module{
 			Remotes: []string{"https://github.com/spicetify/stdlib/repo.json"},
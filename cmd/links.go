@@ -0,0 +1,130 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/ui"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var linksCmd = &cobra.Command{
+	Use:   "links",
+	Short: "List the links of the modules folder and flag the dangling or foreign ones",
+	Long:  "every entry of the modules folder should link the enabled version of its module in the store: dangling links lead nowhere, foreign ones outside the store, mismatched ones to another version and stale ones belong to a module that isn't enabled\n\nexits with 1 when a link needs fixing",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		statuses, err := module.InspectLinks()
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		broken := 0
+		for _, status := range statuses {
+			if status.Broken() {
+				broken++
+			}
+		}
+
+		if outputFormat == "json" {
+			printJSON(statuses)
+		} else if len(statuses) == 0 {
+			log.Println(i18n.T("No module is enabled"))
+		} else {
+			table := ui.NewTable("MODULE", "STATE", "TARGET")
+			for _, status := range statuses {
+				target := status.Target
+				switch status.State {
+				case module.LinkMismatched:
+					target += ui.Dim(" (expected " + status.Expected + ")")
+				case module.LinkMissing:
+					target = ui.Dim("expected " + status.Expected)
+				}
+				table.Row(ui.Cyan(status.Module), linkStateColor(status.State), target)
+			}
+			table.Render(os.Stdout)
+			if broken > 0 {
+				fmt.Println()
+				log.Println(i18n.T("%d links need fixing, run `bespoke links repair` to recreate them from the vault or `bespoke links prune` to only remove the stale ones", broken))
+			}
+		}
+		if broken > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+var linksRepairCmd = &cobra.Command{
+	Use:   "repair",
+	Short: "Recreate the links of the modules folder from the vault",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		changes, err := module.RepairLinks()
+		for _, change := range changes {
+			fmt.Println(change)
+		}
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if len(changes) == 0 {
+			log.Println(i18n.T("Links are consistent, nothing to repair"))
+		}
+	},
+}
+
+var linksPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove the dangling links and those that don't belong to an enabled module",
+	Long:  "folders put in the modules folder by hand are left alone",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		pruned, err := module.PruneLinks()
+		for _, status := range pruned {
+			fmt.Println("-", status.Module, ui.Dim("("+string(status.State)+")"))
+		}
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if len(pruned) == 0 {
+			log.Println(i18n.T("No stale link"))
+		}
+	},
+}
+
+func linkStateColor(state module.LinkState) string {
+	switch state {
+	case module.LinkOk:
+		return ui.Green(string(state))
+	case module.LinkStale:
+		return ui.Yellow(string(state))
+	}
+	return ui.Red(string(state))
+}
+
+func init() {
+	rootCmd.AddCommand(linksCmd)
+
+	linksCmd.AddCommand(linksRepairCmd, linksPruneCmd)
+}
@@ -0,0 +1,61 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/module"
+	"bespoke/ui"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var logLimit int
+
+var pkgLogCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Show the history of vault changes",
+	Run: func(cmd *cobra.Command, args []string) {
+		entries, err := module.ReadJournal()
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if logLimit > 0 && len(entries) > logLimit {
+			entries = entries[len(entries)-logLimit:]
+		}
+		if outputFormat == "json" {
+			printJSON(entries)
+			return
+		}
+		table := ui.NewTable("TIME", "OPERATION", "MODULE", "COMMAND")
+		for _, entry := range entries {
+			table.Row(entry.Time.Format(time.DateTime), ui.Cyan(string(entry.Operation)), entry.Identifier, ui.Dim(entry.Command))
+		}
+		table.Render(os.Stdout)
+	},
+}
+
+func init() {
+	pkgCmd.AddCommand(pkgLogCmd)
+
+	pkgLogCmd.Flags().IntVarP(&logLimit, "limit", "n", 0, "Only show the last n changes")
+}
@@ -0,0 +1,84 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"encoding/json"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	manifestFormat string
+	manifestFile   string
+)
+
+var manifestCmd = &cobra.Command{
+	Use:   "manifest",
+	Short: "Print the loader manifest of the enabled modules",
+}
+
+var manifestEmitCmd = &cobra.Command{
+	Use:   "emit [--format json|js] [--file path]",
+	Short: "Print the loader manifest as JSON, or as a script the client can load without the daemon",
+	Long:  "js renders a self-contained bespoke.modules.js with entries relative to the modules folder, which bespoke also keeps up to date in the modules folder for clients running without the daemon",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest, err := module.GetLoaderManifest()
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		var content []byte
+		switch manifestFormat {
+		case "json":
+			content, err = json.MarshalIndent(manifest, "", "  ")
+			content = append(content, '\n')
+		case "js":
+			content, err = module.LoaderScript(manifest)
+		default:
+			log.Fatalln(i18n.T("Unknown manifest format %s, expected json or js", manifestFormat))
+		}
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		if manifestFile == "" {
+			os.Stdout.Write(content)
+			return
+		}
+		if err := os.WriteFile(manifestFile, content, 0644); err != nil {
+			log.Fatalln(err.Error())
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(manifestCmd)
+
+	manifestCmd.AddCommand(manifestEmitCmd)
+
+	manifestEmitCmd.Flags().StringVar(&manifestFormat, "format", "json", "Format of the manifest: json or js")
+	manifestEmitCmd.Flags().StringVar(&manifestFile, "file", "", "Write the manifest to this file instead of printing it")
+}
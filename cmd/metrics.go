@@ -0,0 +1,141 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/network"
+	"bespoke/paths"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// outdatedCheck caches the number of outdated modules, which takes a request per module to count
+var outdatedCheck struct {
+	sync.Mutex
+	count   int
+	checked time.Time
+	running bool
+}
+
+// refreshOutdated counts the outdated modules in the background when the last count is older than metrics.outdated-interval
+func refreshOutdated() {
+	outdatedCheck.Lock()
+	defer outdatedCheck.Unlock()
+	if outdatedCheck.running || time.Since(outdatedCheck.checked) < viper.GetDuration("metrics.outdated-interval") {
+		return
+	}
+	outdatedCheck.running = true
+
+	go func() {
+		count, err := countOutdated()
+		outdatedCheck.Lock()
+		defer outdatedCheck.Unlock()
+		outdatedCheck.running = false
+		if err != nil {
+			log.Println(i18n.T("Can't check for newer versions: %s", err.Error()))
+			return
+		}
+		outdatedCheck.count = count
+		outdatedCheck.checked = time.Now()
+	}()
+}
+
+func countOutdated() (int, error) {
+	statuses, err := module.ModuleStatuses()
+	if err != nil {
+		return 0, err
+	}
+	if err := module.CheckRemote(statuses); err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, status := range statuses {
+		if status.State == module.StateEnabled && status.Outdated() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func writeMetric(w io.Writer, name string, kind string, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %s\n", name, help, name, kind, name, strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+// handleMetrics serves the statistics of the vault in the Prometheus text format
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	statuses, err := module.ModuleStatuses()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	counts := map[module.ModuleState]int{}
+	for _, status := range statuses {
+		counts[status.State]++
+	}
+
+	journal, err := module.ReadJournal()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	lastUpdate := time.Time{}
+	for _, entry := range journal {
+		if slices.Contains([]module.Operation{module.OpInstall, module.OpUpgrade, module.OpRename}, entry.Operation) && entry.Time.After(lastUpdate) {
+			lastUpdate = entry.Time
+		}
+	}
+
+	refreshOutdated()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprintf(w, "# HELP bespoke_info Version of bespoke and workspace the metrics are about.\n# TYPE bespoke_info gauge\n")
+	fmt.Fprintf(w, "bespoke_info{version=%s,workspace=%s} 1\n", quoteLabel(cliVersion()), quoteLabel(paths.Workspace))
+	writeMetric(w, "bespoke_modules_installed", "gauge", "Installed module versions.", float64(len(statuses)))
+	writeMetric(w, "bespoke_modules_enabled", "gauge", "Enabled modules.", float64(counts[module.StateEnabled]))
+	writeMetric(w, "bespoke_modules_broken", "gauge", "Installed module versions that can't be loaded.", float64(counts[module.StateBroken]))
+
+	outdatedCheck.Lock()
+	if !outdatedCheck.checked.IsZero() {
+		writeMetric(w, "bespoke_modules_outdated", "gauge", "Enabled modules with a newer version available.", float64(outdatedCheck.count))
+		writeMetric(w, "bespoke_outdated_check_timestamp_seconds", "gauge", "When the outdated modules were last counted.", float64(outdatedCheck.checked.Unix()))
+	}
+	outdatedCheck.Unlock()
+
+	if !lastUpdate.IsZero() {
+		writeMetric(w, "bespoke_last_update_timestamp_seconds", "gauge", "When a module was last installed or upgraded.", float64(lastUpdate.Unix()))
+	}
+	writeMetric(w, "bespoke_download_errors_total", "counter", "Requests that failed or got an error status since the daemon started.", float64(network.Failures.Load()))
+}
+
+// quoteLabel escapes a label value of the Prometheus text format
+func quoteLabel(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
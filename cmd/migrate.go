@@ -0,0 +1,102 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/paths"
+	"bespoke/ui"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	spicetifyDir      string
+	spicetifyMappings string
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Import modules from other Spotify customization tools",
+}
+
+var migrateSpicetifyCmd = &cobra.Command{
+	Use:   "spicetify",
+	Short: "Install the modules equivalent to the extensions, themes and custom apps of a spicetify install",
+	Long:  "items are matched with the mapping index set by --mappings or the spicetify-mappings setting, enabled items stay enabled",
+	Run: func(cmd *cobra.Command, args []string) {
+		source := viper.GetString("spicetify-mappings")
+		if cmd.Flags().Changed("mappings") {
+			source = spicetifyMappings
+		}
+		if source == "" {
+			log.Fatalln(i18n.T("No mapping index, pass --mappings or run `bespoke config set spicetify-mappings <url|file>`"))
+		}
+
+		items, err := module.ScanSpicetify(spicetifyDir)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		mappings, err := module.FetchSpicetifyMappings(source)
+		if err != nil {
+			log.Fatalln(i18n.T("Can't read the mapping index: %s", err))
+		}
+
+		results := module.MigrateSpicetify(items, mappings)
+		if outputFormat == "json" {
+			printJSON(results)
+			return
+		}
+
+		table := ui.NewTable("KIND", "NAME", "MODULE", "STATUS")
+		remaining := 0
+		for _, result := range results {
+			status := result.Status
+			switch result.Status {
+			case module.MigrationMigrated, module.MigrationInstalled:
+				status = ui.Green(status)
+			default:
+				status = ui.Red(status)
+				remaining++
+			}
+			if result.Reason != "" {
+				status += " (" + result.Reason + ")"
+			}
+			table.Row(result.Kind, ui.Cyan(result.Name), result.Module, status)
+		}
+		table.Render(os.Stdout)
+		if remaining > 0 {
+			fmt.Println(i18n.T("%d item(s) couldn't be migrated", remaining))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.AddCommand(migrateSpicetifyCmd)
+
+	migrateSpicetifyCmd.Flags().StringVar(&spicetifyDir, "spicetify-dir", paths.GetSpicetifyConfigPath(), "spicetify config folder")
+	migrateSpicetifyCmd.Flags().StringVar(&spicetifyMappings, "mappings", "", "URL or path of the mapping index")
+}
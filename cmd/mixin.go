@@ -0,0 +1,140 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/archive"
+	"bespoke/i18n"
+	"bespoke/mixin"
+	"bespoke/module"
+	"bespoke/paths"
+	"bespoke/trace"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Mixins are applied to a fresh copy of the stock client in the build folder, then the chunks they rewrite
+// are copied to the client loaded by Spotify. The chunks rewritten by the previous build are copied too,
+// which restores them when their mixins are gone
+
+func mixinChunksPath() string {
+	return filepath.Join(paths.StatePath, "mixins.json")
+}
+
+func readMixinChunks() []string {
+	chunks := []string{}
+	if raw, err := os.ReadFile(mixinChunksPath()); err == nil {
+		json.Unmarshal(raw, &chunks)
+	}
+	return chunks
+}
+
+func writeMixinChunks(chunks []string) error {
+	raw, err := json.Marshal(chunks)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(paths.StatePath, os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(mixinChunksPath(), raw, 0644)
+}
+
+// stockSpa returns the unpatched client, which is kept as a backup once Spotify is patched in place
+func stockSpa() string {
+	src, _ := getApps()
+	spa := filepath.Join(src, "xpui.spa")
+	if _, err := os.Stat(spa + ".bak"); !mirror && err == nil {
+		return spa + ".bak"
+	}
+	return spa
+}
+
+func applyMixins(destXpuiPath string) error {
+	mixins, err := module.EnabledMixins()
+	if err != nil {
+		return err
+	}
+	previous := readMixinChunks()
+	if len(mixins) == 0 && len(previous) == 0 {
+		return nil
+	}
+
+	build := filepath.Join(paths.CachePath, "build", "xpui")
+	log.Println(i18n.T("Building the client with %d mixins in %s", len(mixins), build))
+	if dryRun {
+		return nil
+	}
+	if err := os.RemoveAll(build); err != nil {
+		return err
+	}
+	if err := archive.UnZip(stockSpa(), build); err != nil {
+		return err
+	}
+
+	result, err := mixin.Apply(build, mixins)
+	if err != nil {
+		return err
+	}
+	for module, reason := range result.Failed {
+		log.Println(i18n.T("Couldn't apply the mixin of %s: %s", module, reason))
+	}
+	for _, conflict := range result.Conflicts {
+		log.Println(i18n.T("Conflicting mixins: %s all patch %s", strings.Join(conflict.Modules, ", "), conflict.Chunk))
+	}
+
+	chunks := append(previous, result.Chunks...)
+	slices.Sort(chunks)
+	chunks = slices.Compact(chunks)
+	for _, chunk := range chunks {
+		raw, err := os.ReadFile(filepath.Join(build, filepath.FromSlash(chunk)))
+		if err != nil {
+			// The chunk was removed by a Spotify update
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(destXpuiPath, filepath.FromSlash(chunk)), raw, 0644); err != nil {
+			return err
+		}
+	}
+	if err := writeMixinChunks(result.Chunks); err != nil {
+		return err
+	}
+
+	if slices.Contains(chunks, "index.html") {
+		return patchIndexHtml(destXpuiPath)
+	}
+	return nil
+}
+
+// refreshMixins rebuilds the patched client after the enabled modules changed
+func refreshMixins() {
+	defer trace.Start("apply mixins", "").Finish()
+	if !isApplied() {
+		return
+	}
+	_, dest := getApps()
+	if err := applyMixins(filepath.Join(dest, "xpui")); err != nil {
+		log.Println(i18n.T("Couldn't apply the mixins: %s", err.Error()))
+	}
+}
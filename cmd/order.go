@@ -0,0 +1,86 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/ui"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	orderBefore string
+	orderAfter  string
+)
+
+var pkgOrderCmd = &cobra.Command{
+	Use:   "order id --before|--after id",
+	Short: "Change the load order of a module",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if (orderBefore == "") == (orderAfter == "") {
+			log.Fatalln(i18n.T("Exactly one of --before or --after is required"))
+		}
+
+		identifier := module.NewModuleIdentifier(args[0])
+		anchor, after := orderBefore, false
+		if orderAfter != "" {
+			anchor, after = orderAfter, true
+		}
+
+		if err := module.MoveModule(identifier, module.NewModuleIdentifier(anchor), after); err != nil {
+			log.Fatalln(err.Error())
+		}
+	},
+}
+
+var pkgOrderListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List modules in load order",
+	Run: func(cmd *cobra.Command, args []string) {
+		order, vault, err := module.GetModuleOrder()
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		table := ui.NewTable("PRIORITY", "MODULE", "ENABLED")
+		for _, identifier := range order {
+			enabled := ui.Green(string(vault.Modules[identifier].Enabled))
+			if vault.Modules[identifier].Enabled == "" {
+				enabled = ui.Dim("disabled")
+			}
+			table.Row(strconv.Itoa(vault.Modules[identifier].Priority), string(identifier), enabled)
+		}
+		table.Render(os.Stdout)
+	},
+}
+
+func init() {
+	pkgCmd.AddCommand(pkgOrderCmd)
+
+	pkgOrderCmd.AddCommand(pkgOrderListCmd)
+
+	pkgOrderCmd.Flags().StringVar(&orderBefore, "before", "", "Load the module before this one")
+	pkgOrderCmd.Flags().StringVar(&orderAfter, "after", "", "Load the module after this one")
+}
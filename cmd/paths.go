@@ -20,8 +20,11 @@
 package cmd
 
 import (
+	"bespoke/i18n"
 	"bespoke/paths"
+	"bespoke/ui"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
 )
@@ -41,21 +44,57 @@ var pathsCmd = &cobra.Command{
 			showSpotifyConfig = true
 			showConfig = true
 		}
-		fmt.Println("mirror:", mirror)
+		fmt.Println(i18n.T("workspace:"), paths.Workspace)
+		fmt.Println(i18n.T("mirror:"), mirror)
+		fmt.Println(i18n.T("sandbox:"), sandbox)
 		if showSpotiyData {
-			fmt.Println("Spotify data:", spotifyDataPath)
+			fmt.Println(i18n.T("Spotify data:"), spotifyDataPath)
 		}
 		if showSpotifyConfig {
-			fmt.Println("Spotify config:", spotifyConfigPath)
+			fmt.Println(i18n.T("Spotify config:"), spotifyConfigPath)
 		}
 		if showConfig {
-			fmt.Println("config file:", paths.ConfigPath)
+			fmt.Println(i18n.T("config file:"), paths.ConfigPath)
 		}
 	},
 }
 
+// pathNames lists what `bespoke path` can print, in display order
+var pathNames = []string{"config", "cache", "state", "log", "spotify-data", "spotify-config"}
+
+var pathCmd = &cobra.Command{
+	Use:       "path [config|cache|state|log|spotify-data|spotify-config]",
+	Short:     "Print the folders used by bespoke",
+	Long:      "the bespoke folders can be relocated with BESPOKE_CONFIG, BESPOKE_CACHE, BESPOKE_STATE and BESPOKE_LOG, or all at once along with Spotify's folders with BESPOKE_SANDBOX, and are nested per workspace",
+	ValidArgs: pathNames,
+	Args:      cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
+	Run: func(cmd *cobra.Command, args []string) {
+		folders := map[string]string{
+			"config":         paths.ConfigPath,
+			"cache":          paths.CachePath,
+			"state":          paths.StatePath,
+			"log":            paths.LogPath,
+			"spotify-data":   spotifyDataPath,
+			"spotify-config": spotifyConfigPath,
+		}
+		if len(args) == 1 {
+			fmt.Println(folders[args[0]])
+			return
+		}
+		if outputFormat == "json" {
+			printJSON(folders)
+			return
+		}
+		table := ui.NewTable()
+		for _, name := range pathNames {
+			table.Row(ui.Bold(name), folders[name])
+		}
+		table.Render(os.Stdout)
+	},
+}
+
 func init() {
-	rootCmd.AddCommand(pathsCmd)
+	rootCmd.AddCommand(pathsCmd, pathCmd)
 
 	pathsCmd.Flags().BoolVar(&showSpotiyData, "spotify-data", false, "Show Spotify data path")
 	pathsCmd.Flags().BoolVar(&showSpotiyData, "spotify-config", false, "Show Spotify config path")
@@ -20,13 +20,32 @@
 package cmd
 
 import (
+	"bespoke/i18n"
 	"bespoke/module"
+	"bespoke/network"
+	"bespoke/ui"
+	"errors"
+	"fmt"
+	"io"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
-var useLocalPath bool
+var (
+	useLocalPath   bool
+	allowUntrusted bool
+	allowHTTP      bool
+	verifyAll      bool
+	listRemote     bool
+	listFilter     string
+	enableVersion  string
+	installSelect  []string
+	sourceArchive  string
+	fromClipboard  bool
+)
 
 var pkgCmd = &cobra.Command{
 	Use:   "pkg action",
@@ -35,53 +54,517 @@ var pkgCmd = &cobra.Command{
 }
 
 var pkgInstallCmd = &cobra.Command{
-	Use:   "install murl",
+	Use:   "install murl|[registry:]id[@version]|git+url#ref=..&path=..|gh-release://owner/repo[@tag][#asset]|-",
 	Short: "Install module",
-	Args:  cobra.ExactArgs(1),
+	Long:  "use -f to install and enable every module listed by `bespoke pkg freeze` in a file (- for stdin)\n\nwhen given the modules.json index of a repository hosting several modules, installs those picked with --select (or interactively) from a single download\n\nfor air-gapped installs, pass the metadata (- for stdin) and the archive of the module with --source-archive\n\npasted sources are cleaned up: surrounding quotes and trailing punctuation are dropped, links to files on github.com are turned into their raw.githubusercontent.com URL and dropped file:// URLs are installed as local paths",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if installFile != "" || fromClipboard {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
-		metadataURL := args[0]
+		module.AllowUntrusted = allowUntrusted
+		module.AllowHTTP = allowHTTP
+
+		if installFile != "" {
+			err := module.Batch(func() error {
+				if err := installFrozen(installFile); err != nil {
+					return err
+				}
+				return installSharedDependencies()
+			})
+			if err != nil {
+				log.Fatalln(describeOffline(err))
+			}
+			return
+		}
+
+		var metadataURL string
+		if fromClipboard {
+			source, local, ok, err := clipboardSource()
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+			if !ok {
+				return
+			}
+			metadataURL, useLocalPath = source, useLocalPath || local
+		} else if metadataURL = args[0]; metadataURL != "-" {
+			source, local := module.CleanSource(metadataURL)
+			if source != metadataURL {
+				log.Println(i18n.T("Installing %s", source))
+			}
+			metadataURL, useLocalPath = source, useLocalPath || local
+		}
 
 		var err error
-		if useLocalPath {
+		if sourceArchive != "" || metadataURL == "-" {
+			err = installFromArchive(metadataURL, sourceArchive)
+		} else if useLocalPath {
 			err = module.InstallModuleLocal(metadataURL)
+		} else if module.IsModulesIndex(metadataURL) {
+			err = installIndexed(metadataURL, installSelect)
 		} else {
-			err = module.InstallModuleRemote(metadataURL)
+			err = module.InstallModuleMURL(metadataURL)
+		}
+		if err == nil {
+			err = installSharedDependencies()
 		}
 
 		if err != nil {
-			log.Fatalln(err.Error())
+			log.Fatalln(describeOffline(err))
 		}
 	},
 }
 
+// describeOffline points to what still works when a command failed for lack of network
+func describeOffline(err error) string {
+	if network.IsOffline() {
+		return err.Error() + "\n" + i18n.T("Offline, only modules with cached metadata or local paths (--local, --source-archive) can be installed")
+	}
+	return err.Error()
+}
+
+// installFromArchive installs a module from a metadata file (- for stdin) and an archive of its files
+func installFromArchive(metadataPath string, archivePath string) error {
+	if archivePath == "" {
+		return errors.New("pass the archive of the module with --source-archive")
+	}
+	var metadata []byte
+	var err error
+	if metadataPath == "-" {
+		metadata, err = io.ReadAll(os.Stdin)
+	} else {
+		metadata, err = os.ReadFile(metadataPath)
+	}
+	if err != nil {
+		return err
+	}
+	return module.InstallModuleArchive(metadata, archivePath)
+}
+
+// installIndexed installs modules from a modules.json index, asking which ones when none were selected
+func installIndexed(indexURL string, names []string) error {
+	if len(names) == 0 {
+		index, err := module.FetchModulesIndex(indexURL)
+		if err != nil {
+			return err
+		}
+		options := make([]string, len(index.Modules))
+		details := make([]string, len(index.Modules))
+		for i, m := range index.Modules {
+			options[i] = m.Name
+			details[i] = m.Description
+		}
+		var ok bool
+		names, ok = chooseMany(i18n.T("Modules to install"), options, details)
+		if !ok {
+			return errors.New("no module was selected, use --select name1,name2")
+		}
+	}
+	return module.InstallIndexedModules(indexURL, names)
+}
+
 var pkgDeleteCmd = &cobra.Command{
-	Use:   "delete id",
-	Short: "Uninstall module",
-	Args:  cobra.ExactArgs(1),
+	Use:     "delete id|pattern",
+	Aliases: []string{"rem"},
+	Short:   "Uninstall module",
+	Args:    cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		identifier := module.NewStoreIdentifier(args[0])
-		if err := module.DeleteModule(identifier); err != nil {
-			log.Fatalln(err.Error())
+		identifiers := []module.StoreIdentifier{}
+		if module.IsPattern(args[0]) {
+			matches, err := module.MatchStores(args[0])
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+			if !confirmMatches("uninstalled", matches) {
+				return
+			}
+			identifiers = matches
+		} else {
+			identifier, ok := module.ParseStoreIdentifier(args[0])
+			if !ok || identifier.Version == "" {
+				log.Fatalln(i18n.T("expected <author>/<name>/<version> or a pattern, got %s", args[0]))
+			}
+			question := i18n.T("Uninstall %s?", identifier.String())
+			dependents := dependentsOf(identifier)
+			if len(dependents) > 0 {
+				question = i18n.T("%s is needed by %s, uninstall it anyway?", identifier.String(), strings.Join(dependents, ", "))
+			}
+			if !dryRun && !confirmDestructive(question, len(dependents) == 0) {
+				return
+			}
+			identifiers = append(identifiers, identifier)
+		}
+
+		for _, identifier := range identifiers {
+			if err := module.DeleteModule(identifier); err != nil {
+				log.Fatalln(err.Error())
+			}
 		}
 	},
 }
 
 var pkgEnableCmd = &cobra.Command{
-	Use:   "enable id",
+	Use:   "enable id|pattern",
 	Short: "Enable installed module",
+	Long:  "the version of id can be an installed version, latest, previous (the one below the enabled version) or a range such as ^2",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		identifiers := []module.StoreIdentifier{}
+		if module.IsPattern(args[0]) {
+			matches, err := module.MatchStores(args[0])
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+			seen := map[module.ModuleIdentifier]bool{}
+			for _, match := range matches {
+				if seen[match.ModuleIdentifier] {
+					log.Fatalln(i18n.T("Pattern matches several versions of %s", match.ModuleIdentifier))
+				}
+				seen[match.ModuleIdentifier] = true
+			}
+			if !confirmMatches("enabled", matches) {
+				return
+			}
+			identifiers = matches
+		} else {
+			identifier, err := resolveEnableTarget(args[0])
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+			identifiers = append(identifiers, identifier)
+		}
+
+		// Modules matching a pattern are switched together
+		err := module.Batch(func() error {
+			for _, identifier := range identifiers {
+				if err := enableModule(identifier); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		refreshMixins()
+	},
+}
+
+// resolveEnableTarget picks the installed version designated by author/name/<version> or by --version
+func resolveEnableTarget(arg string) (module.StoreIdentifier, error) {
+	spec := enableVersion
+	identifier, ok := module.ParseStoreIdentifier(arg)
+	if !ok {
+		if spec == "" {
+			return identifier, errors.New("expected <author>/<name>/<version>, got " + arg)
+		}
+		identifier, ok = module.ParseStoreIdentifier(arg + "/")
+		if !ok {
+			return identifier, errors.New("expected <author>/<name>, got " + arg)
+		}
+	} else if spec == "" {
+		spec = string(identifier.Version)
+	}
+	// An empty version disables the module, as it always did
+	if spec == "" {
+		return identifier, nil
+	}
+
+	version, err := module.ResolveInstalledVersion(identifier.ModuleIdentifier, spec)
+	if err != nil {
+		return identifier, err
+	}
+	if string(version) != spec {
+		log.Println(i18n.T("%s resolved to %s", spec, identifier.ModuleIdentifier.String()+"/"+string(version)))
+	}
+	identifier.Version = version
+	return identifier, nil
+}
+
+func enableModule(identifier module.StoreIdentifier) error {
+	err := module.ToggleModuleInVault(identifier)
+	conflictErr, ok := err.(*module.ConflictError)
+	if !ok {
+		return err
+	}
+
+	log.Println(conflictErr.Error())
+	if !confirm(i18n.T("Disable the conflicting modules and enable %s?", identifier.String()), false) {
+		return err
+	}
+	return module.EnableModuleReplacing(identifier, conflictErr.Conflicts)
+}
+
+var pkgDisableCmd = &cobra.Command{
+	Use:   "disable id|pattern",
+	Short: "Disable module",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		identifiers := []module.ModuleIdentifier{}
+		if module.IsPattern(args[0]) {
+			matches, err := module.MatchModules(args[0])
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+			if !confirmMatches("disabled", matches) {
+				return
+			}
+			identifiers = matches
+		} else {
+			identifier, ok := module.ParseModuleIdentifier(args[0])
+			if !ok {
+				log.Fatalln(i18n.T("expected <author>/<name> or a pattern, got %s", args[0]))
+			}
+			identifiers = append(identifiers, identifier)
+		}
+
+		err := module.Batch(func() error {
+			for _, identifier := range identifiers {
+				if err := module.ToggleModuleInVault(module.StoreIdentifier{ModuleIdentifier: identifier}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		refreshMixins()
+	},
+}
+
+func confirmMatches[T fmt.Stringer](action string, matches []T) bool {
+	if len(matches) == 0 {
+		log.Println(i18n.T("No module matches the pattern"))
+		return false
+	}
+	fmt.Println(i18n.T("The following modules will be %s:", i18n.T(action)))
+	for _, match := range matches {
+		line := "\t" + match.String()
+		if identifier, ok := any(match).(module.StoreIdentifier); ok && action == "uninstalled" {
+			if dependents := dependentsOf(identifier); len(dependents) > 0 {
+				line += " (" + i18n.T("needed by %s", strings.Join(dependents, ", ")) + ")"
+			}
+		}
+		fmt.Println(line)
+	}
+	return dryRun || confirmDestructive(i18n.T("Proceed?"), false)
+}
+
+// dependentsOf lists the installed modules that break when the module of identifier is removed
+func dependentsOf(identifier module.StoreIdentifier) []string {
+	dependents, err := module.Dependents(identifier.ModuleIdentifier)
+	if err != nil {
+		return nil
+	}
+	names := []string{}
+	for _, dependent := range dependents {
+		names = append(names, string(dependent))
+	}
+	return names
+}
+
+var pkgSearchCmd = &cobra.Command{
+	Use:   "search query",
+	Short: "Search modules in the configured registries",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
-		identifier := module.NewStoreIdentifier(args[0])
-		if err := module.ToggleModuleInVault(identifier); err != nil {
+		spinner := ui.Spin("Searching registries")
+		results, err := module.SearchRegistries(args[0])
+		spinner.Stop()
+		if err != nil {
+			log.Println(err.Error())
+		}
+		if outputFormat == "json" {
+			printJSON(results)
+			return
+		}
+		table := ui.NewTable("MODULE", "LATEST", "DESCRIPTION")
+		for _, result := range results {
+			table.Row(ui.Dim(result.Registry+":")+ui.Cyan(string(result.Identifier)), string(result.Latest), result.Description)
+		}
+		table.Render(os.Stdout)
+	},
+}
+
+var pkgVerifyCmd = &cobra.Command{
+	Use:   "verify [id]",
+	Short: "Check installed modules against the file hashes recorded at install time",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var reports []module.VerifyReport
+		if verifyAll || len(args) == 0 {
+			var err error
+			spinner := ui.Spin("Hashing installed modules")
+			reports, err = module.VerifyAll()
+			spinner.Stop()
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+		} else {
+			identifier, err := module.ResolveStoreIdentifier(args[0])
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+			report, err := module.VerifyModule(identifier)
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+			reports = append(reports, report)
+		}
+
+		failed := false
+		for _, report := range reports {
+			if report.Ok() {
+				fmt.Println(ui.Green("ok"), report.Identifier)
+				continue
+			}
+			failed = true
+			fmt.Println(ui.Red("FAIL"), report.Identifier)
+			for _, file := range report.Modified {
+				fmt.Println("\t"+ui.Yellow(i18n.T("modified:")), file)
+			}
+			for _, file := range report.Missing {
+				fmt.Println("\t"+ui.Red(i18n.T("missing:")), file)
+			}
+			for _, file := range report.Extra {
+				fmt.Println("\t"+ui.Dim(i18n.T("extra:")), file)
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+var pkgListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List installed modules",
+	Run: func(cmd *cobra.Command, args []string) {
+		statuses, err := module.ModuleStatuses()
+		if err != nil {
 			log.Fatalln(err.Error())
 		}
+		if listRemote {
+			spinner := ui.Spin("Checking remote sources")
+			err := module.CheckRemote(statuses)
+			spinner.Stop()
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+		}
+		if listFilter != "" {
+			statuses, err = module.FilterStatuses(statuses, listFilter)
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+		}
+
+		if outputFormat == "json" {
+			printJSON(statuses)
+			return
+		}
+		headers := []string{"MODULE", "STATE", "LICENSE", "VERIFIED"}
+		if listRemote {
+			headers = append(headers, "LATEST", "SOURCE")
+		}
+		table := ui.NewTable(headers...)
+		for _, status := range statuses {
+			verified := ""
+			if status.Verified {
+				verified = ui.Cyan("✓ verified")
+			}
+			if !listRemote {
+				table.Row(status.Module, formatState(status), status.License, verified)
+				continue
+			}
+			table.Row(status.Module, formatState(status), status.License, verified, formatLatest(status), formatSource(status))
+		}
+		table.Render(os.Stdout)
+	},
+}
+
+func formatLatest(status module.ModuleStatus) string {
+	switch {
+	case status.LatestError != "":
+		return ui.Red("unknown") + " " + ui.Dim("("+status.LatestError+")")
+	case status.Outdated():
+		return ui.Yellow(string(status.Latest))
+	}
+	return ui.Green(string(status.Latest))
+}
+
+func formatSource(status module.ModuleStatus) string {
+	switch {
+	case status.Source == nil:
+		return ui.Dim("local")
+	case !status.Source.Reachable:
+		return ui.Red("unreachable") + " " + ui.Dim("("+status.Source.Error+")")
+	}
+	return ui.Green("ok")
+}
+
+func formatState(status module.ModuleStatus) string {
+	var state string
+	switch status.State {
+	case module.StateEnabled:
+		state = ui.Green(string(status.State))
+	case module.StateBroken:
+		state = ui.Red(string(status.State)) + " " + ui.Dim("("+status.Reason+")")
+	case module.StateUpdating:
+		state = ui.Yellow(string(status.State))
+	default:
+		state = ui.Dim(string(status.State))
+	}
+	if status.SourceMissing != nil {
+		state += " " + ui.Red("(source missing)")
+	}
+	return state
+}
+
+var pkgLintCmd = &cobra.Command{
+	Use:   "lint [dir]",
+	Short: "Check the metadata.json of a module in strict mode",
+	Long:  "rejects unknown fields, missing required fields and entries that don't exist, the schema is printed by `bespoke schema metadata`",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		moduleDir := "."
+		if len(args) > 0 {
+			moduleDir = args[0]
+		}
+		if err := module.LintModule(moduleDir); err != nil {
+			fmt.Println(ui.Red("FAIL"), err.Error())
+			os.Exit(1)
+		}
+		fmt.Println(ui.Green("ok"), moduleDir)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(pkgCmd)
 
-	pkgCmd.AddCommand(pkgInstallCmd, pkgDeleteCmd, pkgEnableCmd)
+	pkgCmd.AddCommand(pkgInstallCmd, pkgDeleteCmd, pkgEnableCmd, pkgDisableCmd, pkgSearchCmd, pkgVerifyCmd, pkgLintCmd, pkgListCmd)
+
+	pkgCmd.PersistentFlags().BoolVar(&module.AllowScripts, "allow-scripts", false, "Run the postInstall and preRemove scripts of every module")
+	pkgCmd.PersistentFlags().BoolVar(&module.WaitForLocks, "wait", false, "Wait for concurrent operations on the same modules instead of failing")
+
+	pkgListCmd.Flags().BoolVar(&listRemote, "remote", false, "Also show the latest available version and whether the source of each version is reachable")
+	pkgListCmd.Flags().StringVar(&listFilter, "filter", "", "Only list the versions matching an expression over their JSON fields, e.g. 'enabled == true && tags contains \"theme\"'")
+
+	pkgEnableCmd.Flags().StringVar(&enableVersion, "version", "", "Version to enable: an installed version, latest, previous or a range such as ^2")
+
+	pkgVerifyCmd.Flags().BoolVar(&verifyAll, "all", false, "Verify every installed module")
 
 	pkgInstallCmd.Flags().BoolVar(&useLocalPath, "local", false, "Use local path")
+	pkgInstallCmd.Flags().StringVarP(&installFile, "file", "f", "", "Install the modules listed in a file written by pkg freeze, - for stdin")
+	pkgInstallCmd.Flags().BoolVar(&allowUntrusted, "allow-untrusted", false, "Allow installing from untrusted registries")
+	pkgInstallCmd.Flags().BoolVar(&allowHTTP, "allow-http", false, "Allow installing from plain http:// sources")
+	pkgInstallCmd.Flags().BoolVar(&module.InstallAsDependency, "as-dependency", false, "Record the module as a dependency, removed by pkg gc --orphans once no module needs it")
+	pkgInstallCmd.Flags().StringVar(&sourceArchive, "source-archive", "", "Install from this archive of the module's files instead of downloading them, the module isn't checked for upgrades")
+	pkgInstallCmd.Flags().StringSliceVar(&installSelect, "select", nil, "Modules to install from a modules.json index, asked for when omitted")
+	pkgInstallCmd.Flags().BoolVar(&module.StrictMetadata, "strict", false, "Refuse metadata with unknown or missing fields")
+	pkgInstallCmd.Flags().BoolVar(&fromClipboard, "from-clipboard", false, "Install the module whose metadata URL (or id) was copied to the clipboard, after confirmation")
 }
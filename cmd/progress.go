@@ -0,0 +1,71 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/module"
+	"bespoke/ui"
+	"log"
+	"os"
+	"strconv"
+)
+
+// terminalProgress renders the events of the module package on a status line of stderr
+type terminalProgress struct {
+	line *ui.StatusLine
+}
+
+// initProgress shows the progress of installs, upgrades and syncs when stderr is a terminal
+func initProgress() {
+	line := ui.NewStatusLine()
+	if line == nil || outputFormat == "json" {
+		return
+	}
+	log.SetOutput(line.Wrap(os.Stderr))
+	module.Progress = terminalProgress{line}
+}
+
+func (p terminalProgress) Notify(event module.Event) {
+	name := event.Source
+	if event.Module != (module.StoreIdentifier{}) {
+		name = event.Module.String()
+	}
+
+	switch event.Step {
+	case module.StepResolve:
+		p.line.Update("Resolving " + name)
+	case module.StepDownload:
+		// git reports the progress of clones itself
+		if event.Done == 0 {
+			p.line.Clear()
+			return
+		}
+		status := "Downloading " + name + " " + formatSize(event.Done)
+		if event.Total > 0 {
+			status += " / " + formatSize(event.Total)
+		}
+		p.line.Update(status)
+	case module.StepExtract:
+		p.line.Update("Extracting " + name + " (" + strconv.FormatInt(event.Done, 10) + " files)")
+	default:
+		// The other steps are logged by the module package
+		p.line.Clear()
+	}
+}
@@ -0,0 +1,179 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/ui"
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// stdinIsTerminal tells whether the user can answer prompts, tests swap it along with stdin
+var stdinIsTerminal = func() bool { return ui.IsTerminal(os.Stdin) }
+
+// autoConfirm answers yes to every confirmation, set by --yes or the auto-confirm setting
+var autoConfirm bool
+
+// readAnswer reads a line from stdin, ok is false when there is nothing to read
+// (stdin closed or redirected from /dev/null, as for the protocol handler)
+func readAnswer() (answer string, ok bool) {
+	answer, err := stdin.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if err != nil && answer == "" {
+		return "", false
+	}
+	if !ui.IsTerminal(os.Stdin) {
+		// Complete the prompt line with the piped answer
+		fmt.Println(answer)
+	}
+	return answer, true
+}
+
+func prompt(question string, def string) string {
+	if def != "" {
+		fmt.Printf("%s [%s]: ", question, def)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+	answer, ok := readAnswer()
+	if !ok {
+		fmt.Println(def)
+	}
+	if answer == "" {
+		return def
+	}
+	return answer
+}
+
+func yesNo(b bool) string {
+	if b {
+		return i18n.T("yes")
+	}
+	return i18n.T("no")
+}
+
+func confirm(question string, def bool) bool {
+	options := i18n.T("y/N")
+	if def {
+		options = i18n.T("Y/n")
+	}
+	fmt.Printf("%s (%s): ", question, options)
+
+	if autoConfirm {
+		fmt.Println(yesNo(true))
+		return true
+	}
+	answer, ok := readAnswer()
+	if !ok {
+		fmt.Println(yesNo(def))
+		return def
+	}
+	return parseAnswer(answer, def)
+}
+
+// confirmDestructive asks before removing modules or files: def only applies when the user can answer in a
+// terminal, scripts get no for an answer unless they pass --yes or pipe one
+func confirmDestructive(question string, def bool) bool {
+	return confirm(question, def && stdinIsTerminal())
+}
+
+func parseAnswer(answer string, def bool) bool {
+	// English answers are always understood
+	switch strings.ToLower(answer) {
+	case "y", "yes", i18n.T("y"), i18n.T("yes"):
+		return true
+	case "n", "no", i18n.T("n"), i18n.T("no"):
+		return false
+	}
+	return def
+}
+
+// chooseMany lets the user pick options by number or name (comma separated, or all),
+// ok is false when there is nothing to read
+func chooseMany(question string, options []string, details []string) (chosen []string, ok bool) {
+	for i, option := range options {
+		fmt.Printf("%3d) %s", i+1, ui.Bold(option))
+		if details[i] != "" {
+			fmt.Print(" - " + details[i])
+		}
+		fmt.Println()
+	}
+	for {
+		fmt.Printf("%s (%s): ", question, i18n.T("numbers or names, comma separated, or all"))
+		answer, ok := readAnswer()
+		if !ok {
+			fmt.Println()
+			return nil, false
+		}
+		if answer == "all" || answer == i18n.T("all") {
+			return options, true
+		}
+
+		chosen = []string{}
+		invalid := ""
+		for _, field := range strings.Split(answer, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			if i, err := strconv.Atoi(field); err == nil && i >= 1 && i <= len(options) {
+				field = options[i-1]
+			} else if !slices.Contains(options, field) {
+				invalid = field
+				break
+			}
+			if !slices.Contains(chosen, field) {
+				chosen = append(chosen, field)
+			}
+		}
+		if invalid == "" && len(chosen) > 0 {
+			return chosen, true
+		}
+		if invalid != "" {
+			fmt.Println(i18n.T("Unknown choice %s", invalid))
+		}
+	}
+}
+
+// chooseOne lets the user pick a single option by number, ok is false when there is nothing to read
+func chooseOne(question string, options []string) (chosen int, ok bool) {
+	for i, option := range options {
+		fmt.Printf("%3d) %s\n", i+1, option)
+	}
+	for {
+		fmt.Printf("%s (1-%d): ", question, len(options))
+		answer, ok := readAnswer()
+		if !ok {
+			fmt.Println()
+			return 0, false
+		}
+		if i, err := strconv.Atoi(answer); err == nil && i >= 1 && i <= len(options) {
+			return i - 1, true
+		}
+		fmt.Println(i18n.T("Unknown choice %s", answer))
+	}
+}
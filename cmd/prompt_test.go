@@ -0,0 +1,48 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestConfirmDestructive(t *testing.T) {
+	previous, previousIsTerminal := stdin, stdinIsTerminal
+	t.Cleanup(func() { stdin, stdinIsTerminal = previous, previousIsTerminal })
+	stdinIsTerminal = func() bool { return false }
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		// Without a terminal to answer in, the default yes doesn't apply
+		{"", false},
+		{"\n", false},
+		{"y\n", true},
+		{"no\n", false},
+	}
+	for _, tt := range tests {
+		stdin = bufio.NewReader(strings.NewReader(tt.input))
+		if got := confirmDestructive("Uninstall a/one?", true); got != tt.want {
+			t.Errorf("confirmDestructive() with %q piped = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
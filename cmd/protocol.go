@@ -20,11 +20,15 @@
 package cmd
 
 import (
+	"bespoke/i18n"
 	"bespoke/module"
+	"bespoke/notify"
+	"fmt"
 	"log"
 	"os/exec"
 	"regexp"
 	"runtime"
+	"strings"
 
 	e "bespoke/errors"
 
@@ -37,47 +41,144 @@ var protocolCmd = &cobra.Command{
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		res, err := HandleProtocol(args[0])
-		open(res)
+		if res != "" {
+			open(res)
+		}
 		if err != nil {
-			log.Panicln(err.Error())
+			log.Fatalln(err.Error())
 		}
 	},
 }
 
 func HandleProtocol(message string) (string, error) {
-	re := regexp.MustCompile(`bespoke:(?<uuid>[^:]+):(?<action>[^:]+)(:(?<args>.*))?`)
+	// The uuid ends up in the URL opened to answer Spotify, it can't hold anything else
+	re := regexp.MustCompile(`^bespoke:(?<uuid>[A-Za-z0-9-]+):(?<action>[^:]+)(:(?<args>.*))?$`)
 	submatches := re.FindStringSubmatch(message)
+	if submatches == nil {
+		return "", e.ErrUnsupportedOperation
+	}
 	uuid := submatches[1]
 	response := "spotify:app:rpc:bespoke:" + uuid
 	action := submatches[2]
-	arguments := submatches[4]
-	err := hp(action, arguments)
+	arguments, signed, err := module.VerifyProtocolRequest(action, submatches[4])
+	if err == nil {
+		err = hp(action, arguments, signed)
+	}
 	if err == nil {
 		response += ":1"
 	} else {
 		response += ":0"
 	}
+	notifyProtocolResult(action, arguments, err)
 	return response, err
 }
 
-func hp(action, arguments string) error {
+func notifyProtocolResult(action, arguments string, err error) {
+	verbs := map[string]string{
+		"add":    "install",
+		"remove": "remove",
+		"enable": "enable",
+		"sync":   "apply snapshot",
+	}
+	verb, ok := verbs[action]
+	if !ok {
+		verb = action
+	}
+
+	if err != nil {
+		notify.Notify("bespoke", "Failed to "+verb+" "+arguments+": "+err.Error())
+	} else {
+		notify.Notify("bespoke", "Successfully ran "+verb+" "+arguments)
+	}
+}
+
+// hp runs an action requested by a website, signed is set when the marketplace signed the deep link
+func hp(action, arguments string, signed bool) error {
 	switch action {
 	case "add":
-		metadataURL := arguments
-		return module.InstallModuleRemote(metadataURL)
+		metadataURL := module.NormalizeSourceURL(arguments)
+		if err := module.CheckProtocolSource(metadataURL, signed); err != nil {
+			return err
+		}
+		if !confirmProtocol(i18n.T("A website asks to install %s, continue?", metadataURL)) {
+			return e.ErrCancelled
+		}
+		return module.InstallModuleMURL(metadataURL)
 
 	case "remove":
-		identifier := module.NewStoreIdentifier(arguments)
+		identifier, ok := module.ParseStoreIdentifier(arguments)
+		if !ok || identifier.Version == "" {
+			return e.ErrUnsupportedOperation
+		}
+		question := i18n.T("A website asks to uninstall %s, continue?", identifier.String())
+		if dependents := dependentsOf(identifier); len(dependents) > 0 {
+			question = i18n.T("A website asks to uninstall %s, which %s need, continue?", identifier.String(), strings.Join(dependents, ", "))
+		}
+		if !confirmProtocol(question) {
+			return e.ErrCancelled
+		}
 		return module.DeleteModule(identifier)
 
 	case "enable":
-		identifier := module.NewStoreIdentifier(arguments)
+		identifier, ok := module.ParseStoreIdentifier(arguments)
+		if !ok || identifier.Version == "" {
+			return e.ErrUnsupportedOperation
+		}
+		if !confirmProtocol(i18n.T("A website asks to enable %s, continue?", identifier.String())) {
+			return e.ErrCancelled
+		}
 		return module.ToggleModuleInVault(identifier)
 
+	case "sync":
+		// Snapshots are only fetched from trusted registries, which vouch for them like for signed links
+		snapshot, registry, err := module.FetchSnapshot(arguments)
+		if err != nil {
+			return err
+		}
+		plan, err := module.PlanSnapshot(snapshot, registry)
+		if err != nil || plan.Empty() {
+			return err
+		}
+		for _, identifier := range plan.Enable {
+			log.Println(i18n.T("Enable %s", identifier.String()))
+		}
+		for _, identifier := range plan.Disable {
+			log.Println(i18n.T("Disable %s", identifier.String()))
+		}
+		question := i18n.T("A website asks to apply snapshot %s (install %d, enable %d and disable %d modules), continue?", arguments, len(plan.Install), len(plan.Enable), len(plan.Disable))
+		if !confirmProtocol(question) {
+			return e.ErrCancelled
+		}
+		if err := module.ApplySnapshot(plan); err != nil {
+			return err
+		}
+		refreshMixins()
+		return nil
+
 	}
 	return e.ErrUnsupportedOperation
 }
 
+// confirmProtocol asks the user about a request made by a website in the terminal, or in a dialog without one
+// (protocol handlers are started without a terminal). Answers piped to stdin and --yes don't apply, and the answer
+// defaults to no
+func confirmProtocol(question string) bool {
+	if stdinIsTerminal() {
+		fmt.Printf("%s (%s): ", question, i18n.T("y/N"))
+		if answer, ok := readAnswer(); ok {
+			return parseAnswer(answer, false)
+		}
+		fmt.Println()
+	}
+
+	ok, err := notify.Ask("bespoke", question)
+	if err != nil {
+		log.Println(i18n.T("Can't ask for confirmation: %s", err.Error()))
+		return false
+	}
+	return ok
+}
+
 func init() {
 	rootCmd.AddCommand(protocolCmd)
 }
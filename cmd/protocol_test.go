@@ -0,0 +1,50 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	e "bespoke/errors"
+	"bespoke/notify"
+	"errors"
+	"testing"
+)
+
+func TestHandleProtocolMalformed(t *testing.T) {
+	enabled := notify.Enabled
+	t.Cleanup(func() { notify.Enabled = enabled })
+	notify.Enabled = false
+
+	tests := []struct {
+		message  string
+		response string
+	}{
+		{"garbage", ""},
+		{"bespoke:abc&calc:remove:a/b/1.0.0", ""},
+		{"bespoke:abc:remove:foo/bar", "spotify:app:rpc:bespoke:abc:0"},
+		{"bespoke:abc:enable:foo", "spotify:app:rpc:bespoke:abc:0"},
+		{"bespoke:abc:nonsense", "spotify:app:rpc:bespoke:abc:0"},
+	}
+	for _, tt := range tests {
+		response, err := HandleProtocol(tt.message)
+		if !errors.Is(err, e.ErrUnsupportedOperation) || response != tt.response {
+			t.Errorf("HandleProtocol(%q) = %q, %v, want %q, %v", tt.message, response, err, tt.response, e.ErrUnsupportedOperation)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay lets a command finish writing the vault (and the CLI run several commands in a row) before
+// the daemon reloads, so that a burst of changes causes a single reload
+const reloadDelay = 300 * time.Millisecond
+
+// daemonState is the state of the vault the daemon serves, swapped in one step on every reload
+var daemonState atomic.Pointer[module.VaultState]
+
+// currentState returns the state last loaded, loading it if the daemon couldn't yet
+func currentState() (*module.VaultState, error) {
+	if s := daemonState.Load(); s != nil {
+		return s, nil
+	}
+	return reloadState()
+}
+
+// reloadState reads the vault again, tells the connected Spotify clients about it when it changed and keeps
+// serving the previous state when it can't be read
+func reloadState() (*module.VaultState, error) {
+	next, err := module.LoadVaultState()
+	if err != nil {
+		return nil, err
+	}
+	previous := daemonState.Swap(next)
+	if previous != nil && previous.Revision != next.Revision {
+		clients := broadcastRPC("bespoke:reload:" + next.Revision)
+		log.Println(i18n.T("Reloaded the vault, revision %s notified %d clients", next.Revision, clients))
+	}
+	return next, nil
+}
+
+// watchVault reloads the state whenever another bespoke process changes the vault, until ctx is done
+func watchVault(ctx context.Context) {
+	if _, err := reloadState(); err != nil {
+		log.Println(i18n.T("Can't load the vault: %s", err.Error()))
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Println(i18n.T("Can't watch the vault: %s", err.Error()))
+		return
+	}
+	defer watcher.Close()
+	// The modules folder is swapped for a new generation on every change, it is watched again after each reload
+	watch := func() {
+		for _, folder := range module.VaultWatchPaths() {
+			watcher.Remove(folder)
+			if err := watcher.Add(folder); err != nil {
+				log.Println(i18n.T("Can't watch %s: %s", folder, err.Error()))
+			}
+		}
+	}
+	watch()
+
+	reloads := make(chan struct{}, 1)
+	debounce := time.AfterFunc(time.Hour, func() {
+		select {
+		case reloads <- struct{}{}:
+		default:
+		}
+	})
+	debounce.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			debounce.Stop()
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if module.IsVaultChange(event.Name) {
+				debounce.Reset(reloadDelay)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Println("error:", err)
+		case <-reloads:
+			if _, err := reloadState(); err != nil {
+				log.Println(i18n.T("Can't reload the vault, still serving the previous one: %s", err.Error()))
+			}
+			watch()
+		}
+	}
+}
@@ -20,11 +20,26 @@
 package cmd
 
 import (
+	"bespoke/i18n"
+	"context"
 	"fmt"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"syscall"
+	"time"
 
+	"bespoke/archive"
+	"bespoke/link"
+	"bespoke/module"
+	"bespoke/network"
+	"bespoke/notify"
 	"bespoke/paths"
+	"bespoke/trace"
+	"bespoke/ui"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -37,8 +52,30 @@ var (
 	spotifyDataPath   string
 	spotifyConfigPath string
 	cfgFile           string
+	linkMode          string
+	timeout           time.Duration
+	outputFormat      string
+	dryRun            bool
+	workspace         string
+	traceSummary      bool
+	traceOTLP         string
+
+	sandbox paths.Sandbox
 )
 
+// version is set at build time with -ldflags "-X bespoke/cmd.version=<version>"
+var version string
+
+func cliVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "bespoke",
 	Short: "Make Spotify your own",
@@ -47,42 +84,332 @@ var rootCmd = &cobra.Command{
 }
 
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := interruptible()
+	defer stop()
+	network.Context = ctx
+	module.Context = ctx
+
+	err := rootCmd.ExecuteContext(ctx)
+	finishTrace()
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
+// finishTrace prints or exports the spans recorded with --trace and --trace-otlp. Commands exiting on a fatal
+// error don't get there
+func finishTrace() {
+	if !trace.Enabled {
+		return
+	}
+	if traceSummary {
+		trace.Summary(os.Stderr)
+	}
+	if traceOTLP != "" {
+		if err := trace.ExportOTLP(traceOTLP); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.T("Can't export the trace: %s", err))
+		}
+	}
+}
+
+// interruptible returns a context cancelled by the first SIGINT or SIGTERM, so that downloads, git and scripts stop
+// and the partial installs are cleaned up as with any other error. A second signal exits right away
+func interruptible() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-signals; !ok {
+			return
+		}
+		fmt.Fprintln(os.Stderr, i18n.T("Cancelling, interrupt again to exit right away"))
+		cancel()
+		if _, ok := <-signals; ok {
+			os.Exit(130)
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		close(signals)
+		cancel()
+	}
+}
+
 func init() {
 	cobra.OnInitialize(initConfig)
 
+	rootCmd.Version = cliVersion()
+
 	rootCmd.Flags().BoolVar(&autoUpdate, "auto-update", false, "Toggle auto updates for bespoke")
 
 	rootCmd.PersistentFlags().BoolVarP(&mirror, "mirror", "m", false, "Mirror Spotify files instead of patching them directly")
-	rootCmd.PersistentFlags().StringVar(&spotifyDataPath, "spotify-data", paths.GetSpotifyPath(), "Override Spotify data folder (containing the spotify executable)")
-	rootCmd.PersistentFlags().StringVar(&spotifyConfigPath, "spotify-config", paths.GetSpotifyConfigPath(), "Override Spotify config folder (containing prefs & offline.bnk)")
+	// Spotify's folders are detected once the command runs, see resolveSpotifyPaths
+	rootCmd.PersistentFlags().StringVar(&spotifyDataPath, "spotify-data", "", "Override Spotify data folder (containing the spotify executable, detected by default)")
+	rootCmd.PersistentFlags().StringVar(&spotifyConfigPath, "spotify-config", "", "Override Spotify config folder (containing prefs & offline.bnk, detected by default)")
 	viper.BindPFlag("mirror", rootCmd.PersistentFlags().Lookup("mirror"))
 	viper.BindPFlag("spotify-data", rootCmd.PersistentFlags().Lookup("spotify-data"))
+	rootCmd.PersistentFlags().StringVar(&linkMode, "link-mode", "", "Override how files are linked into Spotify: symlink or copy (default depends on the Spotify sandbox)")
 	viper.BindPFlag("spotify-config", rootCmd.PersistentFlags().Lookup("spotify-config"))
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", network.Timeout, "Timeout of a single network request")
+	viper.BindPFlag("link-mode", rootCmd.PersistentFlags().Lookup("link-mode"))
+	viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", "text", "Output format of listings: text or json")
+	viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
+	rootCmd.PersistentFlags().Bool("no-color", false, "Disable colored output (also disabled by NO_COLOR or when not writing to a terminal)")
+	viper.BindPFlag("no-color", rootCmd.PersistentFlags().Lookup("no-color"))
+	rootCmd.PersistentFlags().BoolP("yes", "y", false, "Answer yes to every confirmation prompt")
+	viper.BindPFlag("auto-confirm", rootCmd.PersistentFlags().Lookup("yes"))
+	rootCmd.PersistentFlags().String("scope", string(module.ScopeUser), "Where to download modules: user, or system to share them with the other users of the machine (which takes admin rights)")
+	viper.BindPFlag("scope", rootCmd.PersistentFlags().Lookup("scope"))
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Print the files, links and vault entries that would change without touching them")
+	rootCmd.PersistentFlags().Bool("offline", false, "Don't use the network: install only from the cache and local paths, and skip checking for newer versions")
+	viper.BindPFlag("offline", rootCmd.PersistentFlags().Lookup("offline"))
+	rootCmd.PersistentFlags().String("proxy", "", "Proxy for every request, e.g. http://proxy.corp:3128 (defaults to HTTP(S)_PROXY, then the system settings)")
+	viper.BindPFlag("proxy", rootCmd.PersistentFlags().Lookup("proxy"))
+	rootCmd.PersistentFlags().String("cacert", "", "PEM bundle of certificates to trust along with the system ones, e.g. that of a proxy intercepting TLS")
+	viper.BindPFlag("cacert", rootCmd.PersistentFlags().Lookup("cacert"))
+	rootCmd.PersistentFlags().Bool("insecure", false, "Don't verify TLS certificates (discouraged: anyone on the network can tamper with downloads, use --cacert instead)")
+	viper.BindPFlag("insecure", rootCmd.PersistentFlags().Lookup("insecure"))
+
+	rootCmd.PersistentFlags().BoolVar(&traceSummary, "trace", false, "Time the steps of the command (metadata fetches, requests, downloads, extraction, vault writes) and print them as a tree on stderr")
+	rootCmd.PersistentFlags().StringVar(&traceOTLP, "trace-otlp", "", "Export the timings of --trace as OTLP/JSON to a file, or to a collector given its URL (e.g. http://localhost:4318/v1/traces)")
+
+	rootCmd.PersistentFlags().StringVar(&workspace, "workspace", "", "Workspace holding the config, modules and hooks of one Spotify install (defaults to BESPOKE_WORKSPACE, then the "+paths.DefaultWorkspace+" workspace)")
 
 	defaultcfgFile := filepath.Join(paths.ConfigPath, "config.yaml")
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", defaultcfgFile, "config file (default is "+defaultcfgFile+")")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", defaultcfgFile, "config file (default is "+defaultcfgFile+", or config.yaml in the workspace folder)")
 }
 
-func initConfig() {
+// initBaseConfig selects the workspace, reads the config file and localizes the commands, which is all printing
+// the help or shell completions needs
+func initBaseConfig() error {
+	initWorkspace()
+
 	viper.SetConfigFile(cfgFile)
+	viper.SetEnvPrefix("bespoke")
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_", ".", "_"))
 	viper.AutomaticEnv()
 
+	configErr := viper.ReadInConfig()
+	initLang(viper.GetString("lang"))
+	return configErr
+}
+
+// isLightCommand reports whether the command being run only prints help or completions, these skip the rest of
+// initConfig (policy, registries, network, Spotify detection...) so that they answer right away
+func isLightCommand() bool {
+	cmd, _, err := rootCmd.Find(os.Args[1:])
+	if err != nil {
+		return false
+	}
+	switch cmd.Name() {
+	case "help", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return true
+	}
+	return cmd.HasParent() && cmd.Parent().Name() == "completion"
+}
+
+func initConfig() {
+	if traceSummary || traceOTLP != "" {
+		trace.Enabled = true
+		trace.Start("bespoke", strings.Join(os.Args[1:], " "))
+	}
+	defer trace.Start("init config", "").Finish()
+
+	configErr := initBaseConfig()
+	if isLightCommand() {
+		return
+	}
+
 	viper.SetDefault("mirror", mirror)
-	viper.SetDefault("spotify-data", spotifyDataPath)
-	viper.SetDefault("spotify-config", spotifyConfigPath)
+	viper.SetDefault("auto-confirm", false)
+	viper.SetDefault("daemon-port", 7967)
+	// metrics.outdated-interval is how often the daemon's /metrics endpoint counts the outdated modules
+	viper.SetDefault("metrics.outdated-interval", time.Hour)
+	// hooks-url overrides the hooks release channel when set
+	viper.SetDefault("hooks-url", "")
+	viper.SetDefault("hooks.channel", "stable")
+	// hooks.public-key makes sync require the checksums of the hooks to be signed with this ed25519 key
+	viper.SetDefault("hooks.public-key", "")
+	resolveSpotifyPaths()
+
+	if configErr == nil {
+		fmt.Fprintln(os.Stderr, i18n.T("Using config file: %s", viper.ConfigFileUsed()))
+	}
+	if paths.SandboxPath != "" {
+		fmt.Fprintln(os.Stderr, i18n.T("Sandboxed in %s", paths.SandboxPath))
+	}
+
+	// Environment variables (BESPOKE_SPOTIFY_DATA, ...) apply even without a config file
+	mirror = viper.GetBool("mirror")
+	spotifyDataPath = viper.GetString("spotify-data")
+	spotifyConfigPath = viper.GetString("spotify-config")
+	linkMode = viper.GetString("link-mode")
+	timeout = viper.GetDuration("timeout")
+	outputFormat = viper.GetString("output")
+	autoConfirm = viper.GetBool("auto-confirm")
+	ui.Configure(viper.GetBool("no-color"))
+	module.DryRun = dryRun
+	module.HooksPublicKey = viper.GetString("hooks.public-key")
+	initProgress()
+
+	initSandbox()
+	initNetwork()
+	initPolicy()
+	initProtocol()
+	initScripts()
+	initScan()
+	initScope()
+	initRegistries()
+
+	initBackups()
+
+	// store.compress keeps the disabled versions of modules compressed in the store
+	viper.SetDefault("store.compress", module.CompressDisabled)
+	module.CompressDisabled = getSwitch("store.compress")
 
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+	viper.SetDefault("notifications", true)
+	notify.Enabled = viper.GetBool("notifications")
+}
+
+// resolveSpotifyPaths only looks for Spotify's folders when neither the flags, the environment nor the config
+// file set them
+func resolveSpotifyPaths() {
+	if viper.GetString("spotify-data") == "" {
+		viper.SetDefault("spotify-data", paths.GetSpotifyPath())
+	}
+	if viper.GetString("spotify-config") == "" {
+		viper.SetDefault("spotify-config", paths.GetSpotifyConfigPath())
+	}
+}
+
+func initBackups() {
+	viper.SetDefault("backups.keep", module.BackupsKeep)
+	viper.SetDefault("backups.max-age", module.BackupsMaxAge)
+	module.BackupsKeep = viper.GetInt("backups.keep")
+	module.BackupsMaxAge = viper.GetDuration("backups.max-age")
+	viper.SetDefault("trash.max-age", module.TrashMaxAge)
+	module.TrashMaxAge = viper.GetDuration("trash.max-age")
+	module.ConfigFile = cfgFile
+}
+
+func initRegistries() {
+	viper.SetDefault("overrides", module.OverridesPath)
+	module.OverridesPath = viper.GetString("overrides")
+
+	if err := viper.UnmarshalKey("registries", &module.Registries); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("Invalid registries config: %s", err))
+	}
+	network.RegistryHosts = []string{}
+	for _, registry := range module.Registries {
+		if u, err := url.Parse(registry.URL); err == nil && u.Hostname() != "" {
+			network.RegistryHosts = append(network.RegistryHosts, strings.ToLower(u.Hostname()))
+		}
+	}
+	viper.SetDefault("known-hosts", module.KnownHosts)
+	module.KnownHosts = viper.GetStringSlice("known-hosts")
+
+	// Opt-in only, disable again with `bespoke config set telemetry off` or BESPOKE_TELEMETRY=off
+	viper.SetDefault("telemetry", false)
+	module.Telemetry = getSwitch("telemetry")
+}
+
+func initScripts() {
+	viper.SetDefault("scripts.timeout", module.ScriptTimeout)
+	module.ScriptTimeout = viper.GetDuration("scripts.timeout")
+	module.TrustedScriptAuthors = viper.GetStringSlice("scripts.trusted-authors")
+}
+
+func initScan() {
+	viper.SetDefault("scan.command", "")
+	viper.SetDefault("scan.timeout", module.ScanTimeout)
+	module.ScanCommand = viper.GetString("scan.command")
+	module.ScanTimeout = viper.GetDuration("scan.timeout")
+}
+
+func initScope() {
+	scope, err := module.ParseScope(viper.GetString("scope"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	module.InstallScope = scope
+}
+
+// initPolicy exits rather than running without the policy when it can't be loaded
+func initPolicy() {
+	// A policy file the user points to must exist, the default one is optional
+	required := viper.IsSet("policy")
+	viper.SetDefault("policy", filepath.Join(paths.ConfigPath, "policy.json"))
+	if err := module.LoadPolicy(viper.GetString("policy"), required); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("Can't load policy file: %s", err))
+		os.Exit(1)
+	}
+}
+
+func initProtocol() {
+	viper.SetDefault("protocol.trusted-hosts", module.ProtocolTrustedHosts)
+	viper.SetDefault("protocol.trusted-origins", trustedOrigins)
+	module.ProtocolTrustedHosts = viper.GetStringSlice("protocol.trusted-hosts")
+	module.ProtocolSigningKey = viper.GetString("protocol.signing-key")
+	trustedOrigins = viper.GetStringSlice("protocol.trusted-origins")
+}
+
+func initNetwork() {
+	viper.SetDefault("connect-timeout", network.ConnectTimeout)
+	viper.SetDefault("read-timeout", network.ReadTimeout)
+	viper.SetDefault("retries", network.Retries)
+	viper.SetDefault("http-cache-ttl", network.CacheTTL)
+	// max-requests and max-host-requests bound the requests in flight, 0 for no limit
+	viper.SetDefault("max-requests", network.MaxRequests)
+	viper.SetDefault("max-host-requests", network.MaxHostRequests)
+	// offline is on, off, or auto to go offline for a while whenever the network is found unreachable
+	viper.SetDefault("offline", "auto")
+
+	network.Timeout = timeout
+	network.ConnectTimeout = viper.GetDuration("connect-timeout")
+	network.ReadTimeout = viper.GetDuration("read-timeout")
+	network.Retries = viper.GetInt("retries")
+	network.CacheTTL = viper.GetDuration("http-cache-ttl")
+	network.MaxRequests = viper.GetInt("max-requests")
+	network.MaxHostRequests = viper.GetInt("max-host-requests")
+	if network.MaxRequests > 0 {
+		module.RemoteWorkers = network.MaxRequests
+	}
+	network.Tokens = viper.GetStringMapString("tokens")
+	network.DetectOffline = strings.ToLower(viper.GetString("offline")) == "auto"
+	network.Offline = !network.DetectOffline && getSwitch("offline")
+	network.OnOffline = func() {
+		fmt.Fprintln(os.Stderr, i18n.T("The network is unreachable, continuing offline"))
+	}
+	network.Proxy = viper.GetString("proxy")
+	network.CACert = viper.GetString("cacert")
+	network.Insecure = viper.GetBool("insecure")
+	if network.Insecure {
+		fmt.Fprintln(os.Stderr, i18n.T("TLS certificates aren't verified (--insecure), downloads can be tampered with"))
+	}
+	if err := network.Configure(); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("Invalid network settings: %s", err))
+		os.Exit(1)
+	}
+}
+
+func initSandbox() {
+	sandbox = paths.DetectSandbox(spotifyDataPath)
+	if sandbox == paths.SandboxSnap && !mirror {
+		fmt.Fprintln(os.Stderr, i18n.T("Spotify snap installs are read-only, using mirror mode"))
+		mirror = true
+	}
 
-		mirror = viper.GetBool("mirror")
-		spotifyDataPath = viper.GetString("spotify-data")
-		spotifyConfigPath = viper.GetString("spotify-config")
+	if linkMode == "" {
+		if sandbox == paths.SandboxNone {
+			link.Mode = link.Symlink
+		} else {
+			link.Mode = link.Copy
+		}
+	} else {
+		link.Mode = link.ParseStrategy(linkMode)
 	}
+	archive.LongPaths = viper.GetBool("long-paths")
 }
@@ -0,0 +1,84 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// addDefaultCommands has cobra add the help, completion and __complete commands, which it only does
+// when executing the command line
+var addDefaultCommands = sync.OnceFunc(func() {
+	os.Args = []string{"bespoke", "__complete", ""}
+	rootCmd.SetArgs(os.Args[1:])
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	rootCmd.Execute()
+	rootCmd.SetArgs(nil)
+	rootCmd.SetOut(nil)
+	rootCmd.SetErr(nil)
+})
+
+// withArgs runs the command line through initConfig as bespoke would, against a config file of its own
+func withArgs(tb testing.TB, args ...string) {
+	tb.Helper()
+	previousArgs, previousCfgFile := os.Args, cfgFile
+	tb.Cleanup(func() { os.Args, cfgFile = previousArgs, previousCfgFile })
+	cfgFile = filepath.Join(tb.TempDir(), "config.yaml")
+	addDefaultCommands()
+	os.Args = append([]string{"bespoke"}, args...)
+}
+
+func TestIsLightCommand(t *testing.T) {
+	tests := []struct {
+		args []string
+		want bool
+	}{
+		{[]string{"__complete", "pkg", ""}, true},
+		{[]string{"__completeNoDesc", "pkg", ""}, true},
+		{[]string{"help", "pkg"}, true},
+		{[]string{"completion", "bash"}, true},
+		{[]string{"pkg", "list"}, false},
+		{[]string{"status"}, false},
+	}
+	for _, tt := range tests {
+		withArgs(t, tt.args...)
+		if got := isLightCommand(); got != tt.want {
+			t.Errorf("isLightCommand(%v) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+// BenchmarkStartup measures the configuration the shell completions wait for on every key press
+func BenchmarkStartup(b *testing.B) {
+	for _, args := range [][]string{{"__complete", "pkg", "install", ""}, {"help"}} {
+		b.Run(args[0], func(b *testing.B) {
+			withArgs(b, args...)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				initConfig()
+			}
+		})
+	}
+}
@@ -22,6 +22,7 @@ package cmd
 import (
 	"os/exec"
 	"path/filepath"
+	"runtime"
 
 	"bespoke/paths"
 
@@ -46,9 +47,18 @@ func execRun(args []string) {
 	args = prepend(args, defaultArgs...)
 	var execPath string
 	if mirror {
-		execPath = filepath.Join(xdg.ConfigHome, "Microsoft", "WindowsApps", "Spotify.exe")
 		args = prepend(args, "--app-directory="+filepath.Join(paths.ConfigPath, "apps"))
-	} else {
+	}
+	switch {
+	case sandbox == paths.SandboxFlatpak:
+		execPath = "flatpak"
+		args = prepend(args, "run", paths.FlatpakAppID)
+	case sandbox == paths.SandboxSnap:
+		execPath = "snap"
+		args = prepend(args, "run", "spotify")
+	case mirror && runtime.GOOS == "windows":
+		execPath = filepath.Join(xdg.ConfigHome, "Microsoft", "WindowsApps", "Spotify.exe")
+	default:
 		execPath = paths.GetSpotifyExecPath(spotifyDataPath)
 	}
 	exec.Command(execPath, args...).Start()
@@ -0,0 +1,53 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/module"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+var sbomFormat string
+
+var pkgSbomCmd = &cobra.Command{
+	Use:   "sbom [--format cyclonedx|spdx]",
+	Short: "Print a software bill of materials of the installed modules",
+	Long:  "lists every installed version with its source, commit, license and the sha256 of its files as a CycloneDX 1.5 or SPDX 2.3 JSON document",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		components, err := module.SbomComponents()
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		sbom, err := module.BuildSbom(sbomFormat, components, cliVersion())
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		printJSON(sbom)
+	},
+}
+
+func init() {
+	pkgCmd.AddCommand(pkgSbomCmd)
+
+	pkgSbomCmd.Flags().StringVar(&sbomFormat, "format", module.SbomCycloneDX, "Format of the document: cyclonedx or spdx")
+}
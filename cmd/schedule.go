@@ -0,0 +1,107 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/schedule"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	scheduleInterval time.Duration
+	scheduleNotify   bool
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule action",
+	Short: "Manage periodic background updates",
+	Run:   func(cmd *cobra.Command, args []string) {},
+}
+
+var scheduleInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Periodically upgrade modules and re-patch Spotify after it updates",
+	Run: func(cmd *cobra.Command, args []string) {
+		if !cmd.Flags().Changed("every") {
+			scheduleInterval = viper.GetDuration("schedule.interval")
+		}
+		if !cmd.Flags().Changed("notify") {
+			scheduleNotify = viper.GetBool("schedule.notify")
+		}
+
+		exe, err := os.Executable()
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		upgrade := []string{"pkg", "upgrade", "--all", "--quiet"}
+		apply := []string{"apply", "--if-needed"}
+		if scheduleNotify {
+			upgrade = append(upgrade, "--notify")
+			apply = append(apply, "--notify")
+		}
+
+		job := schedule.Job{
+			Executable: exe,
+			Commands:   [][]string{upgrade, apply},
+			Interval:   scheduleInterval,
+		}
+		if err := schedule.Install(job); err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		if err := saveConfig("schedule.interval", scheduleInterval.String()); err != nil {
+			log.Println(err.Error())
+		}
+		if err := saveConfig("schedule.notify", scheduleNotify); err != nil {
+			log.Println(err.Error())
+		}
+		log.Println(i18n.T("Scheduled updates every %s", scheduleInterval))
+	},
+}
+
+var scheduleRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Stop periodic background updates",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := schedule.Remove(); err != nil {
+			log.Fatalln(err.Error())
+		}
+		log.Println(i18n.T("Removed scheduled updates"))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+
+	scheduleCmd.AddCommand(scheduleInstallCmd, scheduleRemoveCmd)
+
+	viper.SetDefault("schedule.interval", 24*time.Hour)
+	viper.SetDefault("schedule.notify", true)
+
+	scheduleInstallCmd.Flags().DurationVar(&scheduleInterval, "every", 24*time.Hour, "Interval between updates")
+	scheduleInstallCmd.Flags().BoolVar(&scheduleNotify, "notify", true, "Send a desktop notification when something changed")
+}
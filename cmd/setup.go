@@ -0,0 +1,120 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/paths"
+	"bespoke/uri"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var starterModules []string
+
+var setupCmd = &cobra.Command{
+	Use:   "setup",
+	Short: "Guided first-run setup of bespoke",
+	Long:  "detects Spotify, initializes bespoke, downloads the hooks, patches Spotify and optionally installs a starter set of modules",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := execSetup(); err != nil {
+			log.Fatalln(err.Error())
+		}
+		log.Println(i18n.T("Setup complete, launch Spotify with `bespoke run`"))
+	},
+}
+
+func execSetup() error {
+	log.Println(i18n.T("[1/5] Detecting Spotify"))
+	if err := setupSpotifyPaths(); err != nil {
+		return err
+	}
+
+	log.Println(i18n.T("[2/5] Initializing bespoke"))
+	if err := enableDeveloperMode(); err != nil {
+		log.Println(i18n.T("Couldn't enable developer mode, try running setup in an elevated shell: %s", err.Error()))
+	}
+	if _, err := module.GetVault(); err != nil {
+		if err := initVault(); err != nil {
+			return err
+		}
+	}
+
+	log.Println(i18n.T("[3/5] Downloading hooks"))
+	if err := installHooks(); err != nil {
+		return err
+	}
+
+	log.Println(i18n.T("[4/5] Patching Spotify"))
+	src, _ := getApps()
+	if _, err := os.Stat(filepath.Join(src, "xpui.spa")); err == nil {
+		if err := execApply(); err != nil {
+			return err
+		}
+	} else {
+		log.Println(i18n.T("Spotify is already patched"))
+	}
+
+	log.Println(i18n.T("[5/5] Registering the bespoke: protocol handler"))
+	if err := uri.RegisterURIScheme(); err != nil {
+		log.Println(i18n.T("Couldn't register the protocol handler: %s", err.Error()))
+	}
+
+	if len(starterModules) > 0 && confirm(i18n.T("Install the starter modules?"), true) {
+		for _, metadataURL := range starterModules {
+			log.Println(i18n.T("Installing %s", metadataURL))
+			if err := module.InstallModuleRemote(metadataURL); err != nil {
+				log.Println(err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+func setupSpotifyPaths() error {
+	for {
+		if _, err := os.Stat(paths.GetSpotifyAppsPath(spotifyDataPath)); err == nil {
+			break
+		}
+		log.Println(i18n.T("Couldn't find Spotify in %s", spotifyDataPath))
+		spotifyDataPath = prompt(i18n.T("Path to the Spotify data folder (containing the spotify executable)"), "")
+		if spotifyDataPath == "" {
+			return os.ErrNotExist
+		}
+		if err := saveConfig("spotify-data", spotifyDataPath); err != nil {
+			return err
+		}
+	}
+
+	initSandbox()
+	log.Println(i18n.T("Found Spotify in %s (sandbox: %s)", spotifyDataPath, sandbox.String()))
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(setupCmd)
+
+	setupCmd.Flags().StringSliceVar(&starterModules, "module", []string{}, "Metadata URL of a starter module to install (repeatable)")
+}
@@ -0,0 +1,120 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/module"
+	"bespoke/ui"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var pkgShowCmd = &cobra.Command{
+	Use:     "show murl|[registry:]id[@version]|git+url#ref=..&path=..",
+	Aliases: []string{"info"},
+	Short:   "Print the metadata of a module without installing it",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		spinner := ui.Spin("Fetching metadata")
+		preview, err := module.PreviewModule(args[0])
+		spinner.Stop()
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		if outputFormat == "json" {
+			printJSON(preview)
+			return
+		}
+
+		metadata := preview.Metadata
+		fmt.Println(ui.Bold(strings.Join(metadata.Authors, ", ")+"/"+metadata.Name), ui.Cyan(metadata.Version))
+		if metadata.Description != "" {
+			fmt.Println(metadata.Description)
+		}
+		fmt.Println()
+
+		table := ui.NewTable()
+		field := func(name string, value string) {
+			if value != "" {
+				table.Row(ui.Bold(name), value)
+			}
+		}
+		field("Source", preview.Source)
+		field("Trust", formatTrust(preview.Trust))
+		field("Authors", strings.Join(metadata.Authors, ", "))
+		field("Tags", strings.Join(metadata.Tags, ", "))
+		field("Spotify", metadata.Spotify)
+		if metadata.License != "" {
+			field("License", metadata.License)
+		} else {
+			field("License", ui.Dim("none"))
+		}
+		field("Dependencies", formatDependencies(metadata.Dependencies))
+		field("Provides", strings.Join(metadata.Provides, ", "))
+		field("Conflicts", strings.Join(metadata.Conflicts, ", "))
+		field("Renamed from", strings.Join(metadata.RenamedFrom, ", "))
+		field("Schemes", strings.Join(metadata.SchemeNames(), ", "))
+		if len(metadata.Permissions) > 0 {
+			field("Permissions", ui.Yellow(strings.Join(metadata.DescribePermissions(), ", ")))
+		} else {
+			field("Permissions", ui.Dim("none"))
+		}
+		if preview.DownloadSize >= 0 {
+			field("Download size", formatSize(preview.DownloadSize))
+		} else {
+			field("Download size", ui.Dim("unknown"))
+		}
+		installed := []string{}
+		for _, status := range preview.Statuses {
+			installed = append(installed, string(status.Identifier.Version)+" "+formatState(status))
+		}
+		field("Installed", strings.Join(installed, ", "))
+		table.Render(os.Stdout)
+	},
+}
+
+func formatTrust(trust module.SourceTrust) string {
+	switch {
+	case trust.Insecure:
+		return ui.Red(trust.String())
+	case trust.Level == module.TrustUnknown:
+		return ui.Yellow(trust.String())
+	}
+	return ui.Green(trust.String())
+}
+
+func formatDependencies(dependencies map[string]string) string {
+	formatted := []string{}
+	for identifier, version := range dependencies {
+		formatted = append(formatted, identifier+"@"+version)
+	}
+	slices.Sort(formatted)
+	return strings.Join(formatted, ", ")
+}
+
+func init() {
+	pkgCmd.AddCommand(pkgShowCmd)
+}
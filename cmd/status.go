@@ -0,0 +1,224 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/network"
+	"bespoke/paths"
+	"bespoke/ui"
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type statusReport struct {
+	Version string `json:"version"`
+	Hooks   struct {
+		Version string `json:"version"`
+		Channel string `json:"channel"`
+		Pin     string `json:"pin,omitempty"`
+		URL     string `json:"url,omitempty"`
+	} `json:"hooks"`
+	Spotify struct {
+		Version string `json:"version"`
+		Path    string `json:"path"`
+		Patched bool   `json:"patched"`
+		Mirror  bool   `json:"mirror"`
+	} `json:"spotify"`
+	Daemon struct {
+		Enabled bool `json:"enabled"`
+		Running bool `json:"running"`
+		Port    int  `json:"port"`
+	} `json:"daemon"`
+	Modules struct {
+		Installed int `json:"installed"`
+		Enabled   int `json:"enabled"`
+		// Outdated is -1 when the latest versions couldn't be checked
+		Outdated int `json:"outdated"`
+		Broken   int `json:"broken"`
+		// SourceMissing counts the enabled modules whose source is gone, see module.MissingSource
+		SourceMissing int `json:"sourceMissing"`
+	} `json:"modules"`
+	Vault struct {
+		Schema int `json:"schema"`
+	} `json:"vault"`
+	Cache struct {
+		Size int64 `json:"size"`
+	} `json:"cache"`
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Summarize the state of bespoke, Spotify, the daemon and the modules",
+	Run: func(cmd *cobra.Command, args []string) {
+		report := collectStatus()
+
+		if outputFormat == "json" {
+			printJSON(report)
+			return
+		}
+
+		table := ui.NewTable()
+		table.Row(ui.Bold("bespoke"), report.Version)
+
+		hooks := ui.Yellow("not synced")
+		if report.Hooks.Version != "" {
+			hooks = report.Hooks.Version
+		} else if report.Hooks.URL != "" {
+			hooks = report.Hooks.URL
+		}
+		channel := report.Hooks.Channel
+		if report.Hooks.Pin != "" {
+			channel = "pinned to " + report.Hooks.Pin
+		}
+		table.Row(ui.Bold("hooks"), hooks+" "+ui.Dim("("+channel+")"))
+
+		spotify := report.Spotify.Version
+		if spotify == "" {
+			spotify = ui.Dim("unknown version")
+		}
+		patched := ui.Red("not patched")
+		if report.Spotify.Patched {
+			patched = ui.Green("patched")
+		}
+		if report.Spotify.Mirror {
+			patched += ui.Dim(" (mirror)")
+		}
+		table.Row(ui.Bold("spotify"), spotify+", "+patched)
+
+		daemon := ui.Dim("stopped")
+		if report.Daemon.Running {
+			daemon = ui.Green("running") + " on port " + strconv.Itoa(report.Daemon.Port)
+		}
+		if !report.Daemon.Enabled {
+			daemon += ui.Dim(" (disabled)")
+		}
+		table.Row(ui.Bold("daemon"), daemon)
+
+		modules := fmt.Sprintf("%d installed, %d enabled", report.Modules.Installed, report.Modules.Enabled)
+		if report.Modules.Outdated > 0 {
+			modules += ", " + ui.Yellow(strconv.Itoa(report.Modules.Outdated)+" outdated")
+		} else if report.Modules.Outdated == 0 {
+			modules += ", 0 outdated"
+		}
+		if report.Modules.Broken > 0 {
+			modules += ", " + ui.Red(strconv.Itoa(report.Modules.Broken)+" broken")
+		} else {
+			modules += ", 0 broken"
+		}
+		if report.Modules.SourceMissing > 0 {
+			modules += ", " + ui.Red(strconv.Itoa(report.Modules.SourceMissing)+" with a missing source") + ui.Dim(" (see pkg outdated)")
+		}
+		table.Row(ui.Bold("modules"), modules)
+		table.Row(ui.Bold("vault"), "schema "+strconv.Itoa(report.Vault.Schema))
+		table.Row(ui.Bold("cache"), formatSize(report.Cache.Size))
+		table.Render(os.Stdout)
+	},
+}
+
+func collectStatus() statusReport {
+	var report statusReport
+	report.Version = cliVersion()
+
+	installed, err := readInstalledHooks()
+	if err != nil {
+		log.Println(i18n.T("Can't read the installed hooks: %s", err.Error()))
+	}
+	report.Hooks.Version = installed.Version
+	report.Hooks.URL = installed.URL
+	report.Hooks.Channel = viper.GetString("hooks.channel")
+	report.Hooks.Pin = viper.GetString("hooks.pin")
+
+	report.Spotify.Version = spotifyVersion()
+	report.Spotify.Path = spotifyDataPath
+	report.Spotify.Patched = isApplied()
+	report.Spotify.Mirror = mirror
+
+	report.Daemon.Enabled = daemon
+	report.Daemon.Port = viper.GetInt("daemon-port")
+	report.Daemon.Running = pingDaemon(report.Daemon.Port) == nil
+
+	statuses, err := module.ModuleStatuses()
+	if err != nil {
+		log.Println(i18n.T("Can't read the vault: %s", err.Error()))
+	}
+	report.Modules.Outdated = -1
+	if !network.IsOffline() {
+		spinner := ui.Spin("Checking for newer versions")
+		err := module.CheckRemote(statuses)
+		spinner.Stop()
+		if err == nil && !network.IsOffline() {
+			report.Modules.Outdated = 0
+		}
+	}
+	for _, status := range statuses {
+		report.Modules.Installed++
+		switch status.State {
+		case module.StateEnabled:
+			report.Modules.Enabled++
+			if status.Outdated() && report.Modules.Outdated >= 0 {
+				report.Modules.Outdated++
+			}
+			if status.SourceMissing != nil {
+				report.Modules.SourceMissing++
+			}
+		case module.StateBroken:
+			report.Modules.Broken++
+		}
+	}
+
+	if vault, err := module.GetVault(); err == nil {
+		report.Vault.Schema = vault.Schema
+	}
+
+	report.Cache.Size, _ = module.DirSize(paths.CachePath)
+	return report
+}
+
+// spotifyVersion reads the version of the Spotify client that was launched last from its prefs
+func spotifyVersion() string {
+	file, err := os.Open(filepath.Join(spotifyConfigPath, "prefs"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "app.last-launched-version="); ok {
+			return strings.Trim(value, `"`)
+		}
+	}
+	return ""
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+
+}
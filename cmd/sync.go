@@ -21,38 +21,210 @@ package cmd
 
 import (
 	"bespoke/archive"
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/network"
 	"bespoke/paths"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	hooksChannel string
+	hooksPin     string
+	checkHooks   bool
+	// insecureSkipVerify installs hooks that can't be checked against the checksums of their release
+	insecureSkipVerify bool
 )
 
 var syncCmd = &cobra.Command{
 	Use:   "sync",
 	Short: "Update bespoke from GitHub",
+	Long:  "--channel and --pin are saved, later syncs keep following them (pass --channel again to unpin)",
 	Run: func(cmd *cobra.Command, args []string) {
+		if cmd.Flags().Changed("channel") && !slices.Contains(module.HooksChannels, hooksChannel) {
+			log.Fatalln(i18n.T("Unknown channel %s, expected one of %s", hooksChannel, strings.Join(module.HooksChannels, ", ")))
+		}
+
+		settings := map[string]string{}
+		if cmd.Flags().Changed("channel") {
+			settings["hooks.channel"] = hooksChannel
+			settings["hooks.pin"] = ""
+		}
+		if cmd.Flags().Changed("pin") {
+			settings["hooks.pin"] = hooksPin
+		}
+		for key, value := range settings {
+			// Checking for updates of another channel doesn't switch to it
+			if checkHooks || dryRun {
+				viper.Set(key, value)
+			} else if err := saveConfig(key, value); err != nil {
+				log.Fatalln(err.Error())
+			}
+		}
+
+		if checkHooks {
+			if err := checkHooksUpdate(); err != nil {
+				log.Fatalln(err.Error())
+			}
+			return
+		}
 		if err := installHooks(); err != nil {
+			if network.IsOffline() {
+				log.Fatalln(i18n.T("Can't download the hooks while offline"))
+			}
 			log.Panicln(err.Error())
 		}
 	},
 }
 
-// TODO: let the user choose which release to install (& include version compatibility info)
+// installedHooks records which hooks release was last synced
+type installedHooks struct {
+	Version string `json:"version"`
+	URL     string `json:"url"`
+}
+
+func installedHooksPath() string {
+	return filepath.Join(paths.StatePath, "hooks.json")
+}
+
+func readInstalledHooks() (installedHooks, error) {
+	var installed installedHooks
+	file, err := os.ReadFile(installedHooksPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return installed, nil
+		}
+		return installed, err
+	}
+	err = json.Unmarshal(file, &installed)
+	return installed, err
+}
+
+func writeInstalledHooks(installed installedHooks) error {
+	file, err := json.Marshal(installed)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(installedHooksPath()), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(installedHooksPath(), file, 0644)
+}
+
+// resolveHooks returns the hooks release to sync: the hooks-url setting when set, else the pinned release
+// or the latest release of the channel
+func resolveHooks() (module.HooksRelease, error) {
+	if hooksURL := viper.GetString("hooks-url"); hooksURL != "" {
+		return module.HooksRelease{URL: hooksURL, Checksums: hooksURL + ".sha256"}, nil
+	}
+	return module.ResolveHooksRelease(viper.GetString("hooks.channel"), viper.GetString("hooks.pin"))
+}
+
+func checkHooksUpdate() error {
+	if viper.GetString("hooks-url") != "" {
+		return errors.New("hooks are downloaded from the hooks-url setting, unset it to follow a channel")
+	}
+
+	installed, err := readInstalledHooks()
+	if err != nil {
+		return err
+	}
+	release, err := resolveHooks()
+	if err != nil {
+		return err
+	}
+
+	update := installed.Version != release.Version
+	if outputFormat == "json" {
+		printJSON(map[string]any{"installed": installed.Version, "available": release.Version, "update": update})
+		return nil
+	}
+	switch {
+	case !update:
+		fmt.Println(i18n.T("Hooks %s are up to date", release.Version))
+	case installed.Version == "":
+		fmt.Println(i18n.T("Hooks %s are available, run `bespoke sync` to install them", release.Version))
+	default:
+		fmt.Println(i18n.T("Hooks %s are available (installed: %s), run `bespoke sync` to update", release.Version, installed.Version))
+	}
+	return nil
+}
+
 func installHooks() error {
-	res, err := http.Get("http://github.com/spicetify/hooks/releases/latest/download/hooks.tar.gz")
+	release, err := resolveHooks()
+	if err != nil {
+		return err
+	}
+
+	hooksFolder := filepath.Join(paths.ConfigPath, "hooks")
+	log.Println(i18n.T("Downloading %s -> %s", release.URL, hooksFolder))
+	if dryRun {
+		return nil
+	}
+
+	res, err := network.Get(release.URL)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return network.NewStatusError(res)
+	}
+	// The hooks run inside Spotify, so they are checked before anything is extracted
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if err := release.Verify(raw); err != nil {
+		if !insecureSkipVerify {
+			return errors.New(err.Error() + " (pass --insecure-skip-verify to install them anyway)")
+		}
+		log.Println(i18n.T("Installing unverified hooks: %s", err.Error()))
+	}
 
 	re := regexp.MustCompile(`^(.*)$`)
 
-	return archive.UnTarGZ(res.Body, re, filepath.Join(paths.ConfigPath, "hooks"))
+	// The hooks are extracted next to the installed ones and swapped in once complete, so that
+	// an interrupted download doesn't leave Spotify with half of them
+	next := hooksFolder + ".next"
+	if err := os.RemoveAll(next); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(next, os.ModePerm); err != nil {
+		return err
+	}
+	if err := archive.UnTarGZ(bytes.NewReader(raw), re, next, archive.Filter{}); err != nil {
+		os.RemoveAll(next)
+		return err
+	}
+	if err := os.RemoveAll(hooksFolder); err != nil {
+		return err
+	}
+	if err := os.Rename(next, hooksFolder); err != nil {
+		return err
+	}
+	return writeInstalledHooks(installedHooks{release.Version, release.URL})
 }
 
 func init() {
 	rootCmd.AddCommand(syncCmd)
+
+	syncCmd.Flags().StringVar(&hooksChannel, "channel", "stable", "Release channel of the hooks to follow: "+strings.Join(module.HooksChannels, ", "))
+	syncCmd.Flags().StringVar(&hooksPin, "pin", "", "Stay on this hooks version (release tag) until another channel is chosen")
+	syncCmd.Flags().BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, "Install hooks whose checksum (or signature) can't be verified")
+	syncCmd.Flags().BoolVar(&checkHooks, "check", false, "Report whether newer hooks are available without installing them")
 }
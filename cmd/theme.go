@@ -0,0 +1,82 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/ui"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var themeCmd = &cobra.Command{
+	Use:   "theme action",
+	Short: "Manage the color schemes of themes",
+	Run:   func(cmd *cobra.Command, args []string) {},
+}
+
+var themeSetCmd = &cobra.Command{
+	Use:   "set id scheme",
+	Short: "Switch an enabled theme to another color scheme",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		identifier := module.NewModuleIdentifier(args[0])
+		if err := module.SetScheme(identifier, args[1]); err != nil {
+			log.Fatalln(err.Error())
+		}
+		log.Println(i18n.T("Switched %s to the %s scheme", identifier.String(), args[1]))
+	},
+}
+
+var themeListCmd = &cobra.Command{
+	Use:   "list id",
+	Short: "List the color schemes of an enabled theme",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		metadata, active, err := module.GetScheme(module.NewModuleIdentifier(args[0]))
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		if outputFormat == "json" {
+			printJSON(map[string]any{"active": active, "schemes": metadata.Schemes})
+			return
+		}
+		table := ui.NewTable("SCHEME", "VARIABLES", "")
+		for _, name := range metadata.SchemeNames() {
+			marker := ""
+			if name == active {
+				marker = ui.Green("active")
+			}
+			table.Row(ui.Cyan(name), strconv.Itoa(len(metadata.Schemes[name])), marker)
+		}
+		table.Render(os.Stdout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(themeCmd)
+
+	themeCmd.AddCommand(themeSetCmd, themeListCmd)
+}
@@ -0,0 +1,69 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/ui"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var undoList bool
+
+var undoCmd = &cobra.Command{
+	Use:   "undo [id]",
+	Short: "Revert the last (or the given) vault operation",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if undoList {
+			entries, err := module.UndoableEntries()
+			if err != nil {
+				log.Fatalln(err.Error())
+			}
+			table := ui.NewTable("ID", "TIME", "OPERATION", "MODULE")
+			for _, entry := range entries {
+				table.Row(ui.Dim(entry.ID), entry.Time.Format(time.DateTime), ui.Cyan(string(entry.Operation)), entry.Identifier)
+			}
+			table.Render(os.Stdout)
+			return
+		}
+
+		id := ""
+		if len(args) > 0 {
+			id = args[0]
+		}
+		entry, err := module.Undo(id)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		log.Println(i18n.T("Undid %s %s", entry.Operation, entry.Identifier))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+
+	undoCmd.Flags().BoolVar(&undoList, "list", false, "List the operations that can be undone")
+}
@@ -0,0 +1,121 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/paths"
+	"bespoke/service"
+	"bespoke/uri"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+var purgeConfig bool
+
+var uninstallCmd = &cobra.Command{
+	Use:     "uninstall",
+	Aliases: []string{"nuke"},
+	Short:   "Remove every trace of bespoke",
+	Long:    "restores Spotify, deletes the hooks, modules and store, and unregisters the bespoke: protocol handler",
+	Run: func(cmd *cobra.Command, args []string) {
+		question := i18n.T("This will restore Spotify and delete all installed modules and hooks, continue?")
+		if purgeConfig {
+			question = i18n.T("This will restore Spotify and delete all bespoke files including your config, continue?")
+		}
+		if !confirm(question, false) {
+			return
+		}
+		execUninstall()
+		log.Println(i18n.T("bespoke was uninstalled"))
+	},
+}
+
+func execUninstall() {
+	log.Println(i18n.T("Restoring Spotify to stock state"))
+	execFix()
+
+	// The protocol handler and the daemon service are shared by every workspace
+	if paths.Workspace == paths.DefaultWorkspace {
+		if err := uri.UnregisterURIScheme(); err != nil {
+			log.Println(i18n.T("Couldn't unregister the protocol handler: %s", err.Error()))
+		}
+		if service.Installed() {
+			if err := service.Remove(); err != nil {
+				log.Println(i18n.T("Couldn't remove the daemon service: %s", err.Error()))
+			}
+		}
+	}
+
+	removeOwned(paths.CachePath, cacheEntries)
+	removeOwned(paths.StatePath, stateEntries)
+	removeOwned(paths.LogPath, logEntries)
+
+	removeConfigFolders(purgeConfig)
+}
+
+// removeConfigFolders removes what bespoke creates in the config folder, which may hold anything else, along with
+// the config file when purge is set
+func removeConfigFolders(purge bool) {
+	owned := []string{"apps", "hooks", "modules", "generations", "store", "manifests", "bin"}
+	if purge {
+		owned = append(owned, "journal.jsonl", "overrides.json", "policy.json", "config.yaml")
+		if module.ConfigFile != "" {
+			if err := os.Remove(module.ConfigFile); err != nil && !os.IsNotExist(err) {
+				log.Println(err.Error())
+			}
+		}
+	}
+	removeOwned(paths.ConfigPath, owned)
+}
+
+// The names bespoke creates in the cache, state and log folders. The folders can be moved anywhere (e.g.
+// BESPOKE_CACHE=~/.cache), so only these are removed
+var (
+	cacheEntries = []string{"http", "removed", "snapshots", "build", completionRefreshMarker}
+	stateEntries = []string{"backups", "locks", "mixins.json", "hooks.json"}
+	logEntries   = []string{"scripts", "daemon.log"}
+)
+
+// removeOwned removes the given entries of folder, then folder itself unless something else is left in it, such
+// as the other workspaces nested in the default one
+func removeOwned(folder string, entries []string) {
+	for _, name := range entries {
+		entryPath := filepath.Join(folder, name)
+		if _, err := os.Lstat(entryPath); err != nil {
+			continue
+		}
+		log.Println(i18n.T("Removing %s", entryPath))
+		if err := os.RemoveAll(entryPath); err != nil {
+			log.Println(err.Error())
+		}
+	}
+	os.Remove(folder)
+}
+
+func init() {
+	rootCmd.AddCommand(uninstallCmd)
+
+	uninstallCmd.Flags().BoolVar(&purgeConfig, "purge", false, "Also remove the config file")
+}
@@ -0,0 +1,88 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/module"
+	"bespoke/paths"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPurgeKeepsForeignFiles(t *testing.T) {
+	previous, previousConfigFile := paths.ConfigPath, module.ConfigFile
+	t.Cleanup(func() { paths.ConfigPath, module.ConfigFile = previous, previousConfigFile })
+	paths.ConfigPath = t.TempDir()
+	module.ConfigFile = filepath.Join(paths.ConfigPath, "config.yaml")
+
+	owned := []string{"modules/vault.json", "store/a/one/1.0.0/metadata.json", "journal.jsonl", "config.yaml"}
+	foreign := []string{"notes.txt", "dotfiles/.gitconfig"}
+	for _, name := range append(owned, foreign...) {
+		file := filepath.Join(paths.ConfigPath, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removeConfigFolders(true)
+
+	for _, name := range owned {
+		if _, err := os.Stat(filepath.Join(paths.ConfigPath, name)); err == nil {
+			t.Errorf("%s was kept", name)
+		}
+	}
+	for _, name := range foreign {
+		if _, err := os.Stat(filepath.Join(paths.ConfigPath, name)); err != nil {
+			t.Errorf("%s was removed: %v", name, err)
+		}
+	}
+}
+
+func TestRemoveOwnedSparesSharedFolders(t *testing.T) {
+	// e.g. BESPOKE_CACHE=~/.cache
+	cache := t.TempDir()
+	for _, name := range []string{"http/0123", "removed/a/one/1.0.0/metadata.json", "other-app/data", "fontconfig"} {
+		file := filepath.Join(cache, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removeOwned(cache, cacheEntries)
+
+	entries, err := os.ReadDir(cache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	left := []string{}
+	for _, entry := range entries {
+		left = append(left, entry.Name())
+	}
+	if len(left) != 2 || left[0] != "fontconfig" || left[1] != "other-app" {
+		t.Errorf("the cache folder holds %v, want [fontconfig other-app]", left)
+	}
+}
@@ -0,0 +1,202 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/network"
+	"bespoke/notify"
+	"bespoke/ui"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	upgradeAll    bool
+	upgradeQuiet  bool
+	upgradeNotify bool
+	upgradeFix    bool
+)
+
+func upgradeTargets(args []string) []module.ModuleIdentifier {
+	identifiers := []module.ModuleIdentifier{}
+	for _, arg := range args {
+		identifiers = append(identifiers, module.NewModuleIdentifier(arg))
+	}
+	return identifiers
+}
+
+var pkgOutdatedCmd = &cobra.Command{
+	Use:   "outdated [id...]",
+	Short: "List enabled modules with a newer version available",
+	Run: func(cmd *cobra.Command, args []string) {
+		spinner := ui.Spin("Checking for upgrades")
+		upgrades, errs := module.CheckUpgrades(upgradeTargets(args))
+		spinner.Stop()
+		for _, err := range errs {
+			log.Println(err.Error())
+		}
+		if outputFormat == "json" {
+			printJSON(upgrades)
+			return
+		}
+		defer reportMissingSources(upgradeTargets(args))
+		if len(upgrades) == 0 && network.IsOffline() {
+			return
+		}
+		if len(upgrades) == 0 {
+			fmt.Println(i18n.T("All modules are up to date"))
+			return
+		}
+		table := ui.NewTable("MODULE", "CURRENT", "LATEST")
+		for _, upgrade := range upgrades {
+			table.Row(upgrade.Module.String(), ui.Yellow(string(upgrade.From)), ui.Green(string(upgrade.To)))
+		}
+		table.Render(os.Stdout)
+	},
+}
+
+// reportMissingSources warns about the modules (all of them when identifiers is empty) whose source is gone,
+// suggesting modules of the registries that may replace them
+func reportMissingSources(identifiers []module.ModuleIdentifier) {
+	vault, err := module.GetVault()
+	if err != nil {
+		return
+	}
+	for _, identifier := range vault.OrderedModules() {
+		m := vault.Modules[identifier]
+		moduleIdentifier := module.NewModuleIdentifier(string(identifier))
+		if m.SourceMissing == nil || (len(identifiers) > 0 && !slices.Contains(identifiers, moduleIdentifier)) {
+			continue
+		}
+		log.Println(ui.Red(i18n.T("%s may be unmaintained:", identifier)), i18n.T("its source is missing since %s", m.SourceMissing.Since.Local().Format(time.DateOnly)),
+			ui.Dim("("+m.SourceMissing.URL+": "+m.SourceMissing.Error+")"))
+
+		alternatives := module.Alternatives(moduleIdentifier, 3)
+		if len(alternatives) == 0 {
+			continue
+		}
+		names := make([]string, len(alternatives))
+		for i, alternative := range alternatives {
+			names[i] = string(alternative.Identifier)
+			if alternative.Description != "" {
+				names[i] += " " + ui.Dim("("+alternative.Description+")")
+			}
+		}
+		log.Println("  alternatives:", strings.Join(names, ", "))
+	}
+}
+
+var pkgUpgradeCmd = &cobra.Command{
+	Use:   "upgrade [id...]",
+	Short: "Install and enable the latest version of modules",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 && !upgradeAll && !upgradeFix {
+			log.Fatalln(i18n.T("Specify the modules to upgrade or use --all"))
+		}
+		module.AllowHTTP = allowHTTP
+
+		var upgrades []module.Upgrade
+		var unfixable []module.ModuleIdentifier
+		var errs []error
+		if upgradeFix {
+			spinner := ui.Spin("Checking advisories")
+			var findings []module.AuditFinding
+			findings, errs = module.Audit()
+			spinner.Stop()
+			upgrades, unfixable = module.AuditFixes(findings)
+			if len(args) > 0 {
+				targets := upgradeTargets(args)
+				upgrades = slices.DeleteFunc(upgrades, func(upgrade module.Upgrade) bool {
+					return !slices.Contains(targets, upgrade.Module)
+				})
+				unfixable = slices.DeleteFunc(unfixable, func(identifier module.ModuleIdentifier) bool {
+					return !slices.Contains(targets, identifier)
+				})
+			}
+		} else {
+			spinner := ui.Spin("Checking for upgrades")
+			upgrades, errs = module.CheckUpgrades(upgradeTargets(args))
+			spinner.Stop()
+		}
+		if !upgradeQuiet {
+			for _, err := range errs {
+				log.Println(err.Error())
+			}
+			reportMissingSources(upgradeTargets(args))
+		}
+
+		upgraded := []string{}
+		failed := []string{}
+		// The upgraded modules are loaded together, after the last one is installed
+		err := module.Batch(func() error {
+			for _, upgrade := range upgrades {
+				if err := module.ApplyUpgrade(upgrade); err != nil {
+					fmt.Fprintln(os.Stderr, upgrade.Module, err.Error())
+					failed = append(failed, upgrade.Module.String())
+					continue
+				}
+				if !upgradeQuiet {
+					log.Println(i18n.T("Upgraded %s %s -> %s", upgrade.Module, upgrade.From, upgrade.To))
+				}
+				upgraded = append(upgraded, upgrade.Module.String()+" "+string(upgrade.To))
+			}
+			// No published version fixes these, they stay installed but disabled
+			for _, identifier := range unfixable {
+				if err := module.ToggleModuleInVault(module.StoreIdentifier{ModuleIdentifier: identifier}); err != nil {
+					fmt.Fprintln(os.Stderr, identifier, err.Error())
+					failed = append(failed, identifier.String())
+					continue
+				}
+				log.Println(i18n.T("Disabled %s as no published version fixes its advisories", identifier))
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			failed = append(failed, upgraded...)
+			upgraded = nil
+		}
+
+		if upgradeNotify && len(upgraded) > 0 {
+			notify.Notify("bespoke", "Upgraded "+strings.Join(upgraded, ", "))
+		}
+		if upgradeNotify && len(failed) > 0 {
+			notify.Notify("bespoke", "Failed to upgrade "+strings.Join(failed, ", "))
+		}
+	},
+}
+
+func init() {
+	pkgCmd.AddCommand(pkgOutdatedCmd, pkgUpgradeCmd)
+
+	pkgUpgradeCmd.Flags().BoolVar(&upgradeAll, "all", false, "Upgrade every enabled module")
+	pkgUpgradeCmd.Flags().BoolVarP(&upgradeQuiet, "quiet", "q", false, "Only print errors")
+	pkgUpgradeCmd.Flags().BoolVar(&allowHTTP, "allow-http", false, "Allow upgrading from plain http:// sources")
+	pkgUpgradeCmd.Flags().BoolVar(&upgradeFix, "fix", false, "Move the modules affected by an advisory (see pkg audit) to the recommended version, disabling those no version fixes")
+	pkgUpgradeCmd.Flags().BoolVar(&upgradeNotify, "notify", false, "Send a desktop notification when modules were upgraded")
+}
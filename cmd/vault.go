@@ -0,0 +1,237 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/ui"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	pullExact   bool
+	restoreFrom string
+)
+
+var vaultCmd = &cobra.Command{
+	Use:   "vault action",
+	Short: "Manage the modules vault",
+	Run:   func(cmd *cobra.Command, args []string) {},
+}
+
+var vaultRepairCmd = &cobra.Command{
+	Use:   "repair",
+	Short: "Rebuild a consistent vault from the store and modules folders",
+	Run: func(cmd *cobra.Command, args []string) {
+		changes, err := module.RepairVault(dryRun)
+		for _, change := range changes {
+			fmt.Println(change)
+		}
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if len(changes) == 0 {
+			log.Println(i18n.T("Vault is consistent, nothing to repair"))
+		} else if dryRun {
+			log.Println(i18n.T("Dry run, no changes were written"))
+		}
+	},
+}
+
+var vaultPushCmd = &cobra.Command{
+	Use:   "push [remote]",
+	Short: "Upload the enabled modules and their settings to sync them to another machine",
+	Long:  "remote is gist:<id>, gist: to create a private gist, or the https URL of a file on a WebDAV or S3 compatible endpoint. It defaults to the vault.remote setting and is saved there",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		module.AllowHTTP = allowHTTP
+		remote := syncRemote(args)
+		synced, errs := module.ExportVault()
+		for _, err := range errs {
+			log.Println(err.Error())
+		}
+
+		pushed, err := module.PushVault(remote, &synced)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if pushed != viper.GetString("vault.remote") {
+			if err := saveConfig("vault.remote", pushed); err != nil {
+				log.Println(err.Error())
+			}
+		}
+		log.Println(i18n.T("Pushed %d modules to %s", len(synced.Modules), pushed))
+	},
+}
+
+var vaultPullCmd = &cobra.Command{
+	Use:   "pull [remote]",
+	Short: "Install and enable the modules pushed from another machine",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		module.AllowHTTP = allowHTTP
+		remote := syncRemote(args)
+		synced, err := module.PullVault(remote)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		if err := installFrozenModules(synced.Modules); err != nil {
+			log.Fatalln(err.Error())
+		}
+		for identifier, settings := range synced.Settings {
+			moduleIdentifier := module.NewModuleIdentifier(identifier)
+			if _, scheme, err := module.GetScheme(moduleIdentifier); err != nil || settings.Scheme == "" || scheme == settings.Scheme {
+				continue
+			}
+			if err := module.SetScheme(moduleIdentifier, settings.Scheme); err != nil {
+				log.Println(err.Error())
+			}
+		}
+
+		if pullExact {
+			if err := disableUnsynced(synced.Modules); err != nil {
+				log.Fatalln(err.Error())
+			}
+		}
+		refreshMixins()
+		log.Println(i18n.T("Pulled %d modules from %s", len(synced.Modules), remote))
+	},
+}
+
+var vaultBackupsCmd = &cobra.Command{
+	Use:   "backups",
+	Short: "List the backups of the vault and config file taken before each change",
+	Long:  "backups.keep sets how many are kept (0 disables them) and backups.max-age how long",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		backups, err := module.ListBackups()
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if outputFormat == "json" {
+			printJSON(backups)
+			return
+		}
+		if len(backups) == 0 {
+			log.Println(i18n.T("No backup yet"))
+			return
+		}
+		table := ui.NewTable("ID", "TIME", "CONFIG")
+		for _, backup := range backups {
+			config := ui.Dim("no")
+			if backup.Config {
+				config = "yes"
+			}
+			table.Row(ui.Cyan(backup.ID), backup.Time.Format(time.DateTime), config)
+		}
+		table.Render(os.Stdout)
+	},
+}
+
+var vaultRestoreCmd = &cobra.Command{
+	Use:   "restore --from <backup id>",
+	Short: "Replace the vault and config file with a backup",
+	Long:  "the backup id can be shortened to any unique prefix (e.g. 20240131-12), the current state is backed up first and the restore can be undone with `bespoke undo`",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if restoreFrom == "" {
+			log.Fatalln(i18n.T("No backup given, pick one from `bespoke vault backups` with --from"))
+		}
+		backup, err := module.FindBackup(restoreFrom)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		if !dryRun && !confirm("Restore the vault from "+backup.Time.Format(time.DateTime)+"?", false) {
+			return
+		}
+
+		missing, err := module.RestoreBackup(backup)
+		for _, identifier := range missing {
+			log.Println(i18n.T("%s is enabled in the backup but no longer installed, reinstall it or run `bespoke vault repair`", identifier.String()))
+		}
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		refreshMixins()
+		log.Println(i18n.T("Restored the vault from %s", backup.ID))
+	},
+}
+
+func syncRemote(args []string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	remote := viper.GetString("vault.remote")
+	if remote == "" {
+		log.Fatalln(i18n.T("No remote given and vault.remote isn't set"))
+	}
+	return remote
+}
+
+// disableUnsynced disables the enabled modules missing from the pulled ones
+func disableUnsynced(frozen []module.FrozenModule) error {
+	synced := []module.ModuleIdentifier{}
+	for _, m := range frozen {
+		synced = append(synced, module.NewStoreIdentifier(m.Module).ModuleIdentifier)
+	}
+	enabled, err := module.GetEnabledModules()
+	if err != nil {
+		return err
+	}
+	extra := []module.ModuleIdentifier{}
+	for _, identifier := range enabled {
+		if !slices.Contains(synced, identifier.ModuleIdentifier) {
+			extra = append(extra, identifier.ModuleIdentifier)
+		}
+	}
+	if len(extra) == 0 || !confirmMatches("disabled", extra) {
+		return nil
+	}
+
+	return module.Batch(func() error {
+		for _, identifier := range extra {
+			if err := module.ToggleModuleInVault(module.StoreIdentifier{ModuleIdentifier: identifier}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func init() {
+	rootCmd.AddCommand(vaultCmd)
+
+	vaultCmd.AddCommand(vaultRepairCmd, vaultPushCmd, vaultPullCmd, vaultBackupsCmd, vaultRestoreCmd)
+
+	vaultRestoreCmd.Flags().StringVar(&restoreFrom, "from", "", "Backup to restore, as listed by `bespoke vault backups`")
+
+	vaultPullCmd.Flags().BoolVar(&pullExact, "exact", false, "Also disable the enabled modules that weren't pushed")
+	vaultPushCmd.Flags().BoolVar(&allowHTTP, "allow-http", false, "Allow pushing to a plain http:// remote")
+	vaultPullCmd.Flags().BoolVar(&allowHTTP, "allow-http", false, "Allow pulling from a plain http:// remote and installing from plain http:// sources")
+}
@@ -0,0 +1,69 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/ui"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var pkgWhyCmd = &cobra.Command{
+	Use:   "why id",
+	Short: "Explain why a module is installed",
+	Long:  "prints the chains of installed modules that depend on it, from explicitly installed modules or modules no other module depends on, and whether it is orphaned",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		report, err := module.Why(args[0])
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		if outputFormat == "json" {
+			printJSON(report)
+			return
+		}
+		switch {
+		case report.Explicit:
+			fmt.Println(i18n.T("%s was installed explicitly", args[0]))
+		case report.Orphaned:
+			fmt.Println(args[0], ui.Yellow(i18n.T("is orphaned:")), i18n.T("it was installed as a dependency and no explicitly installed module needs it, `bespoke pkg gc --orphans` removes it"))
+		case len(report.Chains) == 0:
+			fmt.Println(i18n.T("%s isn't required by other installed modules", args[0]))
+		}
+		for _, chain := range report.Chains {
+			names := []string{}
+			for _, identifier := range chain {
+				names = append(names, string(identifier))
+			}
+			names[len(names)-1] = ui.Bold(names[len(names)-1])
+			fmt.Println(strings.Join(names, ui.Dim(" -> ")))
+		}
+	},
+}
+
+func init() {
+	pkgCmd.AddCommand(pkgWhyCmd)
+}
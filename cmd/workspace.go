@@ -0,0 +1,182 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bespoke/i18n"
+	"bespoke/module"
+	"bespoke/paths"
+	"bespoke/ui"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var workspaceCmd = &cobra.Command{
+	Use:   "workspace",
+	Short: "Manage workspaces",
+	Long:  "each workspace has its own config, vault, store and hooks, so that several Spotify installs (e.g. stable and beta) can be customized independently. Select one with --workspace or BESPOKE_WORKSPACE",
+}
+
+var workspaceListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List workspaces, marking the active one",
+	Run: func(cmd *cobra.Command, args []string) {
+		workspaces, err := paths.ListWorkspaces()
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+
+		type workspaceInfo struct {
+			Name   string `json:"name"`
+			Path   string `json:"path"`
+			Active bool   `json:"active"`
+		}
+		infos := make([]workspaceInfo, 0, len(workspaces))
+		for _, name := range workspaces {
+			infos = append(infos, workspaceInfo{name, paths.WorkspaceConfigPath(name), name == paths.Workspace})
+		}
+
+		if outputFormat == "json" {
+			printJSON(infos)
+			return
+		}
+		table := ui.NewTable("", "NAME", "PATH")
+		for _, info := range infos {
+			marker, name := "", info.Name
+			if info.Active {
+				marker, name = ui.Green("*"), ui.Green(name)
+			}
+			table.Row(marker, name, ui.Dim(info.Path))
+		}
+		table.Render(os.Stdout)
+	},
+}
+
+var workspaceCreateCmd = &cobra.Command{
+	Use:   "create name",
+	Short: "Create a workspace",
+	Long:  "pass --spotify-data and --spotify-config to save the Spotify install managed by the new workspace",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if err := paths.ValidateWorkspace(name); err != nil {
+			log.Fatalln(err.Error())
+		}
+		if workspaceExists(name) {
+			log.Fatalln(i18n.T("Workspace %s already exists", name))
+		}
+		if dryRun {
+			log.Println(i18n.T("would create workspace %s in %s", name, paths.WorkspaceConfigPath(name)))
+			return
+		}
+
+		useWorkspace(name)
+		for _, key := range []string{"spotify-data", "spotify-config"} {
+			if cmd.Flags().Changed(key) {
+				if err := saveConfig(key, viper.GetString(key)); err != nil {
+					log.Fatalln(err.Error())
+				}
+			}
+		}
+		if err := initVault(); err != nil {
+			log.Fatalln(err.Error())
+		}
+		log.Println(i18n.T("Created workspace %s", name))
+		fmt.Println(i18n.T("Run `bespoke --workspace %s apply` to patch its Spotify install", name))
+	},
+}
+
+var workspaceRemoveCmd = &cobra.Command{
+	Use:   "remove name",
+	Short: "Delete a workspace and its files",
+	Long:  "deletes the config, vault, store and hooks of the workspace; run `bespoke --workspace name fix` beforehand to restore its Spotify install",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if name == paths.DefaultWorkspace {
+			log.Fatalln(i18n.T("The default workspace can't be removed"))
+		}
+		if name == paths.Workspace {
+			log.Fatalln(i18n.T("Can't remove the active workspace %s", name))
+		}
+		if !workspaceExists(name) {
+			log.Fatalln(i18n.T("Unknown workspace %s", name))
+		}
+		if !confirm(i18n.T("This will delete the modules, hooks and config of workspace %s, continue?", name), false) {
+			return
+		}
+		if dryRun {
+			log.Println(i18n.T("would remove workspace %s", name))
+			return
+		}
+
+		for _, folder := range paths.WorkspacePaths(name) {
+			log.Println(i18n.T("Removing %s", folder))
+			if err := os.RemoveAll(folder); err != nil {
+				log.Fatalln(err.Error())
+			}
+		}
+	},
+}
+
+func workspaceExists(name string) bool {
+	if name == paths.DefaultWorkspace {
+		return true
+	}
+	_, err := os.Stat(paths.WorkspaceConfigPath(name))
+	return err == nil
+}
+
+// useWorkspace repoints every workspace-relative path, including the config file unless it was given explicitly
+func useWorkspace(name string) {
+	if err := paths.UseWorkspace(name); err != nil {
+		log.Fatalln(err.Error())
+	}
+	module.ConfigurePaths()
+	if !rootCmd.PersistentFlags().Changed("config") {
+		cfgFile = filepath.Join(paths.ConfigPath, "config.yaml")
+	}
+}
+
+func initWorkspace() {
+	if workspace == "" {
+		workspace = os.Getenv("BESPOKE_WORKSPACE")
+	}
+	if workspace != "" {
+		if err := paths.ValidateWorkspace(workspace); err != nil {
+			log.Fatalln(err.Error())
+		}
+	}
+	if workspace != "" && !workspaceExists(workspace) {
+		log.Fatalln(i18n.T("Unknown workspace %s, create it with `bespoke workspace create %s`", workspace, workspace))
+	}
+	useWorkspace(workspace)
+}
+
+func init() {
+	rootCmd.AddCommand(workspaceCmd)
+
+	workspaceCmd.AddCommand(workspaceListCmd, workspaceCreateCmd, workspaceRemoveCmd)
+}
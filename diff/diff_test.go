@@ -0,0 +1,97 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package diff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want []Edit
+	}{
+		{"both empty", "", "", []Edit{}},
+		{"from empty", "", "a\nb\n", []Edit{{Insert, "a\n"}, {Insert, "b\n"}}},
+		{"to empty", "a\nb\n", "", []Edit{{Delete, "a\n"}, {Delete, "b\n"}}},
+		{"equal", "a\nb\n", "a\nb\n", []Edit{{Equal, "a\n"}, {Equal, "b\n"}}},
+		{"insertion", "a\nc\n", "a\nb\nc\n", []Edit{{Equal, "a\n"}, {Insert, "b\n"}, {Equal, "c\n"}}},
+		{"deletion", "a\nb\nc\n", "a\nc\n", []Edit{{Equal, "a\n"}, {Delete, "b\n"}, {Equal, "c\n"}}},
+		{"replacement", "a\nb\nc\n", "a\nx\nc\n", []Edit{{Equal, "a\n"}, {Delete, "b\n"}, {Insert, "x\n"}, {Equal, "c\n"}}},
+		{"newline added at the end", "a\nb", "a\nb\n", []Edit{{Equal, "a\n"}, {Delete, "b"}, {Insert, "b\n"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Lines(splitLines(tt.a), splitLines(tt.b))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lines = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCount(t *testing.T) {
+	edits := Lines(splitLines("a\nb\nc\n"), splitLines("a\nx\ny\nc\n"))
+	if added, removed := Count(edits); added != 2 || removed != 1 {
+		t.Errorf("Count = +%d -%d, want +2 -1", added, removed)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{"both empty", "", "", ""},
+		{"unchanged", "a\nb\n", "a\nb\n", ""},
+		{"from empty", "", "a\nb\n", "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{"to empty", "a\nb\n", "", "--- a\n+++ b\n@@ -1,2 +0,0 @@\n-a\n-b\n"},
+		{"insertion", "a\nc\n", "a\nb\nc\n", "--- a\n+++ b\n@@ -1,2 +1,3 @@\n a\n+b\n c\n"},
+		{"deletion", "a\nb\nc\n", "a\nc\n", "--- a\n+++ b\n@@ -1,3 +1,2 @@\n a\n-b\n c\n"},
+		{"no newline at the end", "a\nb", "a\nc", "--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+c\n\\ No newline at end of file\n"},
+		{"newline added at the end", "a\nb", "a\nb\n", "--- a\n+++ b\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"},
+		{"separate hunks", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "0\n2\n3\n4\n5\n6\n7\n8\n9\n11\n",
+			"--- a\n+++ b\n@@ -1,4 +1,4 @@\n-1\n+0\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+11\n"},
+		{"merged hunks", "1\n2\n3\n4\n5\n6\n", "0\n2\n3\n4\n5\n7\n",
+			"--- a\n+++ b\n@@ -1,6 +1,6 @@\n-1\n+0\n 2\n 3\n 4\n 5\n-6\n+7\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := Files("a", "b", tt.a, tt.b)
+			if got != tt.want {
+				t.Errorf("Files =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	if IsBinary([]byte("text\n")) {
+		t.Error("text is binary")
+	}
+	if !IsBinary([]byte{'a', 0, 'b'}) {
+		t.Error("content with NUL bytes isn't binary")
+	}
+}
@@ -22,3 +22,4 @@ package errors
 import "errors"
 
 var ErrUnsupportedOperation = errors.New("this opperation is not supported")
+var ErrCancelled = errors.New("operation cancelled")
@@ -0,0 +1,158 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package fsys is the filesystem the vault, the store, the module links and the extracted archives are
+// read from and written to, so that tests can run against an in-memory one
+package fsys
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// FS defaults to the OS filesystem, tests swap it for afero.NewMemMapFs().
+// Scripts, git and the Spotify client work on the real files whatever FS is
+var FS afero.Fs = afero.NewOsFs()
+
+// IsOS reports whether FS is backed by the real filesystem
+func IsOS() bool {
+	_, ok := FS.(*afero.OsFs)
+	return ok
+}
+
+func Open(name string) (afero.File, error) {
+	return FS.Open(name)
+}
+
+func OpenFile(name string, flag int, perm fs.FileMode) (afero.File, error) {
+	return FS.OpenFile(name, flag, perm)
+}
+
+func Create(name string) (afero.File, error) {
+	return FS.Create(name)
+}
+
+func ReadFile(name string) ([]byte, error) {
+	return afero.ReadFile(FS, name)
+}
+
+func WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return afero.WriteFile(FS, name, data, perm)
+}
+
+func ReadDir(name string) ([]fs.DirEntry, error) {
+	infos, err := afero.ReadDir(FS, name)
+	entries := make([]fs.DirEntry, 0, len(infos))
+	for _, info := range infos {
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	return entries, err
+}
+
+func Mkdir(name string, perm fs.FileMode) error {
+	return FS.Mkdir(name, perm)
+}
+
+func MkdirAll(name string, perm fs.FileMode) error {
+	return FS.MkdirAll(name, perm)
+}
+
+func MkdirTemp(dir string, pattern string) (string, error) {
+	return afero.TempDir(FS, dir, pattern)
+}
+
+func CreateTemp(dir string, pattern string) (afero.File, error) {
+	return afero.TempFile(FS, dir, pattern)
+}
+
+func Chmod(name string, mode fs.FileMode) error {
+	return FS.Chmod(name, mode)
+}
+
+func Remove(name string) error {
+	return FS.Remove(name)
+}
+
+func RemoveAll(name string) error {
+	return FS.RemoveAll(name)
+}
+
+func Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return FS.Chtimes(name, atime, mtime)
+}
+
+func Rename(oldname string, newname string) error {
+	return FS.Rename(oldname, newname)
+}
+
+func Stat(name string) (fs.FileInfo, error) {
+	return FS.Stat(name)
+}
+
+// Lstat doesn't follow links, on filesystems without links it is Stat
+func Lstat(name string) (fs.FileInfo, error) {
+	if lstater, ok := FS.(afero.Lstater); ok {
+		fi, _, err := lstater.LstatIfPossible(name)
+		return fi, err
+	}
+	return FS.Stat(name)
+}
+
+func Symlink(oldname string, newname string) error {
+	if linker, ok := FS.(afero.Linker); ok {
+		return linker.SymlinkIfPossible(oldname, newname)
+	}
+	return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: afero.ErrNoSymlink}
+}
+
+func Readlink(name string) (string, error) {
+	if reader, ok := FS.(afero.LinkReader); ok {
+		return reader.ReadlinkIfPossible(name)
+	}
+	return "", &os.PathError{Op: "readlink", Path: name, Err: afero.ErrNoReadlink}
+}
+
+// EvalSymlinks resolves the links in path, which is returned as is on filesystems without links
+func EvalSymlinks(path string) (string, error) {
+	if IsOS() {
+		return filepath.EvalSymlinks(path)
+	}
+	if _, err := FS.Stat(path); err != nil {
+		return "", err
+	}
+	return filepath.Clean(path), nil
+}
+
+func Glob(pattern string) ([]string, error) {
+	return afero.Glob(FS, pattern)
+}
+
+// WalkDir is filepath.WalkDir on FS
+func WalkDir(root string, fn fs.WalkDirFunc) error {
+	return afero.Walk(FS, root, func(path string, info fs.FileInfo, err error) error {
+		if info == nil {
+			return fn(path, nil, err)
+		}
+		return fn(path, fs.FileInfoToDirEntry(info), err)
+	})
+}
@@ -0,0 +1,105 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package i18n translates user-facing messages. Messages are looked up by their English text,
+// which is also what gets printed when the active language has no translation for them
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// Lang is the language of the messages, "en" when no catalog matched
+var Lang = "en"
+
+var catalog = map[string]string{}
+
+// Languages lists the languages that have a catalog, English included
+func Languages() []string {
+	languages := []string{"en"}
+	entries, _ := locales.ReadDir("locales")
+	for _, entry := range entries {
+		languages = append(languages, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	slices.Sort(languages)
+	return languages
+}
+
+// Detect returns the language of the user, from LC_ALL, LC_MESSAGES and LANG, then the system settings
+func Detect() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if locale := os.Getenv(env); locale != "" {
+			return locale
+		}
+	}
+	return systemLocale()
+}
+
+// candidates turns a POSIX (fr_FR.UTF-8@euro) or BCP 47 (fr-FR) locale into the catalog names to look for
+func candidates(locale string) []string {
+	locale, _, _ = strings.Cut(locale, ".")
+	locale, _, _ = strings.Cut(locale, "@")
+	locale = strings.ReplaceAll(locale, "_", "-")
+	if locale == "" || locale == "C" || locale == "POSIX" {
+		return nil
+	}
+	language, _, _ := strings.Cut(locale, "-")
+	return slices.Compact([]string{locale, strings.ToLower(language)})
+}
+
+// Use switches to the catalog of a locale (detected from the environment when empty), falling back to English
+func Use(locale string) {
+	if locale == "" {
+		locale = Detect()
+	}
+	Lang = "en"
+	catalog = map[string]string{}
+	for _, name := range candidates(locale) {
+		file, err := locales.ReadFile(path.Join("locales", name+".json"))
+		if err != nil {
+			continue
+		}
+		if err := json.Unmarshal(file, &catalog); err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid catalog for", name+":", err)
+			continue
+		}
+		Lang = name
+		return
+	}
+}
+
+// T translates a message, formatting it with args like fmt.Sprintf when any are given
+func T(message string, args ...any) string {
+	if translated, ok := catalog[message]; ok && translated != "" {
+		message = translated
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
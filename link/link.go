@@ -0,0 +1,126 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package link
+
+import (
+	"bespoke/fsys"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+type Strategy int
+
+const (
+	Symlink Strategy = iota
+	Copy
+)
+
+func (s Strategy) String() string {
+	switch s {
+	case Copy:
+		return "copy"
+	default:
+		return "symlink"
+	}
+}
+
+func ParseStrategy(s string) Strategy {
+	if s == "copy" {
+		return Copy
+	}
+	return Symlink
+}
+
+// Sandboxed Spotify installs (flatpak, snap) can't follow symlinks pointing outside of the sandbox,
+// in which case we fall back to copying files
+var Mode = Symlink
+
+func Create(oldname string, newname string) error {
+	if err := fsys.MkdirAll(filepath.Dir(newname), 0755); err != nil {
+		return err
+	}
+	if Mode == Copy {
+		return CopyDir(oldname, newname)
+	}
+	return fsys.Symlink(oldname, newname)
+}
+
+func Remove(name string) error {
+	fi, err := fsys.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&fs.ModeSymlink != 0 {
+		return fsys.Remove(name)
+	}
+	return fsys.RemoveAll(name)
+}
+
+func CopyDir(src string, dest string) error {
+	// WalkDir doesn't descend into a root that is a link (e.g. the modules folder, which links to its generation)
+	src, err := fsys.EvalSymlinks(src)
+	if err != nil {
+		return err
+	}
+	return fsys.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+
+		fi, err := fsys.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		if fi.IsDir() {
+			if d.Type()&fs.ModeSymlink != 0 {
+				return CopyDir(path, target)
+			}
+			return fsys.MkdirAll(target, 0755)
+		}
+
+		return copyFile(path, target, fi.Mode())
+	})
+}
+
+func copyFile(src string, dest string, perm fs.FileMode) error {
+	in, err := fsys.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := fsys.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
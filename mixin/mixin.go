@@ -0,0 +1,181 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package mixin
+
+import (
+	"bespoke/fsys"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Patch rewrites the chunks of the extracted client (files of xpui, e.g. "xpui.js") matching Chunk
+type Patch struct {
+	// Chunk is a path relative to the xpui folder, which may contain wildcards
+	Chunk string `json:"chunk"`
+	Find  string `json:"find"`
+	// Replace may refer to the groups of Find with ${1} when Regex is set
+	Replace string `json:"replace"`
+	Regex   bool   `json:"regex"`
+}
+
+// Mixin holds the patches of the mixin entry of a module
+type Mixin struct {
+	Module  string  `json:"module"`
+	Patches []Patch `json:"patches"`
+}
+
+func Parse(module string, data []byte) (Mixin, error) {
+	mixin := Mixin{Module: module}
+	if err := json.Unmarshal(data, &mixin); err != nil {
+		return mixin, errors.New("invalid mixin of " + module + ": " + err.Error())
+	}
+	mixin.Module = module
+	for _, patch := range mixin.Patches {
+		if patch.Chunk == "" || patch.Find == "" {
+			return mixin, errors.New("invalid mixin of " + module + ": patches need a chunk and a find string")
+		}
+		if patch.Regex {
+			if _, err := regexp.Compile(patch.Find); err != nil {
+				return mixin, errors.New("invalid mixin of " + module + ": " + err.Error())
+			}
+		}
+	}
+	return mixin, nil
+}
+
+// Conflict reports a chunk patched by several modules, the later ones in load order see the chunk as
+// rewritten by the earlier ones
+type Conflict struct {
+	Chunk   string   `json:"chunk"`
+	Modules []string `json:"modules"`
+}
+
+// Result describes the outcome of Apply
+type Result struct {
+	// Chunks lists the files that were rewritten
+	Chunks    []string          `json:"chunks"`
+	Conflicts []Conflict        `json:"conflicts"`
+	Failed    map[string]string `json:"failed"`
+}
+
+func (p *Patch) apply(content string) (string, bool) {
+	if p.Regex {
+		re := regexp.MustCompile(p.Find)
+		if !re.MatchString(content) {
+			return content, false
+		}
+		return re.ReplaceAllString(content, p.Replace), true
+	}
+	if !strings.Contains(content, p.Find) {
+		return content, false
+	}
+	return strings.ReplaceAll(content, p.Find, p.Replace), true
+}
+
+func listChunks(dir string) ([]string, error) {
+	chunks := []string{}
+	err := fsys.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		chunks = append(chunks, filepath.ToSlash(rel))
+		return nil
+	})
+	return chunks, err
+}
+
+// Apply applies mixins in order to the client extracted in dir. A mixin is applied entirely or not at all:
+// when one of its patches matches nothing, its module is reported in Result.Failed and the others go on
+func Apply(dir string, mixins []Mixin) (Result, error) {
+	result := Result{Chunks: []string{}, Conflicts: []Conflict{}, Failed: map[string]string{}}
+	chunks, err := listChunks(dir)
+	if err != nil {
+		return result, err
+	}
+
+	contents := map[string]string{}
+	read := func(chunk string) (string, error) {
+		if content, ok := contents[chunk]; ok {
+			return content, nil
+		}
+		raw, err := fsys.ReadFile(filepath.Join(dir, filepath.FromSlash(chunk)))
+		return string(raw), err
+	}
+
+	patchedBy := map[string][]string{}
+	for _, mixin := range mixins {
+		rewritten := map[string]string{}
+		var failure string
+		for _, patch := range mixin.Patches {
+			matched := false
+			for _, chunk := range chunks {
+				if ok, _ := path.Match(strings.TrimPrefix(patch.Chunk, "./"), chunk); !ok {
+					continue
+				}
+				content, ok := rewritten[chunk]
+				if !ok {
+					if content, err = read(chunk); err != nil {
+						return result, err
+					}
+				}
+				if content, ok = patch.apply(content); ok {
+					rewritten[chunk] = content
+					matched = true
+				}
+			}
+			if !matched {
+				failure = "nothing in " + patch.Chunk + " matches " + patch.Find
+				break
+			}
+		}
+		if failure != "" {
+			result.Failed[mixin.Module] = failure
+			continue
+		}
+
+		for chunk, content := range rewritten {
+			contents[chunk] = content
+			patchedBy[chunk] = append(patchedBy[chunk], mixin.Module)
+		}
+	}
+
+	for chunk, content := range contents {
+		if err := fsys.WriteFile(filepath.Join(dir, filepath.FromSlash(chunk)), []byte(content), 0644); err != nil {
+			return result, err
+		}
+		result.Chunks = append(result.Chunks, chunk)
+		if modules := patchedBy[chunk]; len(modules) > 1 {
+			result.Conflicts = append(result.Conflicts, Conflict{Chunk: chunk, Modules: modules})
+		}
+	}
+	slices.Sort(result.Chunks)
+	slices.SortFunc(result.Conflicts, func(a, b Conflict) int { return strings.Compare(a.Chunk, b.Chunk) })
+	return result, nil
+}
@@ -0,0 +1,100 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+)
+
+// Action is a maintenance operation a module exposes to its users (e.g. clear-cache), run with `bespoke pkg run`
+type Action struct {
+	Description string `json:"description,omitempty"`
+	// Run is a command run in the store folder of the module, like the lifecycle scripts
+	Run string `json:"run,omitempty"`
+	// Rpc is a message sent by the daemon to the module running in Spotify
+	Rpc string `json:"rpc,omitempty"`
+}
+
+func (m *Metadata) invalidActions() []string {
+	problems := []string{}
+	for name, action := range m.Actions {
+		if (action.Run == "") == (action.Rpc == "") {
+			problems = append(problems, "action "+name+" must set either run or rpc")
+		}
+	}
+	return problems
+}
+
+// ModuleActions lists the actions declared by an installed module
+func ModuleActions(identifier StoreIdentifier) (map[string]Action, error) {
+	metadata, err := readStoreMetadata(identifier)
+	if err != nil {
+		return nil, errors.New(identifier.String() + " isn't installed")
+	}
+	return metadata.Actions, nil
+}
+
+func FindAction(identifier StoreIdentifier, name string) (Action, error) {
+	actions, err := ModuleActions(identifier)
+	if err != nil {
+		return Action{}, err
+	}
+	action, ok := actions[name]
+	if !ok {
+		names := make([]string, 0, len(actions))
+		for name := range actions {
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			return Action{}, errors.New(identifier.String() + " has no actions")
+		}
+		slices.Sort(names)
+		return Action{}, errors.New(identifier.String() + " has no action " + name + ", available: " + strings.Join(names, ", "))
+	}
+	return action, nil
+}
+
+// RunAction runs the command of an action, with the same restrictions as the lifecycle scripts
+func RunAction(identifier StoreIdentifier, name string, action Action) ([]byte, error) {
+	if action.Run == "" {
+		return nil, errors.New(name + " is sent to " + identifier.String() + " in Spotify by the daemon, it has no command to run")
+	}
+	if !scriptsAllowed(identifier, isVerifiedStore(identifier)) {
+		return nil, errors.New("the " + name + " action of " + identifier.String() + " runs a command, allow scripts or trust its author to run it: " + action.Run)
+	}
+	if skip("run the %s action of %s: %s", name, identifier, action.Run) {
+		return nil, nil
+	}
+
+	log.Println("Running the", name, "action of", identifier.String())
+	// Scripts run in the store folder
+	if err := decompressStore(identifier); err != nil {
+		return nil, err
+	}
+	return runModuleScript(identifier, identifier.toFilePath(), "action-"+name, action.Run)
+}
+
+// ActionMessage is the message the daemon sends to the Spotify clients for an rpc action
+func ActionMessage(identifier StoreIdentifier, action Action) string {
+	return "bespoke:action:" + identifier.String() + ":" + action.Rpc
+}
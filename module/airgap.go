@@ -0,0 +1,128 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/archive"
+	"bespoke/fsys"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// LocalRemote marked the modules installed from an archive provided locally in the vaults written before
+// Store.Local tracked it per version, it isn't a remote fetchRepoIndex can read
+const LocalRemote = "local"
+
+// isLocalVersion reports whether the version was installed from metadata and an archive provided locally
+// (air-gapped installs), it has no source to be upgraded from
+func isLocalVersion(module *Module, version Version) bool {
+	store, ok := module.V[version]
+	return ok && store.Local
+}
+
+// InstallModuleArchive installs a module from its metadata and an archive of its files (a bundle written by
+// `dev bundle` or an archive of its folder), without any network access
+func InstallModuleArchive(rawMetadata []byte, archivePath string) error {
+	metadata, err := parseMetadata(bytes.NewReader(rawMetadata))
+	if err != nil {
+		return errors.New("invalid metadata: " + err.Error())
+	}
+	if problems := metadata.missingFields(); len(problems) > 0 {
+		return errors.New("invalid metadata: " + strings.Join(problems, ", "))
+	}
+
+	tmp, moduleDir, err := extractSourceArchive(archivePath)
+	if tmp != "" {
+		defer fsys.RemoveAll(tmp)
+	}
+	if err != nil {
+		return err
+	}
+
+	metadataPath := filepath.Join(moduleDir, "metadata.json")
+	if archived, err := fetchLocalMetadata(metadataPath); err == nil {
+		if archived.getStoreIdentifier() != metadata.getStoreIdentifier() {
+			return errors.New(archivePath + " holds " + archived.getStoreIdentifier().String() + ", not " + metadata.getStoreIdentifier().String())
+		}
+	}
+	if err := fsys.WriteFile(metadataPath, rawMetadata, 0644); err != nil {
+		return err
+	}
+
+	if err := installModuleDir(archivePath, moduleDir, metadata, ""); err != nil {
+		return err
+	}
+	if DryRun {
+		return nil
+	}
+
+	// The archive isn't a source upgrades can be fetched from
+	identifier := metadata.getStoreIdentifier()
+	return MutateVault(func(vault *Vault) bool {
+		module := vault.getModule(identifier.ModuleIdentifier.toPath())
+		store := module.V[identifier.Version]
+		store.Metadatas = []RemoteURL{}
+		store.Local = true
+		module.V[identifier.Version] = store
+		vault.setModule(identifier.ModuleIdentifier.toPath(), module)
+		return true
+	})
+}
+
+// extractSourceArchive scans and extracts an archive into a temporary folder, which the caller must remove.
+// The files of the module are either at the root of the archive or in its only folder (like GitHub archives)
+func extractSourceArchive(archivePath string) (tmp string, moduleDir string, err error) {
+	file, err := fsys.Open(archivePath)
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	body, cleanup, err := scanArchive(file, archivePath)
+	if err != nil {
+		return "", "", err
+	}
+	defer cleanup()
+
+	tmp, err = fsys.MkdirTemp(os.TempDir(), "bespoke-archive-")
+	if err != nil {
+		return "", "", err
+	}
+	// The ./ entry of archives made with `tar -C dir .` isn't extracted
+	filter := archive.Filter{Extracted: trackExtraction(StoreIdentifier{}, archivePath)}
+	if err := archive.UnTarGZ(body, regexp.MustCompile(`^(?:\./)?(.*[^./].*)$`), tmp, filter); err != nil {
+		return tmp, "", errors.New("can't extract " + archivePath + ": " + err.Error())
+	}
+
+	moduleDir = tmp
+	if entries, err := os.ReadDir(tmp); err == nil && len(entries) == 1 && entries[0].IsDir() {
+		moduleDir = filepath.Join(tmp, entries[0].Name())
+	}
+	if _, err := os.Stat(filepath.Join(moduleDir, checksumsFile)); err == nil {
+		if err := verifyBundle(moduleDir); err != nil {
+			return tmp, "", err
+		}
+	}
+	return tmp, moduleDir, nil
+}
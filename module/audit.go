@@ -0,0 +1,189 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/network"
+	"bespoke/version"
+	"encoding/json"
+	"errors"
+	"slices"
+)
+
+const (
+	AdvisoryVulnerability = "vulnerability"
+	AdvisoryRetracted     = "retracted"
+	AdvisoryMalicious     = "malicious"
+)
+
+// Advisory is an entry of the advisory feed a registry publishes at Registry.Advisories
+type Advisory struct {
+	ID     string              `json:"id"`
+	Module ModuleIdentifierStr `json:"module"`
+	// Versions is the range of affected versions, every version is affected when it is empty
+	Versions string `json:"versions"`
+	// Kind is vulnerability, retracted or malicious
+	Kind string `json:"kind"`
+	// Severity is low, moderate, high or critical
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+}
+
+type AdvisoryFeed struct {
+	Advisories []Advisory `json:"advisories"`
+}
+
+func (a *Advisory) affects(v Version) (bool, error) {
+	if a.Versions == "" {
+		return true, nil
+	}
+	c, err := version.ParseConstraint(a.Versions)
+	if err != nil {
+		return false, err
+	}
+	return c.Check(string(v)), nil
+}
+
+// AuditFinding is an installed version affected by an advisory
+type AuditFinding struct {
+	Module   ModuleIdentifierStr `json:"module"`
+	Version  Version             `json:"version"`
+	Enabled  bool                `json:"enabled"`
+	Registry string              `json:"registry"`
+	Advisory Advisory            `json:"advisory"`
+	// Recommended is the newest published version no advisory affects, the module should be removed when it is empty
+	Recommended    Version `json:"recommended,omitempty"`
+	recommendedURL RemoteURL
+	// unlisted is set when some published versions couldn't be listed, a missing recommendation doesn't mean
+	// that no version fixes the advisory
+	unlisted bool
+}
+
+type registryAdvisory struct {
+	registry string
+	Advisory
+}
+
+// fetchAdvisories reads the advisory feeds of the trusted registries, keeping the advisories of the modules each
+// registry indexes: a registry can't retract or flag the modules of another one
+func fetchAdvisories() ([]registryAdvisory, []error) {
+	advisories := []registryAdvisory{}
+	errs := []error{}
+	for _, registry := range sortedRegistries() {
+		if registry.Advisories == "" || !registry.Trusted {
+			continue
+		}
+		index, err := fetchRegistryIndex(registry)
+		if err != nil {
+			errs = append(errs, errors.New("can't read the modules indexed by registry "+registry.Name+" to check its advisories: "+err.Error()))
+			continue
+		}
+		raw, err := network.GetCached(registry.Advisories)
+		if err != nil {
+			errs = append(errs, errors.New("can't fetch the advisories of registry "+registry.Name+": "+err.Error()))
+			continue
+		}
+		var feed AdvisoryFeed
+		if err := json.Unmarshal(raw, &feed); err != nil {
+			errs = append(errs, errors.New("invalid advisories of registry "+registry.Name+": "+err.Error()))
+			continue
+		}
+		for _, advisory := range feed.Advisories {
+			if _, ok := index.Modules[advisory.Module]; !ok {
+				continue
+			}
+			advisories = append(advisories, registryAdvisory{registry.Name, advisory})
+		}
+	}
+	return advisories, errs
+}
+
+// Audit cross-references the installed versions with the advisory feeds of the registries
+func Audit() ([]AuditFinding, []error) {
+	vault, err := GetVault()
+	if err != nil {
+		return nil, []error{err}
+	}
+	advisories, errs := fetchAdvisories()
+
+	findings := []AuditFinding{}
+	for _, identifier := range vault.OrderedModules() {
+		module := vault.Modules[identifier]
+		relevant := slices.DeleteFunc(slices.Clone(advisories), func(advisory registryAdvisory) bool {
+			return advisory.Module != identifier
+		})
+		if len(relevant) == 0 {
+			continue
+		}
+
+		affected := func(v Version) bool {
+			for _, advisory := range relevant {
+				if ok, err := advisory.affects(v); ok || err != nil {
+					return true
+				}
+			}
+			return false
+		}
+		published, fetchErrs := publishedVersions(identifier, &module)
+		errs = append(errs, fetchErrs...)
+		recommended, recommendedURL := latestPublished(published, func(v Version) bool {
+			return !affected(v)
+		})
+
+		for _, v := range module.versions() {
+			for _, advisory := range relevant {
+				ok, err := advisory.affects(v)
+				if err != nil {
+					errs = append(errs, errors.New("invalid range of advisory "+advisory.ID+" of registry "+advisory.registry+": "+err.Error()))
+					continue
+				}
+				if ok {
+					findings = append(findings, AuditFinding{identifier, v, module.Enabled == v, advisory.registry, advisory.Advisory, recommended, recommendedURL, len(fetchErrs) > 0})
+				}
+			}
+		}
+	}
+	return findings, errs
+}
+
+// AuditFixes lists the upgrades moving the enabled versions affected by an advisory to their recommended version,
+// and the enabled modules no published version fixes. Modules whose published versions couldn't all be listed
+// are left out of both
+func AuditFixes(findings []AuditFinding) ([]Upgrade, []ModuleIdentifier) {
+	upgrades := []Upgrade{}
+	unfixable := []ModuleIdentifier{}
+	seen := map[ModuleIdentifierStr]bool{}
+	for _, finding := range findings {
+		if !finding.Enabled || seen[finding.Module] {
+			continue
+		}
+		seen[finding.Module] = true
+		identifier := NewModuleIdentifier(string(finding.Module))
+		if finding.Recommended == "" && finding.unlisted {
+			continue
+		}
+		if finding.Recommended == "" {
+			unfixable = append(unfixable, identifier)
+			continue
+		}
+		upgrades = append(upgrades, Upgrade{identifier, finding.Version, finding.Recommended, finding.recommendedURL})
+	}
+	return upgrades, unfixable
+}
@@ -0,0 +1,103 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+package module
+
+import (
+	"bespoke/network"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	mux := http.NewServeMux()
+	serve := func(path string, body string) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(body))
+		})
+	}
+	serve("/main/index.json", `{"modules": {"a/one": {"versions": {"1.0.0": "https://example.com/1.0.0/metadata.json", "2.0.0": "https://example.com/2.0.0/metadata.json"}}}}`)
+	serve("/main/advisories.json", `{"advisories": [
+		{"id": "BSA-1", "module": "a/one", "versions": "<2.0.0", "kind": "vulnerability"},
+		{"id": "BSA-2", "module": "b/two", "kind": "malicious"}
+	]}`)
+	serve("/other/index.json", `{"modules": {"a/one": {"versions": {}}, "b/two": {"versions": {}}}}`)
+	serve("/other/advisories.json", `{"advisories": [{"id": "EVIL-1", "module": "b/two", "kind": "malicious"}]}`)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		remotes   []string
+		findings  []string
+		upgrades  int
+		unfixable int
+		errs      int
+	}{
+		{"trusted advisories of indexed modules", nil, []string{"a/one 1.0.0 BSA-1 2.0.0"}, 1, 0, 0},
+		{"unlisted versions", []string{server.URL + "/missing/index.json"}, []string{"a/one 1.0.0 BSA-1 2.0.0"}, 1, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFs(t)
+			registries, retries := Registries, network.Retries
+			t.Cleanup(func() { Registries, network.Retries = registries, retries })
+			network.Retries = 0
+			Registries = []Registry{
+				{Name: "main", URL: server.URL + "/main/index.json", Trusted: true, Advisories: server.URL + "/main/advisories.json"},
+				{Name: "other", URL: server.URL + "/other/index.json", Priority: 1, Advisories: server.URL + "/other/advisories.json"},
+			}
+			vault := &Vault{Modules: map[ModuleIdentifierStr]Module{
+				"a/one": {Enabled: "1.0.0", V: map[Version]Store{"1.0.0": {Installed: true}}, Remotes: tt.remotes},
+				"b/two": {Enabled: "1.0.0", V: map[Version]Store{"1.0.0": {Installed: true}}},
+			}}
+			if err := SetVault(vault); err != nil {
+				t.Fatal(err)
+			}
+
+			findings, errs := Audit()
+			if len(errs) != tt.errs {
+				t.Errorf("Audit() returned %d errors, want %d: %v", len(errs), tt.errs, errs)
+			}
+			got := []string{}
+			for _, finding := range findings {
+				got = append(got, string(finding.Module)+" "+string(finding.Version)+" "+finding.Advisory.ID+" "+string(finding.Recommended))
+			}
+			if len(got) != len(tt.findings) || (len(got) > 0 && got[0] != tt.findings[0]) {
+				t.Errorf("Audit() = %v, want %v", got, tt.findings)
+			}
+			upgrades, unfixable := AuditFixes(findings)
+			if len(upgrades) != tt.upgrades || len(unfixable) != tt.unfixable {
+				t.Errorf("AuditFixes() = %v, %v, want %d upgrades and %d unfixable", upgrades, unfixable, tt.upgrades, tt.unfixable)
+			}
+		})
+	}
+}
+
+func TestAuditFixesUnlisted(t *testing.T) {
+	findings := []AuditFinding{
+		{Module: "a/one", Version: "1.0.0", Enabled: true, unlisted: true},
+		{Module: "b/two", Version: "1.0.0", Enabled: true},
+	}
+	upgrades, unfixable := AuditFixes(findings)
+	if len(upgrades) != 0 || len(unfixable) != 1 || unfixable[0].String() != "b/two" {
+		t.Errorf("AuditFixes() = %v, %v, want only b/two unfixable", upgrades, unfixable)
+	}
+}
@@ -0,0 +1,241 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"bytes"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Every vault change is preceded by a backup of the vault (which holds the settings of each module too) and of
+// the config file, unless they didn't change since the last backup. Old backups are rotated out
+
+var (
+	// BackupsKeep is how many backups are kept, 0 disables backups
+	BackupsKeep = 20
+	// BackupsMaxAge removes the backups older than this, 0 keeps them regardless of their age
+	BackupsMaxAge = 30 * 24 * time.Hour
+	// ConfigFile is bespoke's config file, backed up along with the vault
+	ConfigFile string
+)
+
+// backupIdLayout sorts backups chronologically and avoids the characters Windows forbids in file names
+const backupIdLayout = "20060102-150405.000"
+
+type Backup struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Config is set when the backup holds the config file
+	Config bool `json:"config"`
+}
+
+func (b *Backup) folder() string {
+	return filepath.Join(backupsFolder, b.ID)
+}
+
+// ListBackups returns the backups, most recent first
+func ListBackups() ([]Backup, error) {
+	entries, err := fsys.ReadDir(backupsFolder)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Backup{}, nil
+		}
+		return nil, err
+	}
+
+	backups := []Backup{}
+	for _, entry := range entries {
+		t, err := time.ParseInLocation(backupIdLayout, entry.Name(), time.Local)
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		backup := Backup{ID: entry.Name(), Time: t}
+		if _, err := fsys.Stat(filepath.Join(backup.folder(), filepath.Base(ConfigFile))); err == nil && ConfigFile != "" {
+			backup.Config = true
+		}
+		backups = append(backups, backup)
+	}
+	slices.Reverse(backups)
+	return backups, nil
+}
+
+// FindBackup returns the backup with the given id, or the only one starting with it (e.g. 20240131-12)
+func FindBackup(id string) (Backup, error) {
+	backups, err := ListBackups()
+	if err != nil {
+		return Backup{}, err
+	}
+	matches := []Backup{}
+	for _, backup := range backups {
+		if backup.ID == id {
+			return backup, nil
+		}
+		if strings.HasPrefix(backup.ID, id) {
+			matches = append(matches, backup)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Backup{}, errors.New("no backup matches " + id + ", list them with `bespoke vault backups`")
+	case 1:
+		return matches[0], nil
+	}
+	return Backup{}, errors.New(id + " matches several backups, from " + matches[len(matches)-1].ID + " to " + matches[0].ID)
+}
+
+// backupVault copies the vault and the config file before they change, errors are only logged so that a full
+// disk doesn't prevent fixing things
+func backupVault() {
+	if BackupsKeep <= 0 {
+		return
+	}
+	if err := writeBackup(); err != nil {
+		log.Println("Can't back up the vault:", err.Error())
+	}
+	pruneBackups()
+}
+
+func writeBackup() error {
+	vaultJson, err := fsys.ReadFile(vaultPath)
+	if err != nil {
+		// Nothing to back up before the first install
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var configYaml []byte
+	if ConfigFile != "" {
+		configYaml, _ = fsys.ReadFile(ConfigFile)
+	}
+
+	if backups, err := ListBackups(); err == nil && len(backups) > 0 {
+		latest := backups[0].folder()
+		previousVault, _ := fsys.ReadFile(filepath.Join(latest, filepath.Base(vaultPath)))
+		var previousConfig []byte
+		if ConfigFile != "" {
+			previousConfig, _ = fsys.ReadFile(filepath.Join(latest, filepath.Base(ConfigFile)))
+		}
+		if bytes.Equal(previousVault, vaultJson) && bytes.Equal(previousConfig, configYaml) {
+			return nil
+		}
+	}
+
+	backup := Backup{ID: time.Now().Format(backupIdLayout)}
+	if err := fsys.MkdirAll(backup.folder(), os.ModePerm); err != nil {
+		return err
+	}
+	if err := fsys.WriteFile(filepath.Join(backup.folder(), filepath.Base(vaultPath)), vaultJson, 0600); err != nil {
+		return err
+	}
+	if configYaml != nil {
+		return fsys.WriteFile(filepath.Join(backup.folder(), filepath.Base(ConfigFile)), configYaml, 0600)
+	}
+	return nil
+}
+
+// pruneBackups removes the backups beyond BackupsKeep and those older than BackupsMaxAge, the most recent backup
+// is always kept
+func pruneBackups() {
+	backups, err := ListBackups()
+	if err != nil {
+		return
+	}
+	for i, backup := range backups {
+		if i == 0 {
+			continue
+		}
+		if i >= BackupsKeep || (BackupsMaxAge > 0 && time.Since(backup.Time) > BackupsMaxAge) {
+			fsys.RemoveAll(backup.folder())
+		}
+	}
+}
+
+// RestoreBackup replaces the vault (and the config file when the backup holds it) with a backup. The current
+// state is backed up first, and the restore can be undone. It returns the enabled versions missing from the store
+func RestoreBackup(backup Backup) ([]StoreIdentifier, error) {
+	vault, err := readVaultFile(filepath.Join(backup.folder(), filepath.Base(vaultPath)))
+	if err != nil {
+		return nil, err
+	}
+	if vault.Modules == nil {
+		vault.Modules = map[ModuleIdentifierStr]Module{}
+	}
+
+	// The policy may have changed since the backup, its modules are checked like those enabled by hand
+	for identifierStr, module := range vault.Modules {
+		if module.Enabled == "" {
+			continue
+		}
+		identifier := StoreIdentifier{NewModuleIdentifier(string(identifierStr)), module.Enabled}
+		if err := ActivePolicy.CheckModule(identifier, module.V[module.Enabled].Verified); err != nil {
+			return nil, err
+		}
+	}
+
+	missing := []StoreIdentifier{}
+	for identifierStr, module := range vault.Modules {
+		if module.Enabled == "" {
+			continue
+		}
+		identifier := StoreIdentifier{NewModuleIdentifier(string(identifierStr)), module.Enabled}
+		if isCompressed(identifier) {
+			if err := decompressStore(identifier); err != nil {
+				return missing, err
+			}
+		}
+		if _, err := fsys.Stat(identifier.toFilePath()); err != nil {
+			missing = append(missing, identifier)
+		}
+	}
+
+	// Setting the vault backs it up and may prune this backup, so its config file is read first
+	var configYaml, previousConfig []byte
+	if backup.Config {
+		if configYaml, err = fsys.ReadFile(filepath.Join(backup.folder(), filepath.Base(ConfigFile))); err != nil {
+			return missing, err
+		}
+		// Undoing the restore brings the config file back too
+		previousConfig, _ = fsys.ReadFile(ConfigFile)
+	}
+
+	before := snapshotVault()
+	if err := SetVault(vault); err != nil {
+		return missing, err
+	}
+	if !stagedModules() {
+		if _, err := repairSymlinks(vault, DryRun); err != nil {
+			return missing, err
+		}
+	}
+	if backup.Config && !skip("restore %s from backup %s", ConfigFile, backup.ID) {
+		if err := writeVaultFile(ConfigFile, configYaml); err != nil {
+			return missing, err
+		}
+	}
+	return missing, recordWithConfig(OpRestore, backup.ID, before, previousConfig)
+}
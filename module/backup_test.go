@@ -0,0 +1,101 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestBackup(t *testing.T, vaultJson, configYaml string) Backup {
+	t.Helper()
+	backup := Backup{ID: "20240101-000000.000", Config: true}
+	if err := fsys.MkdirAll(backup.folder(), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile(filepath.Join(backup.folder(), filepath.Base(vaultPath)), []byte(vaultJson), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile(filepath.Join(backup.folder(), filepath.Base(ConfigFile)), []byte(configYaml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return backup
+}
+
+func useConfigFile(t *testing.T, content string) {
+	t.Helper()
+	previous := ConfigFile
+	t.Cleanup(func() { ConfigFile = previous })
+	ConfigFile = filepath.Join(t.TempDir(), "config.yaml")
+	if err := fsys.WriteFile(ConfigFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUndoRestoreBringsConfigBack(t *testing.T) {
+	useMemFs(t)
+	useConfigFile(t, "current: true\n")
+	if err := SetVault(&Vault{Modules: map[ModuleIdentifierStr]Module{}}); err != nil {
+		t.Fatal(err)
+	}
+	backup := writeTestBackup(t, `{"modules":{}}`, "restored: true\n")
+
+	if _, err := RestoreBackup(backup); err != nil {
+		t.Fatal(err)
+	}
+	if config, _ := fsys.ReadFile(ConfigFile); string(config) != "restored: true\n" {
+		t.Fatalf("the config file wasn't restored: %q", config)
+	}
+
+	entry, err := Undo("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Operation != OpRestore {
+		t.Fatalf("undid %s, want %s", entry.Operation, OpRestore)
+	}
+	if config, _ := fsys.ReadFile(ConfigFile); string(config) != "current: true\n" {
+		t.Errorf("undoing the restore left the config file as %q", config)
+	}
+}
+
+func TestRestoreBackupChecksPolicy(t *testing.T) {
+	useMemFs(t)
+	useConfigFile(t, "current: true\n")
+	previous := ActivePolicy
+	t.Cleanup(func() { ActivePolicy = previous })
+	ActivePolicy = Policy{BlockedIdentifiers: []string{"a/blocked"}}
+	if err := SetVault(&Vault{Modules: map[ModuleIdentifierStr]Module{}}); err != nil {
+		t.Fatal(err)
+	}
+	backup := writeTestBackup(t, `{"modules":{"a/blocked":{"enabled":"1.0.0","v":{}}}}`, "restored: true\n")
+
+	_, err := RestoreBackup(backup)
+	var violation *PolicyViolationError
+	if !errors.As(err, &violation) {
+		t.Fatalf("restoring a blocked module returned %v, want a policy violation", err)
+	}
+	if config, _ := fsys.ReadFile(ConfigFile); string(config) != "current: true\n" {
+		t.Errorf("the config file was restored despite the violation: %q", config)
+	}
+}
@@ -0,0 +1,197 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/archive"
+	"bespoke/mixin"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const checksumsFile = "SHA256SUMS"
+
+func (m *Metadata) Validate(moduleDir string) error {
+	problems := m.missingFields()
+	for _, entry := range m.entryFiles() {
+		if _, err := os.Stat(filepath.Join(moduleDir, filepath.FromSlash(entry))); err != nil {
+			problems = append(problems, "entry "+entry+" doesn't exist")
+		}
+	}
+	for _, entry := range m.mixinFiles() {
+		if raw, err := os.ReadFile(filepath.Join(moduleDir, filepath.FromSlash(entry))); err == nil {
+			if _, err := mixin.Parse(m.getModuleIdentifier().String(), raw); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New("invalid metadata: " + strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// entryFiles lists the entries of every platform
+func (m *Metadata) entryFiles() []string {
+	entries := m.Entries.files()
+	for _, platform := range m.Platforms {
+		entries = append(entries, platform.Entries.files()...)
+	}
+	slices.Sort(entries)
+	return slices.Compact(entries)
+}
+
+// mixinFiles lists the mixin entries of every platform
+func (m *Metadata) mixinFiles() []string {
+	entries := []Entries{m.Entries}
+	for _, platform := range m.Platforms {
+		entries = append(entries, platform.Entries)
+	}
+	mixins := []string{}
+	for _, e := range entries {
+		if e.Mixin != "" {
+			mixins = append(mixins, path.Clean(strings.TrimPrefix(e.Mixin, "./")))
+		}
+	}
+	return mixins
+}
+
+func (e *Entries) files() []string {
+	entries := []string{}
+	for _, entry := range []string{e.Js, e.Css, e.Mixin} {
+		if entry != "" {
+			entries = append(entries, path.Clean(strings.TrimPrefix(entry, "./")))
+		}
+	}
+	return entries
+}
+
+func runScript(dir string, script string) error {
+	cmd := scriptCommand(Context, script)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// bundleFiles lists metadata.json (and its signature), the entries and every file matching the assets patterns,
+// for all platforms
+func bundleFiles(moduleDir string, metadata *Metadata) ([]string, error) {
+	files := append([]string{"metadata.json"}, metadata.entryFiles()...)
+	if _, err := os.Stat(filepath.Join(moduleDir, "metadata.json"+signatureSuffix)); err == nil {
+		files = append(files, "metadata.json"+signatureSuffix)
+	}
+	assets := slices.Clone(metadata.Assets)
+	for _, platform := range metadata.Platforms {
+		assets = append(assets, platform.Assets...)
+	}
+
+	err := filepath.WalkDir(moduleDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(moduleDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		for _, pattern := range assets {
+			if matchAsset(pattern, rel) {
+				files = append(files, rel)
+				break
+			}
+		}
+		return nil
+	})
+
+	slices.Sort(files)
+	return slices.Compact(files), err
+}
+
+// checksums lists the hashes of files in the format of sha256sum, to be archived along with them rather than
+// written into the module folder
+func checksums(moduleDir string, files []string) (archive.File, error) {
+	sums := strings.Builder{}
+	for _, file := range files {
+		hash, err := hashFile(filepath.Join(moduleDir, filepath.FromSlash(file)))
+		if err != nil {
+			return archive.File{}, err
+		}
+		sums.WriteString(hash + "  " + file + "\n")
+	}
+	return archive.File{Name: checksumsFile, Data: []byte(sums.String())}, nil
+}
+
+// Bundle packages the module in moduleDir into <outDir>/<name>-<version>.tar.gz (and a .sha256 sidecar)
+func Bundle(moduleDir string, outDir string, build bool) (string, error) {
+	metadata, err := fetchLocalMetadata(filepath.Join(moduleDir, "metadata.json"))
+	if err != nil {
+		return "", err
+	}
+
+	if build && metadata.Scripts.Build != "" {
+		log.Println("Running build script:", metadata.Scripts.Build)
+		if err := runScript(moduleDir, metadata.Scripts.Build); err != nil {
+			return "", err
+		}
+	}
+
+	if err := metadata.Validate(moduleDir); err != nil {
+		return "", err
+	}
+
+	files, err := bundleFiles(moduleDir, &metadata)
+	if err != nil {
+		return "", err
+	}
+	// A stale list left in the module folder is replaced by the generated one
+	files = slices.DeleteFunc(files, func(file string) bool { return file == checksumsFile })
+	sums, err := checksums(moduleDir, files)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return "", err
+	}
+	artifact := filepath.Join(outDir, metadata.Name+"-"+metadata.Version+".tar.gz")
+	out, err := os.Create(artifact)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close()
+
+	h := sha256.New()
+	if err := archive.TarGZ(io.MultiWriter(out, h), moduleDir, files, sums); err != nil {
+		return "", err
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil)) + "  " + filepath.Base(artifact) + "\n"
+	return artifact, os.WriteFile(artifact+".sha256", []byte(sum), 0644)
+}
@@ -0,0 +1,70 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestBundleLeavesModuleUntouched(t *testing.T) {
+	moduleDir, outDir := t.TempDir(), t.TempDir()
+	metadata := `{"name": "one", "version": "1.0.0", "authors": ["a"], "entries": {"js": "index.js"}}`
+	if err := os.WriteFile(filepath.Join(moduleDir, "metadata.json"), []byte(metadata), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(moduleDir, "index.js"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	artifact, err := Bundle(moduleDir, outDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(moduleDir, checksumsFile)); !os.IsNotExist(err) {
+		t.Errorf("%s was written into the module folder", checksumsFile)
+	}
+
+	file, err := os.Open(artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, header.Name)
+	}
+	if want := []string{"index.js", "metadata.json", checksumsFile}; !slices.Equal(names, want) {
+		t.Errorf("bundled %v, want %v", names, want)
+	}
+}
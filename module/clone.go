@@ -0,0 +1,186 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"bespoke/link"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DevVersion is the version of the modules linked from a working copy with LinkModuleDev
+const DevVersion Version = "dev"
+
+// cloneSource is where the source of an installed module can be cloned from
+type cloneSource struct {
+	repo string
+	ref  string
+	path string
+}
+
+func findCloneSource(murl string, commit string) (cloneSource, bool) {
+	source := cloneSource{ref: commit}
+	switch {
+	case IsGitSource(murl):
+		gs, err := ParseGitSource(murl)
+		if err != nil {
+			return source, false
+		}
+		source.repo, source.path = gs.Repo, gs.Path
+		if source.ref == "" {
+			source.ref = gs.Ref
+		}
+	case IsReleaseSource(murl):
+		rs, err := ParseReleaseSource(murl)
+		if err != nil {
+			return source, false
+		}
+		source.repo = "https://github.com/" + rs.Owner + "/" + rs.Repo + ".git"
+		if source.ref == "" {
+			source.ref = rs.Tag
+		}
+	default:
+		parts := githubRawRe.FindStringSubmatch(murl)
+		if parts == nil {
+			return source, false
+		}
+		source.repo = "https://github.com/" + parts[1] + "/" + parts[2] + ".git"
+		source.path = parts[4]
+		if source.ref == "" {
+			source.ref = parts[3]
+		}
+	}
+	return source, true
+}
+
+// installedStore finds an installed version of a module, the enabled one when identifier has no version
+func installedStore(vault *Vault, identifier StoreIdentifier) (StoreIdentifier, Store, error) {
+	module, ok := vault.Modules[identifier.ModuleIdentifier.toPath()]
+	if !ok {
+		return identifier, Store{}, errors.New(identifier.ModuleIdentifier.String() + " isn't installed")
+	}
+	if identifier.Version == "" {
+		identifier.Version = module.Enabled
+	}
+	store, ok := module.V[identifier.Version]
+	if !ok || identifier.Version == "" {
+		return identifier, Store{}, errors.New("can't find an installed version of " + identifier.String())
+	}
+	return identifier, store, nil
+}
+
+// CloneModule fetches the source of an installed version (the enabled one when identifier has no version) into
+// dir, checked out at the installed commit, and returns the folder of the module inside it. Modules that don't
+// come from a git repository are copied from the store into a new repository instead
+func CloneModule(identifier StoreIdentifier, dir string) (string, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return "", err
+	}
+	identifier, store, err := installedStore(vault, identifier)
+	if err != nil {
+		return "", err
+	}
+	if len(store.Metadatas) == 0 {
+		return "", errors.New(identifier.String() + " was installed from a local path, its source is already on disk")
+	}
+
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return "", errors.New(dir + " already exists and isn't empty")
+	}
+
+	source, ok := findCloneSource(store.Metadatas[0], store.Commit)
+	if !ok {
+		return dir, copyModuleSource(identifier, dir)
+	}
+
+	// The ref is passed to git checkout, which would take it for an option
+	if strings.HasPrefix(source.ref, "-") {
+		return "", errors.New("invalid ref " + source.ref + " for " + identifier.String())
+	}
+	if err := git("", "clone", "--quiet", "--", source.repo, dir); err != nil {
+		return "", errors.New("git clone failed: " + err.Error())
+	}
+	if source.ref != "" {
+		if err := git(dir, "checkout", "--quiet", source.ref); err != nil {
+			return "", errors.New("git checkout failed: " + err.Error())
+		}
+	}
+	return filepath.Join(dir, filepath.FromSlash(source.path)), nil
+}
+
+// copyModuleSource initializes a repository holding the installed files of a module
+func copyModuleSource(identifier StoreIdentifier, dir string) error {
+	root, cleanup, err := storeRoot(identifier)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	if err := link.CopyDir(root, dir); err != nil {
+		return err
+	}
+	steps := [][]string{
+		{"init", "--quiet"},
+		{"add", "--all"},
+		{"commit", "--quiet", "--message", "Import " + identifier.String()},
+	}
+	for _, step := range steps {
+		if err := git(dir, step...); err != nil {
+			return errors.New("git " + step[0] + " failed: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// LinkModuleDev installs the module in moduleDir as its dev version, linked rather than copied to the store
+// so that edits are picked up without reinstalling it
+func LinkModuleDev(moduleDir string) (StoreIdentifier, error) {
+	moduleDir, err := filepath.Abs(moduleDir)
+	if err != nil {
+		return StoreIdentifier{}, err
+	}
+	metadata, err := fetchLocalMetadata(filepath.Join(moduleDir, "metadata.json"))
+	if err != nil {
+		return StoreIdentifier{}, err
+	}
+	metadata.Version = string(DevVersion)
+	identifier := metadata.getStoreIdentifier()
+
+	storePath := identifier.toFilePath()
+	if _, err := fsys.Lstat(storePath); err == nil {
+		if err := link.Remove(storePath); err != nil {
+			return identifier, err
+		}
+	}
+	if err := fsys.MkdirAll(filepath.Dir(storePath), 0755); err != nil {
+		return identifier, err
+	}
+	if err := fsys.Symlink(moduleDir, storePath); err != nil {
+		return identifier, err
+	}
+
+	return identifier, AddModuleInVault(&metadata, &Store{
+		Installed: true,
+		Metadatas: []string{},
+	})
+}
@@ -0,0 +1,165 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/network"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// SyncedVault is what `bespoke vault push` uploads: the freeze manifest of the enabled modules and their settings
+type SyncedVault struct {
+	Modules  []FrozenModule            `json:"modules"`
+	Settings map[string]ModuleSettings `json:"settings"`
+}
+
+// ModuleSettings holds the choices made for a module on one machine that should follow it to the others
+type ModuleSettings struct {
+	Scheme string `json:"scheme,omitempty"`
+}
+
+// ExportVault freezes the enabled modules along with their settings, see Freeze for the errors
+func ExportVault() (SyncedVault, []error) {
+	frozen, errs := Freeze()
+	synced := SyncedVault{Modules: frozen, Settings: map[string]ModuleSettings{}}
+
+	vault, err := GetVault()
+	if err != nil {
+		return synced, append(errs, err)
+	}
+	for _, m := range frozen {
+		identifier := NewStoreIdentifier(m.Module).ModuleIdentifier
+		if scheme := vault.Modules[identifier.toPath()].Scheme; scheme != "" {
+			synced.Settings[identifier.String()] = ModuleSettings{Scheme: scheme}
+		}
+	}
+	return synced, errs
+}
+
+// A sync remote is either "gist:<id>" (or "gist:" to create a new private gist) or the https URL of a
+// file on a WebDAV or S3 compatible endpoint, which is read with GET and written with PUT. Both
+// authenticate with the token configured for their host
+const gistPrefix = "gist:"
+
+// gistFile is the name of the vault in the gist
+const gistFile = "bespoke-vault.json"
+
+// PushVault uploads the exported vault to remote, and returns the remote to pull from afterwards, which
+// differs from remote when a gist was created
+func PushVault(remote string, synced *SyncedVault) (string, error) {
+	data, err := json.MarshalIndent(synced, "", "  ")
+	if err != nil {
+		return remote, err
+	}
+
+	if id, ok := strings.CutPrefix(remote, gistPrefix); ok {
+		gist := &github.Gist{Files: map[github.GistFilename]github.GistFile{
+			gistFile: {Content: github.String(string(data))},
+		}}
+		if id != "" {
+			_, _, err := client.Gists.Edit(Context, id, gist)
+			return remote, err
+		}
+		gist.Description = github.String("bespoke modules")
+		gist.Public = github.Bool(false)
+		created, _, err := client.Gists.Create(Context, gist)
+		if err != nil {
+			return remote, err
+		}
+		return gistPrefix + created.GetID(), nil
+	}
+
+	if err := checkSyncURL(remote); err != nil {
+		return remote, err
+	}
+	req, err := http.NewRequestWithContext(Context, http.MethodPut, remote, bytes.NewReader(data))
+	if err != nil {
+		return remote, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Bodies can't be replayed by network.Do's retries
+	res, err := network.Client.Do(req)
+	if err != nil {
+		return remote, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return remote, errors.New("couldn't upload the vault to " + remote + ": " + res.Status)
+	}
+	return remote, nil
+}
+
+// PullVault downloads the vault pushed to remote
+func PullVault(remote string) (SyncedVault, error) {
+	var synced SyncedVault
+	var data []byte
+
+	if id, ok := strings.CutPrefix(remote, gistPrefix); ok {
+		if id == "" {
+			return synced, errors.New("missing gist id, push first or set it as gist:<id>")
+		}
+		gist, _, err := client.Gists.Get(Context, id)
+		if err != nil {
+			return synced, err
+		}
+		file, ok := gist.Files[gistFile]
+		if !ok {
+			return synced, errors.New("gist " + id + " has no " + gistFile)
+		}
+		data = []byte(file.GetContent())
+	} else {
+		if err := checkSyncURL(remote); err != nil {
+			return synced, err
+		}
+		res, err := network.Get(remote)
+		if err != nil {
+			return synced, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return synced, errors.New("couldn't download the vault from " + remote + ": " + res.Status)
+		}
+		if data, err = io.ReadAll(res.Body); err != nil {
+			return synced, err
+		}
+	}
+
+	if err := json.Unmarshal(data, &synced); err != nil {
+		return synced, errors.New("invalid vault at " + remote + ": " + err.Error())
+	}
+	return synced, nil
+}
+
+func checkSyncURL(remote string) error {
+	if isPlainHTTP(remote) && !AllowHTTP {
+		return errors.New(remote + " isn't served over https, allow http to use it anyway")
+	}
+	if !strings.HasPrefix(remote, "https://") && !strings.HasPrefix(remote, "http://") {
+		return errors.New("unsupported remote " + remote + ", expected gist:<id> or an https URL")
+	}
+	return nil
+}
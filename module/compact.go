@@ -0,0 +1,336 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"archive/tar"
+	"bespoke/archive"
+	"bespoke/fsys"
+	"bespoke/trace"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"regexp"
+)
+
+// CompressDisabled keeps only the enabled version of modules extracted in the store, the others are
+// compressed into a tarball next to where their folder was and extracted again when they are enabled
+var CompressDisabled = true
+
+// blobEntryRe extracts every entry of a compressed store at its own path
+var blobEntryRe = regexp.MustCompile(`^(.+)$`)
+
+// blobExtension names the format of the compressed versions. They are gzipped tarballs rather than zstd ones,
+// which would take a dependency the build doesn't have; the extension tells the format so that blobs written
+// now are still recognized if the format changes
+const blobExtension = ".tar.gz"
+
+func (a storeArea) blobFilePath(si StoreIdentifier) string {
+	return a.filePath(si) + blobExtension
+}
+
+func (si *StoreIdentifier) toBlobFilePath() string {
+	return userStore().blobFilePath(*si)
+}
+
+func isCompressed(identifier StoreIdentifier) bool {
+	return userStore().isCompressed(identifier)
+}
+
+func (a storeArea) isCompressed(identifier StoreIdentifier) bool {
+	_, err := fsys.Lstat(a.blobFilePath(identifier))
+	return err == nil
+}
+
+// compressStore replaces the store folder of a version with a tarball and returns the disk space saved.
+// Links to working copies or to the system store are left alone
+func compressStore(identifier StoreIdentifier) (int64, error) {
+	return compressStoreIn(userStore(), identifier)
+}
+
+func compressStoreIn(area storeArea, identifier StoreIdentifier) (int64, error) {
+	root, blobPath := area.filePath(identifier), area.blobFilePath(identifier)
+	fi, err := fsys.Lstat(root)
+	if err != nil || !fi.IsDir() || area.isInstalling(identifier) {
+		return 0, nil
+	}
+	if skip("compress %s into %s", root, blobPath) {
+		return 0, nil
+	}
+	lock, err := lockStoreIn(area, identifier)
+	if err != nil {
+		return 0, err
+	}
+	defer lock.unlock()
+	defer trace.Start("compress", identifier.String()).Finish()
+
+	size, err := DirSize(root)
+	if err != nil {
+		return 0, err
+	}
+	files := []string{}
+	err = fsys.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// The tarball is only renamed into place once complete, so that an interrupted compression loses nothing
+	partial := blobPath + ".partial"
+	blob, err := fsys.Create(partial)
+	if err != nil {
+		return 0, err
+	}
+	err = archive.TarGZ(blob, root, files)
+	if cerr := blob.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = fsys.Rename(partial, blobPath)
+	}
+	if err != nil {
+		fsys.Remove(partial)
+		return 0, err
+	}
+	if err := fsys.RemoveAll(root); err != nil {
+		return 0, err
+	}
+
+	compressed, err := fsys.Stat(blobPath)
+	if err != nil {
+		return 0, err
+	}
+	return size - compressed.Size(), nil
+}
+
+// decompressStore extracts a compressed version back into its store folder
+func decompressStore(identifier StoreIdentifier) error {
+	return decompressStoreIn(userStore(), identifier)
+}
+
+func decompressStoreIn(area storeArea, identifier StoreIdentifier) error {
+	if !area.isCompressed(identifier) {
+		return nil
+	}
+	blobPath, storePath := area.blobFilePath(identifier), area.filePath(identifier)
+	if skip("extract %s into %s", blobPath, storePath) {
+		return nil
+	}
+	lock, err := lockStoreIn(area, identifier)
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+	defer trace.Start("decompress", identifier.String()).Finish()
+
+	blob, err := fsys.Open(blobPath)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	partial := storePath + ".partial"
+	if err := fsys.RemoveAll(partial); err != nil {
+		return err
+	}
+	if err := archive.UnTarGZ(blob, blobEntryRe, partial, archive.Filter{}); err != nil {
+		fsys.RemoveAll(partial)
+		return err
+	}
+	if err := fsys.Rename(partial, storePath); err != nil {
+		return err
+	}
+	return fsys.Remove(blobPath)
+}
+
+// storeRoot is the folder holding the files of an installed version, compressed versions are extracted
+// to a temporary folder removed by cleanup
+func storeRoot(identifier StoreIdentifier) (root string, cleanup func(), err error) {
+	if !isCompressed(identifier) {
+		root, err = fsys.EvalSymlinks(identifier.toFilePath())
+		return root, func() {}, err
+	}
+	tmp, err := fsys.MkdirTemp("", "bespoke-"+string(identifier.Name)+"-")
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup = func() { fsys.RemoveAll(tmp) }
+	blob, err := fsys.Open(identifier.toBlobFilePath())
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	defer blob.Close()
+	if err := archive.UnTarGZ(blob, blobEntryRe, tmp, archive.Filter{}); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return tmp, cleanup, nil
+}
+
+// walkBlob calls fn with every file of a compressed version, until it returns io.EOF
+func walkBlob(identifier StoreIdentifier, fn func(name string, r io.Reader) error) error {
+	blob, err := fsys.Open(identifier.toBlobFilePath())
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+	gzipReader, err := gzip.NewReader(blob)
+	if err != nil {
+		return err
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := fn(header.Name, tarReader); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// readBlobFile reads the first file of a compressed version accepted by match
+func readBlobFile(identifier StoreIdentifier, match func(name string) bool) ([]byte, error) {
+	var content []byte
+	err := walkBlob(identifier, func(name string, r io.Reader) error {
+		if !match(name) {
+			return nil
+		}
+		raw, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		content = raw
+		return io.EOF
+	})
+	if err == nil && content == nil {
+		return nil, fs.ErrNotExist
+	}
+	return content, err
+}
+
+// readStoreFile reads a file of an installed version, compressed or not
+func readStoreFile(identifier StoreIdentifier, name string) ([]byte, error) {
+	if isCompressed(identifier) {
+		return readBlobFile(identifier, func(entry string) bool { return entry == name })
+	}
+	return fsys.ReadFile(filepath.Join(identifier.toFilePath(), filepath.FromSlash(name)))
+}
+
+func hashBlob(identifier StoreIdentifier) (Manifest, error) {
+	manifest := Manifest{}
+	err := walkBlob(identifier, func(name string, r io.Reader) error {
+		h := sha256.New()
+		if _, err := io.Copy(h, r); err != nil {
+			return err
+		}
+		manifest[name] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+	return manifest, err
+}
+
+// disabledVersions are the versions disabled since the last compression, see compressDisabledVersions
+var disabledVersions = []StoreIdentifier{}
+
+// compressDisabledVersions compresses the versions disabled since the last call, once the links to them are gone
+func compressDisabledVersions() {
+	pending := disabledVersions
+	disabledVersions = []StoreIdentifier{}
+	if !CompressDisabled || len(pending) == 0 {
+		return
+	}
+	vault, err := GetVault()
+	if err != nil {
+		return
+	}
+	for _, identifier := range pending {
+		module := vault.Modules[identifier.ModuleIdentifier.toPath()]
+		if _, ok := module.V[identifier.Version]; !ok || module.Enabled == identifier.Version {
+			continue
+		}
+		if _, err := compressStore(identifier); err != nil {
+			log.Println("Can't compress", identifier.String()+":", err.Error())
+		}
+	}
+}
+
+type CompactReport struct {
+	Compressed []string `json:"compressed"`
+	// Saved is the disk space saved, in bytes
+	Saved int64 `json:"saved"`
+}
+
+// CompactStore compresses every installed version that isn't enabled
+func CompactStore() (CompactReport, error) {
+	report := CompactReport{Compressed: []string{}}
+	vault, err := GetVault()
+	if err != nil {
+		return report, err
+	}
+
+	errs := []error{}
+	for _, identifierStr := range vault.OrderedModules() {
+		module := vault.Modules[identifierStr]
+		for _, v := range module.versions() {
+			identifier := StoreIdentifier{NewModuleIdentifier(string(identifierStr)), v}
+			if v == module.Enabled || isCompressed(identifier) {
+				continue
+			}
+			if fi, err := fsys.Lstat(identifier.toFilePath()); err != nil || !fi.IsDir() {
+				continue
+			}
+			saved, err := compressStore(identifier)
+			if err != nil {
+				errs = append(errs, errors.New(identifier.String()+": "+err.Error()))
+				continue
+			}
+			report.Compressed = append(report.Compressed, identifier.String())
+			report.Saved += saved
+		}
+	}
+	return report, errors.Join(errs...)
+}
@@ -0,0 +1,114 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/trace"
+	"bytes"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+type Conflict struct {
+	Module StoreIdentifier
+	Reason string
+}
+
+type ConflictError struct {
+	Identifier StoreIdentifier
+	Conflicts  []Conflict
+}
+
+func (e *ConflictError) Error() string {
+	reasons := []string{}
+	for _, conflict := range e.Conflicts {
+		reasons = append(reasons, conflict.Module.String()+" ("+conflict.Reason+")")
+	}
+	return e.Identifier.String() + " conflicts with " + strings.Join(reasons, ", ")
+}
+
+func readStoreMetadata(identifier StoreIdentifier) (Metadata, error) {
+	if isCompressed(identifier) {
+		raw, err := readStoreFile(identifier, "metadata.json")
+		if err != nil {
+			return Metadata{}, err
+		}
+		return parseMetadata(bytes.NewReader(raw))
+	}
+	return fetchLocalMetadata(filepath.Join(identifier.toFilePath(), "metadata.json"))
+}
+
+func conflictReason(a *Metadata, b *Metadata, bIdentifier ModuleIdentifier) (string, bool) {
+	for _, capability := range a.Provides {
+		if slices.Contains(b.Provides, capability) {
+			return "both provide " + capability, true
+		}
+	}
+	for _, conflict := range a.Conflicts {
+		if conflict == bIdentifier.String() || slices.Contains(b.Provides, conflict) {
+			return "declared conflict with " + conflict, true
+		}
+	}
+	return "", false
+}
+
+// FindConflicts lists the enabled modules that can't be active at the same time as identifier
+func FindConflicts(identifier StoreIdentifier) ([]Conflict, error) {
+	defer trace.Start("check conflicts", identifier.String()).Finish()
+	metadata, err := readStoreMetadata(identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled, err := GetEnabledModules()
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := []Conflict{}
+	for _, other := range enabled {
+		if other.ModuleIdentifier == identifier.ModuleIdentifier {
+			continue
+		}
+		otherMetadata, err := readStoreMetadata(other)
+		if err != nil {
+			continue
+		}
+		if reason, ok := conflictReason(&metadata, &otherMetadata, other.ModuleIdentifier); ok {
+			conflicts = append(conflicts, Conflict{other, reason})
+		} else if reason, ok := conflictReason(&otherMetadata, &metadata, identifier.ModuleIdentifier); ok {
+			conflicts = append(conflicts, Conflict{other, reason})
+		}
+	}
+	return conflicts, nil
+}
+
+// EnableModuleReplacing disables the conflicting modules before enabling identifier
+func EnableModuleReplacing(identifier StoreIdentifier, conflicts []Conflict) error {
+	return Batch(func() error {
+		for _, conflict := range conflicts {
+			if err := ToggleModuleInVault(StoreIdentifier{ModuleIdentifier: conflict.Module.ModuleIdentifier}); err != nil {
+				return err
+			}
+		}
+		return ToggleModuleInVault(identifier)
+	})
+}
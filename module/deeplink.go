@@ -0,0 +1,89 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+var (
+	// ProtocolTrustedHosts are the metadata hosts websites can ask to install from without a signed deep link
+	ProtocolTrustedHosts = []string{"raw.githubusercontent.com"}
+	// ProtocolSigningKey is the secret shared with the marketplace to verify signed deep links
+	ProtocolSigningKey string
+)
+
+// Signed deep links end with :sig=<hex HMAC-SHA256 of "<action>:<arguments>">
+const signatureSeparator = ":sig="
+
+func SignProtocolRequest(action string, arguments string, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(action + ":" + arguments))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyProtocolRequest strips the signature of a deep link, signed is set when it matches ProtocolSigningKey
+func VerifyProtocolRequest(action string, arguments string) (payload string, signed bool, err error) {
+	i := strings.LastIndex(arguments, signatureSeparator)
+	if i < 0 {
+		return arguments, false, nil
+	}
+	payload, signature := arguments[:i], arguments[i+len(signatureSeparator):]
+	if ProtocolSigningKey == "" {
+		return payload, false, errors.New("the link is signed but no protocol signing key is configured")
+	}
+	expected := SignProtocolRequest(action, payload, ProtocolSigningKey)
+	if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)) {
+		return payload, false, errors.New("invalid signature")
+	}
+	return payload, true, nil
+}
+
+// CheckProtocolSource rejects the unsigned installs requested by websites from hosts that aren't trusted,
+// module references are checked against the host of the metadata URL they resolve to
+func CheckProtocolSource(murl string, signed bool) error {
+	if signed {
+		return nil
+	}
+
+	metadataURL := murl
+	if ref, ok := ParseModuleRef(murl); ok && !IsGitSource(murl) {
+		resolved, _, err := ResolveModuleRef(ref)
+		if err != nil {
+			return err
+		}
+		metadataURL = resolved
+	}
+
+	host := sourceHost(metadataURL)
+	for _, trusted := range ProtocolTrustedHosts {
+		if host != "" && strings.EqualFold(host, trusted) {
+			return nil
+		}
+	}
+	if host == "" {
+		host = metadataURL
+	}
+	return errors.New("websites can only install from trusted hosts without a signed link, " + host + " isn't trusted")
+}
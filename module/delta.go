@@ -0,0 +1,208 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/archive"
+	"bespoke/fsys"
+	"bespoke/link"
+	"bespoke/network"
+	"bespoke/trace"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxDeltaFiles caps the number of files fetched one by one, past it downloading the archive is cheaper
+var maxDeltaFiles = 100
+
+// deltaBase checks that the store of an installed version can be patched into a newer version of the same
+// module folder: it must come from a known commit of the repo and be untouched since, postInstall scripts
+// may have changed its files
+func deltaBase(from StoreIdentifier, target VersionedGithubPath) (string, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return "", err
+	}
+	store, ok := vault.getModule(from.ModuleIdentifier.toPath()).V[from.Version]
+	if !ok || store.Commit == "" || len(store.Metadatas) == 0 || !githubRawRe.MatchString(store.Metadatas[0]) {
+		return "", errors.New(from.String() + " wasn't installed from a GitHub commit")
+	}
+	// The base URL is pinned to its commit so it doesn't need to be resolved again
+	submatches := githubRawRe.FindStringSubmatch(store.Metadatas[0])
+	if submatches[1] != target.owner || submatches[2] != target.repo || submatches[4] != target.path {
+		return "", errors.New(from.String() + " was installed from another repository or folder")
+	}
+
+	metadata, err := readStoreMetadata(from)
+	if err != nil {
+		return "", err
+	}
+	if metadata.Scripts.PostInstall != "" {
+		return "", errors.New(from.String() + " has a postInstall script")
+	}
+	report, err := VerifyModule(from)
+	if err != nil {
+		return "", err
+	}
+	if !report.Ok() {
+		return "", errors.New(from.String() + " was modified since it was installed")
+	}
+	return store.Commit, nil
+}
+
+// patchModuleInStore populates storePath, the store folder of a new version, by copying the store of an installed
+// version of the same module and fetching only the files changed between their commits
+func patchModuleInStore(metadataURL RemoteURL, from StoreIdentifier, to StoreIdentifier, storePath string, filter archive.Filter) error {
+	defer trace.Start("patch", from.String()+" -> "+to.String()).Finish()
+	// Private repositories can't be read through raw links
+	if network.TokenFor("api.github.com") != "" {
+		return errors.New("delta upgrades are only available for public repositories")
+	}
+	if activeScanCommand() != "" {
+		return errors.New("the scan command needs the whole archive")
+	}
+	githubPath, err := parseGithubRawLink(metadataURL)
+	if err != nil {
+		return err
+	}
+	base, err := deltaBase(from, githubPath)
+	if err != nil {
+		return err
+	}
+	// The copy only holds the files selected by the installed version
+	if previous, err := readStoreMetadata(from); err != nil || !sameFiles(previous.filesFilter(), filter) {
+		return errors.New("the files selected by the module changed")
+	}
+	head, err := resolveCommit(metadataURL)
+	if err != nil {
+		return err
+	}
+	if head == "" {
+		return errors.New("can't resolve the commit of " + metadataURL)
+	}
+
+	comparison, _, err := client.Repositories.CompareCommits(Context, githubPath.owner, githubPath.repo, base, head)
+	if err != nil {
+		return err
+	}
+	// Files are listed relative to the merge base, which is only the installed commit when moving forward
+	if status := comparison.GetStatus(); status != "ahead" && status != "identical" {
+		return errors.New(to.String() + " isn't a descendant of the installed commit (" + status + ")")
+	}
+
+	prefix := ""
+	if githubPath.path != "" {
+		prefix = githubPath.path + "/"
+	}
+	changes := map[string]string{}
+	for _, file := range comparison.Files {
+		rel, ok := strings.CutPrefix(file.GetFilename(), prefix)
+		if !ok {
+			continue
+		}
+		// The previous name of renamed files isn't reported, so they can't be removed from the copy
+		if file.GetStatus() == "renamed" {
+			return errors.New(file.GetFilename() + " was renamed")
+		}
+		changes[rel] = file.GetStatus()
+	}
+	if len(changes) > maxDeltaFiles || len(comparison.Files) >= 300 {
+		return errors.New("too many changed files")
+	}
+
+	if skip("patch %s into %s (%d changed files)", from.toFilePath(), storePath, len(changes)) {
+		return nil
+	}
+
+	src, err := fsys.EvalSymlinks(from.toFilePath())
+	if err != nil {
+		return err
+	}
+	if err := link.CopyDir(src, storePath); err != nil {
+		return err
+	}
+	for rel, status := range changes {
+		dest := filepath.Join(storePath, filepath.FromSlash(rel))
+		if status == "removed" || !filter.IsZero() && !filter.Match(rel) {
+			if err := fsys.Remove(dest); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		rawURL := "https://raw.githubusercontent.com/" + path.Join(githubPath.owner, githubPath.repo, head, escapePath(prefix+rel))
+		if err := downloadFile(to, rawURL, dest); err != nil {
+			return err
+		}
+	}
+	log.Println("Patched", to.String(), "with", len(changes), "changed files instead of downloading the whole archive")
+	return nil
+}
+
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func downloadFile(identifier StoreIdentifier, fileURL string, dest string) error {
+	res, err := network.Get(fileURL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return network.NewStatusError(res)
+	}
+
+	if err := fsys.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+	file, err := fsys.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(file, trackDownload(res.Body, identifier, fileURL, res.ContentLength))
+	return err
+}
+
+// upgradeModuleInStore tries to patch the store of the installed version, and downloads the whole module when it can't
+func upgradeModuleInStore(metadataURL RemoteURL, from StoreIdentifier, to StoreIdentifier, dest string, filter archive.Filter) error {
+	err := patchModuleInStore(metadataURL, from, to, dest, filter)
+	if err == nil {
+		return nil
+	}
+	log.Println("Downloading", to.String(), "in full:", err.Error())
+	if !DryRun {
+		if err := fsys.RemoveAll(dest); err != nil {
+			return err
+		}
+	}
+	return downloadModuleInStore(metadataURL, to, dest, filter)
+}
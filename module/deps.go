@@ -0,0 +1,253 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/version"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// InstallAsDependency records the installed versions as dependencies of other modules,
+// which `pkg gc --orphans` removes once no explicitly installed module needs them
+var InstallAsDependency bool
+
+// activeVersion is the version whose metadata describes a module: the enabled one, else the newest installed one
+func (m *Module) activeVersion() Version {
+	if m.Enabled != "" {
+		return m.Enabled
+	}
+	versions := m.versions()
+	if len(versions) == 0 {
+		return ""
+	}
+	return versions[len(versions)-1]
+}
+
+// dependenciesOf reads the dependencies declared by an installed version
+func dependenciesOf(identifier StoreIdentifier) map[ModuleIdentifierStr]Version {
+	dependencies := map[ModuleIdentifierStr]Version{}
+	metadata, err := readStoreMetadata(identifier)
+	if err != nil {
+		return dependencies
+	}
+	return metadata.dependencies()
+}
+
+// dependencies lists the ranges metadata declares, keyed by the module they depend on
+func (m *Metadata) dependencies() map[ModuleIdentifierStr]Version {
+	dependencies := map[ModuleIdentifierStr]Version{}
+	for dependency, version := range m.Dependencies {
+		// Dependencies are keyed by "<author>/<name>", optionally with a registry prefix
+		if _, name, ok := strings.Cut(dependency, ":"); ok {
+			dependency = name
+		}
+		dependencies[ModuleIdentifierStr(dependency)] = Version(version)
+	}
+	return dependencies
+}
+
+// dependents maps every module to the installed modules declaring a dependency on it
+func dependents(vault *Vault) map[ModuleIdentifierStr][]ModuleIdentifierStr {
+	graph := map[ModuleIdentifierStr][]ModuleIdentifierStr{}
+	for _, identifier := range vault.OrderedModules() {
+		module := vault.Modules[identifier]
+		version := module.activeVersion()
+		if version == "" {
+			continue
+		}
+		for dependency := range dependenciesOf(StoreIdentifier{NewModuleIdentifier(string(identifier)), version}) {
+			graph[dependency] = append(graph[dependency], identifier)
+		}
+	}
+	return graph
+}
+
+// Dependents lists the installed modules declaring a dependency on the module of identifier, which break when
+// it is removed
+func Dependents(identifier ModuleIdentifier) ([]ModuleIdentifierStr, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return nil, err
+	}
+	return dependents(vault)[identifier.toPath()], nil
+}
+
+// resolveDependency picks the installed version satisfying a dependency: the exact version, else the version
+// flattening shares for the range
+func (m *Module) resolveDependency(constraint Version) Version {
+	if _, ok := m.V[constraint]; ok {
+		return constraint
+	}
+	if c, err := version.ParseConstraint(string(constraint)); err == nil {
+		return m.sharedVersion(c)
+	}
+	return ""
+}
+
+func (m *Module) isExplicit() bool {
+	return m.V[m.activeVersion()].Explicit
+}
+
+type WhyReport struct {
+	Module ModuleIdentifierStr `json:"module"`
+	// Chains go from an explicitly installed module, or one no other module depends on, down to the module
+	Chains   [][]ModuleIdentifierStr `json:"chains"`
+	Explicit bool                    `json:"explicit"`
+	// Orphaned is set when the module was installed as a dependency and no explicitly installed module needs it anymore
+	Orphaned bool `json:"orphaned"`
+}
+
+// Why explains why a module is installed
+func Why(identifier string) (WhyReport, error) {
+	if !moduleIdentifierRe.MatchString(identifier) {
+		return WhyReport{}, errors.New("invalid module " + identifier + ", expected <author>/<name>")
+	}
+	vault, err := GetVault()
+	if err != nil {
+		return WhyReport{}, err
+	}
+	target := ModuleIdentifierStr(identifier)
+	module, ok := vault.Modules[target]
+	if !ok {
+		return WhyReport{}, errors.New(identifier + " isn't installed")
+	}
+
+	graph := dependents(vault)
+	report := WhyReport{Module: target, Chains: [][]ModuleIdentifierStr{}, Explicit: module.isExplicit()}
+	var walk func(chain []ModuleIdentifierStr)
+	walk = func(chain []ModuleIdentifierStr) {
+		parents := graph[chain[0]]
+		head := vault.Modules[chain[0]]
+		if len(chain) > 1 && (len(parents) == 0 || head.isExplicit()) {
+			report.Chains = append(report.Chains, chain)
+			return
+		}
+		for _, parent := range parents {
+			// Dependency cycles end the chain where they loop
+			if slices.Contains(chain, parent) {
+				report.Chains = append(report.Chains, chain)
+				continue
+			}
+			walk(append([]ModuleIdentifierStr{parent}, chain...))
+		}
+	}
+	walk([]ModuleIdentifierStr{target})
+
+	orphans, err := findOrphans(vault)
+	if err != nil {
+		return report, err
+	}
+	report.Orphaned = slices.ContainsFunc(orphans, func(orphan StoreIdentifier) bool {
+		return orphan.ModuleIdentifier.toPath() == target
+	})
+	return report, nil
+}
+
+// Orphans lists the versions installed as dependencies that no explicitly installed version needs anymore
+func Orphans() ([]StoreIdentifier, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return nil, err
+	}
+	return findOrphans(vault)
+}
+
+func findOrphans(vault *Vault) ([]StoreIdentifier, error) {
+	needed := map[StoreIdentifier]bool{}
+	queue := []StoreIdentifier{}
+	keep := func(identifier StoreIdentifier) {
+		if !needed[identifier] {
+			needed[identifier] = true
+			queue = append(queue, identifier)
+		}
+	}
+
+	for _, identifierStr := range vault.OrderedModules() {
+		moduleIdentifier := NewModuleIdentifier(string(identifierStr))
+		for version, store := range vault.Modules[identifierStr].V {
+			if store.Explicit {
+				keep(StoreIdentifier{moduleIdentifier, version})
+			}
+		}
+	}
+
+	for len(queue) > 0 {
+		identifier := queue[0]
+		queue = queue[1:]
+		for dependency, constraint := range dependenciesOf(identifier) {
+			module, ok := vault.Modules[dependency]
+			if !ok {
+				continue
+			}
+			moduleIdentifier := NewModuleIdentifier(string(dependency))
+			if resolved := module.resolveDependency(constraint); resolved != "" {
+				keep(StoreIdentifier{moduleIdentifier, resolved})
+				continue
+			}
+			// Without an installed version satisfying it, any installed version may be the one in use
+			for installed := range module.V {
+				keep(StoreIdentifier{moduleIdentifier, installed})
+			}
+		}
+	}
+
+	orphans := []StoreIdentifier{}
+	for _, identifierStr := range vault.OrderedModules() {
+		moduleIdentifier := NewModuleIdentifier(string(identifierStr))
+		module := vault.Modules[identifierStr]
+		for _, version := range module.versions() {
+			if identifier := (StoreIdentifier{moduleIdentifier, version}); !needed[identifier] {
+				orphans = append(orphans, identifier)
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// MarkModule records whether an installed version was installed explicitly or as a dependency
+func MarkModule(identifier StoreIdentifier, explicit bool) error {
+	unlock, err := lockVault()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	before := snapshotVault()
+	vault, err := GetVault()
+	if err != nil {
+		return err
+	}
+
+	store, ok := vault.getModule(identifier.ModuleIdentifier.toPath()).V[identifier.Version]
+	if !ok {
+		return errors.New("Can't find matching " + identifier.toPath())
+	}
+	if store.Explicit == explicit {
+		return nil
+	}
+	store.Explicit = explicit
+	vault.setStore(identifier, &store)
+
+	if err := SetVault(vault); err != nil {
+		return err
+	}
+	return record(OpMark, identifier.String(), before)
+}
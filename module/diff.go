@@ -0,0 +1,251 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/archive"
+	"bespoke/diff"
+	"bespoke/fsys"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+type FileStatus string
+
+const (
+	FileModified FileStatus = "modified"
+	FileAdded    FileStatus = "added"
+	FileRemoved  FileStatus = "removed"
+)
+
+// FileDiff is the change to a single file of a module, Patch is empty for binary files
+type FileDiff struct {
+	File    string     `json:"file"`
+	Status  FileStatus `json:"status"`
+	Added   int        `json:"added"`
+	Removed int        `json:"removed"`
+	Binary  bool       `json:"binary,omitempty"`
+	Patch   string     `json:"patch,omitempty"`
+}
+
+// DiffModule lists the changes from the store copy of a module to another installed version, or from the files
+// it was installed from to the store copy when other is empty, which shows local edits
+func DiffModule(identifier StoreIdentifier, other Version) ([]FileDiff, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return nil, err
+	}
+	identifier, store, err := installedStore(vault, identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	root, cleanup, err := storeRoot(identifier)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	if other != "" {
+		otherIdentifier, _, err := installedStore(vault, StoreIdentifier{identifier.ModuleIdentifier, other})
+		if err != nil {
+			return nil, err
+		}
+		otherRoot, otherCleanup, err := storeRoot(otherIdentifier)
+		if err != nil {
+			return nil, err
+		}
+		defer otherCleanup()
+		return diffFolders(root, otherRoot)
+	}
+
+	report, err := VerifyModule(identifier)
+	if err != nil {
+		return nil, err
+	}
+	if report.Ok() {
+		return []FileDiff{}, nil
+	}
+
+	// The hashes recorded at install time tell which files changed, the source is only fetched to show how
+	pristine := ""
+	if len(report.Modified) > 0 || len(report.Missing) > 0 {
+		if len(store.Metadatas) == 0 {
+			return nil, errors.New(identifier.String() + " was installed from a local path, there is no pristine copy to compare with")
+		}
+		tmp, moduleDir, err := fetchPristine(pinSource(store.Metadatas[0], store.Commit))
+		if tmp != "" {
+			defer fsys.RemoveAll(tmp)
+		}
+		if err != nil {
+			return nil, errors.New("can't fetch the pristine copy of " + identifier.String() + ": " + err.Error())
+		}
+		pristine = moduleDir
+	}
+
+	diffs := []FileDiff{}
+	for _, file := range report.Modified {
+		d, err := diffFile(pristine, root, file)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, d)
+	}
+	for _, file := range report.Missing {
+		d, err := diffFile(pristine, "", file)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, d)
+	}
+	for _, file := range report.Extra {
+		d, err := diffFile("", root, file)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, d)
+	}
+	slices.SortFunc(diffs, func(a, b FileDiff) int {
+		if a.File < b.File {
+			return -1
+		}
+		return 1
+	})
+	return diffs, nil
+}
+
+// fetchPristine downloads the files of a module from its source into a temporary folder, which the caller must remove
+func fetchPristine(murl string) (tmp string, moduleDir string, err error) {
+	switch {
+	case IsGitSource(murl):
+		source, err := ParseGitSource(murl)
+		if err != nil {
+			return "", "", err
+		}
+		tmp, moduleDir, _, err = source.cloneModule()
+		return tmp, moduleDir, err
+	case IsReleaseSource(murl):
+		source, err := ParseReleaseSource(murl)
+		if err != nil {
+			return "", "", err
+		}
+		tmp, _, _, err = source.fetchModule()
+		return tmp, tmp, err
+	}
+
+	githubPath, err := parseGithubRawLink(murl)
+	if err != nil {
+		return "", "", err
+	}
+	tmp, err = fsys.MkdirTemp(os.TempDir(), "bespoke-pristine-")
+	if err != nil {
+		return "", "", err
+	}
+	return tmp, tmp, downloadModule(StoreIdentifier{}, githubPath, tmp, archive.Filter{})
+}
+
+func listFiles(root string) ([]string, error) {
+	files := []string{}
+	err := fsys.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
+
+func diffFolders(from string, to string) ([]FileDiff, error) {
+	fromFiles, err := listFiles(from)
+	if err != nil {
+		return nil, err
+	}
+	toFiles, err := listFiles(to)
+	if err != nil {
+		return nil, err
+	}
+
+	files := append(fromFiles, toFiles...)
+	slices.Sort(files)
+	files = slices.Compact(files)
+
+	diffs := []FileDiff{}
+	for _, file := range files {
+		fromDir, toDir := from, to
+		if !slices.Contains(fromFiles, file) {
+			fromDir = ""
+		}
+		if !slices.Contains(toFiles, file) {
+			toDir = ""
+		}
+		d, err := diffFile(fromDir, toDir, file)
+		if err != nil {
+			return nil, err
+		}
+		if d.Status == FileModified && d.Added == 0 && d.Removed == 0 && !d.Binary {
+			continue
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs, nil
+}
+
+// diffFile compares file in the from and to folders, an empty folder stands for a file that doesn't exist on that side
+func diffFile(from string, to string, file string) (FileDiff, error) {
+	d := FileDiff{File: file, Status: FileModified}
+	fromName, toName := "a/"+file, "b/"+file
+
+	var a, b []byte
+	if from == "" {
+		d.Status, fromName = FileAdded, "/dev/null"
+	} else {
+		content, err := fsys.ReadFile(filepath.Join(from, filepath.FromSlash(file)))
+		if err != nil {
+			return d, err
+		}
+		a = content
+	}
+	if to == "" {
+		d.Status, toName = FileRemoved, "/dev/null"
+	} else {
+		content, err := fsys.ReadFile(filepath.Join(to, filepath.FromSlash(file)))
+		if err != nil {
+			return d, err
+		}
+		b = content
+	}
+
+	if diff.IsBinary(a) || diff.IsBinary(b) {
+		d.Binary = string(a) != string(b)
+		return d, nil
+	}
+	patch, edits := diff.Files(fromName, toName, string(a), string(b))
+	d.Added, d.Removed = diff.Count(edits)
+	d.Patch = patch
+	return d, nil
+}
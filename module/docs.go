@@ -0,0 +1,109 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/network"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	DocReadme    = "README.md"
+	DocChangelog = "CHANGELOG.md"
+)
+
+// ResolveInstalled turns "<author>/<name>[/<version>]" into an installed version, defaulting to the enabled
+// version, or the only installed one
+func ResolveInstalled(identifier string) (StoreIdentifier, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return StoreIdentifier{}, err
+	}
+
+	if storeIdentifier, ok := ParseStoreIdentifier(identifier); ok && storeIdentifier.Version != "" {
+		if _, ok := vault.getModule(storeIdentifier.ModuleIdentifier.toPath()).V[storeIdentifier.Version]; !ok {
+			return StoreIdentifier{}, errors.New(storeIdentifier.String() + " isn't installed")
+		}
+		return storeIdentifier, nil
+	}
+
+	moduleIdentifier := NewModuleIdentifier(strings.TrimSuffix(identifier, "/"))
+	module := vault.getModule(moduleIdentifier.toPath())
+	if module.Enabled != "" {
+		return StoreIdentifier{moduleIdentifier, module.Enabled}, nil
+	}
+	if len(module.V) == 1 {
+		for version := range module.V {
+			return StoreIdentifier{moduleIdentifier, version}, nil
+		}
+	}
+	if len(module.V) == 0 {
+		return StoreIdentifier{}, errors.New(moduleIdentifier.String() + " isn't installed")
+	}
+	return StoreIdentifier{}, errors.New(moduleIdentifier.String() + " has several versions installed, pick one with " + moduleIdentifier.String() + "/<version>")
+}
+
+// ModuleDoc reads a documentation file (README.md, CHANGELOG.md) of an installed module, from its store folder
+// when the module ships it, else from its GitHub repository at the installed commit (module folder, then repository root)
+func ModuleDoc(identifier StoreIdentifier, name string) (string, error) {
+	if entries, err := os.ReadDir(identifier.toFilePath()); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.EqualFold(entry.Name(), name) {
+				doc, err := os.ReadFile(filepath.Join(identifier.toFilePath(), entry.Name()))
+				return string(doc), err
+			}
+		}
+	}
+	if isCompressed(identifier) {
+		if doc, err := readBlobFile(identifier, func(entry string) bool { return strings.EqualFold(entry, name) }); err == nil {
+			return string(doc), nil
+		}
+	}
+
+	vault, err := GetVault()
+	if err != nil {
+		return "", err
+	}
+	store := vault.getModule(identifier.ModuleIdentifier.toPath()).V[identifier.Version]
+	if len(store.Metadatas) == 0 || !githubRawRe.MatchString(store.Metadatas[0]) {
+		return "", errors.New(identifier.String() + " has no " + name + " and wasn't installed from GitHub")
+	}
+
+	submatches := githubRawRe.FindStringSubmatch(store.Metadatas[0])
+	owner, repo, ref, dir := submatches[1], submatches[2], submatches[3], submatches[4]
+	if store.Commit != "" {
+		ref = store.Commit
+	}
+	candidates := []string{path.Join(dir, name), name}
+	if dir == "" {
+		candidates = candidates[1:]
+	}
+	for _, candidate := range candidates {
+		doc, err := network.GetCached("https://raw.githubusercontent.com/" + path.Join(owner, repo, ref, candidate))
+		if err == nil {
+			return string(doc), nil
+		}
+	}
+	return "", errors.New("can't find " + name + " in " + owner + "/" + repo + "@" + ref)
+}
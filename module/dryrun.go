@@ -0,0 +1,127 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DryRun makes mutating operations print the changes they would make instead of applying them
+var DryRun bool
+
+// pendingVault is the vault as it would have been written in dry-run mode, so that chained
+// operations (e.g. install then enable) see each other's changes
+var pendingVault []byte
+
+// skip reports whether a change must be skipped, printing it in dry-run mode
+func skip(format string, args ...any) bool {
+	if DryRun {
+		fmt.Printf("would "+format+"\n", args...)
+	}
+	return DryRun
+}
+
+func setPendingVault(vault *Vault) error {
+	vaultJson, err := json.Marshal(vault)
+	if err != nil {
+		return err
+	}
+	current, _ := GetVault()
+	printVaultChanges(current, vault)
+	pendingVault = vaultJson
+	return nil
+}
+
+func getPendingVault() (*Vault, error) {
+	var vault Vault
+	err := json.Unmarshal(pendingVault, &vault)
+	return &vault, err
+}
+
+func printVaultChanges(before *Vault, after *Vault) {
+	identifiers := []ModuleIdentifierStr{}
+	for identifier := range before.Modules {
+		identifiers = append(identifiers, identifier)
+	}
+	for identifier := range after.Modules {
+		if _, ok := before.Modules[identifier]; !ok {
+			identifiers = append(identifiers, identifier)
+		}
+	}
+	slices.Sort(identifiers)
+
+	for _, identifier := range identifiers {
+		old, hadOld := before.Modules[identifier]
+		new, hasNew := after.Modules[identifier]
+		switch {
+		case !hasNew:
+			skip("remove vault entry %s", identifier)
+		case !hadOld:
+			skip("add vault entry %s (%s)", identifier, describeModule(&new))
+		default:
+			if changes := describeModuleChanges(&old, &new); len(changes) > 0 {
+				skip("update vault entry %s: %s", identifier, strings.Join(changes, ", "))
+			}
+		}
+	}
+}
+
+func describeEnabled(module *Module) string {
+	if module.Enabled == "" {
+		return "none"
+	}
+	return string(module.Enabled)
+}
+
+func describeModule(module *Module) string {
+	versions := []string{}
+	for _, version := range module.versions() {
+		versions = append(versions, string(version))
+	}
+	return "versions: [" + strings.Join(versions, " ") + "], enabled: " + describeEnabled(module)
+}
+
+func describeModuleChanges(old *Module, new *Module) []string {
+	changes := []string{}
+	if old.Enabled != new.Enabled {
+		changes = append(changes, "enabled "+describeEnabled(old)+" -> "+describeEnabled(new))
+	}
+	versionChanges := []string{}
+	for version := range new.V {
+		if _, ok := old.V[version]; !ok {
+			versionChanges = append(versionChanges, "add version "+string(version))
+		}
+	}
+	for version := range old.V {
+		if _, ok := new.V[version]; !ok {
+			versionChanges = append(versionChanges, "remove version "+string(version))
+		}
+	}
+	slices.Sort(versionChanges)
+	changes = append(changes, versionChanges...)
+	if old.Priority != new.Priority {
+		changes = append(changes, "priority "+strconv.Itoa(old.Priority)+" -> "+strconv.Itoa(new.Priority))
+	}
+	return changes
+}
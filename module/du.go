@@ -0,0 +1,99 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type DiskUsage struct {
+	Identifier StoreIdentifier
+	Size       int64
+	Compressed bool
+}
+
+// DirSize sums the sizes of the files under dir, without following symlinks
+func DirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// DirSizes measures several folders concurrently
+func DirSizes(dirs []string) ([]int64, error) {
+	sizes := make([]int64, len(dirs))
+	errs := make([]error, len(dirs))
+
+	sem := make(chan struct{}, runtime.NumCPU())
+	var wg sync.WaitGroup
+	for i, dir := range dirs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			sizes[i], errs[i] = DirSize(dir)
+		}()
+	}
+	wg.Wait()
+
+	return sizes, errors.Join(errs...)
+}
+
+// StoreDiskUsage reports the size of every version in the store (local installs are links and count as empty,
+// compressed versions count as the size of their tarball)
+func StoreDiskUsage() ([]DiskUsage, error) {
+	storeDirs, err := filepath.Glob(filepath.Join(storeFolder, "*", "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+	storeDirs = slices.DeleteFunc(storeDirs, func(p string) bool { return strings.HasSuffix(p, ".partial") })
+
+	sizes, err := DirSizes(storeDirs)
+	usages := make([]DiskUsage, 0, len(storeDirs))
+	for i, storeDir := range storeDirs {
+		blob, compressed := strings.CutSuffix(storeDir, ".tar.gz")
+		usages = append(usages, DiskUsage{storeIdentifierFromFilePath(blob), sizes[i], compressed})
+	}
+	return usages, err
+}
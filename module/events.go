@@ -0,0 +1,108 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"io"
+)
+
+// Step is a stage of an install, upgrade or sync
+type Step string
+
+const (
+	StepResolve  Step = "resolve"
+	StepDownload Step = "download"
+	StepScan     Step = "scan"
+	StepExtract  Step = "extract"
+	StepScript   Step = "script"
+	StepEnable   Step = "enable"
+	StepDisable  Step = "disable"
+	// StepDone is sent once a version was added to the store
+	StepDone Step = "done"
+)
+
+type Event struct {
+	Step Step `json:"step"`
+	// Module is the version the event is about, unset when it isn't known yet (e.g. while downloading a release)
+	Module StoreIdentifier `json:"module"`
+	// Source is the URL (or registry) being resolved, the URL being downloaded, scanned or extracted, or the name
+	// of the script being run
+	Source string `json:"source,omitempty"`
+	// Done and Total count the bytes downloaded or the files extracted so far, Total is -1 when unknown
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
+}
+
+// Events receives the progress of the operations of the package, for callers to render it.
+// Notify is called from the goroutine running the operation and should return quickly
+type Events interface {
+	Notify(event Event)
+}
+
+// EventsFunc lets a function be used as Events
+type EventsFunc func(event Event)
+
+func (f EventsFunc) Notify(event Event) {
+	f(event)
+}
+
+type noEvents struct{}
+
+func (noEvents) Notify(Event) {}
+
+// Progress is notified of every step of installs, upgrades and syncs, it ignores them by default
+var Progress Events = noEvents{}
+
+func notify(step Step, identifier StoreIdentifier, source string) {
+	Progress.Notify(Event{Step: step, Module: identifier, Source: source, Total: -1})
+}
+
+// progressReader reports the bytes read from a download
+type progressReader struct {
+	r          io.Reader
+	identifier StoreIdentifier
+	source     string
+	done       int64
+	total      int64
+}
+
+// trackDownload reports the progress of reading a download of total bytes (-1 when unknown) from r
+func trackDownload(r io.Reader, identifier StoreIdentifier, source string, total int64) io.Reader {
+	notify(StepDownload, identifier, source)
+	return &progressReader{r: r, identifier: identifier, source: source, total: total}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.done += int64(n)
+		Progress.Notify(Event{Step: StepDownload, Module: p.identifier, Source: p.source, Done: p.done, Total: p.total})
+	}
+	return n, err
+}
+
+// trackExtraction returns the callback reporting the files extracted from an archive
+func trackExtraction(identifier StoreIdentifier, source string) func(string) {
+	done := int64(0)
+	return func(string) {
+		done++
+		Progress.Notify(Event{Step: StepExtract, Module: identifier, Source: source, Done: done, Total: -1})
+	}
+}
@@ -0,0 +1,82 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/archive"
+	"bespoke/fsys"
+	"io/fs"
+	"path/filepath"
+	"slices"
+)
+
+// Files selects the files of the module folder that are installed, so that the docs and tests of large repositories
+// don't end up in the store. Patterns follow archive.Filter, metadata.json and the entries are always installed
+type Files struct {
+	Include  []string `json:"include,omitempty"`
+	Exclude  []string `json:"exclude,omitempty"`
+	MaxDepth int      `json:"maxDepth,omitempty"`
+}
+
+func (m *Metadata) filesFilter() archive.Filter {
+	if m.Files == nil {
+		return archive.Filter{}
+	}
+	filter := archive.Filter{
+		Include:  m.Files.Include,
+		Exclude:  m.Files.Exclude,
+		Keep:     append(m.entryFiles(), "metadata.json"),
+		MaxDepth: m.Files.MaxDepth,
+	}
+	// Assets are part of the module even when the author forgot to include them
+	if len(filter.Include) > 0 {
+		filter.Include = append(filter.Include, m.Assets...)
+		for _, platform := range m.Platforms {
+			filter.Include = append(filter.Include, platform.Assets...)
+		}
+	}
+	return filter
+}
+
+// sameFiles compares the patterns of two filters, the kept files are derived from the entries
+func sameFiles(a archive.Filter, b archive.Filter) bool {
+	return slices.Equal(a.Include, b.Include) && slices.Equal(a.Exclude, b.Exclude) && a.MaxDepth == b.MaxDepth
+}
+
+// pruneFiles removes the files of a freshly populated store that don't pass the filter,
+// for sources that can't be filtered while they are copied
+func pruneFiles(storePath string, filter archive.Filter) error {
+	if filter.IsZero() {
+		return nil
+	}
+	return fsys.WalkDir(storePath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(storePath, p)
+		if err != nil {
+			return err
+		}
+		if filter.Match(filepath.ToSlash(rel)) || skip("remove %s (not in files)", p) {
+			return nil
+		}
+		return fsys.Remove(p)
+	})
+}
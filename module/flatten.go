@@ -0,0 +1,388 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/version"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// SharedDependency is the single version of a module installed for every module depending on it
+type SharedDependency struct {
+	Module ModuleIdentifierStr `json:"module"`
+	// Constraints maps each dependent to the range of versions it declares
+	Constraints map[ModuleIdentifierStr]string `json:"constraints"`
+	// Constraint is the intersection of the ranges of the dependents
+	Constraint string  `json:"constraint"`
+	Version    Version `json:"version"`
+	// Source is the metadata URL the shared version is installed from when it isn't installed yet
+	Source RemoteURL `json:"source,omitempty"`
+	// Duplicates are the other versions installed as dependencies, which the shared version replaces
+	Duplicates []Version `json:"duplicates"`
+	// Changed is set when flattening would install, enable, remove or record anything
+	Changed bool `json:"changed"`
+}
+
+// DependencyConflict is reported when no version satisfies every module depending on a module
+type DependencyConflict struct {
+	Module      ModuleIdentifierStr            `json:"module"`
+	Constraints map[ModuleIdentifierStr]string `json:"constraints"`
+}
+
+func (c *DependencyConflict) Error() string {
+	dependents := sortedDependents(c.Constraints)
+	required := make([]string, len(dependents))
+	for i, dependent := range dependents {
+		required[i] = string(dependent) + " (" + c.Constraints[dependent] + ")"
+	}
+	if len(required) == 1 {
+		return "no version of " + string(c.Module) + " is installed or published that satisfies " + required[0]
+	}
+	return "no version of " + string(c.Module) + " satisfies every module depending on it: " + strings.Join(required, ", ")
+}
+
+func sortedDependents(constraints map[ModuleIdentifierStr]string) []ModuleIdentifierStr {
+	dependents := make([]ModuleIdentifierStr, 0, len(constraints))
+	for dependent := range constraints {
+		dependents = append(dependents, dependent)
+	}
+	slices.Sort(dependents)
+	return dependents
+}
+
+// sharedVersion picks the installed version satisfying a constraint: the enabled one, else the newest one
+func (m *Module) sharedVersion(c version.Constraint) Version {
+	if m.Enabled != "" && c.Check(string(m.Enabled)) {
+		return m.Enabled
+	}
+	versions := m.versions()
+	for i := len(versions) - 1; i >= 0; i-- {
+		if c.Check(string(versions[i])) {
+			return versions[i]
+		}
+	}
+	return ""
+}
+
+// publishedVersions lists the metadata URL of every version published in the registries and the remotes of a module,
+// along with the indexes that couldn't be fetched
+func publishedVersions(identifier ModuleIdentifierStr, module *Module) (map[Version]RemoteURL, []error) {
+	published := map[Version]RemoteURL{}
+	errs := []error{}
+	add := func(versions map[Version]RemoteURL) {
+		for v, metadataURL := range versions {
+			if _, ok := published[v]; !ok {
+				published[v] = metadataURL
+			}
+		}
+	}
+
+	if module != nil {
+		for _, remote := range module.Remotes {
+			if remote == LocalRemote {
+				continue
+			}
+			index, err := fetchRepoIndex(remote)
+			if err != nil {
+				errs = append(errs, errors.New("can't list the versions of "+string(identifier)+" published at "+remote+": "+err.Error()))
+				continue
+			}
+			add(index.Versions)
+		}
+	}
+	for _, registry := range sortedRegistries() {
+		if !registry.Trusted && !AllowUntrusted {
+			continue
+		}
+		index, err := fetchRegistryIndex(registry)
+		if err != nil {
+			errs = append(errs, errors.New("can't list the versions of "+string(identifier)+" in registry "+registry.Name+": "+err.Error()))
+			continue
+		}
+		add(index.Modules[identifier].Versions)
+	}
+	return published, errs
+}
+
+// latestPublished picks the newest published version accepted by keep
+func latestPublished(published map[Version]RemoteURL, keep func(Version) bool) (Version, RemoteURL) {
+	versions := make([]Version, 0, len(published))
+	for v := range published {
+		if keep(v) {
+			versions = append(versions, v)
+		}
+	}
+	if latest := version.Latest(versions); latest != "" {
+		return latest, published[latest]
+	}
+	return "", ""
+}
+
+// installedConstraints lists, for every dependency, the ranges declared by every installed version of the modules
+// depending on it, enabled or not
+func installedConstraints(vault *Vault) map[ModuleIdentifierStr][]version.Constraint {
+	installed := map[ModuleIdentifierStr][]version.Constraint{}
+	for identifier, module := range vault.Modules {
+		for _, v := range module.versions() {
+			for dependency, constraint := range dependenciesOf(StoreIdentifier{NewModuleIdentifier(string(identifier)), v}) {
+				// Invalid ranges are reported for the active versions
+				if c, err := version.ParseConstraint(string(constraint)); err == nil {
+					installed[dependency] = append(installed[dependency], c)
+				}
+			}
+		}
+	}
+	return installed
+}
+
+// compare lists the versions of the installed module the shared version replaces and whether anything changes.
+// A version is kept when an installed version of a dependent accepts it but not the shared version
+func (s *SharedDependency) compare(module *Module, installed []version.Constraint) {
+	needed := func(v Version) bool {
+		return slices.ContainsFunc(installed, func(c version.Constraint) bool {
+			return c.Check(string(v)) && !c.Check(string(s.Version))
+		})
+	}
+	if module != nil {
+		for _, v := range module.versions() {
+			if v != s.Version && !module.V[v].Explicit && !needed(v) {
+				s.Duplicates = append(s.Duplicates, v)
+			}
+		}
+	}
+	s.Changed = module == nil || s.Source != "" || module.Enabled != s.Version ||
+		module.Constraint != s.Constraint || len(s.Duplicates) > 0
+}
+
+// PlanDependencies picks a single version of every module other modules depend on, satisfying the ranges
+// declared by all of them. Modules no version satisfies are returned as DependencyConflict errors.
+// upgrades are the planned upgrades of dependents, the ranges of their new version are used instead
+func PlanDependencies(upgrades []Upgrade) ([]SharedDependency, []error, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	errs := []error{}
+	upgraded := map[ModuleIdentifierStr]map[ModuleIdentifierStr]Version{}
+	for _, upgrade := range upgrades {
+		metadata, _, err := fetchRemoteMetadata(upgrade.MetadataURL)
+		if err != nil {
+			errs = append(errs, errors.New("can't read the dependencies of "+upgrade.Module.String()+" "+string(upgrade.To)+": "+err.Error()))
+			continue
+		}
+		upgraded[upgrade.Module.toPath()] = metadata.dependencies()
+	}
+
+	required := map[ModuleIdentifierStr]map[ModuleIdentifierStr]string{}
+	for _, identifier := range vault.OrderedModules() {
+		module := vault.Modules[identifier]
+		active := module.activeVersion()
+		if active == "" {
+			continue
+		}
+		declared, ok := upgraded[identifier]
+		if !ok {
+			declared = dependenciesOf(StoreIdentifier{NewModuleIdentifier(string(identifier)), active})
+		}
+		for dependency, constraint := range declared {
+			if required[dependency] == nil {
+				required[dependency] = map[ModuleIdentifierStr]string{}
+			}
+			required[dependency][identifier] = string(constraint)
+		}
+	}
+
+	installed := installedConstraints(vault)
+	dependencies := make([]ModuleIdentifierStr, 0, len(required))
+	for dependency := range required {
+		dependencies = append(dependencies, dependency)
+	}
+	slices.Sort(dependencies)
+
+	plan := []SharedDependency{}
+	for _, dependency := range dependencies {
+		constraints := required[dependency]
+		var intersection version.Constraint
+		valid := true
+		for i, dependent := range sortedDependents(constraints) {
+			c, err := version.ParseConstraint(constraints[dependent])
+			if err != nil {
+				errs = append(errs, errors.New(string(dependent)+" depends on "+string(dependency)+": "+err.Error()))
+				valid = false
+				break
+			}
+			if i == 0 {
+				intersection = c
+			} else {
+				intersection = version.Intersect(intersection, c)
+			}
+		}
+		if !valid {
+			continue
+		}
+
+		shared := SharedDependency{Module: dependency, Constraints: constraints, Constraint: intersection.String(), Duplicates: []Version{}}
+		var module *Module
+		if m, ok := vault.Modules[dependency]; ok {
+			module = &m
+			shared.Version = module.sharedVersion(intersection)
+		}
+		if shared.Version == "" {
+			published, fetchErrs := publishedVersions(dependency, module)
+			shared.Version, shared.Source = latestPublished(published, func(v Version) bool {
+				return intersection.Check(string(v))
+			})
+			// Not a conflict when a satisfying version may be published where the versions couldn't be listed
+			if shared.Version == "" && len(fetchErrs) > 0 {
+				errs = append(errs, fetchErrs...)
+				continue
+			}
+		}
+		if shared.Version == "" {
+			errs = append(errs, &DependencyConflict{dependency, constraints})
+			continue
+		}
+
+		shared.compare(module, installed[dependency])
+		plan = append(plan, shared)
+	}
+	return plan, errs, nil
+}
+
+// FlattenDependencies installs and enables the shared version of every dependency and records the range
+// it satisfies, then removes the duplicates unless keepDuplicates is set
+func FlattenDependencies(plan []SharedDependency, keepDuplicates bool) error {
+	for _, shared := range plan {
+		if !shared.Changed {
+			continue
+		}
+		identifier := StoreIdentifier{NewModuleIdentifier(string(shared.Module)), shared.Version}
+
+		if shared.Source != "" {
+			asDependency := InstallAsDependency
+			InstallAsDependency = true
+			err := InstallModuleMURL(shared.Source)
+			InstallAsDependency = asDependency
+			if err != nil {
+				return errors.New("can't install " + identifier.toPath() + ": " + err.Error())
+			}
+		}
+		if err := ToggleModuleInVault(identifier); err != nil {
+			return err
+		}
+		if err := MutateVault(func(vault *Vault) bool {
+			if module, ok := vault.Modules[shared.Module]; ok {
+				module.Constraint = shared.Constraint
+				vault.Modules[shared.Module] = module
+			}
+			return true
+		}); err != nil {
+			return err
+		}
+
+		if keepDuplicates {
+			continue
+		}
+		for _, duplicate := range shared.Duplicates {
+			if err := DeleteModule(StoreIdentifier{identifier.ModuleIdentifier, duplicate}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Resolution settles a DependencyConflict
+type Resolution string
+
+const (
+	// PreferInstalled keeps the installed version of the dependency, although some dependents don't accept it
+	PreferInstalled Resolution = "prefer-installed"
+	// PreferNewest upgrades the dependents, in case their newer versions agree, and otherwise picks the newest
+	// version of the dependency
+	PreferNewest Resolution = "prefer-newest"
+)
+
+// ParseResolution reads a resolution, which is empty when conflicts are left to the user
+func ParseResolution(s string) (Resolution, error) {
+	switch r := Resolution(s); r {
+	case "", PreferInstalled, PreferNewest:
+		return r, nil
+	}
+	return "", errors.New("unknown resolution " + s + ", expected prefer-newest or prefer-installed")
+}
+
+// DependentUpgrades lists the newer versions of the modules taking part in a conflict
+func (c *DependencyConflict) DependentUpgrades() ([]Upgrade, []error) {
+	dependents := []ModuleIdentifier{}
+	for _, dependent := range sortedDependents(c.Constraints) {
+		dependents = append(dependents, NewModuleIdentifier(string(dependent)))
+	}
+	return CheckUpgrades(dependents)
+}
+
+// Settle picks the version of the dependency the resolution prefers, regardless of the ranges of its dependents:
+// the enabled (or newest installed) version for PreferInstalled, the newest installed or published one for PreferNewest
+func (c *DependencyConflict) Settle(resolution Resolution) (SharedDependency, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return SharedDependency{}, err
+	}
+	shared := SharedDependency{Module: c.Module, Constraints: c.Constraints, Duplicates: []Version{}}
+
+	var module *Module
+	installed := []Version{}
+	if m, ok := vault.Modules[c.Module]; ok {
+		module = &m
+		installed = module.versions()
+	}
+	switch resolution {
+	case PreferInstalled:
+		if module != nil && module.Enabled != "" {
+			shared.Version = module.Enabled
+		} else {
+			shared.Version = version.Latest(installed)
+		}
+		if shared.Version == "" {
+			return shared, errors.New(string(c.Module) + " isn't installed, there is no version to keep")
+		}
+	case PreferNewest:
+		published, errs := publishedVersions(c.Module, module)
+		// A newer version may be published where the versions couldn't be listed
+		if len(errs) > 0 {
+			return shared, errs[0]
+		}
+		shared.Version, shared.Source = latestPublished(published, func(Version) bool { return true })
+		if newest := version.Latest(installed); newest != "" && (shared.Version == "" || version.Compare(string(newest), string(shared.Version)) >= 0) {
+			shared.Version, shared.Source = newest, ""
+		}
+		if shared.Version == "" {
+			return shared, errors.New("can't find a version of " + string(c.Module))
+		}
+	default:
+		return shared, c
+	}
+
+	shared.compare(module, installedConstraints(vault)[c.Module])
+	return shared, nil
+}
@@ -0,0 +1,117 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+package module
+
+import (
+	"bespoke/fsys"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func writeStoreDependencies(t *testing.T, identifier StoreIdentifier, dependencies map[string]string) {
+	t.Helper()
+	metadata := Metadata{Name: string(identifier.Name), Version: string(identifier.Version), Dependencies: dependencies}
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.MkdirAll(identifier.toFilePath(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile(filepath.Join(identifier.toFilePath(), "metadata.json"), raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlanDependenciesWithUpgrades(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name": "x", "version": "2.0.0", "dependencies": {"lib/core": "^2.0.0"}}`))
+	}))
+	defer server.Close()
+	useMemFs(t)
+
+	writeStoreDependencies(t, NewStoreIdentifier("app/x/1.0.0"), map[string]string{"lib/core": "^1.0.0"})
+	writeStoreDependencies(t, NewStoreIdentifier("app/y/1.0.0"), map[string]string{"lib/core": "^2.0.0"})
+	vault := &Vault{Modules: map[ModuleIdentifierStr]Module{
+		"app/x":    {Enabled: "1.0.0", V: map[Version]Store{"1.0.0": {Installed: true}}},
+		"app/y":    {Enabled: "1.0.0", V: map[Version]Store{"1.0.0": {Installed: true}}},
+		"lib/core": {Enabled: "2.1.0", V: map[Version]Store{"1.2.0": {Installed: true}, "2.1.0": {Installed: true}}},
+	}}
+	if err := SetVault(vault); err != nil {
+		t.Fatal(err)
+	}
+
+	_, errs, err := PlanDependencies(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("PlanDependencies(nil) = %v, want a conflict on lib/core", errs)
+	}
+	if _, ok := errs[0].(*DependencyConflict); !ok {
+		t.Fatalf("PlanDependencies(nil) = %v, want a conflict on lib/core", errs)
+	}
+
+	upgrades := []Upgrade{{NewModuleIdentifier("app/x"), "1.0.0", "2.0.0", server.URL + "/metadata.json"}}
+	plan, errs, err := PlanDependencies(upgrades)
+	if err != nil || len(errs) > 0 {
+		t.Fatalf("PlanDependencies(upgrades) = %v, %v", errs, err)
+	}
+	if len(plan) != 1 || plan[0].Module != "lib/core" || plan[0].Version != "2.1.0" {
+		t.Errorf("PlanDependencies(upgrades) = %+v, want lib/core 2.1.0 shared", plan)
+	}
+
+	// The vault is untouched until the plan is applied
+	if vault, err := GetVault(); err != nil || vault.Modules["app/x"].Enabled != "1.0.0" {
+		t.Errorf("the vault changed while planning")
+	}
+}
+
+func TestPlanDependenciesKeepsNeededDuplicates(t *testing.T) {
+	useMemFs(t)
+	writeStoreDependencies(t, NewStoreIdentifier("app/x/1.0.0"), map[string]string{"lib/core": "^1.0.0"})
+	writeStoreDependencies(t, NewStoreIdentifier("app/x/2.0.0"), map[string]string{"lib/core": "^2.0.0"})
+	writeStoreDependencies(t, NewStoreIdentifier("app/y/1.0.0"), map[string]string{"lib/core": "^2.0.0"})
+	vault := &Vault{Modules: map[ModuleIdentifierStr]Module{
+		"app/x": {Enabled: "2.0.0", V: map[Version]Store{"1.0.0": {Installed: true}, "2.0.0": {Installed: true}}},
+		"app/y": {Enabled: "1.0.0", V: map[Version]Store{"1.0.0": {Installed: true}}},
+		"lib/core": {Enabled: "2.1.0", V: map[Version]Store{
+			"1.2.0": {Installed: true}, "2.0.0": {Installed: true}, "2.1.0": {Installed: true},
+		}},
+	}}
+	if err := SetVault(vault); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, errs, err := PlanDependencies(nil)
+	if err != nil || len(errs) > 0 {
+		t.Fatalf("PlanDependencies(nil) = %v, %v", errs, err)
+	}
+	if len(plan) != 1 || plan[0].Version != "2.1.0" {
+		t.Fatalf("PlanDependencies(nil) = %+v, want lib/core 2.1.0 shared", plan)
+	}
+	// app/x 1.0.0 is disabled but still needs lib/core 1.2.0
+	if duplicates := plan[0].Duplicates; len(duplicates) != 1 || duplicates[0] != "2.0.0" {
+		t.Errorf("the duplicates of lib/core are %v, want [2.0.0]", duplicates)
+	}
+}
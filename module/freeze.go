@@ -0,0 +1,93 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"errors"
+	"strings"
+)
+
+// FrozenModule pins an enabled module to the exact source it was installed from
+type FrozenModule struct {
+	Module string `json:"module"`
+	Source string `json:"source"`
+	Commit string `json:"commit,omitempty"`
+}
+
+// Freeze lists the enabled modules in load order, with their sources pinned to the installed commit when possible.
+// Modules installed from a local path can't be reproduced elsewhere and are reported as errors
+func Freeze() ([]FrozenModule, []error) {
+	vault, err := GetVault()
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	frozen := []FrozenModule{}
+	errs := []error{}
+	for _, moduleIdentifier := range vault.OrderedModules() {
+		module := vault.Modules[moduleIdentifier]
+		if module.Enabled == "" {
+			continue
+		}
+		identifier := StoreIdentifier{NewModuleIdentifier(string(moduleIdentifier)), module.Enabled}
+		store := module.V[module.Enabled]
+		if len(store.Metadatas) == 0 {
+			errs = append(errs, errors.New(identifier.String()+" was installed from a local path"))
+			continue
+		}
+
+		source := store.Metadatas[0]
+		commit := store.Commit
+		if commit == "" {
+			if commit, err = resolveCommit(source); err != nil {
+				errs = append(errs, errors.New("can't resolve the commit of "+identifier.String()+": "+err.Error()))
+			}
+		}
+		frozen = append(frozen, FrozenModule{identifier.String(), pinSource(source, commit), commit})
+	}
+	return frozen, errs
+}
+
+// pinSource rewrites the branch or tag of a raw link or git source to the given commit
+func pinSource(murl string, commit string) string {
+	if commit == "" {
+		return murl
+	}
+
+	if IsGitSource(murl) {
+		source, err := ParseGitSource(murl)
+		if err != nil {
+			return murl
+		}
+		pinned := gitSourcePrefix + source.Repo + "#ref=" + commit
+		if source.Path != "" {
+			pinned += "&path=" + source.Path
+		}
+		return pinned
+	}
+
+	parts := githubRawRe.FindStringSubmatch(murl)
+	if parts == nil {
+		return murl
+	}
+	prefix := "https://raw.githubusercontent.com/" + parts[1] + "/" + parts[2] + "/"
+	_, rest, _ := strings.Cut(strings.TrimPrefix(murl, prefix), "/")
+	return prefix + commit + "/" + rest
+}
@@ -0,0 +1,342 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"bespoke/link"
+	"bespoke/paths"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// useMemFs runs the test against an in-memory filesystem and folders of its own, the locks still
+// being real files. The in-memory filesystem has no symbolic links, modules are linked by copy
+func useMemFs(t *testing.T) {
+	t.Helper()
+	fs, mode := fsys.FS, link.Mode
+	config, cache, state, log, system := paths.ConfigPath, paths.CachePath, paths.StatePath, paths.LogPath, paths.SystemPath
+	t.Cleanup(func() {
+		fsys.FS, link.Mode = fs, mode
+		paths.ConfigPath, paths.CachePath, paths.StatePath, paths.LogPath, paths.SystemPath = config, cache, state, log, system
+		ConfigurePaths()
+	})
+
+	fsys.FS = afero.NewMemMapFs()
+	link.Mode = link.Copy
+	dir := t.TempDir()
+	paths.ConfigPath = filepath.Join(dir, "config")
+	paths.CachePath = filepath.Join(dir, "cache")
+	paths.StatePath = filepath.Join(dir, "state")
+	paths.LogPath = filepath.Join(dir, "log")
+	paths.SystemPath = filepath.Join(dir, "system")
+	ConfigurePaths()
+}
+
+func writeStoreMetadata(t *testing.T, identifier StoreIdentifier) {
+	t.Helper()
+	writeMetadataIn(t, identifier.toFilePath(), identifier)
+}
+
+func writeMetadataIn(t *testing.T, dir string, identifier StoreIdentifier) {
+	t.Helper()
+	metadata := Metadata{Name: string(identifier.Name), Version: string(identifier.Version), Authors: []string{string(identifier.Author)}}
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile(filepath.Join(dir, "metadata.json"), raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVaultRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		modules map[ModuleIdentifierStr]Module
+	}{
+		{"empty", map[ModuleIdentifierStr]Module{}},
+		{"enabled", map[ModuleIdentifierStr]Module{
+			"a/one": {Enabled: "1.0.0", V: map[Version]Store{"1.0.0": {Installed: true, Metadatas: []RemoteURL{}, Explicit: true}}},
+		}},
+		{"disabled and dependency", map[ModuleIdentifierStr]Module{
+			"a/one": {V: map[Version]Store{"1.0.0": {Installed: true, Metadatas: []RemoteURL{}, Explicit: true}}},
+			"b/two": {Enabled: "2.1.0", Priority: 1, V: map[Version]Store{
+				"2.0.0": {Installed: true, Metadatas: []RemoteURL{}},
+				"2.1.0": {Installed: true, Metadatas: []RemoteURL{"https://example.com/metadata.json"}, Local: true},
+			}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFs(t)
+			for identifier, module := range tt.modules {
+				if module.Enabled != "" {
+					writeStoreMetadata(t, StoreIdentifier{NewModuleIdentifier(string(identifier)), module.Enabled})
+				}
+			}
+			if err := SetVault(&Vault{Modules: tt.modules}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fsys.Stat(vaultPath); err != nil {
+				t.Fatalf("the vault wasn't written to the filesystem: %v", err)
+			}
+			vault, err := GetVault()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if vault.Schema != VaultSchema {
+				t.Errorf("schema = %d, want %d", vault.Schema, VaultSchema)
+			}
+			if !reflect.DeepEqual(vault.Modules, tt.modules) {
+				t.Errorf("modules = %+v, want %+v", vault.Modules, tt.modules)
+			}
+		})
+	}
+}
+
+func TestVaultRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		vault   string
+		shadow  string
+		modules []ModuleIdentifierStr
+		wantErr bool
+	}{
+		{"valid", `{"schema":1,"modules":{"a/one":{"v":{}}}}`, "", []ModuleIdentifierStr{"a/one"}, false},
+		{"corrupt, restored from the shadow copy", `{"schema":1,"mod`, `{"schema":1,"modules":{"b/two":{"v":{}}}}`, []ModuleIdentifierStr{"b/two"}, false},
+		{"corrupt without copies", `{"schema":1,"mod`, "", nil, true},
+		{"newer schema", `{"schema":99,"modules":{}}`, "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFs(t)
+			fsys.MkdirAll(modulesFolder, 0755)
+			if err := fsys.WriteFile(vaultPath, []byte(tt.vault), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.shadow != "" {
+				if err := fsys.WriteFile(vaultPath+".1234.new", []byte(tt.shadow), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			vault, err := GetVault()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			for _, identifier := range tt.modules {
+				if _, ok := vault.Modules[identifier]; !ok {
+					t.Errorf("%s is missing from %+v", identifier, vault.Modules)
+				}
+			}
+		})
+	}
+}
+
+func TestStore(t *testing.T) {
+	one := NewStoreIdentifier("a/one/1.0.0")
+	two := NewStoreIdentifier("a/one/2.0.0")
+	tests := []struct {
+		name    string
+		install []StoreIdentifier
+		enable  StoreIdentifier
+		remove  []StoreIdentifier
+		enabled Version
+		left    []Version
+	}{
+		{"install and enable", []StoreIdentifier{one}, one, nil, "1.0.0", []Version{"1.0.0"}},
+		{"switch version", []StoreIdentifier{one, two}, two, nil, "2.0.0", []Version{"1.0.0", "2.0.0"}},
+		{"remove the enabled version", []StoreIdentifier{one, two}, two, []StoreIdentifier{two}, "", []Version{"1.0.0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFs(t)
+			if err := SetVault(&Vault{Modules: map[ModuleIdentifierStr]Module{}}); err != nil {
+				t.Fatal(err)
+			}
+			for _, identifier := range tt.install {
+				writeStoreMetadata(t, identifier)
+				metadata, err := readStoreMetadata(identifier)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := AddModuleInVault(&metadata, &Store{Installed: true, Metadatas: []RemoteURL{}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := ToggleModuleInVault(tt.enable); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := fsys.Stat(filepath.Join(tt.enable.ModuleIdentifier.toFilePath(), "metadata.json")); err != nil {
+				t.Errorf("%s wasn't linked into the modules folder: %v", tt.enable, err)
+			}
+			for _, identifier := range tt.remove {
+				if err := RemoveModuleInVault(identifier); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			vault, err := GetVault()
+			if err != nil {
+				t.Fatal(err)
+			}
+			module := vault.Modules[one.ModuleIdentifier.toPath()]
+			if module.Enabled != tt.enabled {
+				t.Errorf("enabled = %q, want %q", module.Enabled, tt.enabled)
+			}
+			for _, version := range tt.left {
+				if _, ok := module.V[version]; !ok {
+					t.Errorf("%s is missing from the vault", version)
+				}
+			}
+			if len(module.V) != len(tt.left) {
+				t.Errorf("versions = %v, want %v", module.V, tt.left)
+			}
+		})
+	}
+}
+
+func TestLookupsLeaveVault(t *testing.T) {
+	vault := &Vault{Modules: map[ModuleIdentifierStr]Module{}}
+	if module := vault.getModule("a/missing"); module.V == nil {
+		t.Errorf("getModule returned a module without versions")
+	}
+	if _, ok := vault.getEnabledStore("a/missing"); ok {
+		t.Errorf("a/missing has an enabled store")
+	}
+	if len(vault.Modules) != 0 {
+		t.Errorf("looking up a/missing changed the vault: %v", vault.Modules)
+	}
+
+	if !vault.setStore(NewStoreIdentifier("a/one/1.0.0"), &Store{Installed: true}) {
+		t.Fatal("setStore refused a/one/1.0.0")
+	}
+	if _, ok := vault.Modules["a/one"].V["1.0.0"]; !ok {
+		t.Errorf("setStore didn't add a/one/1.0.0 to the vault")
+	}
+}
+
+func TestInspectLinks(t *testing.T) {
+	one := NewStoreIdentifier("a/one/1.0.0")
+	tests := []struct {
+		name    string
+		mode    link.Strategy
+		enabled Version
+		copied  bool
+		// unknown leaves the module out of the vault
+		unknown bool
+		want    LinkState
+	}{
+		{"copy of the enabled version", link.Copy, "1.0.0", true, false, LinkOk},
+		{"enabled without a copy", link.Copy, "1.0.0", false, false, LinkMissing},
+		{"copy of a disabled module", link.Copy, "", true, false, LinkStale},
+		{"copy of a module missing from the vault", link.Copy, "", true, true, LinkStale},
+		{"folder made by hand next to links", link.Symlink, "1.0.0", true, false, LinkForeign},
+		{"folder made by hand for a disabled module", link.Symlink, "", true, false, LinkForeign},
+		{"folder made by hand for a module missing from the vault", link.Symlink, "", true, true, LinkForeign},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFs(t)
+			writeStoreMetadata(t, one)
+			vault := &Vault{Modules: map[ModuleIdentifierStr]Module{
+				one.ModuleIdentifier.toPath(): {Enabled: tt.enabled, V: map[Version]Store{one.Version: {Installed: true}}},
+			}}
+			if tt.unknown {
+				vault.Modules = map[ModuleIdentifierStr]Module{}
+			}
+			if err := SetVault(vault); err != nil {
+				t.Fatal(err)
+			}
+			link.Mode = tt.mode
+			if tt.copied {
+				if err := link.CopyDir(one.toFilePath(), one.ModuleIdentifier.toFilePath()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			statuses, err := InspectLinks()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(statuses) != 1 {
+				t.Fatalf("statuses = %+v, want one", statuses)
+			}
+			if statuses[0].State != tt.want {
+				t.Errorf("state = %s, want %s", statuses[0].State, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveStoreIdentifier(t *testing.T) {
+	useMemFs(t)
+	vault := &Vault{Modules: map[ModuleIdentifierStr]Module{
+		"a/one": {Enabled: "1.0.0", V: map[Version]Store{"1.0.0": {Installed: true}}},
+		"a/two": {V: map[Version]Store{"1.0.0": {Installed: true}}},
+	}}
+	if err := SetVault(vault); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		identifier string
+		want       string
+		wantErr    bool
+	}{
+		{"a/one/2.0.0", "a/one/2.0.0", false},
+		{"a/one", "a/one/1.0.0", false},
+		{"a/one/", "a/one/1.0.0", false},
+		{"a/two", "", true},
+		{"foo", "", true},
+		{"a/b/c/d", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ResolveStoreIdentifier(tt.identifier)
+		if (err != nil) != tt.wantErr || err == nil && got.String() != tt.want {
+			t.Errorf("ResolveStoreIdentifier(%q) = %s, %v, want %s", tt.identifier, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseIdentifiers(t *testing.T) {
+	for _, identifier := range []string{"foo", "foo/bar/1.0.0", "/bar", "foo/"} {
+		if _, ok := ParseModuleIdentifier(identifier); ok {
+			t.Errorf("ParseModuleIdentifier(%q) accepted it", identifier)
+		}
+	}
+	if identifier, ok := ParseModuleIdentifier("foo/bar"); !ok || identifier.String() != "foo/bar" {
+		t.Errorf("ParseModuleIdentifier(foo/bar) = %s, %v", identifier, ok)
+	}
+	for _, identifier := range []string{"foo", "foo/bar", "foo/bar/1.0.0/x"} {
+		if _, ok := ParseStoreIdentifier(identifier); ok {
+			t.Errorf("ParseStoreIdentifier(%q) accepted it", identifier)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"bespoke/link"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// With symlinks, the modules folder loaded by Spotify is itself a link to a generation folder holding vault.json
+// and the links of the enabled modules. Every vault change is written to a new generation which then replaces the
+// previous one with a single rename (two steps on Windows, see swapModulesFolder), so that the client never sees
+// a half-applied set of modules.
+// Copies are only picked up by `bespoke apply`, so in copy mode the modules folder is still updated in place
+
+// stagedModules reports whether vault changes are published as new generations
+func stagedModules() bool {
+	return link.Mode == link.Symlink && !DryRun
+}
+
+// batching defers publishing the vault until the end of Batch, batchedVault holds its latest state
+var batching bool
+var batchedVault []byte
+
+// Batch runs several vault changes (e.g. enabling every module matching a pattern) and publishes them as a
+// single generation. The changes made before an error are still published
+func Batch(changes func() error) error {
+	if !stagedModules() || batching {
+		return changes()
+	}
+
+	// The vault is read at the first change and written at the end, other processes mustn't change it meanwhile
+	unlock, err := lockVault()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	batching = true
+	err = changes()
+	batching = false
+
+	pending := batchedVault
+	batchedVault = nil
+	if pending == nil {
+		return err
+	}
+	var vault Vault
+	if uerr := json.Unmarshal(pending, &vault); uerr != nil {
+		return uerr
+	}
+	if perr := publishModules(&vault); err == nil {
+		err = perr
+	}
+	compressDisabledVersions()
+	return err
+}
+
+// publishModules writes vault.json, the manifest and the links of the enabled modules to a new generation and swaps it in
+func publishModules(vault *Vault) error {
+	vaultJson, err := json.Marshal(vault)
+	if err != nil {
+		return err
+	}
+	if batching {
+		batchedVault = vaultJson
+		return nil
+	}
+
+	lock, err := lockGenerations()
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
+	if err := fsys.MkdirAll(generationsFolder, os.ModePerm); err != nil {
+		return err
+	}
+	generation, err := fsys.MkdirTemp(generationsFolder, "")
+	if err != nil {
+		return err
+	}
+	// MkdirTemp creates private folders, Spotify runs as the same user but may be sandboxed
+	if err := fsys.Chmod(generation, 0755); err != nil {
+		return err
+	}
+
+	err = writeVaultFile(filepath.Join(generation, filepath.Base(vaultPath)), vaultJson)
+	if err == nil {
+		err = writeLoaderManifest(generation, vault)
+	}
+	for identifier, module := range vault.Modules {
+		if err != nil {
+			break
+		}
+		if module.Enabled == "" {
+			continue
+		}
+		storeIdentifier := StoreIdentifier{NewModuleIdentifier(string(identifier)), module.Enabled}
+		name := filepath.Join(generation, string(storeIdentifier.Author), string(storeIdentifier.Name))
+		err = link.Create(storeIdentifier.toFilePath(), name)
+	}
+	if err == nil {
+		err = swapModulesFolder(generation)
+	}
+	if err != nil {
+		fsys.RemoveAll(generation)
+		return err
+	}
+
+	pruneGenerations(generation)
+	return nil
+}
+
+// swapModulesFolder points the modules folder to a generation. Windows can't rename a link over another, there the
+// old link is removed first, so Spotify may find no modules folder for a moment. The old modules folder is put back
+// when the new one can't take its place
+func swapModulesFolder(generation string) error {
+	next := modulesFolder + ".next"
+	fsys.Remove(next)
+	if err := fsys.Symlink(generation, next); err != nil {
+		return err
+	}
+
+	// The modules folder of older installs is a plain folder, which can't be replaced in one step. It is only
+	// removed once the link took its place
+	previous := ""
+	if fi, err := fsys.Lstat(modulesFolder); err == nil && fi.Mode()&os.ModeSymlink == 0 {
+		previous = modulesFolder + ".previous"
+		fsys.RemoveAll(previous)
+		if err := fsys.Rename(modulesFolder, previous); err != nil {
+			fsys.Remove(next)
+			return err
+		}
+	}
+
+	err := fsys.Rename(next, modulesFolder)
+	if err != nil && previous == "" {
+		if linked, readErr := fsys.Readlink(modulesFolder); readErr == nil && fsys.Remove(modulesFolder) == nil {
+			if err = fsys.Rename(next, modulesFolder); err != nil {
+				fsys.Symlink(linked, modulesFolder)
+			}
+		}
+	}
+	if err != nil {
+		fsys.Remove(next)
+		if previous != "" {
+			fsys.Rename(previous, modulesFolder)
+		}
+		return err
+	}
+	if previous != "" {
+		fsys.RemoveAll(previous)
+	}
+	return nil
+}
+
+// pruneGenerations removes the generations older than the current one, which the modules folder links to.
+// Newer ones may belong to a process that doesn't take the generations lock and are left alone
+func pruneGenerations(current string) {
+	currentInfo, err := fsys.Stat(current)
+	if err != nil {
+		return
+	}
+	if linked, err := fsys.Readlink(modulesFolder); err != nil || filepath.Clean(linked) != filepath.Clean(current) {
+		return
+	}
+	entries, err := fsys.ReadDir(generationsFolder)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		name := filepath.Join(generationsFolder, entry.Name())
+		info, err := entry.Info()
+		if name == current || err != nil || !info.ModTime().Before(currentInfo.ModTime()) {
+			continue
+		}
+		fsys.RemoveAll(name)
+	}
+}
@@ -0,0 +1,85 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+// failingRenameFs fails the renames of source
+type failingRenameFs struct {
+	*afero.OsFs
+	source string
+}
+
+func (f failingRenameFs) Rename(oldname string, newname string) error {
+	if oldname == f.source {
+		return errors.New("rename failed")
+	}
+	return f.OsFs.Rename(oldname, newname)
+}
+
+func TestSwapModulesFolderFromPlainFolder(t *testing.T) {
+	tests := []struct {
+		name    string
+		fail    bool
+		content string
+	}{
+		{"swapped", false, "new"},
+		{"rename fails", true, "old"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFs(t)
+			fsys.FS = &afero.OsFs{}
+			if tt.fail {
+				fsys.FS = failingRenameFs{&afero.OsFs{}, modulesFolder + ".next"}
+			}
+			generation := filepath.Join(generationsFolder, "1")
+			for folder, content := range map[string]string{modulesFolder: "old", generation: "new"} {
+				if err := os.MkdirAll(folder, 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(folder, "vault.json"), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := swapModulesFolder(generation)
+			if (err != nil) != tt.fail {
+				t.Fatalf("swapModulesFolder() = %v", err)
+			}
+			if content, err := os.ReadFile(vaultPath); err != nil || string(content) != tt.content {
+				t.Errorf("the modules folder holds vault %q (%v), want %q", content, err, tt.content)
+			}
+			for _, leftover := range []string{modulesFolder + ".previous", modulesFolder + ".next"} {
+				if _, err := os.Lstat(leftover); err == nil {
+					t.Errorf("%s was left behind", leftover)
+				}
+			}
+		})
+	}
+}
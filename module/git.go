@@ -0,0 +1,231 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/link"
+	"bespoke/network"
+	"bespoke/trace"
+	"errors"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// git+<repo url>#ref=<branch|tag|commit>&path=<path/to/module>
+type GitSource struct {
+	Repo string
+	Ref  string
+	Path string
+}
+
+const gitSourcePrefix = "git+"
+
+func IsGitSource(murl string) bool {
+	return strings.HasPrefix(murl, gitSourcePrefix)
+}
+
+func ParseGitSource(murl string) (GitSource, error) {
+	repo, fragment, _ := strings.Cut(strings.TrimPrefix(murl, gitSourcePrefix), "#")
+	params, err := url.ParseQuery(fragment)
+	if err != nil {
+		return GitSource{}, err
+	}
+	if repo == "" {
+		return GitSource{}, errors.New("missing repository in " + murl)
+	}
+	ref := params.Get("ref")
+	// Both are passed to git, which would take them for options
+	if strings.HasPrefix(repo, "-") || strings.HasPrefix(ref, "-") {
+		return GitSource{}, errors.New("invalid git source " + murl + ": the repository and ref can't start with -")
+	}
+	modulePath := params.Get("path")
+	// The module folder must stay inside the clone
+	if strings.HasPrefix(modulePath, "/") || strings.HasPrefix(modulePath, "\\") || filepath.IsAbs(modulePath) ||
+		slices.Contains(strings.FieldsFunc(modulePath, isPathSeparator), "..") {
+		return GitSource{}, errors.New("invalid git source " + murl + ": the path must be relative to the repository")
+	}
+	return GitSource{
+		Repo: repo,
+		Ref:  ref,
+		Path: modulePath,
+	}, nil
+}
+
+func isPathSeparator(r rune) bool {
+	return r == '/' || r == '\\'
+}
+
+func git(dir string, args ...string) error {
+	cmd := exec.CommandContext(Context, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = network.GitEnv()
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func gitOutput(dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(Context, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = network.GitEnv()
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// shallowClone fetches a single ref of the repository, relying on the user's git credentials
+func (gs GitSource) shallowClone(dir string) error {
+	ref := gs.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	steps := [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "--", "origin", gs.Repo},
+		{"fetch", "--quiet", "--depth", "1", "--", "origin", ref},
+		{"checkout", "--quiet", "FETCH_HEAD"},
+	}
+	for _, step := range steps {
+		if err := git(dir, step...); err != nil {
+			return errors.New("git " + step[0] + " failed: " + err.Error())
+		}
+	}
+	return nil
+}
+
+// cloneModule clones the source into a temporary folder, which the caller must remove, and reads the module metadata
+func (gs GitSource) cloneModule() (tmp string, moduleDir string, metadata Metadata, err error) {
+	defer trace.Start("clone", gs.Repo).Finish()
+	tmp, err = os.MkdirTemp("", "bespoke-git-")
+	if err != nil {
+		return "", "", Metadata{}, err
+	}
+	if err := gs.shallowClone(tmp); err != nil {
+		return tmp, "", Metadata{}, err
+	}
+
+	moduleDir = filepath.Join(tmp, filepath.FromSlash(gs.Path))
+	metadata, err = fetchLocalMetadata(filepath.Join(moduleDir, "metadata.json"))
+	return tmp, moduleDir, metadata, err
+}
+
+func InstallModuleGit(murl string) error {
+	source, err := ParseGitSource(murl)
+	if err != nil {
+		return err
+	}
+	if err := ActivePolicy.CheckSource(murl); err != nil {
+		return err
+	}
+	if err := checkSourceTrust(murl); err != nil {
+		return err
+	}
+
+	notify(StepDownload, StoreIdentifier{}, murl)
+	tmp, moduleDir, metadata, err := source.cloneModule()
+	if tmp != "" {
+		defer os.RemoveAll(tmp)
+	}
+	if err != nil {
+		return err
+	}
+	// There is no archive to scan, the scanner is given the cloned folder of the module
+	if err := scanPath(moduleDir, murl); err != nil {
+		return err
+	}
+
+	commit, _ := gitOutput(tmp, "rev-parse", "HEAD")
+	return installModuleDir(murl, moduleDir, metadata, commit)
+}
+
+// installModuleDir copies a module fetched into a temporary folder (cloned, extracted) into the store
+func installModuleDir(murl string, moduleDir string, metadata Metadata, commit string) error {
+	storeIdentifier := metadata.getStoreIdentifier()
+	if err := ActivePolicy.CheckLicense(storeIdentifier, metadata.License); err != nil {
+		return err
+	}
+	announcePermissions(storeIdentifier, &metadata)
+
+	signed, err := verifyLocalMetadata(filepath.Join(moduleDir, "metadata.json"), &metadata)
+	if err != nil {
+		return err
+	}
+	if err := ActivePolicy.CheckModule(storeIdentifier, signed != nil); err != nil {
+		return err
+	}
+	if err := signed.verifySource(murl); err != nil {
+		return err
+	}
+	if err := signed.verifyCommit(commit); err != nil {
+		return err
+	}
+
+	err = installInStore(storeIdentifier, signed != nil, func(dir string) error {
+		if skip("copy %s into %s", murl, dir) {
+			return nil
+		}
+		if err := link.CopyDir(moduleDir, dir); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(filepath.Join(dir, ".git")); err != nil {
+			return err
+		}
+		if err := pruneFiles(dir, metadata.filesFilter()); err != nil {
+			return err
+		}
+		return signed.verifyFiles(dir)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = AddModuleInVault(&metadata, &Store{
+		Installed: true,
+		Metadatas: []string{murl},
+		Verified:  signed != nil,
+		Commit:    commit,
+	})
+	if err != nil {
+		return err
+	}
+
+	sendInstallPing(storeIdentifier)
+	return nil
+}
+
+// InstallModuleMURL installs a module from any supported source: a metadata URL (or a page of github.com,
+// see NormalizeSourceURL), a git+ source, a gh-release:// source or a [<registry>:]<author>/<name>[@<version>] reference
+func InstallModuleMURL(murl string) error {
+	murl = NormalizeSourceURL(murl)
+	notify(StepResolve, StoreIdentifier{}, murl)
+	if IsReleaseSource(murl) {
+		return InstallModuleRelease(murl)
+	}
+	if IsGitSource(murl) {
+		return InstallModuleGit(murl)
+	}
+	if ref, ok := ParseModuleRef(murl); ok {
+		return InstallModuleRef(ref)
+	}
+	return InstallModuleRemote(murl)
+}
@@ -0,0 +1,53 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+package module
+
+import (
+	"testing"
+)
+
+func TestParseGitSource(t *testing.T) {
+	tests := []struct {
+		murl    string
+		want    GitSource
+		wantErr bool
+	}{
+		{murl: "git+https://example.com/a/b.git", want: GitSource{Repo: "https://example.com/a/b.git"}},
+		{murl: "git+https://example.com/a/b.git#ref=v1&path=mod", want: GitSource{Repo: "https://example.com/a/b.git", Ref: "v1", Path: "mod"}},
+		{murl: "git+", wantErr: true},
+		{murl: "git+--upload-pack=touch /tmp/x", wantErr: true},
+		{murl: "git+https://example.com/a/b.git#ref=--upload-pack=x", wantErr: true},
+		{murl: "git+https://example.com/a/b.git#path=a/../b", wantErr: true},
+		{murl: "git+https://example.com/a/b.git#path=../outside", wantErr: true},
+		{murl: "git+https://example.com/a/b.git#path=a\\..\\..\\outside", wantErr: true},
+		{murl: "git+https://example.com/a/b.git#path=/etc", wantErr: true},
+		{murl: "git+https://example.com/a/b.git#path=a..b/c", want: GitSource{Repo: "https://example.com/a/b.git", Path: "a..b/c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.murl, func(t *testing.T) {
+			got, err := ParseGitSource(tt.murl)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseGitSource(%q) error = %v, wantErr %v", tt.murl, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseGitSource(%q) = %+v, want %+v", tt.murl, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/network"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// MissingSource records in the vault that the source of the enabled version of a module is gone (its repository,
+// release or metadata file was deleted), as found by CheckUpgrades
+type MissingSource struct {
+	URL   RemoteURL `json:"url"`
+	Error string    `json:"error"`
+	// Since is when the source was first found missing
+	Since time.Time `json:"since"`
+}
+
+// SourceMissingError is returned by checkSource for sources answering that they don't exist
+type SourceMissingError struct {
+	URL    RemoteURL
+	Reason string
+}
+
+func (e *SourceMissingError) Error() string {
+	return e.Reason
+}
+
+// isGone tells apart the responses meaning a source was deleted from transient failures
+func isGone(err error) bool {
+	var statusErr *network.StatusError
+	if errors.As(err, &statusErr) {
+		return goneStatus(statusErr.StatusCode, statusErr.URL)
+	}
+	var githubErr *github.ErrorResponse
+	if errors.As(err, &githubErr) && githubErr.Response != nil && githubErr.Response.Request != nil {
+		return goneStatus(githubErr.Response.StatusCode, githubErr.Response.Request.URL.String())
+	}
+	return false
+}
+
+// goneStatus tells whether a response status for url means the document was deleted. Hosts such as GitHub
+// answer 404 for the private documents of anonymous users, a 404 only counts when a token was sent
+func goneStatus(code int, url string) bool {
+	return code == http.StatusGone || code == http.StatusNotFound && network.Authenticates(url)
+}
+
+// pingSource checks the source of the enabled version of a module, known is false when the check failed for another
+// reason than the source being gone (and missing is nil when the source is fine)
+func pingSource(module *Module) (missing *MissingSource, known bool) {
+	metadatas := module.V[module.Enabled].Metadatas
+	if len(metadatas) == 0 {
+		return nil, false
+	}
+	err := checkSource(metadatas[0])
+	var missingErr *SourceMissingError
+	if errors.As(err, &missingErr) {
+		return &MissingSource{URL: missingErr.URL, Error: missingErr.Reason}, true
+	}
+	return nil, err == nil
+}
+
+// recordSourceHealth marks the modules whose source is missing in the vault, and clears the mark of the others.
+// The vault is only written when a mark changes
+func recordSourceHealth(health map[ModuleIdentifierStr]*MissingSource) error {
+	unlock, err := lockVault()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	vault, err := GetVault()
+	if err != nil {
+		return err
+	}
+	changed := false
+	now := time.Now().UTC().Truncate(time.Second)
+	for identifier, missing := range health {
+		module, ok := vault.Modules[identifier]
+		if !ok {
+			continue
+		}
+		previous := module.SourceMissing
+		if missing != nil && previous != nil && previous.URL == missing.URL {
+			missing.Since = previous.Since
+		} else if missing != nil {
+			missing.Since = now
+		}
+		if (missing == nil && previous == nil) || (missing != nil && previous != nil && *missing == *previous) {
+			continue
+		}
+		module.SourceMissing = missing
+		vault.Modules[identifier] = module
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return SetVault(vault)
+}
+
+// Alternatives suggests modules of the registries that may replace one whose source is missing: forks published
+// under the same name by other authors first, then the modules sharing the most tags with it
+func Alternatives(identifier ModuleIdentifier, limit int) []SearchResult {
+	tags := []string{}
+	if vault, err := GetVault(); err == nil {
+		if module, ok := vault.Modules[identifier.toPath()]; ok {
+			if metadata, err := readStoreMetadata(StoreIdentifier{identifier, module.Enabled}); err == nil {
+				tags = metadata.Tags
+			}
+		}
+	}
+
+	type candidate struct {
+		SearchResult
+		score int
+	}
+	candidates := []candidate{}
+	seen := map[ModuleIdentifierStr]bool{identifier.toPath(): true}
+	for _, registry := range sortedRegistries() {
+		index, err := fetchRegistryIndex(registry)
+		if err != nil {
+			continue
+		}
+		if len(tags) == 0 {
+			tags = index.Modules[identifier.toPath()].Tags
+		}
+		for other, entry := range index.Modules {
+			if seen[other] {
+				continue
+			}
+			score := 0
+			if _, name, _ := strings.Cut(string(other), "/"); name == string(identifier.Name) {
+				score += 100
+			}
+			for _, tag := range entry.Tags {
+				if slices.Contains(tags, tag) {
+					score++
+				}
+			}
+			if score > 0 {
+				seen[other] = true
+				candidates = append(candidates, candidate{SearchResult{registry.Name, other, entry}, score})
+			}
+		}
+	}
+
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		if a.score != b.score {
+			return b.score - a.score
+		}
+		return strings.Compare(string(a.Identifier), string(b.Identifier))
+	})
+	alternatives := []SearchResult{}
+	for _, c := range candidates[:min(limit, len(candidates))] {
+		alternatives = append(alternatives, c.SearchResult)
+	}
+	return alternatives
+}
@@ -0,0 +1,85 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+package module
+
+import (
+	"bespoke/network"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestGoneStatus(t *testing.T) {
+	tokens := network.Tokens
+	t.Cleanup(func() { network.Tokens = tokens })
+	network.Tokens = map[string]string{"private.example.com": "token"}
+	t.Setenv("NETRC", filepath.Join(t.TempDir(), "missing"))
+
+	tests := []struct {
+		code int
+		url  string
+		want bool
+	}{
+		{http.StatusGone, "https://example.com/metadata.json", true},
+		{http.StatusNotFound, "https://private.example.com/metadata.json", true},
+		{http.StatusNotFound, "https://example.com/metadata.json", false},
+		{http.StatusNotFound, "http://private.example.com/metadata.json", false},
+		{http.StatusUnauthorized, "https://private.example.com/metadata.json", false},
+		{http.StatusInternalServerError, "https://private.example.com/metadata.json", false},
+	}
+	for _, tt := range tests {
+		if got := goneStatus(tt.code, tt.url); got != tt.want {
+			t.Errorf("goneStatus(%d, %s) = %v, want %v", tt.code, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestRecordSourceHealth(t *testing.T) {
+	useMemFs(t)
+	missing := &MissingSource{URL: "https://example.com/metadata.json", Error: "gone"}
+	vault := &Vault{Modules: map[ModuleIdentifierStr]Module{
+		"a/one": {Enabled: "1.0.0", V: map[Version]Store{"1.0.0": {Installed: true}}},
+		"a/two": {Enabled: "1.0.0", V: map[Version]Store{"1.0.0": {Installed: true}}, SourceMissing: missing},
+	}}
+	if err := SetVault(vault); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nested in another vault operation, the lock is shared
+	unlock, err := lockVault()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = recordSourceHealth(map[ModuleIdentifierStr]*MissingSource{"a/one": {URL: missing.URL, Error: "gone"}, "a/two": nil})
+	unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vault, err = GetVault()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := vault.Modules["a/one"].SourceMissing; got == nil || got.URL != missing.URL || got.Since.IsZero() {
+		t.Errorf("a/one is recorded as %+v, want missing since now", got)
+	}
+	if got := vault.Modules["a/two"].SourceMissing; got != nil {
+		t.Errorf("a/two is recorded as %+v, want its mark cleared", got)
+	}
+}
@@ -0,0 +1,143 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+const (
+	hooksOwner = "spicetify"
+	hooksRepo  = "hooks"
+	hooksAsset = "hooks.tar.gz"
+)
+
+// HooksChannels lists the release channels of the hooks, from the most to the least stable.
+// beta also follows prereleases, nightly follows the rolling "nightly" release
+var HooksChannels = []string{"stable", "beta", "nightly"}
+
+// HooksPublicKey is the base64 encoded ed25519 key the checksums of the hooks must be signed with, when set
+var HooksPublicKey string
+
+type HooksRelease struct {
+	Version string `json:"version"`
+	URL     string `json:"url"`
+	// Checksums is the URL of the SHA256SUMS file (or .sha256 sidecar) of the release, its detached
+	// signature is published next to it with the .sig suffix
+	Checksums string `json:"checksums,omitempty"`
+}
+
+// ResolveHooksRelease finds the hooks release of a channel, or the pinned release when pin is set
+func ResolveHooksRelease(channel string, pin string) (HooksRelease, error) {
+	ctx := Context
+	var release *github.RepositoryRelease
+	var err error
+	switch {
+	case pin != "":
+		release, _, err = client.Repositories.GetReleaseByTag(ctx, hooksOwner, hooksRepo, pin)
+	case channel == "stable":
+		release, _, err = client.Repositories.GetLatestRelease(ctx, hooksOwner, hooksRepo)
+	case channel == "beta":
+		var releases []*github.RepositoryRelease
+		releases, _, err = client.Repositories.ListReleases(ctx, hooksOwner, hooksRepo, &github.ListOptions{PerPage: 30})
+		i := slices.IndexFunc(releases, func(r *github.RepositoryRelease) bool {
+			return !r.GetDraft() && r.GetTagName() != "nightly"
+		})
+		if err == nil && i == -1 {
+			err = errors.New("no hooks release found")
+		} else if err == nil {
+			release = releases[i]
+		}
+	case channel == "nightly":
+		release, _, err = client.Repositories.GetReleaseByTag(ctx, hooksOwner, hooksRepo, "nightly")
+	default:
+		err = errors.New("unknown hooks channel " + channel)
+	}
+	if err != nil {
+		return HooksRelease{}, err
+	}
+
+	for _, asset := range release.Assets {
+		if asset.GetName() != hooksAsset {
+			continue
+		}
+		version := release.GetTagName()
+		// The nightly tag is reused by every build, tell them apart by their upload date
+		if version == "nightly" {
+			version += "-" + asset.GetUpdatedAt().UTC().Format("20060102150405")
+		}
+		checksums := ""
+		for _, sums := range []string{hooksAsset + ".sha256", checksumsFile} {
+			if asset, err := findAsset(release, sums); err == nil {
+				checksums = asset.GetBrowserDownloadURL()
+				break
+			}
+		}
+		return HooksRelease{version, asset.GetBrowserDownloadURL(), checksums}, nil
+	}
+	return HooksRelease{}, errors.New("hooks release " + release.GetTagName() + " has no " + hooksAsset)
+}
+
+// Verify checks the downloaded hooks archive against the checksum published with the release,
+// and the checksums against their signature when HooksPublicKey is set
+func (r HooksRelease) Verify(raw []byte) error {
+	if r.Checksums == "" {
+		return errors.New("hooks " + r.Version + " have no published checksum (" + checksumsFile + " or " + hooksAsset + ".sha256), refusing to install unverifiable hooks")
+	}
+	sums, err := downloadBytes(r.Checksums)
+	if err != nil {
+		return errors.New("can't download the checksums of the hooks: " + err.Error())
+	}
+
+	if HooksPublicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(HooksPublicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return errors.New("the hooks public key isn't a valid ed25519 key")
+		}
+		encoded, err := downloadBytes(r.Checksums + signatureSuffix)
+		if err != nil {
+			return errors.New("can't download the signature of the hooks checksums: " + err.Error())
+		}
+		signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+		if err != nil || !ed25519.Verify(key, sums, signature) {
+			return errors.New("the checksums of the hooks aren't signed by the hooks public key, refusing to install them")
+		}
+	}
+
+	name := path.Base(r.URL)
+	sum, ok := parseChecksums(sums)[name]
+	if !ok {
+		return errors.New(r.Checksums + " has no checksum for " + name)
+	}
+	h := sha256.Sum256(raw)
+	if hex.EncodeToString(h[:]) != sum {
+		return errors.New(name + " doesn't match its checksum, refusing to install the hooks")
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Operation string
+
+const (
+	OpInstall Operation = "install"
+	OpEnable  Operation = "enable"
+	OpDisable Operation = "disable"
+	OpRemove  Operation = "remove"
+	OpUpgrade Operation = "upgrade"
+	OpRename  Operation = "rename"
+	OpOrder   Operation = "order"
+	OpRepair  Operation = "repair"
+	OpMark    Operation = "mark"
+	OpScheme  Operation = "scheme"
+	OpUndo    Operation = "undo"
+	OpRestore Operation = "restore"
+)
+
+type JournalEntry struct {
+	ID         string    `json:"id"`
+	Time       time.Time `json:"time"`
+	Operation  Operation `json:"operation"`
+	Identifier string    `json:"identifier"`
+	Command    string    `json:"command"`
+}
+
+var (
+	// journalMu guards recording and initiator
+	journalMu sync.Mutex
+	// Undoing an operation goes through the regular vault mutations, which mustn't be journaled themselves
+	recording = true
+	// initiator describes what triggered the running operation, the command line of this process by default
+	initiator = strings.Join(os.Args, " ")
+)
+
+// operations serializes the operations run with RunAs
+var operations sync.Mutex
+
+// RunAs runs f as an operation triggered by by, whose vault mutations are journaled with it. Operations run one at
+// a time, so that concurrent callers such as the RPC connections of the daemon don't journal under each other's name
+func RunAs(by string, f func() error) error {
+	operations.Lock()
+	defer operations.Unlock()
+
+	journalMu.Lock()
+	previous := initiator
+	initiator = by
+	journalMu.Unlock()
+	defer func() {
+		journalMu.Lock()
+		initiator = previous
+		journalMu.Unlock()
+	}()
+	return f()
+}
+
+// withoutRecording runs f without journaling its vault mutations
+func withoutRecording(f func()) {
+	journalMu.Lock()
+	recording = false
+	journalMu.Unlock()
+	defer func() {
+		journalMu.Lock()
+		recording = true
+		journalMu.Unlock()
+	}()
+	f()
+}
+
+func snapshotVault() []byte {
+	if batching && batchedVault != nil {
+		return batchedVault
+	}
+	raw, _ := fsys.ReadFile(vaultPath)
+	return raw
+}
+
+func (e *JournalEntry) snapshotPath() string {
+	return filepath.Join(snapshotsFolder, e.ID+".json")
+}
+
+// configSnapshotPath holds the config file before the operations that replace it
+func (e *JournalEntry) configSnapshotPath() string {
+	return filepath.Join(snapshotsFolder, e.ID+".config")
+}
+
+// record appends an entry to the journal, along with the state of the vault before the mutation
+func record(operation Operation, identifier string, before []byte) error {
+	return recordWithConfig(operation, identifier, before, nil)
+}
+
+// recordWithConfig also keeps config, the content of the config file before the mutation, for the operations
+// that replace it
+func recordWithConfig(operation Operation, identifier string, before []byte, config []byte) error {
+	journalMu.Lock()
+	recorded, command := recording, initiator
+	journalMu.Unlock()
+	if !recorded || DryRun {
+		return nil
+	}
+
+	now := time.Now()
+	entry := JournalEntry{
+		ID:         strconv.FormatInt(now.UnixNano(), 36),
+		Time:       now,
+		Operation:  operation,
+		Identifier: identifier,
+		Command:    command,
+	}
+	entryJson, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if before != nil || config != nil {
+		if err := fsys.MkdirAll(snapshotsFolder, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	if before != nil {
+		if err := fsys.WriteFile(entry.snapshotPath(), before, 0600); err != nil {
+			return err
+		}
+	}
+	if config != nil {
+		if err := fsys.WriteFile(entry.configSnapshotPath(), config, 0600); err != nil {
+			return err
+		}
+	}
+
+	if err := fsys.MkdirAll(filepath.Dir(journalPath), os.ModePerm); err != nil {
+		return err
+	}
+	file, err := fsys.OpenFile(journalPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(entryJson, '\n'))
+	return err
+}
+
+func ReadJournal() ([]JournalEntry, error) {
+	file, err := fsys.Open(journalPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []JournalEntry{}, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := []JournalEntry{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,58 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestRunAsConcurrently(t *testing.T) {
+	useMemFs(t)
+	wg := sync.WaitGroup{}
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			by := "rpc " + strconv.Itoa(i)
+			err := RunAs(by, func() error {
+				return record(OpEnable, by, nil)
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries, err := ReadJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 8 {
+		t.Fatalf("%d entries were journaled, want 8", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.Command != entry.Identifier {
+			t.Errorf("the operation of %s was journaled as run by %s", entry.Identifier, entry.Command)
+		}
+	}
+}
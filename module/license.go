@@ -0,0 +1,168 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"errors"
+	"strings"
+)
+
+// licenseExpression is a parsed SPDX license expression, such as (MIT OR Apache-2.0) AND BSD-3-Clause
+type licenseExpression struct {
+	// id is the license of a leaf, the operands being combined otherwise
+	id       string
+	operator string
+	operands []licenseExpression
+}
+
+// satisfiedBy tells whether the licenses accepted by allowed are enough to use the module: any operand of an OR,
+// and every operand of an AND
+func (e licenseExpression) satisfiedBy(allowed func(id string) bool) bool {
+	switch e.operator {
+	case "OR":
+		for _, operand := range e.operands {
+			if operand.satisfiedBy(allowed) {
+				return true
+			}
+		}
+		return false
+	case "AND":
+		for _, operand := range e.operands {
+			if !operand.satisfiedBy(allowed) {
+				return false
+			}
+		}
+		return true
+	}
+	return allowed(e.id)
+}
+
+func tokenizeLicense(expression string) []string {
+	expression = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expression)
+	return strings.Fields(expression)
+}
+
+// parseLicense parses an SPDX license expression, where WITH binds tighter than AND, which binds tighter than OR
+func parseLicense(expression string) (licenseExpression, error) {
+	p := licenseParser{tokens: tokenizeLicense(expression)}
+	e, err := p.parseOr()
+	if err != nil {
+		return licenseExpression{}, err
+	}
+	if p.pos < len(p.tokens) {
+		return licenseExpression{}, errors.New("unexpected " + p.tokens[p.pos] + " in license " + expression)
+	}
+	return e, nil
+}
+
+type licenseParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *licenseParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// accept consumes the next token if it is the operator op, operators being matched regardless of case
+func (p *licenseParser) accept(op string) bool {
+	if strings.EqualFold(p.peek(), op) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *licenseParser) parseOr() (licenseExpression, error) {
+	return p.parseBinary("OR", p.parseAnd)
+}
+
+func (p *licenseParser) parseAnd() (licenseExpression, error) {
+	return p.parseBinary("AND", p.parseWith)
+}
+
+func (p *licenseParser) parseBinary(operator string, parseOperand func() (licenseExpression, error)) (licenseExpression, error) {
+	operand, err := parseOperand()
+	if err != nil {
+		return licenseExpression{}, err
+	}
+	operands := []licenseExpression{operand}
+	for p.accept(operator) {
+		operand, err := parseOperand()
+		if err != nil {
+			return licenseExpression{}, err
+		}
+		operands = append(operands, operand)
+	}
+	if len(operands) == 1 {
+		return operand, nil
+	}
+	return licenseExpression{operator: operator, operands: operands}, nil
+}
+
+func (p *licenseParser) parseWith() (licenseExpression, error) {
+	e, err := p.parsePrimary()
+	if err != nil {
+		return licenseExpression{}, err
+	}
+	// Exceptions (GPL-2.0 WITH Classpath-exception-2.0) only grant more rights
+	if e.operator == "" && p.accept("WITH") {
+		if p.isOperand() {
+			p.pos++
+			return e, nil
+		}
+		return licenseExpression{}, errors.New("missing the exception after WITH")
+	}
+	return e, nil
+}
+
+func (p *licenseParser) isOperand() bool {
+	token := p.peek()
+	return token != "" && token != "(" && token != ")" && !isLicenseOperator(token)
+}
+
+func isLicenseOperator(token string) bool {
+	return strings.EqualFold(token, "AND") || strings.EqualFold(token, "OR") || strings.EqualFold(token, "WITH")
+}
+
+func (p *licenseParser) parsePrimary() (licenseExpression, error) {
+	if p.accept("(") {
+		e, err := p.parseOr()
+		if err != nil {
+			return licenseExpression{}, err
+		}
+		if !p.accept(")") {
+			return licenseExpression{}, errors.New("missing a closing parenthesis")
+		}
+		return e, nil
+	}
+	if !p.isOperand() {
+		if p.peek() == "" {
+			return licenseExpression{}, errors.New("missing a license")
+		}
+		return licenseExpression{}, errors.New("unexpected " + p.peek())
+	}
+	id := p.peek()
+	p.pos++
+	return licenseExpression{id: id}, nil
+}
@@ -0,0 +1,53 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import "testing"
+
+func TestCheckLicense(t *testing.T) {
+	policy := Policy{AllowedLicenses: []string{"MIT", "Apache-2.0", "GPL-2.0"}}
+	tests := []struct {
+		license string
+		allowed bool
+	}{
+		{"MIT", true},
+		{"mit", true},
+		{"BSD-3-Clause", false},
+		{"MIT OR GPL-3.0", true},
+		{"MIT AND GPL-3.0", false},
+		{"GPL-3.0 OR MIT AND Apache-2.0", true},
+		{"(GPL-3.0 OR MIT) AND Apache-2.0", true},
+		{"(GPL-3.0 OR MIT) AND BSD-3-Clause", false},
+		{"MIT AND (GPL-3.0 OR (BSD-3-Clause AND Apache-2.0))", false},
+		{"MIT AND (GPL-3.0 OR (GPL-2.0 AND Apache-2.0))", true},
+		{"GPL-2.0 WITH Classpath-exception-2.0", true},
+		{"GPL-3.0 WITH Classpath-exception-2.0 OR MIT", true},
+		{"(MIT OR GPL-3.0", false},
+		{"MIT OR", false},
+		{"MIT WITH", false},
+		{"MIT) OR (BSD-3-Clause", false},
+	}
+	for _, test := range tests {
+		err := policy.CheckLicense(NewStoreIdentifier("a/one/1.0.0"), test.license)
+		if (err == nil) != test.allowed {
+			t.Errorf("CheckLicense(%q) = %v, allowed %v", test.license, err, test.allowed)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"bespoke/link"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+type LinkState string
+
+const (
+	LinkOk LinkState = "ok"
+	// LinkDangling points to a folder that doesn't exist anymore
+	LinkDangling LinkState = "dangling"
+	// LinkForeign points outside the store, e.g. a link made by hand
+	LinkForeign LinkState = "foreign"
+	// LinkMismatched points to another version than the enabled one
+	LinkMismatched LinkState = "mismatched"
+	// LinkStale belongs to a module that isn't enabled
+	LinkStale LinkState = "stale"
+	// LinkMissing is the link an enabled module should have
+	LinkMissing LinkState = "missing"
+)
+
+type LinkStatus struct {
+	Module string    `json:"module"`
+	Path   string    `json:"path"`
+	Target string    `json:"target"`
+	State  LinkState `json:"state"`
+	// Expected is the store folder of the enabled version, if any
+	Expected string `json:"expected,omitempty"`
+}
+
+// Broken tells whether the link would be fixed by RepairLinks
+func (s *LinkStatus) Broken() bool {
+	return s.State != LinkOk
+}
+
+// InspectLinks reports every entry of the modules folder, along with the links enabled modules are missing
+func InspectLinks() ([]LinkStatus, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := fsys.Glob(filepath.Join(modulesFolder, "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+	statuses := []LinkStatus{}
+	seen := map[ModuleIdentifierStr]bool{}
+	for _, entry := range entries {
+		fi, err := fsys.Lstat(entry)
+		if err != nil || (!fi.IsDir() && fi.Mode()&fs.ModeSymlink == 0) {
+			continue
+		}
+		moduleIdentifier := moduleIdentifierFromFilePath(entry)
+		seen[moduleIdentifier.toPath()] = true
+		status := LinkStatus{Module: moduleIdentifier.String(), Path: entry, Target: entry}
+		if fi.Mode()&fs.ModeSymlink != 0 {
+			status.Target, _ = fsys.Readlink(entry)
+		}
+
+		module, ok := vault.Modules[moduleIdentifier.toPath()]
+		if ok && module.Enabled != "" {
+			status.Expected = (&StoreIdentifier{moduleIdentifier, module.Enabled}).toFilePath()
+		}
+		status.State = linkState(entry, fi, status.Target, status.Expected)
+		statuses = append(statuses, status)
+	}
+
+	for _, identifierStr := range vault.OrderedModules() {
+		module := vault.Modules[identifierStr]
+		if seen[identifierStr] || module.Enabled == "" {
+			continue
+		}
+		identifier := StoreIdentifier{NewModuleIdentifier(string(identifierStr)), module.Enabled}
+		statuses = append(statuses, LinkStatus{
+			Module:   identifier.ModuleIdentifier.String(),
+			Path:     identifier.ModuleIdentifier.toFilePath(),
+			State:    LinkMissing,
+			Expected: identifier.toFilePath(),
+		})
+	}
+	return statuses, nil
+}
+
+func linkState(name string, fi fs.FileInfo, target string, expected string) LinkState {
+	if _, err := fsys.Stat(name); err != nil {
+		return LinkDangling
+	}
+	if fi.Mode()&fs.ModeSymlink == 0 {
+		// Copies are what copy mode makes, in symlink mode a folder can only have been put there by hand
+		if link.Mode != link.Copy {
+			return LinkForeign
+		}
+		if expected == "" {
+			return LinkStale
+		}
+		return LinkOk
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(name), target)
+	}
+	target = filepath.Clean(target)
+	if !strings.HasPrefix(target, storeFolder+string(filepath.Separator)) {
+		return LinkForeign
+	}
+	if expected == "" {
+		return LinkStale
+	}
+	if target != filepath.Clean(expected) {
+		return LinkMismatched
+	}
+	return LinkOk
+}
+
+// RepairLinks recreates the links of the modules folder from the vault, returning the changes as vault repair does
+func RepairLinks() ([]string, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := repairSymlinks(vault, true)
+	if err != nil || len(changes) == 0 || DryRun {
+		return changes, err
+	}
+	// Staged modules get fresh links from a new generation
+	if stagedModules() {
+		return changes, publishModules(vault)
+	}
+	return repairSymlinks(vault, false)
+}
+
+// PruneLinks removes the links that don't belong to an enabled module or that lead nowhere, leaving the missing
+// and mismatched ones to RepairLinks
+func PruneLinks() ([]LinkStatus, error) {
+	statuses, err := InspectLinks()
+	if err != nil {
+		return nil, err
+	}
+	pruned := []LinkStatus{}
+	for _, status := range statuses {
+		switch status.State {
+		case LinkDangling, LinkForeign, LinkStale:
+		default:
+			continue
+		}
+		// A folder put there by hand isn't ours to delete
+		if status.State == LinkForeign && status.Target == status.Path {
+			continue
+		}
+		if !skip("remove %s", status.Path) {
+			if err := link.Remove(status.Path); err != nil {
+				return pruned, err
+			}
+		}
+		pruned = append(pruned, status)
+	}
+	return pruned, nil
+}
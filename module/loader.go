@@ -0,0 +1,167 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// The loader injected in Spotify reads manifest.json next to vault.json to find what to load, so that it
+// doesn't have to resolve the metadata of every module itself
+
+const loaderManifestName = "manifest.json"
+
+// loaderScriptName is the manifest as a script, for the loader to read from the modules folder when no daemon
+// is running
+const loaderScriptName = "bespoke.modules.js"
+
+// modulesURL is where Spotify serves the modules folder, see symlinkFiles
+const modulesURL = "/modules/"
+
+// LoaderManifest lists the enabled modules in load order
+type LoaderManifest struct {
+	Modules []LoaderModule `json:"modules"`
+}
+
+type LoaderModule struct {
+	Identifier string `json:"identifier"`
+	Version    string `json:"version"`
+	Priority   int    `json:"priority"`
+	// Entries holds the URLs of the entries, relative to the root of the client
+	Entries Entries `json:"entries"`
+	// Scheme is the active color scheme of a theme and Variables the CSS variables it sets
+	Scheme    string            `json:"scheme,omitempty"`
+	Variables map[string]string `json:"variables,omitempty"`
+	// Permissions are the capabilities the loader grants to the module, it is denied the others
+	Permissions []string `json:"permissions"`
+}
+
+func entryURL(identifier ModuleIdentifier, entry string) string {
+	if entry == "" {
+		return ""
+	}
+	return modulesURL + string(identifier.Author) + "/" + string(identifier.Name) + "/" + path.Clean(strings.TrimPrefix(entry, "./"))
+}
+
+// BuildLoaderManifest resolves the entries of the enabled modules of vault, modules whose metadata can't be
+// read are left out
+func BuildLoaderManifest(vault *Vault) LoaderManifest {
+	manifest := LoaderManifest{Modules: []LoaderModule{}}
+	for _, identifier := range vault.OrderedModules() {
+		module := vault.Modules[identifier]
+		if module.Enabled == "" {
+			continue
+		}
+		storeIdentifier := StoreIdentifier{NewModuleIdentifier(string(identifier)), module.Enabled}
+		metadata, err := readStoreMetadata(storeIdentifier)
+		if err != nil {
+			log.Println("Leaving", storeIdentifier.String(), "out of the manifest:", err.Error())
+			continue
+		}
+		resolved := metadata.forPlatform(runtime.GOOS, runtime.GOARCH)
+
+		scheme := resolved.resolveScheme(module.Scheme)
+		manifest.Modules = append(manifest.Modules, LoaderModule{
+			Identifier: string(identifier),
+			Version:    string(module.Enabled),
+			Priority:   module.Priority,
+			Entries: Entries{
+				Js:    entryURL(storeIdentifier.ModuleIdentifier, resolved.Entries.Js),
+				Css:   entryURL(storeIdentifier.ModuleIdentifier, resolved.Entries.Css),
+				Mixin: entryURL(storeIdentifier.ModuleIdentifier, resolved.Entries.Mixin),
+			},
+			Scheme:      scheme,
+			Variables:   resolved.Schemes[scheme],
+			Permissions: grantedPermissions(&resolved),
+		})
+	}
+	return manifest
+}
+
+// GetLoaderManifest builds the manifest of the current vault
+func GetLoaderManifest() (LoaderManifest, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return LoaderManifest{}, err
+	}
+	return BuildLoaderManifest(vault), nil
+}
+
+// writeLoaderManifest writes the manifest of vault, and its script, to folder, which holds (or will hold) vault.json
+func writeLoaderManifest(folder string, vault *Vault) error {
+	manifest := BuildLoaderManifest(vault)
+	manifestJson, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := writeVaultFile(filepath.Join(folder, loaderManifestName), manifestJson); err != nil {
+		return err
+	}
+	script, err := LoaderScript(manifest)
+	if err != nil {
+		return err
+	}
+	return writeVaultFile(filepath.Join(folder, loaderScriptName), script)
+}
+
+// Relative returns a copy of the manifest whose entries are relative to the modules folder
+func (m LoaderManifest) Relative() LoaderManifest {
+	relative := LoaderManifest{Modules: make([]LoaderModule, len(m.Modules))}
+	for i, module := range m.Modules {
+		module.Entries = Entries{
+			Js:    strings.TrimPrefix(module.Entries.Js, modulesURL),
+			Css:   strings.TrimPrefix(module.Entries.Css, modulesURL),
+			Mixin: strings.TrimPrefix(module.Entries.Mixin, modulesURL),
+		}
+		relative.Modules[i] = module
+	}
+	return relative
+}
+
+// loaderScriptTemplate resolves the entries against the URL the script is served from (the modules folder
+// unless it was copied elsewhere) and exposes the manifest as globalThis.__BESPOKE_MANIFEST__
+const loaderScriptTemplate = `// Generated by bespoke from the vault: the modules to load, in order
+(function (manifest) {
+	var script = typeof document !== "undefined" && document.currentScript;
+	var base = new URL(script && script.src ? script.src : %q, location.href);
+	manifest.modules.forEach(function (module) {
+		for (var kind in module.entries) {
+			if (module.entries[kind]) module.entries[kind] = new URL(module.entries[kind], base).pathname;
+		}
+	});
+	globalThis.__BESPOKE_MANIFEST__ = manifest;
+})(%s);
+`
+
+// LoaderScript renders the manifest as a self-contained script, so that the client can load the modules from
+// the filesystem alone
+func LoaderScript(manifest LoaderManifest) ([]byte, error) {
+	manifestJson, err := json.Marshal(manifest.Relative())
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf(loaderScriptTemplate, modulesURL, manifestJson)), nil
+}
@@ -0,0 +1,227 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"bespoke/trace"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+var ErrInstallInProgress = errors.New("install already in progress")
+
+// errLocked is returned by lockFile when another process holds the lock
+var errLocked = errors.New("file is locked")
+
+// WaitForLocks makes store operations wait for concurrent ones instead of failing fast
+var WaitForLocks bool
+
+func (a storeArea) lockFilePath(si StoreIdentifier) string {
+	return filepath.Join(a.locks, string(si.Author), string(si.Name), string(si.Version)+".lock")
+}
+
+// installMarkerFilePath points to a file that only exists while the store folder is being populated
+func (a storeArea) installMarkerFilePath(si StoreIdentifier) string {
+	return filepath.Join(a.locks, string(si.Author), string(si.Name), string(si.Version)+".installing")
+}
+
+func (si *StoreIdentifier) toLockFilePath() string {
+	return userStore().lockFilePath(*si)
+}
+
+func (si *StoreIdentifier) toInstallMarkerFilePath() string {
+	return userStore().installMarkerFilePath(*si)
+}
+
+type storeLock struct {
+	file *os.File
+}
+
+// lockStore takes an exclusive lock on a store folder, released by the OS if the process dies.
+// In-memory filesystems aren't shared with other processes, so there is nothing to lock
+func lockStore(identifier StoreIdentifier) (*storeLock, error) {
+	return lockStoreIn(userStore(), identifier)
+}
+
+func lockStoreIn(area storeArea, identifier StoreIdentifier) (*storeLock, error) {
+	lock, err := lockPath(area.lockFilePath(identifier), identifier.String(), WaitForLocks)
+	if err == errLocked {
+		return nil, fmt.Errorf("%s: %w in another process", identifier, ErrInstallInProgress)
+	}
+	return lock, err
+}
+
+// lockGenerations serializes the publication of generations, so that a generation isn't pruned while another
+// process is writing or linking it
+func lockGenerations() (*storeLock, error) {
+	return lockPath(filepath.Join(locksFolder, "generations.lock"), "the modules folder", true)
+}
+
+// vaultLock is shared by the nested callers of lockVault in this process, the file lock being taken once
+var vaultLock struct {
+	sync.Mutex
+	depth int
+	file  *storeLock
+}
+
+// lockVault serializes the read-modify-write cycles of the vault with other processes, see MutateVault.
+// It is reentrant within the process: nested calls share the lock, released when the outermost unlocks
+func lockVault() (unlock func(), err error) {
+	vaultLock.Lock()
+	defer vaultLock.Unlock()
+	if vaultLock.depth == 0 {
+		file, err := lockPath(filepath.Join(locksFolder, "vault.lock"), "the vault", true)
+		if err != nil {
+			return nil, err
+		}
+		vaultLock.file = file
+	}
+	vaultLock.depth++
+	return sync.OnceFunc(func() {
+		vaultLock.Lock()
+		defer vaultLock.Unlock()
+		vaultLock.depth--
+		if vaultLock.depth == 0 {
+			vaultLock.file.unlock()
+			vaultLock.file = nil
+		}
+	}), nil
+}
+
+// lockPath takes an exclusive lock on the file at name, what names the locked resource in messages. Unless
+// wait is set, errLocked is returned when another process holds the lock
+func lockPath(name string, what string, wait bool) (*storeLock, error) {
+	if DryRun || !fsys.IsOS() {
+		return &storeLock{}, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), os.ModePerm); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	err = lockFile(file, false)
+	if err == errLocked && wait {
+		log.Println("Waiting for another operation on", what)
+		err = lockFile(file, true)
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &storeLock{file}, nil
+}
+
+func (l *storeLock) unlock() {
+	if l.file == nil {
+		return
+	}
+	unlockFile(l.file)
+	l.file.Close()
+}
+
+func isInstalling(identifier StoreIdentifier) bool {
+	return userStore().isInstalling(identifier)
+}
+
+func (a storeArea) isInstalling(identifier StoreIdentifier) bool {
+	_, err := fsys.Stat(a.installMarkerFilePath(identifier))
+	return err == nil
+}
+
+// installInStore populates the store folder of identifier, in the system store when InstallScope is ScopeSystem.
+// verified tells whether the module was signed by its author, which lets a trusted author run its scripts.
+// populate is given the folder to fill
+func installInStore(identifier StoreIdentifier, verified bool, populate func(dir string) error) error {
+	defer trace.Start("install", identifier.String()).Finish()
+	if InstallScope == ScopeSystem {
+		return installInSystemStore(identifier, verified, populate)
+	}
+	return installInUserStore(identifier, verified, populate)
+}
+
+func installInUserStore(identifier StoreIdentifier, verified bool, populate func(dir string) error) error {
+	return installInArea(userStore(), identifier, verified, populate)
+}
+
+// installInArea populates the folder of identifier in the store of area while holding its lock,
+// cleaning up the leftovers of an interrupted install first
+func installInArea(area storeArea, identifier StoreIdentifier, verified bool, populate func(dir string) error) error {
+	lock, err := lockStoreIn(area, identifier)
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
+	storePath := area.filePath(identifier)
+	marker := area.installMarkerFilePath(identifier)
+	if area.isInstalling(identifier) {
+		log.Println("Cleaning up an interrupted install of", identifier.String())
+		if !skip("remove %s", storePath) {
+			if err := fsys.RemoveAll(storePath); err != nil {
+				return err
+			}
+		}
+	} else if _, err := fsys.Stat(storePath); err == nil || area.isCompressed(identifier) {
+		return errors.New(identifier.toPath() + " is already installed, delete it first to install it again")
+	}
+
+	if !DryRun {
+		if err := fsys.MkdirAll(filepath.Dir(marker), os.ModePerm); err != nil {
+			return err
+		}
+		if err := fsys.WriteFile(marker, []byte(strconv.Itoa(os.Getpid())), 0600); err != nil {
+			return err
+		}
+	}
+
+	err = populate(storePath)
+	if err == nil {
+		err = prunePlatformAssets(storePath)
+	}
+	if err == nil {
+		err = runLifecycleScript(identifier, storePath, scriptPostInstall, verified)
+	}
+	if err != nil {
+		if !DryRun {
+			fsys.RemoveAll(storePath)
+			fsys.Remove(marker)
+		}
+		return err
+	}
+	if err := writeManifestOf(identifier, storePath); err != nil {
+		return err
+	}
+
+	notify(StepDone, identifier, "")
+	if DryRun {
+		return nil
+	}
+	return fsys.Remove(marker)
+}
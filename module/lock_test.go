@@ -0,0 +1,106 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+package module
+
+import (
+	"bespoke/fsys"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+func TestLockVaultReentrant(t *testing.T) {
+	folder := locksFolder
+	t.Cleanup(func() { locksFolder = folder })
+	locksFolder = t.TempDir()
+
+	done := make(chan error)
+	go func() {
+		outer, err := lockVault()
+		if err != nil {
+			done <- err
+			return
+		}
+		inner, err := lockVault()
+		if err != nil {
+			done <- err
+			return
+		}
+		inner()
+		outer()
+		// Released, it can be taken again
+		again, err := lockVault()
+		if err == nil {
+			again()
+		}
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("nested lockVault calls deadlocked")
+	}
+	if vaultLock.depth != 0 || vaultLock.file != nil {
+		t.Errorf("the vault lock is still held after every caller unlocked it")
+	}
+}
+
+func TestMutateVaultUnderLock(t *testing.T) {
+	useMemFs(t)
+	// The vault lock is only taken on the disk
+	fsys.FS = afero.NewOsFs()
+	if err := SetVault(&Vault{Modules: map[ModuleIdentifierStr]Module{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		unlock, err := lockVault()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer unlock()
+		done <- MutateVault(func(vault *Vault) bool {
+			vault.Modules = map[ModuleIdentifierStr]Module{"a/one": {V: map[Version]Store{}}}
+			return true
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("MutateVault deadlocked under the vault lock")
+	}
+	vault, err := GetVault()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := vault.Modules["a/one"]; !ok {
+		t.Errorf("the mutation wasn't written: %+v", vault.Modules)
+	}
+}
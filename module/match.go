@@ -0,0 +1,78 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"path"
+	"strings"
+)
+
+func IsPattern(identifier string) bool {
+	return strings.ContainsAny(identifier, "*?[")
+}
+
+// MatchModules returns the vault's modules whose <author>/<name> matches pattern
+func MatchModules(pattern string) ([]ModuleIdentifier, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []ModuleIdentifier{}
+	for _, identifier := range vault.OrderedModules() {
+		ok, err := path.Match(pattern, string(identifier))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, NewModuleIdentifier(string(identifier)))
+		}
+	}
+	return matches, nil
+}
+
+// MatchStores returns the installed versions whose <author>/<name>/<version> matches pattern,
+// patterns without a version segment match every version
+func MatchStores(pattern string) ([]StoreIdentifier, error) {
+	if strings.Count(pattern, "/") < 2 {
+		pattern += "/*"
+	}
+
+	vault, err := GetVault()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []StoreIdentifier{}
+	for _, moduleIdentifier := range vault.OrderedModules() {
+		module := vault.Modules[moduleIdentifier]
+		for _, version := range module.versions() {
+			identifier := StoreIdentifier{NewModuleIdentifier(string(moduleIdentifier)), version}
+			ok, err := path.Match(pattern, identifier.String())
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				matches = append(matches, identifier)
+			}
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,245 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/network"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// SpicetifyMappings maps spicetify extensions (by file name), themes and custom apps (by folder name)
+// to the [<registry>:]<author>/<name>[@<version>] reference of the equivalent module
+type SpicetifyMappings struct {
+	Extensions map[string]string `json:"extensions"`
+	Themes     map[string]string `json:"themes"`
+	Apps       map[string]string `json:"apps"`
+}
+
+const (
+	SpicetifyExtension = "extension"
+	SpicetifyTheme     = "theme"
+	SpicetifyApp       = "app"
+)
+
+type SpicetifyItem struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+const (
+	MigrationMigrated  = "migrated"
+	MigrationInstalled = "already installed"
+	MigrationUnmapped  = "unmapped"
+	MigrationFailed    = "failed"
+)
+
+type MigrationResult struct {
+	SpicetifyItem
+	Module string `json:"module,omitempty"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// FetchSpicetifyMappings reads the mapping index from a URL or a local file
+func FetchSpicetifyMappings(source string) (SpicetifyMappings, error) {
+	var raw []byte
+	var err error
+	if strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://") {
+		raw, err = network.GetCached(source)
+	} else {
+		raw, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return SpicetifyMappings{}, err
+	}
+
+	var mappings SpicetifyMappings
+	err = json.Unmarshal(raw, &mappings)
+	return mappings, err
+}
+
+// readSpicetifyConfig returns the key/value pairs of config-xpui.ini, keyed by "<section>.<key>"
+func readSpicetifyConfig(configPath string) (map[string]string, error) {
+	file, err := os.Open(configPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	config := map[string]string{}
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.Trim(line, "[]")
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			config[section+"."+strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return config, scanner.Err()
+}
+
+func splitSpicetifyList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, "|") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ScanSpicetify lists the extensions, themes and custom apps found in the spicetify config folder,
+// marking the ones enabled in config-xpui.ini
+func ScanSpicetify(spicetifyDir string) ([]SpicetifyItem, error) {
+	config, err := readSpicetifyConfig(filepath.Join(spicetifyDir, "config-xpui.ini"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.New("no spicetify config found in " + spicetifyDir)
+		}
+		return nil, err
+	}
+
+	items := []SpicetifyItem{}
+	add := func(kind string, folder string, enabled []string, isItem func(os.DirEntry) bool) {
+		found := slices.Clone(enabled)
+		if entries, err := os.ReadDir(filepath.Join(spicetifyDir, folder)); err == nil {
+			for _, entry := range entries {
+				if isItem(entry) {
+					found = append(found, entry.Name())
+				}
+			}
+		}
+		slices.Sort(found)
+		for _, name := range slices.Compact(found) {
+			items = append(items, SpicetifyItem{kind, name, slices.Contains(enabled, name)})
+		}
+	}
+
+	add(SpicetifyExtension, "Extensions", splitSpicetifyList(config["AdditionalOptions.extensions"]), func(entry os.DirEntry) bool {
+		return !entry.IsDir() && (filepath.Ext(entry.Name()) == ".js" || filepath.Ext(entry.Name()) == ".mjs")
+	})
+	add(SpicetifyTheme, "Themes", splitSpicetifyList(config["Setting.current_theme"]), os.DirEntry.IsDir)
+	add(SpicetifyApp, "CustomApps", splitSpicetifyList(config["AdditionalOptions.custom_apps"]), os.DirEntry.IsDir)
+	return items, nil
+}
+
+func (m *SpicetifyMappings) lookup(item SpicetifyItem) (string, bool) {
+	var mappings map[string]string
+	switch item.Kind {
+	case SpicetifyExtension:
+		mappings = m.Extensions
+	case SpicetifyTheme:
+		mappings = m.Themes
+	case SpicetifyApp:
+		mappings = m.Apps
+	}
+	if ref, ok := mappings[item.Name]; ok {
+		return ref, true
+	}
+	for name, ref := range mappings {
+		if strings.EqualFold(name, item.Name) {
+			return ref, true
+		}
+	}
+	return "", false
+}
+
+// MigrateSpicetify installs the module equivalent to each item, and enables it when the item was enabled in spicetify
+func MigrateSpicetify(items []SpicetifyItem, mappings SpicetifyMappings) []MigrationResult {
+	results := []MigrationResult{}
+	for _, item := range items {
+		result := MigrationResult{SpicetifyItem: item, Status: MigrationMigrated}
+		mapping, ok := mappings.lookup(item)
+		if !ok {
+			result.Status = MigrationUnmapped
+			results = append(results, result)
+			continue
+		}
+		result.Module = mapping
+		if err := migrateSpicetifyItem(item, mapping, &result); err != nil {
+			result.Status = MigrationFailed
+			result.Reason = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func migrateSpicetifyItem(item SpicetifyItem, mapping string, result *MigrationResult) error {
+	ref, ok := ParseModuleRef(mapping)
+	if !ok {
+		return errors.New("invalid mapping " + mapping)
+	}
+
+	before, err := installedVersions(ref.ModuleIdentifier)
+	if err != nil {
+		return err
+	}
+	if len(before) > 0 && (ref.Version == "" || slices.Contains(before, ref.Version)) {
+		result.Status = MigrationInstalled
+	} else if err := InstallModuleRef(ref); err != nil {
+		return err
+	}
+
+	if !item.Enabled {
+		return nil
+	}
+	after, err := installedVersions(ref.ModuleIdentifier)
+	if err != nil {
+		return err
+	}
+	version := ref.Version
+	if version == "" {
+		for _, v := range after {
+			if !slices.Contains(before, v) {
+				version = v
+			}
+		}
+	}
+	if version == "" && len(after) > 0 {
+		version = after[len(after)-1]
+	}
+	if err := ToggleModuleInVault(StoreIdentifier{ref.ModuleIdentifier, version}); err != nil {
+		result.Reason = "not enabled: " + err.Error()
+	}
+	return nil
+}
+
+func installedVersions(identifier ModuleIdentifier) ([]Version, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return nil, err
+	}
+	return vault.getModule(identifier.toPath()).versions(), nil
+}
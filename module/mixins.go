@@ -0,0 +1,66 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"bespoke/mixin"
+	"log"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// EnabledMixins reads the mixin entries of the enabled modules, in load order. Modules whose mixin can't
+// be read are left out
+func EnabledMixins() ([]mixin.Mixin, error) {
+	enabled, err := GetEnabledModules()
+	if err != nil {
+		return nil, err
+	}
+
+	mixins := []mixin.Mixin{}
+	for _, identifier := range enabled {
+		metadata, err := readStoreMetadata(identifier)
+		if err != nil {
+			log.Println("Skipping the mixin of", identifier.String()+":", err.Error())
+			continue
+		}
+		entry := metadata.forPlatform(runtime.GOOS, runtime.GOARCH).Entries.Mixin
+		if entry == "" {
+			continue
+		}
+
+		file := filepath.Join(identifier.toFilePath(), filepath.FromSlash(path.Clean(strings.TrimPrefix(entry, "./"))))
+		raw, err := fsys.ReadFile(file)
+		if err != nil {
+			log.Println("Skipping the mixin of", identifier.String()+":", err.Error())
+			continue
+		}
+		m, err := mixin.Parse(identifier.ModuleIdentifier.String(), raw)
+		if err != nil {
+			log.Println("Skipping", err.Error())
+			continue
+		}
+		mixins = append(mixins, m)
+	}
+	return mixins, nil
+}
@@ -21,36 +21,73 @@ package module
 
 import (
 	"bespoke/archive"
-	"bespoke/paths"
+	"bespoke/fsys"
+	"bespoke/link"
+	"bespoke/network"
+	"bespoke/trace"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"log"
 	"net/http"
-	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
-	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/google/go-github/github"
 )
 
-var client = github.NewClient(nil)
+var client = github.NewClient(network.Client)
+
+// Context cancels the downloads, git commands and scripts in flight when it is done, the CLI cancels it on Ctrl-C
+var Context = context.Background()
 
 type Metadata struct {
-	Name        string   `json:"name"`
-	Version     string   `json:"version"`
-	Authors     []string `json:"authors"`
-	Description string   `json:"description"`
-	Tags        []string `json:"tags"`
-	Entries     struct {
-		Js    string `json:"js"`
-		Css   string `json:"css"`
-		Mixin string `json:"mixin"`
-	} `json:"entries"`
+	// Schema lets editors validate metadata.json against the output of `bespoke schema metadata`
+	Schema       string            `json:"$schema,omitempty"`
+	Name         string            `json:"name"`
+	Version      string            `json:"version"`
+	Authors      []string          `json:"authors"`
+	Description  string            `json:"description"`
+	Tags         []string          `json:"tags"`
+	Entries      Entries           `json:"entries"`
 	Dependencies map[string]string `json:"dependencies"`
+	Provides     []string          `json:"provides"`
+	Conflicts    []string          `json:"conflicts"`
+	Assets       []string          `json:"assets"`
+	// Platforms holds the entries and assets that only apply to some platforms, see forPlatform
+	Platforms map[string]Platform `json:"platforms,omitempty"`
+	Files     *Files              `json:"files,omitempty"`
+	// RenamedFrom lists the "author/name" identifiers the module was published under before, whose
+	// installs are migrated to the new identifier on upgrade
+	RenamedFrom []string `json:"renamedFrom,omitempty"`
+	// Schemes maps the color schemes of a theme to the CSS variables they set (e.g. "--spice-text": "#ffffff")
+	Schemes map[string]map[string]string `json:"schemes,omitempty"`
+	// Permissions lists the capabilities the module needs at runtime (e.g. "network"), see Permissions
+	Permissions []string `json:"permissions,omitempty"`
+	Scripts     struct {
+		Build       string `json:"build"`
+		PostInstall string `json:"postInstall"`
+		PreRemove   string `json:"preRemove"`
+	} `json:"scripts"`
+	// Actions are the maintenance operations the module exposes, by name (see Action)
+	Actions map[string]Action `json:"actions,omitempty"`
+	// Spotify is the range of Spotify client versions the module works with (e.g. ">=1.2.30")
+	Spotify string `json:"spotify"`
+	// License is the SPDX license expression of the module (e.g. "MIT")
+	License string `json:"license,omitempty"`
+}
+
+type Entries struct {
+	Js    string `json:"js"`
+	Css   string `json:"css"`
+	Mixin string `json:"mixin"`
 }
 
 func (m *Metadata) getAuthor() string {
@@ -87,29 +124,55 @@ type VersionedGithubPath struct {
 
 var githubRawRe = regexp.MustCompile(`https://raw.githubusercontent.com/(?<owner>[^/]+)/(?<repo>[^/]+)/(?<version>[^/]+)/(?<dirname>.*?)/?(?<basename>[^/])+$`)
 
-func (ghp VersionedGithubPath) getRepoArchiveLink() string {
-	url := "https://github.com/" + ghp.owner + "/" + ghp.repo + "/archive/"
-
+func (ghp VersionedGithubPath) getRef() string {
 	switch ghp.version.__type {
 	case "commit":
-		url += ghp.version.commit
+		return ghp.version.commit
 
 	case "tag":
-		url += "refs/tags/" + ghp.version.tag
+		return "refs/tags/" + ghp.version.tag
 
 	case "branch":
-		url += "refs/heads/" + ghp.version.branch
+		return "refs/heads/" + ghp.version.branch
 
 	}
+	return ""
+}
 
-	url += ".tar.gz"
+func (ghp VersionedGithubPath) getRepoArchiveLink() string {
+	// Private repositories can only be downloaded through the API, which redirects to an authenticated codeload link
+	if network.TokenFor("api.github.com") != "" {
+		return "https://api.github.com/repos/" + ghp.owner + "/" + ghp.repo + "/tarball/" + ghp.getRef()
+	}
 
-	return url
+	return "https://github.com/" + ghp.owner + "/" + ghp.repo + "/archive/" + ghp.getRef() + ".tar.gz"
 }
 
 type Store struct {
 	Installed bool        `json:"installed"`
 	Metadatas []RemoteURL `json:"metadatas"`
+	// Verified is set when the metadata was signed by an author vouched for by a trusted registry
+	Verified bool `json:"verified,omitempty"`
+	// Commit is the commit the module was installed from, when its source is a git repository
+	Commit string `json:"commit,omitempty"`
+	// Vanity is the URL the module was installed from when it redirected to the metadata URL, which is
+	// recorded instead so that upgrades don't depend on the redirect
+	Vanity RemoteURL `json:"vanity,omitempty"`
+	// Explicit is unset when the version was only installed as a dependency of other modules
+	Explicit bool `json:"explicit"`
+	// Local is set when the version was installed from metadata and an archive provided locally
+	Local bool `json:"local,omitempty"`
+}
+
+// UnmarshalJSON treats versions recorded before installs were tracked as explicitly installed
+func (s *Store) UnmarshalJSON(data []byte) error {
+	type store Store
+	decoded := store{Explicit: true}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*s = Store(decoded)
+	return nil
 }
 
 type Author string
@@ -118,22 +181,40 @@ type Version string
 type ModuleIdentifierStr string
 
 type Module struct {
-	Enabled Version           `json:"enabled"`
-	Remotes []string          `json:"remotes"`
-	V       map[Version]Store `json:"v"`
-}
+	Enabled  Version           `json:"enabled"`
+	Priority int               `json:"priority"`
+	Remotes  []string          `json:"remotes"`
+	V        map[Version]Store `json:"v"`
+	// Scheme is the color scheme picked with SetScheme, the default scheme of the theme applies otherwise
+	Scheme string `json:"scheme,omitempty"`
+	// Constraint is the range of versions shared by the modules depending on this one, recorded by FlattenDependencies
+	Constraint string `json:"constraint,omitempty"`
+	// SourceMissing is set when the source of the enabled version is gone, see MissingSource
+	SourceMissing *MissingSource `json:"sourceMissing,omitempty"`
+}
+
+// VaultSchema is the version of the vault format, bumped when older releases would misread it:
+// 1 records whether each version was installed explicitly
+const VaultSchema = 1
+
 type Vault struct {
+	Schema  int                            `json:"schema"`
 	Modules map[ModuleIdentifierStr]Module `json:"modules"`
 }
 
+// getModule returns a copy of a module, or an empty one when it isn't in the vault. The vault is left untouched,
+// changes are saved with setModule
 func (v *Vault) getModule(identifier ModuleIdentifierStr) *Module {
 	module, ok := v.Modules[identifier]
 	if !ok {
 		module = Module{
-			Enabled: "",
-			V:       map[Version]Store{},
+			Enabled:  "",
+			Priority: v.nextPriority(),
+			V:        map[Version]Store{},
 		}
-		v.Modules[identifier] = module
+	}
+	if module.V == nil {
+		module.V = map[Version]Store{}
 	}
 	return &module
 }
@@ -162,6 +243,7 @@ func (v *Vault) setStore(identifier StoreIdentifier, module *Store) bool {
 	}
 	versions := v.getModule(identifier.ModuleIdentifier.toPath())
 	versions.V[identifier.Version] = *module
+	v.setModule(identifier.ModuleIdentifier.toPath(), versions)
 	return true
 }
 
@@ -177,6 +259,14 @@ type ModuleIdentifier struct {
 // <owner>/<module>
 var moduleIdentifierRe = regexp.MustCompile(`^(?<author>[^/]+)/(?<name>[^/]+)$`)
 
+// ParseModuleIdentifier is NewModuleIdentifier for untrusted input
+func ParseModuleIdentifier(identifier string) (ModuleIdentifier, bool) {
+	if !moduleIdentifierRe.MatchString(identifier) {
+		return ModuleIdentifier{}, false
+	}
+	return NewModuleIdentifier(identifier), true
+}
+
 func NewModuleIdentifier(identifier string) ModuleIdentifier {
 	parts := moduleIdentifierRe.FindStringSubmatch(identifier)
 	return ModuleIdentifier{
@@ -189,6 +279,10 @@ func (mi *ModuleIdentifier) toPath() ModuleIdentifierStr {
 	return ModuleIdentifierStr(path.Join(string(mi.Author), string(mi.Name)))
 }
 
+func (mi ModuleIdentifier) String() string {
+	return string(mi.toPath())
+}
+
 func (mi *ModuleIdentifier) toFilePath() string {
 	return filepath.Join(modulesFolder, string(mi.Author), string(mi.Name))
 }
@@ -201,6 +295,36 @@ type StoreIdentifier struct {
 // <owner>/<module>/<version>
 var storeIdentifierRe = regexp.MustCompile(`^(?<identifier>[^/]+/[^/]+)/(?<version>[^/]*)$`)
 
+// ParseStoreIdentifier is NewStoreIdentifier for untrusted input
+func ParseStoreIdentifier(identifier string) (StoreIdentifier, bool) {
+	if !storeIdentifierRe.MatchString(identifier) {
+		return StoreIdentifier{}, false
+	}
+	return NewStoreIdentifier(identifier), true
+}
+
+// ResolveStoreIdentifier finds the version designated by author/name/version, or by author/name for its
+// enabled version
+func ResolveStoreIdentifier(identifier string) (StoreIdentifier, error) {
+	if storeIdentifier, ok := ParseStoreIdentifier(identifier); ok && storeIdentifier.Version != "" {
+		return storeIdentifier, nil
+	}
+	identifier = strings.TrimSuffix(identifier, "/")
+	if !moduleIdentifierRe.MatchString(identifier) {
+		return StoreIdentifier{}, errors.New("invalid module identifier " + identifier + ", expected <author>/<name>[/<version>]")
+	}
+	vault, err := GetVault()
+	if err != nil {
+		return StoreIdentifier{}, err
+	}
+	moduleIdentifier := NewModuleIdentifier(identifier)
+	module, ok := vault.Modules[moduleIdentifier.toPath()]
+	if !ok || module.Enabled == "" {
+		return StoreIdentifier{}, errors.New(identifier + " isn't enabled, name the version to use")
+	}
+	return StoreIdentifier{moduleIdentifier, module.Enabled}, nil
+}
+
 func NewStoreIdentifier(identifier string) StoreIdentifier {
 	parts := storeIdentifierRe.FindStringSubmatch(identifier)
 	return StoreIdentifier{
@@ -213,37 +337,79 @@ func (si *StoreIdentifier) toPath() string {
 	return filepath.Join(string(si.Author), string(si.Name), string(si.Version))
 }
 
+func (si StoreIdentifier) String() string {
+	return path.Join(string(si.Author), string(si.Name), string(si.Version))
+}
+
 func (si *StoreIdentifier) toFilePath() string {
 	return filepath.Join(storeFolder, string(si.Author), string(si.Name), string(si.Version))
 }
 
-var modulesFolder = filepath.Join(paths.ConfigPath, "modules")
-var storeFolder = filepath.Join(paths.ConfigPath, "store")
-var vaultPath = filepath.Join(modulesFolder, "vault.json")
-
 func GetVault() (*Vault, error) {
-	file, err := os.Open(vaultPath)
+	if DryRun && pendingVault != nil {
+		return getPendingVault()
+	}
+	if batching && batchedVault != nil {
+		var vault Vault
+		err := json.Unmarshal(batchedVault, &vault)
+		return &vault, err
+	}
+
+	vault, err := readVaultFile(vaultPath)
+	if errors.Is(err, errCorruptVault) {
+		return recoverVault(err)
+	}
+	return vault, err
+}
+
+func readVaultFile(name string) (*Vault, error) {
+	file, err := fsys.Open(name)
 	if err != nil {
 		return &Vault{}, err
 	}
 	defer file.Close()
 
 	var vault Vault
-	err = json.NewDecoder(file).Decode(&vault)
-	return &vault, err
+	if err := json.NewDecoder(file).Decode(&vault); err != nil {
+		return &vault, fmt.Errorf("%w: %s", errCorruptVault, err)
+	}
+	if vault.Schema > VaultSchema {
+		return &vault, errors.New("the vault was written by a newer version of bespoke (schema " + strconv.Itoa(vault.Schema) + "), upgrade bespoke to use it")
+	}
+	return &vault, nil
 }
 
 func SetVault(vault *Vault) error {
+	defer trace.Start("write vault", "").Finish()
+	vault.Schema = VaultSchema
+	if DryRun {
+		return setPendingVault(vault)
+	}
+	backupVault()
+	if stagedModules() {
+		return publishModules(vault)
+	}
+
 	vaultJson, err := json.Marshal(vault)
 	if err != nil {
 		return err
 	}
 
-	os.MkdirAll(modulesFolder, os.ModePerm)
-	return os.WriteFile(vaultPath, vaultJson, 0700)
+	fsys.MkdirAll(modulesFolder, os.ModePerm)
+	if err := writeVaultFile(vaultPath, vaultJson); err != nil {
+		return err
+	}
+	return writeLoaderManifest(modulesFolder, vault)
 }
 
+// MutateVault applies mutate to the vault under the vault lock, so that the changes of other processes made
+// between reading and writing it aren't lost
 func MutateVault(mutate func(*Vault) bool) error {
+	unlock, err := lockVault()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	vault, err := GetVault()
 	if err != nil {
 		return err
@@ -257,25 +423,51 @@ func MutateVault(mutate func(*Vault) bool) error {
 }
 
 func parseMetadata(r io.Reader) (Metadata, error) {
+	if StrictMetadata {
+		return parseMetadataStrict(r)
+	}
+
 	var metadata Metadata
 	if err := json.NewDecoder(r).Decode(&metadata); err != nil {
 		return Metadata{}, err
 	}
+	if problems := metadata.unsafePathFields(); len(problems) > 0 {
+		return Metadata{}, errors.New("invalid metadata: " + strings.Join(problems, ", "))
+	}
 	return metadata, nil
 }
 
-func fetchRemoteMetadata(metadataURL RemoteURL) (Metadata, error) {
-	res, err := http.Get(metadataURL)
+// fetchRemoteMetadata follows redirects (e.g. from a vanity short URL) and also returns the URL the metadata
+// was served from, which is the one to download the module and check for upgrades from
+func fetchRemoteMetadata(metadataURL RemoteURL) (Metadata, RemoteURL, error) {
+	defer trace.Start("fetch metadata", metadataURL).Finish()
+	raw, resolved, err := network.GetCachedResolved(metadataURL)
 	if err != nil {
-		return Metadata{}, err
+		return Metadata{}, "", metadataURLError(metadataURL, err)
 	}
-	defer res.Body.Close()
 
-	return parseMetadata(res.Body)
+	metadata, err := parseMetadata(bytes.NewReader(raw))
+	if err != nil {
+		return Metadata{}, "", errors.New(resolved + " isn't a valid metadata.json: " + err.Error())
+	}
+	return metadata, resolved, nil
+}
+
+// metadataURLError suggests what to check in a metadata URL that can't be fetched
+func metadataURLError(metadataURL RemoteURL, err error) error {
+	var statusErr *network.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		return err
+	}
+	if parts := githubRawRe.FindStringSubmatch(metadataURL); parts != nil {
+		file := strings.SplitN(metadataURL, "/", 7)[6]
+		return errors.New(err.Error() + ", is the branch (or tag) " + parts[3] + " correct, and does " + parts[1] + "/" + parts[2] + " have a " + file + " file?")
+	}
+	return errors.New(err.Error() + ", is the URL correct?")
 }
 
 func fetchLocalMetadata(metadataURL LocalURL) (Metadata, error) {
-	file, err := os.Open(metadataURL)
+	file, err := fsys.Open(metadataURL)
 	if err != nil {
 		return Metadata{}, err
 	}
@@ -296,40 +488,11 @@ func parseGithubRawLink(metadataURL RemoteURL) (VersionedGithubPath, error) {
 	v := submatches[3]
 	path := submatches[4]
 
-	branches, _, err := client.Repositories.ListBranches(context.Background(), owner, repo, &github.ListOptions{})
+	version, err := resolveGithubVersion(owner, repo, v)
 	if err != nil {
 		return VersionedGithubPath{}, err
 	}
 
-	branchNames := []string{}
-
-	for branch := range branches {
-		branchNames = append(branchNames, branches[branch].GetName())
-	}
-
-	var version GithubPathVersion
-	if len(v) == 40 {
-		version = GithubPathVersion{
-			__type: "commit",
-			commit: v,
-		}
-	} else if slices.Contains(branchNames, v) {
-		version = GithubPathVersion{
-			__type: "branch",
-			branch: v,
-		}
-	} else {
-		tag, err := url.QueryUnescape(v)
-		if err != nil {
-			return VersionedGithubPath{}, err
-		}
-
-		version = GithubPathVersion{
-			__type: "tag",
-			tag:    tag,
-		}
-	}
-
 	return VersionedGithubPath{
 		owner,
 		repo,
@@ -338,34 +501,75 @@ func parseGithubRawLink(metadataURL RemoteURL) (VersionedGithubPath, error) {
 	}, nil
 }
 
-func downloadModuleInStore(metadataURL RemoteURL, storeIdentifier StoreIdentifier) error {
+// downloadModuleInStore downloads the module of storeIdentifier into dest, the store folder it is installed in
+func downloadModuleInStore(metadataURL RemoteURL, storeIdentifier StoreIdentifier, dest string, filter archive.Filter) error {
 	githubPath, err := parseGithubRawLink(metadataURL)
 	if err != nil {
 		return err
 	}
 
-	res, err := http.Get(githubPath.getRepoArchiveLink())
+	if skip("download %s into %s", githubPath.getRepoArchiveLink(), dest) {
+		return nil
+	}
+	return downloadModule(storeIdentifier, githubPath, dest, filter)
+}
+
+// downloadModule extracts the folder of the module from the archive of its repository into dest,
+// identifier is only used to report the progress
+func downloadModule(identifier StoreIdentifier, githubPath VersionedGithubPath, dest string, filter archive.Filter) error {
+	archiveLink := githubPath.getRepoArchiveLink()
+	// The archive is extracted while it is downloaded
+	defer trace.Start("download and extract", archiveLink).Finish()
+	res, err := network.Get(archiveLink)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return network.NewStatusError(res)
+	}
+
+	body, cleanup, err := scanArchive(trackDownload(res.Body, identifier, archiveLink, res.ContentLength), archiveLink)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
 
 	srcRe := regexp.MustCompile(`^[^/]+/` + githubPath.path + "(.*)")
+	filter.Extracted = trackExtraction(identifier, archiveLink)
 
-	return archive.UnTarGZ(res.Body, srcRe, storeIdentifier.toFilePath())
+	// Closing the body once the module folder was extracted aborts the download of the rest of the repository
+	return archive.UnTarGZSubtree(body, srcRe, dest, filter)
 }
 
 func deleteModuleInStore(identifier StoreIdentifier) error {
-	return os.RemoveAll(identifier.toFilePath())
+	if err := deleteManifest(identifier); err != nil {
+		return err
+	}
+	return fsys.RemoveAll(identifier.toFilePath())
 }
 
 func AddModuleInVault(metadata *Metadata, module *Store) error {
-	return MutateVault(func(vault *Vault) bool {
+	before := snapshotVault()
+	err := MutateVault(func(vault *Vault) bool {
+		// Reinstalling a version as a dependency keeps it explicit
+		previous, ok := vault.getStore(metadata)
+		module.Explicit = !InstallAsDependency || ok && previous.Explicit
 		return vault.setStore(metadata.getStoreIdentifier(), module)
 	})
+	if err != nil {
+		return err
+	}
+	return record(OpInstall, metadata.getStoreIdentifier().String(), before)
 }
 
 func ToggleModuleInVault(identifier StoreIdentifier) error {
+	unlock, err := lockVault()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	before := snapshotVault()
 	vault, err := GetVault()
 	if err != nil {
 		return err
@@ -381,53 +585,162 @@ func ToggleModuleInVault(identifier StoreIdentifier) error {
 		if _, ok := module.V[identifier.Version]; !ok {
 			return errors.New("Can't find matching " + identifier.toPath())
 		}
+		if err := ActivePolicy.CheckModule(identifier, module.V[identifier.Version].Verified); err != nil {
+			return err
+		}
+		if err := decompressStore(identifier); err != nil {
+			return err
+		}
+		// In dry-run mode, a version that would have been installed isn't in the store to check against
+		if conflicts, err := FindConflicts(identifier); err != nil && !DryRun {
+			return err
+		} else if len(conflicts) > 0 {
+			return &ConflictError{identifier, conflicts}
+		}
 	}
 
+	previous := module.Enabled
 	module.Enabled = identifier.Version
 	vault.setModule(identifier.ModuleIdentifier.toPath(), module)
 
-	destroySymlink(identifier.ModuleIdentifier)
-	if len(module.Enabled) > 0 {
-		if err := createSymlink(identifier); err != nil {
-			return err
+	// Staged modules get their links from the new generation written by SetVault
+	if !stagedModules() {
+		destroySymlink(identifier.ModuleIdentifier)
+		if len(module.Enabled) > 0 {
+			if err := createSymlink(identifier); err != nil {
+				return err
+			}
 		}
 	}
 
-	return SetVault(vault)
+	if err := SetVault(vault); err != nil {
+		return err
+	}
+	if previous != "" {
+		disabledVersions = append(disabledVersions, StoreIdentifier{identifier.ModuleIdentifier, previous})
+	}
+	// Within a batch, the links to the disabled versions are only gone once the generation is published
+	if !batching {
+		compressDisabledVersions()
+	}
+	if len(module.Enabled) > 0 {
+		notify(StepEnable, identifier, "")
+		return record(OpEnable, identifier.String(), before)
+	}
+	notify(StepDisable, identifier, "")
+	return record(OpDisable, identifier.ModuleIdentifier.String(), before)
 }
 
 func RemoveModuleInVault(identifier StoreIdentifier) error {
-	return MutateVault(func(vault *Vault) bool {
+	before := snapshotVault()
+	err := MutateVault(func(vault *Vault) bool {
 		module := vault.getModule(identifier.ModuleIdentifier.toPath())
 
 		if module.Enabled == identifier.Version {
 			module.Enabled = ""
-			destroySymlink(identifier.ModuleIdentifier)
+			if !stagedModules() {
+				destroySymlink(identifier.ModuleIdentifier)
+			}
 		}
 
 		delete(module.V, identifier.Version)
 		vault.setModule(identifier.ModuleIdentifier.toPath(), module)
 		return true
 	})
+	if err != nil {
+		return err
+	}
+	return record(OpRemove, identifier.String(), before)
 }
 
 func InstallModuleRemote(metadataURL RemoteURL) error {
-	metadata, err := fetchRemoteMetadata(metadataURL)
+	metadata, resolved, err := fetchRemoteMetadata(metadataURL)
 	if err != nil {
 		return err
 	}
 
+	return installModuleRemote(metadataURL, resolved, metadata)
+}
+
+func installModuleRemote(metadataURL RemoteURL, resolved RemoteURL, metadata Metadata) error {
+	return installModuleRemoteWith(metadataURL, resolved, metadata, downloadModuleInStore)
+}
+
+// checkRemoteSources applies the policy and the trust checks to a metadata URL and to where it redirects
+func checkRemoteSources(metadataURL RemoteURL, resolved RemoteURL) error {
+	sources := []RemoteURL{metadataURL}
+	if resolved != metadataURL {
+		log.Println(metadataURL, "redirects to", resolved)
+		// The redirect can't be used to get around the policy or the trust checks
+		sources = append(sources, resolved)
+	}
+	for _, source := range sources {
+		if err := ActivePolicy.CheckSource(source); err != nil {
+			return err
+		}
+		if err := checkSourceTrust(source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// installModuleRemoteWith installs the module whose metadata was fetched from metadataURL and redirected to resolved
+func installModuleRemoteWith(metadataURL RemoteURL, resolved RemoteURL, metadata Metadata, download func(RemoteURL, StoreIdentifier, string, archive.Filter) error) error {
 	storeIdentifier := metadata.getStoreIdentifier()
+	announcePermissions(storeIdentifier, &metadata)
+	if err := checkRemoteSources(metadataURL, resolved); err != nil {
+		return err
+	}
+	vanity := ""
+	if resolved != metadataURL {
+		vanity = metadataURL
+	}
+	metadataURL = resolved
+	if err := ActivePolicy.CheckLicense(storeIdentifier, metadata.License); err != nil {
+		return err
+	}
 
-	err = downloadModuleInStore(metadataURL, storeIdentifier)
+	signed, err := verifyRemoteMetadata(metadataURL, &metadata)
 	if err != nil {
 		return err
 	}
+	if err := ActivePolicy.CheckModule(storeIdentifier, signed != nil); err != nil {
+		return err
+	}
+	if err := signed.verifySource(metadataURL); err != nil {
+		return err
+	}
+	// Used to pin the module in `pkg freeze`, failing to resolve it only prevents the install of modules
+	// signed for a commit
+	commit, _ := resolveCommit(metadataURL)
+	if err := signed.verifyCommit(commit); err != nil {
+		return err
+	}
 
-	return AddModuleInVault(&metadata, &Store{
+	err = installInStore(storeIdentifier, signed != nil, func(dir string) error {
+		if err := download(metadataURL, storeIdentifier, dir, metadata.filesFilter()); err != nil {
+			return err
+		}
+		return signed.verifyFiles(dir)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = AddModuleInVault(&metadata, &Store{
 		Installed: true,
 		Metadatas: []string{metadataURL},
+		Verified:  signed != nil,
+		Commit:    commit,
+		Vanity:    vanity,
 	})
+	if err != nil {
+		return err
+	}
+
+	sendInstallPing(storeIdentifier)
+	return nil
 }
 
 func InstallModuleLocal(metadataURL LocalURL) error {
@@ -437,6 +750,14 @@ func InstallModuleLocal(metadataURL LocalURL) error {
 	}
 
 	storeIdentifier := metadata.getStoreIdentifier()
+	// The files of a local folder can change at any time, they aren't verified
+	if err := ActivePolicy.CheckModule(storeIdentifier, false); err != nil {
+		return err
+	}
+	if err := ActivePolicy.CheckLicense(storeIdentifier, metadata.License); err != nil {
+		return err
+	}
+	announcePermissions(storeIdentifier, &metadata)
 	if err := ensureSymlink(filepath.Dir(metadataURL), storeIdentifier.toFilePath()); err != nil {
 		return err
 	}
@@ -448,17 +769,34 @@ func InstallModuleLocal(metadataURL LocalURL) error {
 }
 
 func DeleteModule(identifier StoreIdentifier) error {
+	// The pre-remove script runs in the store folder
+	if metadata, err := readStoreMetadata(identifier); err == nil && metadata.Scripts.PreRemove != "" {
+		if err := decompressStore(identifier); err != nil {
+			return err
+		}
+	}
+	lock, err := lockStore(identifier)
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
+	// A failing script mustn't make the module impossible to remove
+	if err := runLifecycleScript(identifier, identifier.toFilePath(), scriptPreRemove, isVerifiedStore(identifier)); err != nil {
+		log.Println(err.Error())
+	}
+
 	if err := RemoveModuleInVault(identifier); err != nil {
 		return err
 	}
-	return deleteModuleInStore(identifier)
+	return trashModuleInStore(identifier)
 }
 
 func ensureSymlink(oldname string, newname string) error {
-	if err := os.MkdirAll(filepath.Dir(newname), 0755); err != nil {
-		return err
+	if skip("link %s -> %s (%s)", newname, oldname, link.Mode) {
+		return nil
 	}
-	return os.Symlink(oldname, newname)
+	return link.Create(oldname, newname)
 }
 
 func createSymlink(identifier StoreIdentifier) error {
@@ -466,5 +804,9 @@ func createSymlink(identifier StoreIdentifier) error {
 }
 
 func destroySymlink(identifier ModuleIdentifier) error {
-	return os.Remove(identifier.toFilePath())
+	name := identifier.toFilePath()
+	if _, err := fsys.Lstat(name); err == nil && skip("remove %s", name) {
+		return nil
+	}
+	return link.Remove(name)
 }
@@ -0,0 +1,209 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/archive"
+	"bespoke/fsys"
+	"bespoke/network"
+	"bespoke/trace"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ModulesIndexFile lists the modules of a repository hosting several of them
+const ModulesIndexFile = "modules.json"
+
+type ModulesIndex struct {
+	Modules []IndexedModule `json:"modules"`
+}
+
+type IndexedModule struct {
+	Name string `json:"name"`
+	// Path is the folder of the module relative to the index, holding its metadata.json
+	Path        string `json:"path"`
+	Description string `json:"description,omitempty"`
+}
+
+func IsModulesIndex(murl string) bool {
+	return path.Base(murl) == ModulesIndexFile
+}
+
+func FetchModulesIndex(indexURL RemoteURL) (ModulesIndex, error) {
+	raw, err := network.GetCached(indexURL)
+	if err != nil {
+		return ModulesIndex{}, err
+	}
+	var index ModulesIndex
+	if err := json.Unmarshal(raw, &index); err != nil {
+		return ModulesIndex{}, errors.New(indexURL + " isn't a valid " + ModulesIndexFile + ": " + err.Error())
+	}
+	for _, module := range index.Modules {
+		clean := path.Clean(module.Path)
+		if module.Name == "" || path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return ModulesIndex{}, errors.New(indexURL + " lists an invalid module: " + module.Name + " at " + module.Path)
+		}
+	}
+	return index, nil
+}
+
+// InstallIndexedModules installs the modules of an index picked by name, from a single download of the archive
+// of the repository. Each module is recorded with its own metadata URL, so that it is upgraded on its own
+func InstallIndexedModules(indexURL RemoteURL, names []string) error {
+	if err := ActivePolicy.CheckSource(indexURL); err != nil {
+		return err
+	}
+	if err := checkSourceTrust(indexURL); err != nil {
+		return err
+	}
+
+	index, err := FetchModulesIndex(indexURL)
+	if err != nil {
+		return err
+	}
+	selected := []IndexedModule{}
+	for _, name := range names {
+		i := slices.IndexFunc(index.Modules, func(m IndexedModule) bool { return m.Name == name })
+		if i == -1 {
+			return errors.New(indexURL + " has no module named " + name)
+		}
+		selected = append(selected, index.Modules[i])
+	}
+	if len(selected) == 0 {
+		return errors.New("no module was selected")
+	}
+
+	githubPath, err := parseGithubRawLink(indexURL)
+	if err != nil {
+		return err
+	}
+	tmp, err := fsys.MkdirTemp(os.TempDir(), "bespoke-modules-")
+	if err != nil {
+		return err
+	}
+	defer fsys.RemoveAll(tmp)
+
+	// Only the folders of the selected modules are extracted, each to tmp/<path relative to the repository>
+	folders := make([]string, len(selected))
+	for i, module := range selected {
+		folders[i] = path.Join(githubPath.path, path.Clean(module.Path))
+	}
+	archiveLink := githubPath.getRepoArchiveLink()
+	if !skip("download %s into %s", archiveLink, tmp) {
+		if err := downloadFolders(archiveLink, folders, tmp); err != nil {
+			return err
+		}
+	}
+
+	// Only used to pin the modules in `pkg freeze`, so failing to resolve it doesn't prevent the install
+	commit, _ := resolveCommit(indexURL)
+	for _, module := range selected {
+		rel := path.Join(githubPath.path, path.Clean(module.Path))
+		metadataURL := strings.TrimSuffix(indexURL, ModulesIndexFile) + path.Join(path.Clean(module.Path), "metadata.json")
+		if skip("install %s from %s", module.Name, metadataURL) {
+			continue
+		}
+
+		moduleDir := filepath.Join(tmp, filepath.FromSlash(rel))
+		if err := verifyIndexedMetadata(metadataURL, moduleDir); err != nil {
+			return errors.New(module.Name + ": " + err.Error())
+		}
+		metadata, err := fetchLocalMetadata(filepath.Join(moduleDir, "metadata.json"))
+		if err != nil {
+			return errors.New(module.Name + ": " + err.Error())
+		}
+		if err := installModuleDir(metadataURL, moduleDir, metadata, commit); err != nil {
+			return errors.New(module.Name + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+// verifyIndexedMetadata checks the metadata published at metadataURL like that of the modules installed from their
+// metadata URL, and that the archive of the repository holds the same in moduleDir. The files extracted
+// from the archive are then checked against the signature in moduleDir
+func verifyIndexedMetadata(metadataURL RemoteURL, moduleDir string) error {
+	published, resolved, err := fetchRemoteMetadata(metadataURL)
+	if err != nil {
+		return err
+	}
+	if err := checkRemoteSources(metadataURL, resolved); err != nil {
+		return err
+	}
+	signed, err := verifyRemoteMetadata(resolved, &published)
+	if err != nil {
+		return err
+	}
+	if err := signed.verifySource(resolved); err != nil {
+		return err
+	}
+
+	raw, err := network.GetCached(resolved)
+	if err != nil {
+		return err
+	}
+	extracted, err := fsys.ReadFile(filepath.Join(moduleDir, "metadata.json"))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(raw, extracted) {
+		return errors.New("the metadata published at " + resolved + " doesn't match the one in the archive of the repository")
+	}
+	return nil
+}
+
+// downloadFolders extracts the given folders of a repository from its archive into dest, at their path in the repository
+func downloadFolders(archiveLink string, folders []string, dest string) error {
+	patterns := make([]string, len(folders))
+	for i, folder := range folders {
+		patterns[i] = regexp.QuoteMeta(folder)
+		// The archive entries of the parent folders aren't extracted
+		if err := fsys.MkdirAll(filepath.Join(dest, filepath.FromSlash(path.Dir(folder))), 0755); err != nil {
+			return err
+		}
+	}
+
+	defer trace.Start("download and extract", archiveLink).Finish()
+	res, err := network.Get(archiveLink)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return network.NewStatusError(res)
+	}
+
+	body, cleanup, err := scanArchive(trackDownload(res.Body, StoreIdentifier{}, archiveLink, res.ContentLength), archiveLink)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	srcRe := regexp.MustCompile(`^[^/]+/((?:` + strings.Join(patterns, "|") + `)/.*)$`)
+	return archive.UnTarGZ(body, srcRe, dest, archive.Filter{Extracted: trackExtraction(StoreIdentifier{}, archiveLink)})
+}
@@ -0,0 +1,68 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyIndexedMetadata(t *testing.T) {
+	const published = `{"name": "theme", "version": "1.0.0", "authors": ["a"]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(published))
+	}))
+	defer server.Close()
+	allowHTTP, policy := AllowHTTP, ActivePolicy
+	t.Cleanup(func() { AllowHTTP, ActivePolicy = allowHTTP, policy })
+	AllowHTTP = true
+
+	tests := []struct {
+		name      string
+		extracted string
+		policy    Policy
+		wantErr   bool
+	}{
+		{"matching", published, Policy{}, false},
+		{"archive differs", `{"name": "theme", "version": "6.6.6", "authors": ["a"]}`, Policy{}, true},
+		{"host not allowed", published, Policy{AllowedHosts: []string{"example.com"}}, true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFs(t)
+			ActivePolicy = tt.policy
+			moduleDir := filepath.Join(t.TempDir(), "theme")
+			if err := fsys.MkdirAll(moduleDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := fsys.WriteFile(filepath.Join(moduleDir, "metadata.json"), []byte(tt.extracted), 0644); err != nil {
+				t.Fatal(err)
+			}
+			metadataURL := server.URL + "/" + string(rune('a'+i)) + "/metadata.json"
+			if err := verifyIndexedMetadata(metadataURL, moduleDir); (err != nil) != tt.wantErr {
+				t.Errorf("verifyIndexedMetadata() = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// OrderedModules returns the vault's modules sorted by load priority (lowest first)
+func (v *Vault) OrderedModules() []ModuleIdentifierStr {
+	identifiers := make([]ModuleIdentifierStr, 0, len(v.Modules))
+	for identifier := range v.Modules {
+		identifiers = append(identifiers, identifier)
+	}
+	slices.SortFunc(identifiers, func(a, b ModuleIdentifierStr) int {
+		if pa, pb := v.Modules[a].Priority, v.Modules[b].Priority; pa != pb {
+			return pa - pb
+		}
+		return strings.Compare(string(a), string(b))
+	})
+	return identifiers
+}
+
+func (v *Vault) setOrder(identifiers []ModuleIdentifierStr) {
+	for priority, identifier := range identifiers {
+		module := v.Modules[identifier]
+		module.Priority = priority
+		v.Modules[identifier] = module
+	}
+}
+
+func (v *Vault) nextPriority() int {
+	next := 0
+	for _, module := range v.Modules {
+		next = max(next, module.Priority+1)
+	}
+	return next
+}
+
+// MoveModule places identifier right before (or after) anchor in the load order
+func MoveModule(identifier ModuleIdentifier, anchor ModuleIdentifier, after bool) error {
+	if identifier == anchor {
+		return ErrSameModule
+	}
+
+	unlock, err := lockVault()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	before := snapshotVault()
+	vault, err := GetVault()
+	if err != nil {
+		return err
+	}
+
+	for _, i := range []ModuleIdentifier{identifier, anchor} {
+		if _, ok := vault.Modules[i.toPath()]; !ok {
+			return errors.New("can't find module " + i.String())
+		}
+	}
+
+	order := slices.DeleteFunc(vault.OrderedModules(), func(i ModuleIdentifierStr) bool {
+		return i == identifier.toPath()
+	})
+	i := slices.Index(order, anchor.toPath())
+	if after {
+		i++
+	}
+	vault.setOrder(slices.Insert(order, i, identifier.toPath()))
+
+	if err := SetVault(vault); err != nil {
+		return err
+	}
+	return record(OpOrder, identifier.String(), before)
+}
+
+func GetModuleOrder() ([]ModuleIdentifierStr, *Vault, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return nil, vault, err
+	}
+	return vault.OrderedModules(), vault, nil
+}
+
+// GetEnabledModules returns the enabled version of every module, in load order
+func GetEnabledModules() ([]StoreIdentifier, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return nil, err
+	}
+	return enabledModules(vault), nil
+}
+
+func enabledModules(vault *Vault) []StoreIdentifier {
+	enabled := []StoreIdentifier{}
+	for _, identifier := range vault.OrderedModules() {
+		if version := vault.Modules[identifier].Enabled; len(version) > 0 {
+			enabled = append(enabled, StoreIdentifier{NewModuleIdentifier(string(identifier)), version})
+		}
+	}
+	return enabled
+}
+
+var ErrSameModule = errors.New("can't order a module relative to itself")
@@ -0,0 +1,69 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/paths"
+	"path/filepath"
+)
+
+var (
+	modulesFolder string
+	// The modules folder links to the latest generation, see publishModules
+	generationsFolder string
+	storeFolder       string
+	vaultPath         string
+	manifestsFolder   string
+	journalPath       string
+	// Removed modules are kept around so that their removal can be undone
+	trashFolder      string
+	snapshotsFolder  string
+	backupsFolder    string
+	locksFolder      string
+	scriptLogsFolder string
+	// The system store holds the versions installed for every user, each user's store links to them
+	systemStoreFolder string
+	systemLocksFolder string
+	// OverridesPath points to a JSON object mapping "author/name" (or "author/name@version") to a metadata URL
+	OverridesPath string
+)
+
+func init() {
+	ConfigurePaths()
+}
+
+// ConfigurePaths derives the module folders from the paths package,
+// it must be called again after switching workspace
+func ConfigurePaths() {
+	modulesFolder = filepath.Join(paths.ConfigPath, "modules")
+	generationsFolder = filepath.Join(paths.ConfigPath, "generations")
+	storeFolder = filepath.Join(paths.ConfigPath, "store")
+	vaultPath = filepath.Join(modulesFolder, "vault.json")
+	manifestsFolder = filepath.Join(paths.ConfigPath, "manifests")
+	journalPath = filepath.Join(paths.ConfigPath, "journal.jsonl")
+	trashFolder = filepath.Join(paths.CachePath, "removed")
+	snapshotsFolder = filepath.Join(paths.CachePath, "snapshots")
+	backupsFolder = filepath.Join(paths.StatePath, "backups")
+	locksFolder = filepath.Join(paths.StatePath, "locks")
+	scriptLogsFolder = filepath.Join(paths.LogPath, "scripts")
+	systemStoreFolder = filepath.Join(paths.SystemPath, "store")
+	systemLocksFolder = filepath.Join(paths.SystemPath, "locks")
+	OverridesPath = filepath.Join(paths.ConfigPath, "overrides.json")
+}
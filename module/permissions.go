@@ -0,0 +1,80 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"log"
+	"slices"
+	"strings"
+)
+
+// Permissions are the capabilities a module asks for in its metadata, the client-side loader denies the others
+var Permissions = map[string]string{
+	"network":        "send requests to other websites",
+	"localStorage":   "store data in the client",
+	"player-control": "control playback",
+}
+
+func (m *Metadata) unknownPermissions() []string {
+	unknown := []string{}
+	for _, permission := range m.Permissions {
+		if _, ok := Permissions[permission]; !ok {
+			unknown = append(unknown, permission)
+		}
+	}
+	return unknown
+}
+
+// DescribePermissions lists the permissions of a module with what they allow, sorted by name
+func (m *Metadata) DescribePermissions() []string {
+	permissions := slices.Clone(m.Permissions)
+	slices.Sort(permissions)
+	described := []string{}
+	for _, permission := range slices.Compact(permissions) {
+		if description, ok := Permissions[permission]; ok {
+			permission += " (" + description + ")"
+		} else {
+			permission += " (unknown, denied)"
+		}
+		described = append(described, permission)
+	}
+	return described
+}
+
+// grantedPermissions are the known permissions requested by a module, never nil so that the manifest always
+// tells the loader what to grant
+func grantedPermissions(m *Metadata) []string {
+	granted := []string{}
+	for _, permission := range m.Permissions {
+		if _, ok := Permissions[permission]; ok && !slices.Contains(granted, permission) {
+			granted = append(granted, permission)
+		}
+	}
+	return granted
+}
+
+// announcePermissions shows what a module is allowed to do before it is installed
+func announcePermissions(identifier StoreIdentifier, metadata *Metadata) {
+	if len(metadata.Permissions) == 0 {
+		log.Println(identifier.String(), "doesn't request any permission")
+		return
+	}
+	log.Println(identifier.String(), "requests the permissions:", strings.Join(metadata.DescribePermissions(), ", "))
+}
@@ -0,0 +1,158 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"encoding/json"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Platform holds what a module only ships for some platforms (e.g. native helpers). Its entries replace the
+// shared ones they set and its assets are installed along with the shared ones
+type Platform struct {
+	Entries Entries  `json:"entries"`
+	Assets  []string `json:"assets"`
+}
+
+// matchesPlatform reports whether a key of Metadata.Platforms ("<goos>/<goarch>", "<goos>" or "*/<goarch>")
+// applies to a platform
+func matchesPlatform(key string, goos string, goarch string) bool {
+	keyOS, keyArch, hasArch := strings.Cut(key, "/")
+	return (keyOS == "*" || keyOS == goos) && (!hasArch || keyArch == "*" || keyArch == goarch)
+}
+
+// platformSpecificity orders platform keys so that "linux/amd64" overrides "linux", which overrides "*/amd64"
+func platformSpecificity(key string) int {
+	specificity := 0
+	for _, part := range strings.Split(key, "/") {
+		if part != "*" {
+			specificity++
+		}
+	}
+	if strings.HasPrefix(key, "*") {
+		specificity--
+	}
+	return specificity
+}
+
+// forPlatform returns the metadata the loader sees on a platform, without the other platforms
+func (m *Metadata) forPlatform(goos string, goarch string) Metadata {
+	resolved := *m
+	resolved.Platforms = nil
+	resolved.Assets = slices.Clone(m.Assets)
+
+	keys := []string{}
+	for key := range m.Platforms {
+		if matchesPlatform(key, goos, goarch) {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if d := platformSpecificity(a) - platformSpecificity(b); d != 0 {
+			return d
+		}
+		return strings.Compare(a, b)
+	})
+
+	for _, key := range keys {
+		platform := m.Platforms[key]
+		for _, entry := range []struct{ from, to *string }{
+			{&platform.Entries.Js, &resolved.Entries.Js},
+			{&platform.Entries.Css, &resolved.Entries.Css},
+			{&platform.Entries.Mixin, &resolved.Entries.Mixin},
+		} {
+			if *entry.from != "" {
+				*entry.to = *entry.from
+			}
+		}
+		resolved.Assets = append(resolved.Assets, platform.Assets...)
+	}
+	return resolved
+}
+
+func matchAsset(pattern string, file string) bool {
+	ok, _ := path.Match(strings.TrimPrefix(pattern, "./"), file)
+	return ok
+}
+
+// prunePlatformAssets removes the entries and assets of other platforms from a freshly populated store, and
+// replaces its metadata.json with the metadata resolved for the current platform
+func prunePlatformAssets(storePath string) error {
+	metadata, err := fetchLocalMetadata(filepath.Join(storePath, "metadata.json"))
+	if err != nil || len(metadata.Platforms) == 0 {
+		return nil
+	}
+	resolved := metadata.forPlatform(runtime.GOOS, runtime.GOARCH)
+
+	foreign := []string{}
+	for key, platform := range metadata.Platforms {
+		if !matchesPlatform(key, runtime.GOOS, runtime.GOARCH) {
+			foreign = append(foreign, platform.Assets...)
+			foreign = append(foreign, platform.Entries.files()...)
+		}
+	}
+	kept := append(resolved.Entries.files(), "metadata.json")
+	isKept := func(file string) bool {
+		return slices.Contains(kept, file) || slices.ContainsFunc(resolved.Assets, func(pattern string) bool {
+			return matchAsset(pattern, file)
+		})
+	}
+
+	err = filepath.WalkDir(storePath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(storePath, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if isKept(rel) {
+			return nil
+		}
+		for _, pattern := range foreign {
+			if matchAsset(pattern, rel) {
+				if skip("remove %s (other platform)", p) {
+					return nil
+				}
+				return os.Remove(p)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if skip("write the %s/%s metadata in %s", runtime.GOOS, runtime.GOARCH, storePath) {
+		return nil
+	}
+	resolvedJson, err := json.MarshalIndent(resolved, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(storePath, "metadata.json"), resolvedJson, 0644)
+}
@@ -0,0 +1,183 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/paths"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Policy restricts which modules can be installed or enabled, it is meant to be deployed by admins on managed machines
+type Policy struct {
+	// When non-empty, only modules from these authors are allowed
+	TrustedAuthors []string `json:"trustedAuthors"`
+	// author/name or author/name/version patterns (path.Match syntax)
+	BlockedIdentifiers []string `json:"blockedIdentifiers"`
+	// When non-empty, modules can only be downloaded from these hosts
+	AllowedHosts []string `json:"allowedHosts"`
+	// Replaces the scan command of the config, see ScanCommand
+	ScanCommand string `json:"scanCommand"`
+	// Blocks the modules whose metadata declares no license
+	RequireLicense bool `json:"requireLicense"`
+	// When non-empty, only modules under these licenses (SPDX identifiers, e.g. MIT) are allowed
+	AllowedLicenses []string `json:"allowedLicenses"`
+}
+
+type PolicyViolationError struct {
+	Reason string
+}
+
+func (e *PolicyViolationError) Error() string {
+	return "policy violation: " + e.Reason
+}
+
+var ActivePolicy = Policy{}
+
+// ManagedPolicy is set when ActivePolicy was deployed by admins at paths.SystemPolicyPath
+var ManagedPolicy bool
+
+// LoadPolicy loads the policy of the machine, or the one at policyPath when the machine has none. The policy
+// of the machine fails closed: when it exists but can't be read, nothing is allowed. policyPath is optional
+// unless required, e.g. when the user set it
+func LoadPolicy(policyPath string, required bool) error {
+	policy, err := readPolicy(paths.SystemPolicyPath)
+	if err == nil {
+		ActivePolicy, ManagedPolicy = policy, true
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return errors.New("can't read the policy of the machine at " + paths.SystemPolicyPath + ": " + err.Error())
+	}
+
+	policy, err = readPolicy(policyPath)
+	if os.IsNotExist(err) && !required {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	ActivePolicy = policy
+	return nil
+}
+
+func readPolicy(policyPath string) (Policy, error) {
+	file, err := os.Open(policyPath)
+	if err != nil {
+		return Policy{}, err
+	}
+	defer file.Close()
+
+	var policy Policy
+	err = json.NewDecoder(file).Decode(&policy)
+	return policy, err
+}
+
+var scpLikeRe = regexp.MustCompile(`^[^@/]+@(?<host>[^:/]+):`)
+
+func sourceHost(murl string) string {
+	if IsReleaseSource(murl) {
+		return "github.com"
+	}
+	murl = strings.TrimPrefix(murl, gitSourcePrefix)
+	if u, err := url.Parse(murl); err == nil && u.Host != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	if parts := scpLikeRe.FindStringSubmatch(murl); parts != nil {
+		return strings.ToLower(parts[1])
+	}
+	return ""
+}
+
+func (p *Policy) CheckSource(murl string) error {
+	if len(p.AllowedHosts) == 0 {
+		return nil
+	}
+	host := sourceHost(murl)
+	for _, allowed := range p.AllowedHosts {
+		if host != "" && strings.EqualFold(host, allowed) {
+			return nil
+		}
+	}
+	return &PolicyViolationError{"source host of " + murl + " is not allowed"}
+}
+
+// CheckModule checks a version against the policy. verified tells whether its signature was checked with the key
+// of its author (see verifyMetadata): modules declare their author themselves, so with TrustedAuthors set only
+// signed versions are allowed
+func (p *Policy) CheckModule(identifier StoreIdentifier, verified bool) error {
+	if len(p.TrustedAuthors) > 0 {
+		trusted := false
+		for _, author := range p.TrustedAuthors {
+			trusted = trusted || strings.EqualFold(author, string(identifier.Author))
+		}
+		if !trusted {
+			return &PolicyViolationError{"author " + string(identifier.Author) + " is not trusted"}
+		}
+		if !verified {
+			return &PolicyViolationError{identifier.String() + " isn't signed by " + string(identifier.Author) + ", trusted authors must sign their modules"}
+		}
+	}
+
+	for _, pattern := range p.BlockedIdentifiers {
+		candidates := []string{identifier.ModuleIdentifier.String(), identifier.String()}
+		for _, candidate := range candidates {
+			if ok, _ := path.Match(pattern, candidate); ok {
+				return &PolicyViolationError{identifier.String() + " is blocked by " + pattern}
+			}
+		}
+	}
+
+	return nil
+}
+
+// CheckLicense checks the SPDX license expression declared by a module against the allowed licenses: any alternative
+// (OR) whose licenses (AND) are all allowed is enough. Expressions that can't be parsed are refused
+func (p *Policy) CheckLicense(identifier StoreIdentifier, license string) error {
+	if license == "" {
+		if p.RequireLicense || len(p.AllowedLicenses) > 0 {
+			return &PolicyViolationError{identifier.String() + " declares no license"}
+		}
+		return nil
+	}
+	if len(p.AllowedLicenses) == 0 {
+		return nil
+	}
+
+	expression, err := parseLicense(license)
+	if err != nil {
+		return &PolicyViolationError{"license " + license + " of " + identifier.String() + " can't be read: " + err.Error()}
+	}
+	allowed := expression.satisfiedBy(func(id string) bool {
+		return slices.ContainsFunc(p.AllowedLicenses, func(allowed string) bool {
+			return strings.EqualFold(allowed, id)
+		})
+	})
+	if !allowed {
+		return &PolicyViolationError{"license " + license + " of " + identifier.String() + " is not allowed"}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import "testing"
+
+func TestCheckModuleTrustedAuthors(t *testing.T) {
+	policy := Policy{TrustedAuthors: []string{"spicetify"}, BlockedIdentifiers: []string{"spicetify/old"}}
+	tests := []struct {
+		identifier string
+		verified   bool
+		wantErr    bool
+	}{
+		{"spicetify/theme/1.0.0", true, false},
+		// Any module can declare a trusted author
+		{"spicetify/theme/1.0.0", false, true},
+		{"someone/theme/1.0.0", true, true},
+		{"spicetify/old/1.0.0", true, true},
+	}
+	for _, tt := range tests {
+		err := policy.CheckModule(NewStoreIdentifier(tt.identifier), tt.verified)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckModule(%s, verified %v) = %v, wantErr %v", tt.identifier, tt.verified, err, tt.wantErr)
+		}
+	}
+}
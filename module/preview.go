@@ -0,0 +1,124 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"bespoke/network"
+	"net/http"
+	"os"
+)
+
+// Preview is what can be learned about a module without installing it
+type Preview struct {
+	Source   string      `json:"source"`
+	Trust    SourceTrust `json:"trust"`
+	Metadata Metadata    `json:"metadata"`
+	// DownloadSize is the size of the archive to download, -1 when the server doesn't tell
+	DownloadSize int64 `json:"downloadSize"`
+	// Installed lists the versions already in the store
+	Installed []Version `json:"installed"`
+	Enabled   Version   `json:"enabled"`
+	// Statuses holds the state of each installed version
+	Statuses []ModuleStatus `json:"statuses"`
+}
+
+// PreviewModule fetches the metadata of a module from any supported source (see InstallModuleMURL)
+func PreviewModule(murl string) (Preview, error) {
+	murl = NormalizeSourceURL(murl)
+	preview := Preview{Source: murl, DownloadSize: -1}
+
+	if IsReleaseSource(murl) {
+		source, err := ParseReleaseSource(murl)
+		if err != nil {
+			return preview, err
+		}
+		tmp, resolved, metadata, err := source.fetchModule()
+		if tmp != "" {
+			defer fsys.RemoveAll(tmp)
+		}
+		if err != nil {
+			return preview, err
+		}
+		preview.Source = resolved.String()
+		preview.Metadata = metadata
+		preview.DownloadSize, _ = DirSize(tmp)
+	} else if IsGitSource(murl) {
+		source, err := ParseGitSource(murl)
+		if err != nil {
+			return preview, err
+		}
+		tmp, moduleDir, metadata, err := source.cloneModule()
+		if tmp != "" {
+			defer os.RemoveAll(tmp)
+		}
+		if err != nil {
+			return preview, err
+		}
+		preview.Metadata = metadata
+		preview.DownloadSize, _ = DirSize(moduleDir)
+	} else {
+		if ref, ok := ParseModuleRef(murl); ok {
+			metadataURL, _, err := ResolveModuleRef(ref)
+			if err != nil {
+				return preview, err
+			}
+			preview.Source = metadataURL
+		}
+		metadata, resolved, err := fetchRemoteMetadata(preview.Source)
+		if err != nil {
+			return preview, err
+		}
+		preview.Source = resolved
+		preview.Metadata = metadata
+		preview.DownloadSize = archiveSize(preview.Source)
+	}
+
+	preview.Trust = ClassifySource(preview.Source)
+
+	if len(preview.Metadata.Authors) > 0 {
+		if vault, err := GetVault(); err == nil {
+			identifier := preview.Metadata.getModuleIdentifier()
+			module := vault.getModule(identifier.toPath())
+			preview.Installed = module.versions()
+			preview.Enabled = module.Enabled
+			for _, version := range preview.Installed {
+				preview.Statuses = append(preview.Statuses, inspectStore(module, StoreIdentifier{identifier, version}))
+			}
+		}
+	}
+	return preview, nil
+}
+
+func archiveSize(metadataURL RemoteURL) int64 {
+	githubPath, err := parseGithubRawLink(metadataURL)
+	if err != nil {
+		return -1
+	}
+	res, err := network.Head(githubPath.getRepoArchiveLink())
+	if err != nil {
+		return -1
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return -1
+	}
+	return res.ContentLength
+}
@@ -0,0 +1,213 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+var githubRemoteRe = regexp.MustCompile(`github\.com[:/](?<owner>[^/]+)/(?<repo>[^/]+?)(\.git)?/?$`)
+
+// BumpVersion increments the major, minor or patch component of a x.y.z version
+func BumpVersion(version string, part string) (string, error) {
+	core, _, _ := strings.Cut(version, "-")
+	components := strings.Split(core, ".")
+	if len(components) != 3 {
+		return "", errors.New("version " + version + " isn't in the x.y.z format")
+	}
+	numbers := [3]int{}
+	for i, component := range components {
+		n, err := strconv.Atoi(component)
+		if err != nil {
+			return "", errors.New("version " + version + " isn't in the x.y.z format")
+		}
+		numbers[i] = n
+	}
+
+	switch part {
+	case "major":
+		numbers = [3]int{numbers[0] + 1, 0, 0}
+	case "minor":
+		numbers = [3]int{numbers[0], numbers[1] + 1, 0}
+	case "patch":
+		numbers[2]++
+	default:
+		return "", errors.New("unknown version part " + part)
+	}
+	return strconv.Itoa(numbers[0]) + "." + strconv.Itoa(numbers[1]) + "." + strconv.Itoa(numbers[2]), nil
+}
+
+// setMetadataVersion sets the top-level version of metadata.json, leaving the other fields as they are
+func setMetadataVersion(metadataPath string, version string) error {
+	raw, err := os.ReadFile(metadataPath)
+	if err != nil {
+		return err
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return errors.New("can't read " + metadataPath + ": " + err.Error())
+	}
+	if _, ok := fields["version"]; !ok {
+		return errors.New("can't find the version field in " + metadataPath)
+	}
+	if fields["version"], err = json.Marshal(version); err != nil {
+		return err
+	}
+	raw, err = json.MarshalIndent(fields, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(metadataPath, append(raw, '\n'), 0644)
+}
+
+// snapshotFiles reads files so that restore writes them back as they were, removing those that didn't exist
+func snapshotFiles(files ...string) (restore func()) {
+	contents := map[string][]byte{}
+	for _, file := range files {
+		if raw, err := os.ReadFile(file); err == nil {
+			contents[file] = raw
+		}
+	}
+	return func() {
+		for _, file := range files {
+			if raw, ok := contents[file]; ok {
+				os.WriteFile(file, raw, 0644)
+			} else {
+				os.Remove(file)
+			}
+		}
+	}
+}
+
+type PublishOptions struct {
+	Bump    string
+	Version string
+	OutDir  string
+	Build   bool
+	// SigningKey signs the bumped metadata.json, as required for verified authors
+	SigningKey string
+}
+
+// Publish bumps the module version, tags and pushes it, then creates a GitHub release with the bundle attached.
+// It returns the metadata URL users can install the release from
+func Publish(moduleDir string, opts PublishOptions) (RemoteURL, error) {
+	metadataPath := filepath.Join(moduleDir, "metadata.json")
+	metadata, err := fetchLocalMetadata(metadataPath)
+	if err != nil {
+		return "", err
+	}
+
+	version := opts.Version
+	if version == "" {
+		if version, err = BumpVersion(metadata.Version, opts.Bump); err != nil {
+			return "", err
+		}
+	}
+	tag := "v" + version
+
+	remote, err := gitOutput(moduleDir, "remote", "get-url", "origin")
+	if err != nil {
+		return "", err
+	}
+	parts := githubRemoteRe.FindStringSubmatch(remote)
+	if parts == nil {
+		return "", errors.New("origin " + remote + " isn't a GitHub repository")
+	}
+	owner, repo := parts[1], parts[2]
+
+	prefix, err := gitOutput(moduleDir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return "", err
+	}
+
+	// Until the bump is committed, failing leaves metadata.json and its signature as they were
+	restore := snapshotFiles(metadataPath, metadataPath+signatureSuffix)
+	committed := false
+	defer func() {
+		if !committed {
+			restore()
+		}
+	}()
+	if err := setMetadataVersion(metadataPath, version); err != nil {
+		return "", err
+	}
+
+	metadataURL := "https://raw.githubusercontent.com/" + path.Join(owner, repo, tag, prefix, "metadata.json")
+
+	// The signature is part of the bundle, so that installs from the release are verified too
+	added := []string{"add", "metadata.json"}
+	if opts.SigningKey != "" {
+		sources := []string{metadataURL, ReleaseSource{Owner: owner, Repo: repo, Tag: tag}.String()}
+		if err := SignModule(moduleDir, opts.SigningKey, sources, ""); err != nil {
+			return "", err
+		}
+		added = append(added, "metadata.json"+signatureSuffix)
+	}
+
+	artifact, err := Bundle(moduleDir, opts.OutDir, opts.Build)
+	if err != nil {
+		return "", err
+	}
+
+	steps := [][]string{
+		added,
+		{"commit", "--quiet", "-m", metadata.Name + " " + tag},
+		{"tag", tag},
+		{"push", "--quiet", "origin", "HEAD", tag},
+	}
+	for _, step := range steps {
+		if err := git(moduleDir, step...); err != nil {
+			return "", errors.New("git " + step[0] + " failed: " + err.Error())
+		}
+		committed = committed || step[0] == "commit"
+	}
+
+	ctx := Context
+	release, _, err := client.Repositories.CreateRelease(ctx, owner, repo, &github.RepositoryRelease{
+		TagName: github.String(tag),
+		Name:    github.String(metadata.Name + " " + tag),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, asset := range []string{artifact, artifact + ".sha256"} {
+		file, err := os.Open(asset)
+		if err != nil {
+			return "", err
+		}
+		_, _, err = client.Repositories.UploadReleaseAsset(ctx, owner, repo, release.GetID(), &github.UploadOptions{Name: filepath.Base(asset)}, file)
+		file.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return metadataURL, nil
+}
@@ -0,0 +1,79 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetMetadataVersion(t *testing.T) {
+	metadataPath := filepath.Join(t.TempDir(), "metadata.json")
+	raw := `{"name": "one", "platforms": {"linux": {"version": "0.1.0"}}, "version": "1.0.0", "size": 12345678901234567890}`
+	if err := os.WriteFile(metadataPath, []byte(raw), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := setMetadataVersion(metadataPath, "1.1.0"); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata, err := fetchLocalMetadata(metadataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Version != "1.1.0" {
+		t.Errorf("version = %s, want 1.1.0", metadata.Version)
+	}
+	written, err := os.ReadFile(metadataPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "{\n\t\"name\": \"one\",\n\t\"platforms\": {\n\t\t\"linux\": {\n\t\t\t\"version\": \"0.1.0\"\n\t\t}\n\t},\n\t\"size\": 12345678901234567890,\n\t\"version\": \"1.1.0\"\n}\n"
+	if string(written) != want {
+		t.Errorf("metadata.json = %s, want %s", written, want)
+	}
+
+	if err := os.WriteFile(metadataPath, []byte(`{"name": "one"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := setMetadataVersion(metadataPath, "1.1.0"); err == nil {
+		t.Error("a metadata.json without a version was bumped")
+	}
+}
+
+func TestSnapshotFiles(t *testing.T) {
+	dir := t.TempDir()
+	existing, missing := filepath.Join(dir, "metadata.json"), filepath.Join(dir, "metadata.json.sig")
+	if err := os.WriteFile(existing, []byte("before"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	restore := snapshotFiles(existing, missing)
+	os.WriteFile(existing, []byte("after"), 0644)
+	os.WriteFile(missing, []byte("signature"), 0644)
+
+	restore()
+	if raw, _ := os.ReadFile(existing); string(raw) != "before" {
+		t.Errorf("metadata.json = %s, want before", raw)
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Error("the signature written afterwards wasn't removed")
+	}
+}
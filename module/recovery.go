@@ -0,0 +1,119 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+)
+
+var errCorruptVault = errors.New("vault.json is corrupt")
+
+// shadowPattern names the shadow copies written next to a file, the * being replaced by a random number
+// so that concurrent writers don't share one
+const shadowPattern = ".*.new"
+
+// writeVaultFile writes a shadow copy next to name, flushes it to disk and renames it over name,
+// so that a crash or power loss leaves either the previous or the new vault but never a truncated one
+func writeVaultFile(name string, data []byte) error {
+	dir := filepath.Dir(name)
+	file, err := fsys.CreateTemp(dir, filepath.Base(name)+shadowPattern)
+	if err != nil {
+		return err
+	}
+	shadow := file.Name()
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = fsys.Rename(shadow, name)
+	}
+	if err != nil {
+		fsys.Remove(shadow)
+		return err
+	}
+	return syncDir(dir)
+}
+
+// syncDir flushes the entries of dir to disk, for a rename in it to survive a power loss.
+// Folders can't be flushed on Windows, where renames are journaled by NTFS
+func syncDir(dir string) error {
+	if !fsys.IsOS() || runtime.GOOS == "windows" {
+		return nil
+	}
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
+
+// recoverVault replaces a vault.json that can't be parsed with the shadow copies left by interrupted
+// writes, newest first, or, failing that, the most recent journal snapshot that is valid
+func recoverVault(cause error) (*Vault, error) {
+	candidates := shadowCopies(vaultPath)
+	// Snapshots are named after the time of their journal entry
+	if entries, err := fsys.ReadDir(snapshotsFolder); err == nil {
+		for i := len(entries) - 1; i >= 0; i-- {
+			if strings.HasSuffix(entries[i].Name(), ".json") {
+				candidates = append(candidates, filepath.Join(snapshotsFolder, entries[i].Name()))
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		vault, err := readVaultFile(candidate)
+		if err != nil {
+			continue
+		}
+		if vault.Modules == nil {
+			vault.Modules = map[ModuleIdentifierStr]Module{}
+		}
+		log.Println(cause.Error()+", restoring it from", candidate)
+		return vault, SetVault(vault)
+	}
+
+	return &Vault{}, errors.New(cause.Error() + " and there is no valid copy to restore, run `bespoke vault repair` to rebuild it from the store")
+}
+
+// shadowCopies lists the shadow copies of name, the most recently written first
+func shadowCopies(name string) []string {
+	shadows, _ := fsys.Glob(name + shadowPattern)
+	modTimes := map[string]time.Time{}
+	for _, shadow := range shadows {
+		if fi, err := fsys.Stat(shadow); err == nil {
+			modTimes[shadow] = fi.ModTime()
+		}
+	}
+	slices.SortFunc(shadows, func(a, b string) int { return modTimes[b].Compare(modTimes[a]) })
+	return shadows
+}
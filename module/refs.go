@@ -0,0 +1,185 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/network"
+	"bytes"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var commitRe = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// remoteRefs memoizes the refs (mapped to their commit) advertised by each repository for the lifetime of the process
+var remoteRefs = struct {
+	sync.Mutex
+	repos map[string]map[string]string
+}{repos: map[string]map[string]string{}}
+
+// listRemoteRefs is the equivalent of `git ls-remote`: it reads the refs advertised by the smart HTTP endpoint
+// of the repository, which isn't subject to the API rate limit
+func listRemoteRefs(owner string, repo string) (map[string]string, error) {
+	key := owner + "/" + repo
+	remoteRefs.Lock()
+	defer remoteRefs.Unlock()
+	if refs, ok := remoteRefs.repos[key]; ok {
+		return refs, nil
+	}
+
+	raw, err := network.GetCached("https://github.com/" + key + ".git/info/refs?service=git-upload-pack")
+	if err != nil {
+		return nil, err
+	}
+	refs, err := parseAdvertisedRefs(raw)
+	if err != nil {
+		return nil, err
+	}
+	remoteRefs.repos[key] = refs
+	return refs, nil
+}
+
+// parseAdvertisedRefs reads the pkt-lines of a ref advertisement: "<sha> <ref>[\x00<capabilities>]\n",
+// annotated tags are mapped to the commit they point to
+func parseAdvertisedRefs(raw []byte) (map[string]string, error) {
+	refs := map[string]string{}
+	for len(raw) > 0 {
+		if len(raw) < 4 {
+			return nil, errors.New("truncated ref advertisement")
+		}
+		length, err := strconv.ParseUint(string(raw[:4]), 16, 16)
+		if err != nil {
+			return nil, errors.New("malformed ref advertisement")
+		}
+		// Flush packets separate the service announcement from the refs
+		if length == 0 {
+			raw = raw[4:]
+			continue
+		}
+		if length < 4 || int(length) > len(raw) {
+			return nil, errors.New("malformed ref advertisement")
+		}
+		line := raw[4:length]
+		raw = raw[length:]
+
+		line, _, _ = bytes.Cut(bytes.TrimSuffix(line, []byte("\n")), []byte{0})
+		sha, ref, ok := strings.Cut(string(line), " ")
+		if !ok || !strings.HasPrefix(ref, "refs/") {
+			continue
+		}
+		// The peeled entry of an annotated tag follows the tag object itself
+		if tag, peeled := strings.CutSuffix(ref, "^{}"); peeled {
+			refs[tag] = sha
+		} else {
+			refs[ref] = sha
+		}
+	}
+	return refs, nil
+}
+
+// resolveGithubVersion tells whether the version segment of a raw link is a branch, a tag or a commit.
+// Without access to the advertised refs, a 40-hex string is taken for a commit and any other
+// name is probed as a branch, then as a tag, against codeload
+func resolveGithubVersion(owner string, repo string, v string) (GithubPathVersion, error) {
+	ref, err := url.PathUnescape(v)
+	if err != nil {
+		return GithubPathVersion{}, err
+	}
+
+	branch := GithubPathVersion{__type: "branch", branch: ref}
+	tag := GithubPathVersion{__type: "tag", tag: ref}
+	commit := GithubPathVersion{__type: "commit", commit: ref}
+
+	if refs, err := listRemoteRefs(owner, repo); err == nil {
+		switch {
+		case refs["refs/heads/"+ref] != "":
+			return branch, nil
+		case refs["refs/tags/"+ref] != "":
+			return tag, nil
+		case commitRe.MatchString(ref):
+			return commit, nil
+		}
+		return GithubPathVersion{}, errors.New("no branch or tag named " + ref + " in " + owner + "/" + repo)
+	}
+
+	if commitRe.MatchString(ref) {
+		return commit, nil
+	}
+	for _, candidate := range []GithubPathVersion{branch, tag} {
+		ghp := VersionedGithubPath{owner: owner, repo: repo, version: candidate}
+		res, err := network.Head("https://codeload.github.com/" + owner + "/" + repo + "/tar.gz/" + ghp.getRef())
+		if err != nil {
+			return GithubPathVersion{}, err
+		}
+		res.Body.Close()
+		if res.StatusCode == http.StatusOK {
+			return candidate, nil
+		}
+	}
+	return GithubPathVersion{}, errors.New("can't find a branch or tag named " + ref + " in " + owner + "/" + repo)
+}
+
+// resolveCommit finds the commit a metadata URL or git source currently points to, it returns ""
+// for sources that aren't backed by a git repository
+func resolveCommit(murl string) (string, error) {
+	if IsGitSource(murl) {
+		source, err := ParseGitSource(murl)
+		if err != nil {
+			return "", err
+		}
+		if commitRe.MatchString(source.Ref) {
+			return source.Ref, nil
+		}
+		ref := source.Ref
+		if ref == "" {
+			ref = "HEAD"
+		}
+		out, err := gitOutput("", "ls-remote", "--", source.Repo, ref)
+		if err != nil {
+			return "", err
+		}
+		sha, _, _ := strings.Cut(out, "\t")
+		if !commitRe.MatchString(sha) {
+			return "", errors.New("no ref " + ref + " in " + source.Repo)
+		}
+		return sha, nil
+	}
+
+	if !githubRawRe.MatchString(murl) {
+		return "", nil
+	}
+	githubPath, err := parseGithubRawLink(murl)
+	if err != nil {
+		return "", err
+	}
+	if githubPath.version.__type == "commit" {
+		return githubPath.version.commit, nil
+	}
+	refs, err := listRemoteRefs(githubPath.owner, githubPath.repo)
+	if err != nil {
+		return "", err
+	}
+	return refs[githubPath.getRef()], nil
+}
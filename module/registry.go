@@ -0,0 +1,304 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/network"
+	"bespoke/version"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// [<registry>:]<owner>/<module>[@<version>]
+var moduleRefRe = regexp.MustCompile(`^(?:(?<registry>[^:/@]+):)?(?<identifier>[^/@:]+/[^/@:]+)(?:@(?<version>[^/]+))?$`)
+
+type ModuleRef struct {
+	Registry string
+	StoreIdentifier
+}
+
+func ParseModuleRef(ref string) (ModuleRef, bool) {
+	parts := moduleRefRe.FindStringSubmatch(ref)
+	if parts == nil {
+		return ModuleRef{}, false
+	}
+	return ModuleRef{
+		Registry: parts[1],
+		StoreIdentifier: StoreIdentifier{
+			ModuleIdentifier: NewModuleIdentifier(parts[2]),
+			Version:          Version(parts[3]),
+		},
+	}, true
+}
+
+type Registry struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Priority int    `json:"priority"`
+	Trusted  bool   `json:"trusted"`
+	// Stats is the endpoint receiving anonymous install pings when telemetry is enabled
+	Stats string `json:"stats"`
+	// Snapshots is the URL of the setups published on the marketplace of a trusted registry, {id} is replaced
+	// by the id of the snapshot (see FetchSnapshot)
+	Snapshots string `json:"snapshots"`
+	// Advisories is the URL of the feed of vulnerable, retracted and malicious versions checked by Audit
+	Advisories string `json:"advisories"`
+}
+
+type RegistryEntry struct {
+	RepoIndex
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+}
+
+type RegistryIndex struct {
+	Modules map[ModuleIdentifierStr]RegistryEntry `json:"modules"`
+	Authors map[Author]RegistryAuthor             `json:"authors"`
+}
+
+// Registries are queried by ascending priority
+var Registries = []Registry{}
+
+// Installs resolved through untrusted registries are refused unless AllowUntrusted is set
+var AllowUntrusted = false
+
+func sortedRegistries() []Registry {
+	registries := slices.Clone(Registries)
+	slices.SortStableFunc(registries, func(a, b Registry) int {
+		return a.Priority - b.Priority
+	})
+	return registries
+}
+
+func fetchRegistryIndex(registry Registry) (RegistryIndex, error) {
+	raw, err := network.GetCached(registry.URL)
+	if err != nil {
+		return RegistryIndex{}, err
+	}
+
+	var index RegistryIndex
+	err = json.Unmarshal(raw, &index)
+	return index, err
+}
+
+func resolveFromRegistries(ref ModuleRef) (RemoteURL, Version, error) {
+	found := false
+	for _, registry := range sortedRegistries() {
+		if ref.Registry != "" && ref.Registry != registry.Name {
+			continue
+		}
+		found = true
+
+		index, err := fetchRegistryIndex(registry)
+		if err != nil {
+			continue
+		}
+		entry, ok := index.Modules[ref.ModuleIdentifier.toPath()]
+		if !ok {
+			continue
+		}
+
+		version := ref.Version
+		if version == "" {
+			version = entry.latestVersion()
+		}
+		metadataURL, ok := entry.Versions[version]
+		if !ok {
+			continue
+		}
+
+		if !registry.Trusted && !AllowUntrusted {
+			return "", "", errors.New("registry " + registry.Name + " isn't trusted, allow untrusted registries to install " + ref.ModuleIdentifier.String() + " from it")
+		}
+		return metadataURL, version, nil
+	}
+
+	if ref.Registry != "" && !found {
+		return "", "", errors.New("unknown registry " + ref.Registry)
+	}
+	return "", "", ErrUnresolved
+}
+
+type SearchResult struct {
+	Registry   string              `json:"registry"`
+	Identifier ModuleIdentifierStr `json:"identifier"`
+	RegistryEntry
+}
+
+func SearchRegistries(query string) ([]SearchResult, error) {
+	query = strings.ToLower(query)
+	results := []SearchResult{}
+	for _, registry := range sortedRegistries() {
+		index, err := fetchRegistryIndex(registry)
+		if err != nil {
+			return results, errors.New("can't fetch registry " + registry.Name + ": " + err.Error())
+		}
+
+		identifiers := []ModuleIdentifierStr{}
+		for identifier := range index.Modules {
+			identifiers = append(identifiers, identifier)
+		}
+		slices.Sort(identifiers)
+
+		for _, identifier := range identifiers {
+			entry := index.Modules[identifier]
+			haystack := strings.ToLower(string(identifier) + " " + entry.Description + " " + strings.Join(entry.Tags, " "))
+			if strings.Contains(haystack, query) {
+				results = append(results, SearchResult{registry.Name, identifier, entry})
+			}
+		}
+	}
+	return results, nil
+}
+
+// CachedRegistryModules lists the modules of the registry indexes in the HTTP cache, without any request (for shell
+// completion). fetched is when the least recently fetched index was, zero when one isn't cached
+func CachedRegistryModules() (results []SearchResult, fetched time.Time) {
+	results = []SearchResult{}
+	missing := false
+	for _, registry := range sortedRegistries() {
+		raw, at, ok := network.ReadCached(registry.URL)
+		var index RegistryIndex
+		if !ok || json.Unmarshal(raw, &index) != nil {
+			missing = true
+			continue
+		}
+		if fetched.IsZero() || at.Before(fetched) {
+			fetched = at
+		}
+
+		identifiers := []ModuleIdentifierStr{}
+		for identifier := range index.Modules {
+			identifiers = append(identifiers, identifier)
+		}
+		slices.Sort(identifiers)
+		for _, identifier := range identifiers {
+			results = append(results, SearchResult{registry.Name, identifier, index.Modules[identifier]})
+		}
+	}
+	if missing {
+		return results, time.Time{}
+	}
+	return results, fetched
+}
+
+// A repo index (Module.Remotes) lists the metadata URL of every published version of a module
+type RepoIndex struct {
+	Latest   Version               `json:"latest"`
+	Versions map[Version]RemoteURL `json:"versions"`
+}
+
+// latestVersion is the declared latest version, else the newest listed one
+func (i RepoIndex) latestVersion() Version {
+	if i.Latest != "" {
+		return i.Latest
+	}
+	versions := make([]Version, 0, len(i.Versions))
+	for v := range i.Versions {
+		versions = append(versions, v)
+	}
+	return version.Latest(versions)
+}
+
+func fetchRepoIndex(indexURL RemoteURL) (RepoIndex, error) {
+	raw, err := network.GetCached(indexURL)
+	if err != nil {
+		return RepoIndex{}, err
+	}
+
+	var index RepoIndex
+	err = json.Unmarshal(raw, &index)
+	return index, err
+}
+
+func resolveFromRemotes(module *Module, version Version) (RemoteURL, bool) {
+	for _, remote := range module.Remotes {
+		if remote == LocalRemote {
+			continue
+		}
+		index, err := fetchRepoIndex(remote)
+		if err != nil {
+			continue
+		}
+		if metadataURL, ok := index.Versions[version]; ok {
+			return metadataURL, true
+		}
+	}
+	return "", false
+}
+
+func resolveFromGitTags(module *Module, version Version) (RemoteURL, bool) {
+	for _, store := range module.V {
+		for _, metadataURL := range store.Metadatas {
+			if resolved, err := swapGithubRawLinkTag(metadataURL, version); err == nil {
+				return resolved, true
+			}
+		}
+	}
+	return "", false
+}
+
+func swapGithubRawLinkTag(metadataURL RemoteURL, version Version) (RemoteURL, error) {
+	githubPath, err := parseGithubRawLink(metadataURL)
+	if err != nil {
+		return "", err
+	}
+
+	tags, _, err := client.Repositories.ListTags(Context, githubPath.owner, githubPath.repo, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return "", err
+	}
+
+	candidates := []string{"v" + string(version), string(version)}
+	for _, candidate := range candidates {
+		for _, tag := range tags {
+			if tag.GetName() == candidate {
+				return "https://raw.githubusercontent.com/" + path.Join(githubPath.owner, githubPath.repo, url.PathEscape(candidate), githubPath.path, path.Base(metadataURL)), nil
+			}
+		}
+	}
+
+	return "", errors.New("no tag matching version " + string(version))
+}
+
+func InstallModuleRef(ref ModuleRef) error {
+	metadataURL, version, err := ResolveModuleRef(ref)
+	if err != nil {
+		return err
+	}
+
+	metadata, resolved, err := fetchRemoteMetadata(metadataURL)
+	if err != nil {
+		return err
+	}
+	if metadata.Version != string(version) {
+		return errors.New("resolved metadata is for version " + metadata.Version + ", expected " + string(version))
+	}
+
+	return installModuleRemote(metadataURL, resolved, metadata)
+}
@@ -0,0 +1,281 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/archive"
+	"bespoke/fsys"
+	"bespoke/network"
+	"bespoke/trace"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+const releaseSourcePrefix = "gh-release://"
+
+// gh-release://<owner>/<repo>[@<tag>][#<asset>]
+var releaseSourceRe = regexp.MustCompile(`^gh-release://(?<owner>[^/@#]+)/(?<repo>[^/@#]+)(?:@(?<tag>[^#]+))?(?:#(?<asset>.+))?$`)
+
+// defaultReleaseAsset is installed when the source doesn't name an asset
+const defaultReleaseAsset = "module.tar.gz"
+
+// ReleaseSource is a bundle (see Bundle) attached to a GitHub release, the latest one when Tag is empty
+type ReleaseSource struct {
+	Owner string
+	Repo  string
+	Tag   string
+	Asset string
+}
+
+func IsReleaseSource(murl string) bool {
+	return strings.HasPrefix(murl, releaseSourcePrefix)
+}
+
+func ParseReleaseSource(murl string) (ReleaseSource, error) {
+	parts := releaseSourceRe.FindStringSubmatch(murl)
+	if parts == nil {
+		return ReleaseSource{}, errors.New("invalid release source " + murl + ", expected gh-release://owner/repo[@tag][#asset]")
+	}
+	return ReleaseSource{parts[1], parts[2], parts[3], parts[4]}, nil
+}
+
+func (rs ReleaseSource) String() string {
+	murl := releaseSourcePrefix + rs.Owner + "/" + rs.Repo
+	if rs.Tag != "" {
+		murl += "@" + rs.Tag
+	}
+	if rs.Asset != "" {
+		murl += "#" + rs.Asset
+	}
+	return murl
+}
+
+func (rs ReleaseSource) fetchRelease(ctx context.Context) (*github.RepositoryRelease, error) {
+	if rs.Tag == "" {
+		release, _, err := client.Repositories.GetLatestRelease(ctx, rs.Owner, rs.Repo)
+		return release, err
+	}
+	release, _, err := client.Repositories.GetReleaseByTag(ctx, rs.Owner, rs.Repo, rs.Tag)
+	return release, err
+}
+
+// findAsset picks the named asset, else module.tar.gz, else the only tarball of the release
+func findAsset(release *github.RepositoryRelease, name string) (*github.ReleaseAsset, error) {
+	tarballs := []*github.ReleaseAsset{}
+	for i := range release.Assets {
+		asset := &release.Assets[i]
+		if name != "" && asset.GetName() == name {
+			return asset, nil
+		}
+		if name == "" && asset.GetName() == defaultReleaseAsset {
+			return asset, nil
+		}
+		if strings.HasSuffix(asset.GetName(), ".tar.gz") {
+			tarballs = append(tarballs, asset)
+		}
+	}
+	if name != "" {
+		return nil, errors.New("release " + release.GetTagName() + " has no asset named " + name)
+	}
+	if len(tarballs) != 1 {
+		return nil, errors.New("can't tell which asset of release " + release.GetTagName() + " holds the module, name it with #<asset>")
+	}
+	return tarballs[0], nil
+}
+
+func downloadAsset(asset *github.ReleaseAsset) ([]byte, error) {
+	return downloadBytes(asset.GetBrowserDownloadURL())
+}
+
+// downloadBytes downloads a file without caching it, release assets (like the nightly hooks) can be replaced under the same URL
+func downloadBytes(fileURL string) ([]byte, error) {
+	res, err := network.Get(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, network.NewStatusError(res)
+	}
+	return io.ReadAll(trackDownload(res.Body, StoreIdentifier{}, fileURL, res.ContentLength))
+}
+
+// findChecksum reads the checksum of an asset from its .sha256 sidecar (written by `dev bundle`)
+// or a SHA256SUMS file attached to the release
+func findChecksum(release *github.RepositoryRelease, name string) (string, error) {
+	for _, sums := range []string{name + ".sha256", checksumsFile} {
+		asset, err := findAsset(release, sums)
+		if err != nil {
+			continue
+		}
+		raw, err := downloadAsset(asset)
+		if err != nil {
+			return "", err
+		}
+		if sum, ok := parseChecksums(raw)[name]; ok {
+			return sum, nil
+		}
+	}
+	return "", errors.New("release " + release.GetTagName() + " has no checksum for " + name + ", attach " + name + ".sha256 or " + checksumsFile)
+}
+
+// parseChecksums reads the "<sha256>  <file>" lines of sha256sum
+func parseChecksums(raw []byte) map[string]string {
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	for scanner.Scan() {
+		sum, file, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if ok {
+			sums[strings.TrimPrefix(strings.TrimSpace(file), "*")] = strings.ToLower(sum)
+		}
+	}
+	return sums
+}
+
+// verifyBundle checks the files of an extracted bundle against the checksums it ships
+func verifyBundle(moduleDir string) error {
+	raw, err := fsys.ReadFile(filepath.Join(moduleDir, checksumsFile))
+	if err != nil {
+		return errors.New("the bundle has no " + checksumsFile)
+	}
+	sums := parseChecksums(raw)
+	// Files the checksums don't cover could have been added to the bundle after it was written
+	err = fsys.WalkDir(moduleDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(moduleDir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if _, ok := sums[rel]; !ok && rel != checksumsFile {
+			return errors.New(rel + " isn't listed in the " + checksumsFile + " of the bundle")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for file, sum := range sums {
+		if slices.Contains(strings.Split(file, "/"), "..") {
+			return errors.New(checksumsFile + " of the bundle lists " + file + ", which is outside of the bundle")
+		}
+		hash, err := hashFile(filepath.Join(moduleDir, filepath.FromSlash(file)))
+		if err != nil {
+			return err
+		}
+		if hash != sum {
+			return errors.New(file + " doesn't match the checksum of the bundle")
+		}
+	}
+	return fsys.Remove(filepath.Join(moduleDir, checksumsFile))
+}
+
+// fetchModule downloads and checks the asset, then extracts it into a temporary folder, which the caller must remove.
+// resolved names the tag and asset that were picked
+func (rs ReleaseSource) fetchModule() (tmp string, resolved ReleaseSource, metadata Metadata, err error) {
+	release, err := rs.fetchRelease(Context)
+	if err != nil {
+		return "", rs, Metadata{}, err
+	}
+	asset, err := findAsset(release, rs.Asset)
+	if err != nil {
+		return "", rs, Metadata{}, err
+	}
+	resolved = ReleaseSource{rs.Owner, rs.Repo, release.GetTagName(), asset.GetName()}
+
+	sum, err := findChecksum(release, asset.GetName())
+	if err != nil {
+		return "", resolved, Metadata{}, err
+	}
+	span := trace.Start("download", asset.GetBrowserDownloadURL())
+	raw, err := downloadAsset(asset)
+	span.Finish()
+	if err != nil {
+		return "", resolved, Metadata{}, err
+	}
+	h := sha256.Sum256(raw)
+	if hex.EncodeToString(h[:]) != sum {
+		return "", resolved, Metadata{}, errors.New(asset.GetName() + " doesn't match its checksum")
+	}
+
+	body, cleanup, err := scanArchive(bytes.NewReader(raw), asset.GetBrowserDownloadURL())
+	if err != nil {
+		return "", resolved, Metadata{}, err
+	}
+	defer cleanup()
+
+	tmp, err = fsys.MkdirTemp(os.TempDir(), "bespoke-release-")
+	if err != nil {
+		return "", resolved, Metadata{}, err
+	}
+	filter := archive.Filter{Extracted: trackExtraction(StoreIdentifier{}, asset.GetBrowserDownloadURL())}
+	span = trace.Start("extract", asset.GetName())
+	err = archive.UnTarGZ(body, regexp.MustCompile(`^(?:\./)?(.+)$`), tmp, filter)
+	span.Finish()
+	if err != nil {
+		return tmp, resolved, Metadata{}, err
+	}
+	if err := verifyBundle(tmp); err != nil {
+		return tmp, resolved, Metadata{}, err
+	}
+	metadata, err = fetchLocalMetadata(filepath.Join(tmp, "metadata.json"))
+	return tmp, resolved, metadata, err
+}
+
+// InstallModuleRelease installs a bundle attached to a GitHub release, the recorded source names
+// the tag and asset that were installed
+func InstallModuleRelease(murl string) error {
+	source, err := ParseReleaseSource(murl)
+	if err != nil {
+		return err
+	}
+	if err := ActivePolicy.CheckSource(murl); err != nil {
+		return err
+	}
+	if err := checkSourceTrust(murl); err != nil {
+		return err
+	}
+
+	tmp, resolved, metadata, err := source.fetchModule()
+	if tmp != "" {
+		defer fsys.RemoveAll(tmp)
+	}
+	if err != nil {
+		return err
+	}
+
+	return installModuleDir(resolved.String(), tmp, metadata, "")
+}
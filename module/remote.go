@@ -0,0 +1,206 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/network"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// RemoteWorkers bounds the modules CheckRemote and CheckUpgrades check at once (see network.MaxRequests for the requests)
+	RemoteWorkers = 16
+	// RemoteTimeout bounds each check of CheckRemote, so that it completes in about the same time whatever the number of modules
+	RemoteTimeout = 10 * time.Second
+)
+
+type SourceHealth struct {
+	URL       RemoteURL `json:"url"`
+	Reachable bool      `json:"reachable"`
+	Error     string    `json:"error,omitempty"`
+	// Missing is set when the source answered that it doesn't exist, see SourceMissingError
+	Missing bool `json:"missing,omitempty"`
+}
+
+// withTimeout gives up waiting for f after RemoteTimeout, f keeps running in the background until it returns
+func withTimeout[T any](f func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := f()
+		done <- result{value, err}
+	}()
+
+	timer := time.NewTimer(RemoteTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-timer.C:
+		var zero T
+		return zero, errors.New("timed out after " + RemoteTimeout.String())
+	}
+}
+
+// checkSource sends a single request (HEAD, else GET for servers refusing it) to the metadata URL of an installed version,
+// git sources are checked with git ls-remote and release sources by looking their release up
+func checkSource(metadataURL RemoteURL) error {
+	ctx, cancel := context.WithTimeout(Context, RemoteTimeout)
+	defer cancel()
+
+	if IsGitSource(metadataURL) {
+		source, err := ParseGitSource(metadataURL)
+		if err != nil {
+			return err
+		}
+		cmd := exec.CommandContext(ctx, "git", "ls-remote", "--exit-code", "--", source.Repo, "HEAD")
+		cmd.Env = network.GitEnv()
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		err = cmd.Run()
+		if err != nil && strings.Contains(strings.ToLower(stderr.String()), "not found") {
+			return &SourceMissingError{metadataURL, strings.TrimSpace(stderr.String())}
+		}
+		return err
+	}
+	if IsReleaseSource(metadataURL) {
+		source, err := ParseReleaseSource(metadataURL)
+		if err != nil {
+			return err
+		}
+		_, err = source.fetchRelease(ctx)
+		if isGone(err) {
+			return &SourceMissingError{metadataURL, err.Error()}
+		}
+		return err
+	}
+
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequestWithContext(ctx, method, metadataURL, nil)
+		if err != nil {
+			return err
+		}
+		// Retries would make the slowest sources take several times the timeout
+		res, err := network.Client.Do(req)
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if res.StatusCode == http.StatusMethodNotAllowed || res.StatusCode == http.StatusNotImplemented {
+			continue
+		}
+		if goneStatus(res.StatusCode, res.Request.URL.String()) {
+			return &SourceMissingError{metadataURL, "unexpected status " + res.Status}
+		}
+		if res.StatusCode >= 300 {
+			return errors.New("unexpected status " + res.Status)
+		}
+		return nil
+	}
+	return errors.New("the server refuses HEAD and GET requests")
+}
+
+// CheckRemote fills the latest available version of the modules and the health of the sources of the versions
+// listed by ModuleStatuses, the checks run concurrently. It only reads the vault: the daemon runs it for its
+// metrics, the missing sources are recorded by CheckUpgrades (pkg outdated and upgrade)
+func CheckRemote(statuses []ModuleStatus) error {
+	vault, err := GetVault()
+	if err != nil {
+		return err
+	}
+
+	type latest struct {
+		version Version
+		err     error
+	}
+	latests := map[ModuleIdentifier]*latest{}
+	jobs := []func(){}
+	for i := range statuses {
+		status := &statuses[i]
+		identifier := status.Identifier
+		module := vault.Modules[identifier.ModuleIdentifier.toPath()]
+
+		if _, ok := latests[identifier.ModuleIdentifier]; !ok {
+			l := &latest{}
+			latests[identifier.ModuleIdentifier] = l
+			jobs = append(jobs, func() {
+				l.version, l.err = withTimeout(func() (Version, error) {
+					version, _, err := latestVersion(identifier.ModuleIdentifier, &module)
+					return version, err
+				})
+			})
+		}
+
+		// Local installs have no source to check
+		if metadatas := module.V[identifier.Version].Metadatas; len(metadatas) > 0 {
+			status.Source = &SourceHealth{URL: metadatas[0]}
+			jobs = append(jobs, func() {
+				if err := checkSource(status.Source.URL); err != nil {
+					var missing *SourceMissingError
+					status.Source.Error = err.Error()
+					status.Source.Missing = errors.As(err, &missing)
+					return
+				}
+				status.Source.Reachable = true
+			})
+		}
+	}
+
+	runJobs(jobs, RemoteWorkers)
+
+	for i := range statuses {
+		l := latests[statuses[i].Identifier.ModuleIdentifier]
+		statuses[i].Latest = l.version
+		if l.err != nil {
+			statuses[i].LatestError = l.err.Error()
+		}
+	}
+	return nil
+}
+
+// runJobs runs the jobs on a pool of workers
+func runJobs(jobs []func(), workers int) {
+	queue := make(chan func())
+	var wg sync.WaitGroup
+	for range min(workers, len(jobs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range queue {
+				job()
+			}
+		}()
+	}
+	for _, job := range jobs {
+		queue <- job
+	}
+	close(queue)
+	wg.Wait()
+}
@@ -0,0 +1,163 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/archive"
+	"errors"
+	"log"
+	"slices"
+)
+
+// renamedFrom reports whether the module was published as identifier before its repository was renamed
+// or handed over
+func (m *Metadata) renamedFrom(identifier ModuleIdentifier) bool {
+	return slices.Contains(m.RenamedFrom, identifier.String())
+}
+
+// renameModule installs the upgrade under the module's new identifier, which takes over the load order
+// position, remotes and enabled state of the old one before the old versions are removed
+func renameModule(upgrade Upgrade, resolved RemoteURL, metadata Metadata) (StoreIdentifier, error) {
+	to := metadata.getStoreIdentifier()
+	vault, err := GetVault()
+	if err != nil {
+		return to, err
+	}
+	previous := *vault.getModule(upgrade.Module.toPath())
+
+	if _, ok := vault.getModule(to.ModuleIdentifier.toPath()).V[to.Version]; !ok {
+		from := StoreIdentifier{upgrade.Module, upgrade.From}
+		download := func(metadataURL RemoteURL, to StoreIdentifier, dest string, filter archive.Filter) error {
+			return upgradeModuleInStore(metadataURL, from, to, dest, filter)
+		}
+		if err := installModuleRemoteWith(upgrade.MetadataURL, resolved, metadata, download); err != nil {
+			return to, err
+		}
+	}
+
+	err = MutateVault(func(vault *Vault) bool {
+		module := vault.getModule(to.ModuleIdentifier.toPath())
+		module.Priority = previous.Priority
+		module.Scheme = previous.Scheme
+		if len(module.Remotes) == 0 {
+			module.Remotes = previous.Remotes
+		}
+		if store, ok := module.V[to.Version]; ok {
+			store.Explicit = previous.V[upgrade.From].Explicit
+			module.V[to.Version] = store
+		}
+		vault.setModule(to.ModuleIdentifier.toPath(), module)
+		return true
+	})
+	if err != nil {
+		return to, err
+	}
+
+	// The old module is disabled first as it usually provides the same capabilities
+	if previous.Enabled != "" {
+		if err := ToggleModuleInVault(StoreIdentifier{upgrade.Module, ""}); err != nil {
+			return to, err
+		}
+		if err := ToggleModuleInVault(to); err != nil {
+			return to, err
+		}
+	}
+
+	// The module stays installed under its new name, so its preRemove script isn't run
+	for version := range previous.V {
+		identifier := StoreIdentifier{upgrade.Module, version}
+		if err := RemoveModuleInVault(identifier); err != nil {
+			return to, err
+		}
+		if err := trashModuleInStore(identifier); err != nil {
+			return to, err
+		}
+	}
+	err = MutateVault(func(vault *Vault) bool {
+		delete(vault.Modules, upgrade.Module.toPath())
+		return true
+	})
+	if err != nil {
+		return to, err
+	}
+
+	log.Println(upgrade.Module, "was renamed to", to.ModuleIdentifier)
+	return to, nil
+}
+
+// revertRename restores the versions of the module from before it was renamed to identifier
+func revertRename(identifier StoreIdentifier, snapshot *Vault) error {
+	metadata, err := readStoreMetadata(identifier)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(metadata.RenamedFrom, func(old string) bool {
+		_, ok := snapshot.Modules[ModuleIdentifierStr(old)]
+		return ok
+	})
+	if i < 0 {
+		return errors.New("the previous name of " + identifier.ModuleIdentifier.String() + " is missing from the snapshot")
+	}
+	old := NewModuleIdentifier(metadata.RenamedFrom[i])
+	previous := snapshot.Modules[old.toPath()]
+
+	renamed := snapshot.Modules[identifier.ModuleIdentifier.toPath()]
+	if err := ToggleModuleInVault(StoreIdentifier{identifier.ModuleIdentifier, renamed.Enabled}); err != nil {
+		return err
+	}
+	if _, ok := renamed.V[identifier.Version]; !ok {
+		if err := DeleteModule(identifier); err != nil {
+			return err
+		}
+	}
+
+	for version, store := range previous.V {
+		identifier := StoreIdentifier{old, version}
+		if err := restoreModuleInStore(identifier); err != nil {
+			return err
+		}
+		err := MutateVault(func(vault *Vault) bool {
+			return vault.setStore(identifier, &store)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	err = MutateVault(func(vault *Vault) bool {
+		module := vault.getModule(old.toPath())
+		module.Priority = previous.Priority
+		module.Remotes = previous.Remotes
+		module.Scheme = previous.Scheme
+		vault.setModule(old.toPath(), module)
+		if _, ok := snapshot.Modules[identifier.ModuleIdentifier.toPath()]; !ok {
+			delete(vault.Modules, identifier.ModuleIdentifier.toPath())
+		} else if module, ok := vault.Modules[identifier.ModuleIdentifier.toPath()]; ok {
+			module.Priority = renamed.Priority
+			module.Remotes = renamed.Remotes
+			module.Scheme = renamed.Scheme
+			vault.Modules[identifier.ModuleIdentifier.toPath()] = module
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return ToggleModuleInVault(StoreIdentifier{old, previous.Enabled})
+}
@@ -0,0 +1,199 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"bespoke/link"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RepairVault reconciles vault.json with the store and modules folders
+// and returns a description of every change (applied unless dryRun)
+func RepairVault(dryRun bool) ([]string, error) {
+	changes := []string{}
+
+	unlock, err := lockVault()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	before := snapshotVault()
+	vault, err := GetVault()
+	if err != nil && !os.IsNotExist(err) {
+		changes = append(changes, "! vault.json is corrupted, rebuilding it from scratch")
+		vault = &Vault{}
+	}
+	if vault.Modules == nil {
+		vault.Modules = map[ModuleIdentifierStr]Module{}
+	}
+
+	storeDirs, err := fsys.Glob(filepath.Join(storeFolder, "*", "*", "*"))
+	if err != nil {
+		return changes, err
+	}
+	for _, storeDir := range storeDirs {
+		// Compressed versions are found by their tarball, interrupted (de)compressions are ignored
+		if strings.HasSuffix(storeDir, ".partial") {
+			continue
+		}
+		storeDir = strings.TrimSuffix(storeDir, ".tar.gz")
+		identifier := storeIdentifierFromFilePath(storeDir)
+		if _, err := readStoreMetadata(identifier); err != nil {
+			continue
+		}
+		if isInstalling(identifier) {
+			continue
+		}
+		module := vault.getModule(identifier.ModuleIdentifier.toPath())
+		if _, ok := module.V[identifier.Version]; !ok {
+			changes = append(changes, "+ "+identifier.toPath()+" (found in store)")
+			module.V[identifier.Version] = Store{Installed: true, Metadatas: []RemoteURL{}, Explicit: true}
+			vault.setModule(identifier.ModuleIdentifier.toPath(), module)
+		}
+	}
+
+	for moduleIdentifierStr, module := range vault.Modules {
+		moduleIdentifier := NewModuleIdentifier(string(moduleIdentifierStr))
+		for version := range module.V {
+			identifier := StoreIdentifier{moduleIdentifier, version}
+			if _, err := fsys.Stat(identifier.toFilePath()); err != nil && !isCompressed(identifier) {
+				changes = append(changes, "- "+identifier.toPath()+" (missing from store)")
+				delete(module.V, version)
+			}
+		}
+		if _, ok := module.V[module.Enabled]; len(module.Enabled) > 0 && !ok {
+			changes = append(changes, "- "+string(moduleIdentifierStr)+" enabled version "+string(module.Enabled)+" (not installed)")
+			module.Enabled = ""
+		}
+		if len(module.V) == 0 && len(module.Remotes) == 0 {
+			changes = append(changes, "- "+string(moduleIdentifierStr)+" (empty)")
+			delete(vault.Modules, moduleIdentifierStr)
+			continue
+		}
+		vault.Modules[moduleIdentifierStr] = module
+	}
+
+	// Staged modules get fresh links from the generation published by SetVault
+	linkChanges, err := repairSymlinks(vault, dryRun || stagedModules())
+	changes = append(changes, linkChanges...)
+	if err != nil {
+		return changes, err
+	}
+
+	if dryRun || len(changes) == 0 {
+		return changes, nil
+	}
+	if err := SetVault(vault); err != nil {
+		return changes, err
+	}
+	return changes, record(OpRepair, "vault", before)
+}
+
+func repairSymlinks(vault *Vault, dryRun bool) ([]string, error) {
+	changes := []string{}
+
+	entries, err := fsys.Glob(filepath.Join(modulesFolder, "*", "*"))
+	if err != nil {
+		return changes, err
+	}
+	seen := map[ModuleIdentifierStr]bool{}
+	for _, entry := range entries {
+		fi, err := fsys.Lstat(entry)
+		if err != nil || (!fi.IsDir() && fi.Mode()&fs.ModeSymlink == 0) {
+			continue
+		}
+
+		moduleIdentifier := moduleIdentifierFromFilePath(entry)
+		moduleIdentifierStr := moduleIdentifier.toPath()
+		seen[moduleIdentifierStr] = true
+
+		module, ok := vault.Modules[moduleIdentifierStr]
+		if !ok || len(module.Enabled) == 0 {
+			changes = append(changes, "- link "+string(moduleIdentifierStr)+" (not enabled)")
+			if !dryRun {
+				if err := link.Remove(entry); err != nil {
+					return changes, err
+				}
+			}
+			continue
+		}
+
+		identifier := StoreIdentifier{moduleIdentifier, module.Enabled}
+		if isLinkHealthy(entry, identifier.toFilePath()) {
+			continue
+		}
+
+		changes = append(changes, "~ link "+string(moduleIdentifierStr)+" -> "+identifier.toPath())
+		if !dryRun {
+			link.Remove(entry)
+			if err := createSymlink(identifier); err != nil {
+				return changes, err
+			}
+		}
+	}
+
+	for moduleIdentifierStr, module := range vault.Modules {
+		if seen[moduleIdentifierStr] || len(module.Enabled) == 0 {
+			continue
+		}
+		identifier := StoreIdentifier{NewModuleIdentifier(string(moduleIdentifierStr)), module.Enabled}
+		changes = append(changes, "+ link "+string(moduleIdentifierStr)+" -> "+identifier.toPath())
+		if !dryRun {
+			if err := createSymlink(identifier); err != nil {
+				return changes, err
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+func isLinkHealthy(name string, target string) bool {
+	if _, err := fsys.Stat(name); err != nil {
+		return false
+	}
+	fi, err := fsys.Lstat(name)
+	if err != nil {
+		return false
+	}
+	if fi.Mode()&fs.ModeSymlink == 0 {
+		return link.Mode == link.Copy
+	}
+	dest, err := fsys.Readlink(name)
+	return err == nil && filepath.Clean(dest) == filepath.Clean(target)
+}
+
+func moduleIdentifierFromFilePath(p string) ModuleIdentifier {
+	return ModuleIdentifier{
+		Author: Author(filepath.Base(filepath.Dir(p))),
+		Name:   Name(filepath.Base(p)),
+	}
+}
+
+func storeIdentifierFromFilePath(p string) StoreIdentifier {
+	return StoreIdentifier{
+		ModuleIdentifier: moduleIdentifierFromFilePath(filepath.Dir(p)),
+		Version:          Version(filepath.Base(p)),
+	}
+}
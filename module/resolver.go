@@ -0,0 +1,173 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/trace"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path"
+
+	"github.com/google/go-github/github"
+)
+
+// Resolver maps a module reference to the metadata URL of one of its versions,
+// Resolve returns ErrUnresolved to let the next resolver try
+type Resolver struct {
+	Name    string
+	Resolve func(ref ModuleRef) (RemoteURL, Version, error)
+}
+
+var ErrUnresolved = errors.New("unresolved")
+
+// Resolvers are tried in order by ResolveModuleRef
+var Resolvers = []Resolver{
+	{"overrides", resolveFromOverrides},
+	{"registries", resolveFromRegistries},
+	{"remotes", resolveFromInstalled},
+	{"github", resolveFromGithub},
+}
+
+func loadOverrides() (map[string]RemoteURL, error) {
+	raw, err := os.ReadFile(OverridesPath)
+	if err != nil {
+		return nil, err
+	}
+	var overrides map[string]RemoteURL
+	err = json.Unmarshal(raw, &overrides)
+	return overrides, err
+}
+
+func resolveFromOverrides(ref ModuleRef) (RemoteURL, Version, error) {
+	if ref.Registry != "" {
+		return "", "", ErrUnresolved
+	}
+	overrides, err := loadOverrides()
+	if os.IsNotExist(err) {
+		return "", "", ErrUnresolved
+	} else if err != nil {
+		return "", "", errors.New("invalid overrides file " + OverridesPath + ": " + err.Error())
+	}
+
+	if ref.Version != "" {
+		if metadataURL, ok := overrides[ref.ModuleIdentifier.String()+"@"+string(ref.Version)]; ok {
+			return metadataURL, ref.Version, nil
+		}
+	}
+	metadataURL, ok := overrides[ref.ModuleIdentifier.String()]
+	if !ok {
+		return "", "", ErrUnresolved
+	}
+	return resolveVersionAt(metadataURL, ref.Version)
+}
+
+// resolveVersionAt reads the version published at metadataURL,
+// or looks for a git tag of the requested version next to it
+func resolveVersionAt(metadataURL RemoteURL, version Version) (RemoteURL, Version, error) {
+	metadata, resolved, err := fetchRemoteMetadata(metadataURL)
+	if err != nil {
+		return "", "", err
+	}
+	if version == "" || Version(metadata.Version) == version {
+		return metadataURL, Version(metadata.Version), nil
+	}
+	tagged, err := swapGithubRawLinkTag(resolved, version)
+	if err != nil {
+		return "", "", err
+	}
+	return tagged, version, nil
+}
+
+// resolveFromInstalled looks for explicit versions of already known modules in their remotes and git tags
+func resolveFromInstalled(ref ModuleRef) (RemoteURL, Version, error) {
+	if ref.Registry != "" || ref.Version == "" {
+		return "", "", ErrUnresolved
+	}
+
+	vault, err := GetVault()
+	if err != nil {
+		return "", "", ErrUnresolved
+	}
+	module := vault.getModule(ref.ModuleIdentifier.toPath())
+
+	if metadataURL, ok := resolveFromRemotes(module, ref.Version); ok {
+		return metadataURL, ref.Version, nil
+	}
+	if metadataURL, ok := resolveFromGitTags(module, ref.Version); ok {
+		return metadataURL, ref.Version, nil
+	}
+	return "", "", ErrUnresolved
+}
+
+// resolveFromGithub looks for the metadata of author/name in the GitHub repository of the same name,
+// then in the repositories of author matching name
+func resolveFromGithub(ref ModuleRef) (RemoteURL, Version, error) {
+	if ref.Registry != "" {
+		return "", "", ErrUnresolved
+	}
+
+	ctx := Context
+	author, name := string(ref.Author), string(ref.Name)
+	repos := []*github.Repository{}
+	if repo, _, err := client.Repositories.Get(ctx, author, name); err == nil {
+		repos = append(repos, repo)
+	}
+	query := name + " in:name user:" + author
+	if result, _, err := client.Search.Repositories(ctx, query, &github.SearchOptions{ListOptions: github.ListOptions{PerPage: 5}}); err == nil {
+		for i := range result.Repositories {
+			repos = append(repos, &result.Repositories[i])
+		}
+	}
+
+	for _, repo := range repos {
+		for _, dir := range []string{"", name} {
+			metadataURL := "https://raw.githubusercontent.com/" + path.Join(repo.GetOwner().GetLogin(), repo.GetName(), repo.GetDefaultBranch(), dir, "metadata.json")
+			metadata, _, err := fetchRemoteMetadata(metadataURL)
+			if err != nil || len(metadata.Authors) == 0 || metadata.getModuleIdentifier() != ref.ModuleIdentifier {
+				continue
+			}
+			log.Println("Found", ref.ModuleIdentifier.String(), "on GitHub at", metadataURL)
+			return resolveVersionAt(metadataURL, ref.Version)
+		}
+	}
+	return "", "", ErrUnresolved
+}
+
+// ResolveModuleRef finds the metadata URL of a module through the resolvers
+func ResolveModuleRef(ref ModuleRef) (RemoteURL, Version, error) {
+	defer trace.Start("resolve", ref.toPath()).Finish()
+	for _, resolver := range Resolvers {
+		metadataURL, version, err := resolver.Resolve(ref)
+		if errors.Is(err, ErrUnresolved) {
+			continue
+		}
+		if err != nil {
+			return "", "", errors.New(resolver.Name + ": " + err.Error())
+		}
+		return metadataURL, version, nil
+	}
+
+	if ref.Registry != "" {
+		return "", "", errors.New("can't find " + ref.ModuleIdentifier.String() + " in registry " + ref.Registry)
+	}
+	return "", "", errors.New("can't resolve " + ref.toPath() + " through the overrides, registries, module remotes or GitHub")
+}
@@ -0,0 +1,319 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	SbomCycloneDX = "cyclonedx"
+	SbomSPDX      = "spdx"
+)
+
+// SbomComponent describes an installed version in a software bill of materials
+type SbomComponent struct {
+	Identifier  StoreIdentifier
+	Description string
+	License     string
+	// Source is the metadata URL the version was installed from, empty for local installs
+	Source RemoteURL
+	Commit string
+	// Hash is the sha256 of the files of the version, see Manifest.digest
+	Hash    string
+	Enabled bool
+	// Dependencies are the installed versions satisfying the dependencies the version declares
+	Dependencies []StoreIdentifier
+}
+
+func (c *SbomComponent) ref() string {
+	return c.Identifier.ModuleIdentifier.String() + "@" + string(c.Identifier.Version)
+}
+
+// digest hashes the sorted "<hash>  <path>" lines of the manifest, like sha256sum's output
+func (m Manifest) digest() string {
+	lines := make([]string, 0, len(m))
+	for path, hash := range m {
+		lines = append(lines, hash+"  "+path+"\n")
+	}
+	slices.Sort(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "")))
+	return hex.EncodeToString(sum[:])
+}
+
+// SbomComponents lists every installed version, hashing the files recorded at install time
+// (the current files for local installs, which aren't tracked)
+func SbomComponents() ([]SbomComponent, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return nil, err
+	}
+
+	components := []SbomComponent{}
+	for _, identifierStr := range vault.OrderedModules() {
+		module := vault.Modules[identifierStr]
+		for _, v := range module.versions() {
+			identifier := StoreIdentifier{NewModuleIdentifier(string(identifierStr)), v}
+			store := module.V[v]
+			component := SbomComponent{Identifier: identifier, Commit: store.Commit, Enabled: module.Enabled == v, Dependencies: []StoreIdentifier{}}
+			if len(store.Metadatas) > 0 {
+				component.Source = store.Metadatas[0]
+			}
+			if metadata, err := readStoreMetadata(identifier); err == nil {
+				component.Description = metadata.Description
+				component.License = metadata.License
+			}
+
+			manifest, err := readManifest(identifier)
+			if err != nil {
+				manifest, err = hashStore(identifier)
+			}
+			if err == nil {
+				component.Hash = manifest.digest()
+			}
+
+			for dependency, constraint := range dependenciesOf(identifier) {
+				if installed, ok := vault.Modules[dependency]; ok {
+					if resolved := installed.resolveDependency(constraint); resolved != "" {
+						component.Dependencies = append(component.Dependencies, StoreIdentifier{NewModuleIdentifier(string(dependency)), resolved})
+					}
+				}
+			}
+			slices.SortFunc(component.Dependencies, func(a, b StoreIdentifier) int {
+				return strings.Compare(a.toPath(), b.toPath())
+			})
+			components = append(components, component)
+		}
+	}
+	return components, nil
+}
+
+// BuildSbom formats the components as a CycloneDX 1.5 or SPDX 2.3 JSON document, tool being the version of bespoke
+func BuildSbom(format string, components []SbomComponent, tool string) (any, error) {
+	switch format {
+	case SbomCycloneDX:
+		return cycloneDX(components, tool), nil
+	case SbomSPDX:
+		return spdx(components, tool), nil
+	}
+	return nil, errors.New("unknown SBOM format " + format + ", expected " + SbomCycloneDX + " or " + SbomSPDX)
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+type cdxHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type cdxLicense struct {
+	License struct {
+		Name string `json:"name"`
+	} `json:"license"`
+}
+
+type cdxReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cdxComponent struct {
+	Type               string         `json:"type"`
+	BomRef             string         `json:"bom-ref,omitempty"`
+	Group              string         `json:"group"`
+	Name               string         `json:"name"`
+	Version            string         `json:"version"`
+	Description        string         `json:"description,omitempty"`
+	Licenses           []cdxLicense   `json:"licenses,omitempty"`
+	Hashes             []cdxHash      `json:"hashes,omitempty"`
+	ExternalReferences []cdxReference `json:"externalReferences,omitempty"`
+	Properties         []cdxProperty  `json:"properties,omitempty"`
+}
+
+type cdxDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn"`
+}
+
+type cdxDocument struct {
+	BomFormat    string `json:"bomFormat"`
+	SpecVersion  string `json:"specVersion"`
+	SerialNumber string `json:"serialNumber"`
+	Version      int    `json:"version"`
+	Metadata     struct {
+		Timestamp string `json:"timestamp"`
+		Tools     struct {
+			Components []cdxComponent `json:"components"`
+		} `json:"tools"`
+	} `json:"metadata"`
+	Components   []cdxComponent  `json:"components"`
+	Dependencies []cdxDependency `json:"dependencies"`
+}
+
+func cycloneDX(components []SbomComponent, tool string) cdxDocument {
+	doc := cdxDocument{BomFormat: "CycloneDX", SpecVersion: "1.5", SerialNumber: "urn:uuid:" + newUUID(), Version: 1}
+	doc.Metadata.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	doc.Metadata.Tools.Components = []cdxComponent{{Type: "application", Group: "bespoke", Name: "bespoke", Version: tool}}
+	doc.Components = []cdxComponent{}
+	doc.Dependencies = []cdxDependency{}
+
+	for _, c := range components {
+		component := cdxComponent{
+			Type:        "library",
+			BomRef:      c.ref(),
+			Group:       string(c.Identifier.Author),
+			Name:        string(c.Identifier.Name),
+			Version:     string(c.Identifier.Version),
+			Description: c.Description,
+			Properties:  []cdxProperty{{"bespoke:enabled", fmt.Sprint(c.Enabled)}},
+		}
+		if c.License != "" {
+			license := cdxLicense{}
+			license.License.Name = c.License
+			component.Licenses = []cdxLicense{license}
+		}
+		if c.Hash != "" {
+			component.Hashes = []cdxHash{{"SHA-256", c.Hash}}
+		}
+		if c.Source != "" {
+			component.ExternalReferences = []cdxReference{{"distribution", c.Source}}
+		}
+		if c.Commit != "" {
+			component.Properties = append(component.Properties, cdxProperty{"bespoke:commit", c.Commit})
+		}
+		doc.Components = append(doc.Components, component)
+
+		dependency := cdxDependency{Ref: c.ref(), DependsOn: []string{}}
+		for _, d := range c.Dependencies {
+			dependency.DependsOn = append(dependency.DependsOn, d.ModuleIdentifier.String()+"@"+string(d.Version))
+		}
+		doc.Dependencies = append(doc.Dependencies, dependency)
+	}
+	return doc
+}
+
+type spdxChecksum struct {
+	Algorithm     string `json:"algorithm"`
+	ChecksumValue string `json:"checksumValue"`
+}
+
+type spdxPackage struct {
+	Name             string         `json:"name"`
+	SPDXID           string         `json:"SPDXID"`
+	VersionInfo      string         `json:"versionInfo"`
+	Supplier         string         `json:"supplier"`
+	DownloadLocation string         `json:"downloadLocation"`
+	FilesAnalyzed    bool           `json:"filesAnalyzed"`
+	Checksums        []spdxChecksum `json:"checksums,omitempty"`
+	LicenseConcluded string         `json:"licenseConcluded"`
+	LicenseDeclared  string         `json:"licenseDeclared"`
+	CopyrightText    string         `json:"copyrightText"`
+	Description      string         `json:"description,omitempty"`
+	SourceInfo       string         `json:"sourceInfo,omitempty"`
+}
+
+type spdxRelationship struct {
+	SpdxElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSpdxElement string `json:"relatedSpdxElement"`
+}
+
+type spdxDocument struct {
+	SpdxVersion       string `json:"spdxVersion"`
+	DataLicense       string `json:"dataLicense"`
+	SPDXID            string `json:"SPDXID"`
+	Name              string `json:"name"`
+	DocumentNamespace string `json:"documentNamespace"`
+	CreationInfo      struct {
+		Created  string   `json:"created"`
+		Creators []string `json:"creators"`
+	} `json:"creationInfo"`
+	Packages      []spdxPackage      `json:"packages"`
+	Relationships []spdxRelationship `json:"relationships"`
+}
+
+var spdxIDRe = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// spdxLicenseRe matches the characters of SPDX license expressions, other license fields are left unasserted
+var spdxLicenseRe = regexp.MustCompile(`^[A-Za-z0-9.+:() -]+$`)
+
+func spdxID(identifier StoreIdentifier) string {
+	return "SPDXRef-Package-" + spdxIDRe.ReplaceAllString(identifier.toPath(), "-")
+}
+
+func spdx(components []SbomComponent, tool string) spdxDocument {
+	doc := spdxDocument{SpdxVersion: "SPDX-2.3", DataLicense: "CC0-1.0", SPDXID: "SPDXRef-DOCUMENT", Name: "bespoke-modules"}
+	doc.DocumentNamespace = "https://spdx.org/spdxdocs/bespoke-modules-" + newUUID()
+	doc.CreationInfo.Created = time.Now().UTC().Format(time.RFC3339)
+	doc.CreationInfo.Creators = []string{"Tool: bespoke-" + tool}
+	doc.Packages = []spdxPackage{}
+	doc.Relationships = []spdxRelationship{}
+
+	for _, c := range components {
+		pkg := spdxPackage{
+			Name:             c.Identifier.ModuleIdentifier.String(),
+			SPDXID:           spdxID(c.Identifier),
+			VersionInfo:      string(c.Identifier.Version),
+			Supplier:         "Person: " + string(c.Identifier.Author),
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			CopyrightText:    "NOASSERTION",
+			Description:      c.Description,
+		}
+		if c.Source != "" {
+			pkg.DownloadLocation = c.Source
+		}
+		if spdxLicenseRe.MatchString(c.License) {
+			pkg.LicenseDeclared = c.License
+		}
+		if c.Hash != "" {
+			pkg.Checksums = []spdxChecksum{{"SHA256", c.Hash}}
+		}
+		if c.Commit != "" {
+			pkg.SourceInfo = "built from commit " + c.Commit
+		}
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{doc.SPDXID, "DESCRIBES", pkg.SPDXID})
+		for _, d := range c.Dependencies {
+			doc.Relationships = append(doc.Relationships, spdxRelationship{pkg.SPDXID, "DEPENDS_ON", spdxID(d)})
+		}
+	}
+	return doc
+}
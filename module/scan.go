@@ -0,0 +1,114 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/trace"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ScanCommand is run with the path of every downloaded archive (or cloned folder) before it is extracted into
+// the store, e.g. a virus scanner or a size check. A non-zero exit aborts the install
+var ScanCommand string
+var ScanTimeout = 5 * time.Minute
+
+// activeScanCommand prefers the scanner set by the policy, which users can't opt out of
+func activeScanCommand() string {
+	if ActivePolicy.ScanCommand != "" {
+		return ActivePolicy.ScanCommand
+	}
+	return ScanCommand
+}
+
+func quoteArg(arg string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + arg + `"`
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// scanPath runs ScanCommand on a downloaded archive or folder, source names what it was downloaded from
+func scanPath(path string, source string) error {
+	command := activeScanCommand()
+	if command == "" {
+		return nil
+	}
+
+	defer trace.Start("scan", source).Finish()
+	ctx, cancel := context.WithTimeout(Context, ScanTimeout)
+	defer cancel()
+
+	log.Println("Scanning", source)
+	notify(StepScan, StoreIdentifier{}, source)
+	cmd := scriptCommand(ctx, command+" "+quoteArg(path))
+	cmd.Env = append(os.Environ(), "BESPOKE_ARCHIVE="+path, "BESPOKE_SOURCE="+source)
+	cmd.WaitDelay = 5 * time.Second
+	output, err := cmd.CombinedOutput()
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return errors.New("the scan of " + source + " timed out after " + ScanTimeout.String())
+	}
+	if err != nil {
+		message := "the scan of " + source + " failed (" + err.Error() + ")"
+		if out := strings.TrimSpace(string(output)); out != "" {
+			message += ":\n" + out
+		}
+		return errors.New(message)
+	}
+	return nil
+}
+
+// scanArchive saves a downloaded archive to a temporary file for ScanCommand, and returns a reader over it once
+// the scan passed. The archive is streamed through untouched when no scanner is configured
+func scanArchive(r io.Reader, source string) (io.Reader, func(), error) {
+	if activeScanCommand() == "" {
+		return r, func() {}, nil
+	}
+
+	// The scanner is an external process, so the archive is written to the OS filesystem
+	file, err := os.CreateTemp("", "bespoke-scan-*.tar.gz")
+	if err != nil {
+		return nil, func() {}, err
+	}
+	cleanup := func() {
+		file.Close()
+		os.Remove(file.Name())
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		cleanup()
+		return nil, func() {}, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, func() {}, err
+	}
+	if err := scanPath(file.Name(), source); err != nil {
+		cleanup()
+		return nil, func() {}, err
+	}
+	return file, cleanup, nil
+}
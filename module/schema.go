@@ -0,0 +1,149 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// StrictMetadata rejects metadata with unknown or missing fields instead of ignoring them
+var StrictMetadata = false
+
+var requiredMetadataFields = []string{"name", "version", "authors"}
+
+func (m *Metadata) missingFields() []string {
+	problems := []string{}
+	if m.Name == "" {
+		problems = append(problems, "missing name")
+	}
+	if m.Version == "" {
+		problems = append(problems, "missing version")
+	}
+	if len(m.Authors) == 0 || m.Authors[0] == "" {
+		problems = append(problems, "missing authors")
+	}
+	problems = append(problems, m.unsafePathFields()...)
+	for _, identifier := range m.RenamedFrom {
+		if !moduleIdentifierRe.MatchString(identifier) {
+			problems = append(problems, "invalid renamedFrom "+identifier)
+		}
+	}
+	for scheme, variables := range m.Schemes {
+		for variable := range variables {
+			if !strings.HasPrefix(variable, "--") {
+				problems = append(problems, "scheme "+scheme+" sets "+variable+", which isn't a CSS variable")
+			}
+		}
+	}
+	for _, permission := range m.unknownPermissions() {
+		problems = append(problems, "unknown permission "+permission)
+	}
+	problems = append(problems, m.invalidActions()...)
+	return problems
+}
+
+// unsafePathFields lists the fields store paths are made of (see toFilePath) that would lead outside of the store,
+// these are refused whether the metadata is parsed strictly or not
+func (m *Metadata) unsafePathFields() []string {
+	problems := []string{}
+	check := func(field string, value string) {
+		if value == "." || strings.Contains(value, "..") || strings.ContainsAny(value, `/\`+"\x00") {
+			problems = append(problems, field+" "+strconv.Quote(value)+" can't be used in a path")
+		}
+	}
+	check("name", m.Name)
+	check("version", m.Version)
+	for _, author := range m.Authors {
+		check("author", author)
+	}
+	return problems
+}
+
+func parseMetadataStrict(r io.Reader) (Metadata, error) {
+	var metadata Metadata
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&metadata); err != nil {
+		return Metadata{}, errors.New("invalid metadata: " + err.Error())
+	}
+	if problems := metadata.missingFields(); len(problems) > 0 {
+		return Metadata{}, errors.New("invalid metadata: " + strings.Join(problems, ", "))
+	}
+	return metadata, nil
+}
+
+// LintModule checks the metadata.json of moduleDir in strict mode, along with the files it references
+func LintModule(moduleDir string) error {
+	strict := StrictMetadata
+	StrictMetadata = true
+	metadata, err := fetchLocalMetadata(filepath.Join(moduleDir, "metadata.json"))
+	StrictMetadata = strict
+	if err != nil {
+		return err
+	}
+	return metadata.Validate(moduleDir)
+}
+
+// MetadataSchema is the JSON Schema of metadata.json, generated from the Metadata struct
+func MetadataSchema() map[string]any {
+	schema := jsonSchemaOf(reflect.TypeOf(Metadata{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "bespoke module metadata"
+	schema["required"] = requiredMetadataFields
+
+	properties := schema["properties"].(map[string]any)
+	for _, field := range []string{"name", "version"} {
+		properties[field].(map[string]any)["minLength"] = 1
+	}
+	properties["authors"].(map[string]any)["minItems"] = 1
+	return schema
+}
+
+func jsonSchemaOf(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Int:
+		return map[string]any{"type": "integer"}
+	case reflect.Pointer:
+		return jsonSchemaOf(t.Elem())
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": jsonSchemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchemaOf(t.Elem())}
+	case reflect.Struct:
+		properties := map[string]any{}
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			properties[name] = jsonSchemaOf(t.Field(i).Type)
+		}
+		return map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	}
+	return map[string]any{}
+}
@@ -0,0 +1,49 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMetadataRefusesUnsafePaths(t *testing.T) {
+	tests := []struct {
+		metadata string
+		wantErr  bool
+	}{
+		{`{"name": "theme", "version": "1.0.0", "authors": ["a"]}`, false},
+		{`{"name": "../../../.ssh", "version": "1.0.0", "authors": ["a"]}`, true},
+		{`{"name": "theme", "version": "..", "authors": ["a"]}`, true},
+		{`{"name": "theme", "version": "1.0.0", "authors": ["a/b"]}`, true},
+		{`{"name": "theme", "version": "1.0.0", "authors": ["a\\b"]}`, true},
+		{`{"name": ".", "version": "1.0.0", "authors": ["a"]}`, true},
+	}
+	strict := StrictMetadata
+	t.Cleanup(func() { StrictMetadata = strict })
+	for _, StrictMetadata = range []bool{false, true} {
+		for _, tt := range tests {
+			_, err := parseMetadata(strings.NewReader(tt.metadata))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseMetadata(%s) with strict %v = %v, wantErr %v", tt.metadata, StrictMetadata, err, tt.wantErr)
+			}
+		}
+	}
+}
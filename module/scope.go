@@ -0,0 +1,170 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Scope tells where new versions are downloaded
+type Scope string
+
+const (
+	// ScopeUser downloads into the store of the current user
+	ScopeUser Scope = "user"
+	// ScopeSystem downloads into the system store shared by the users of the machine, and links each
+	// user's store to it. Enabling stays per user, as each user has their own vault
+	ScopeSystem Scope = "system"
+)
+
+var InstallScope = ScopeUser
+
+func ParseScope(scope string) (Scope, error) {
+	switch Scope(scope) {
+	case ScopeUser, ScopeSystem:
+		return Scope(scope), nil
+	}
+	return "", errors.New("unknown scope " + scope + ", expected user or system")
+}
+
+// storeArea is a store folder along with the folder of its locks: the store of the user or the system store
+type storeArea struct {
+	folder string
+	locks  string
+}
+
+func userStore() storeArea {
+	return storeArea{storeFolder, locksFolder}
+}
+
+func systemStore() storeArea {
+	return storeArea{systemStoreFolder, systemLocksFolder}
+}
+
+func (a storeArea) filePath(si StoreIdentifier) string {
+	return filepath.Join(a.folder, string(si.Author), string(si.Name), string(si.Version))
+}
+
+func (si *StoreIdentifier) toSystemFilePath() string {
+	return systemStore().filePath(*si)
+}
+
+// checkSystemStoreWritable tells whether the current user may install into the system store,
+// which usually takes running as root or an administrator
+func checkSystemStoreWritable() error {
+	if DryRun {
+		return nil
+	}
+	if err := fsys.MkdirAll(systemStoreFolder, 0755); err != nil {
+		return err
+	}
+	probe := filepath.Join(systemStoreFolder, ".write-test-"+strconv.Itoa(os.Getpid()))
+	if err := fsys.WriteFile(probe, nil, 0644); err != nil {
+		return err
+	}
+	if err := fsys.Remove(probe); err != nil {
+		return err
+	}
+	// Writable by the current user doesn't mean it is only writable by the admins
+	return checkSystemOwned(systemStoreFolder)
+}
+
+// checkSystemVersion tells whether the copy of identifier in the system store can be trusted: the store, and every
+// folder and file leading to and under the copy, must belong to the admins and only be writable by them
+func checkSystemVersion(identifier StoreIdentifier) error {
+	dir := systemStore().filePath(identifier)
+	for parent := filepath.Dir(dir); parent != filepath.Dir(systemStoreFolder); parent = filepath.Dir(parent) {
+		if err := checkSystemOwned(parent); err != nil {
+			return err
+		}
+	}
+	return fsys.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return checkSystemOwned(path)
+	})
+}
+
+// installInSystemStore links the store folder of identifier to its copy in the system store, installing it there
+// first when no other user did. Without the privileges to write to the system store, the version is installed
+// for the current user only
+func installInSystemStore(identifier StoreIdentifier, verified bool, populate func(dir string) error) error {
+	if _, err := fsys.Lstat(identifier.toFilePath()); err == nil && !isInstalling(identifier) {
+		return errors.New(identifier.toPath() + " is already installed, delete it first to install it again")
+	}
+
+	system := systemStore()
+	_, err := fsys.Stat(system.filePath(identifier))
+	installed := (err == nil || system.isCompressed(identifier)) && !system.isInstalling(identifier)
+
+	// A version other users disabled may be compressed in the system store, the link needs it extracted
+	if installed {
+		if err := decompressStoreIn(system, identifier); err != nil {
+			log.Println("Can't extract", identifier.String(), "in the system store, installing it for the current user only:", err.Error())
+			return installInUserStore(identifier, verified, populate)
+		}
+		if err := checkSystemVersion(identifier); err != nil {
+			log.Println("Not using", identifier.String(), "from the system store, installing it for the current user only:", err.Error())
+			return installInUserStore(identifier, verified, populate)
+		}
+	}
+
+	if installed {
+		log.Println("Using", identifier.String(), "from the system store")
+	} else if err := checkSystemStoreWritable(); err != nil {
+		log.Println("Can't write to the system store, installing", identifier.String(), "for the current user only:", err.Error())
+		return installInUserStore(identifier, verified, populate)
+	} else if err := installInArea(system, identifier, verified, populate); err != nil {
+		return err
+	}
+
+	if err := ensureSymlink(identifier.toSystemFilePath(), identifier.toFilePath()); err != nil {
+		return err
+	}
+	if !installed {
+		return nil
+	}
+	if err := writeManifest(identifier); err != nil {
+		return err
+	}
+	notify(StepDone, identifier, "")
+	return nil
+}
+
+// isSystemInstall tells whether the store folder of identifier links to the system store
+func isSystemInstall(identifier StoreIdentifier) bool {
+	target, err := fsys.EvalSymlinks(identifier.toFilePath())
+	if err != nil {
+		return false
+	}
+	systemStore, err := fsys.EvalSymlinks(systemStoreFolder)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(target, systemStore+string(filepath.Separator))
+}
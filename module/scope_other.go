@@ -0,0 +1,53 @@
+//go:build !windows
+
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"errors"
+	"os"
+	"syscall"
+)
+
+// checkSystemOwned tells whether path belongs to root and can't be written by other users
+func checkSystemOwned(path string) error {
+	// The system store is only checked on the disk
+	if !fsys.IsOS() {
+		return nil
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return errors.New("can't read the owner of " + path)
+	}
+	if stat.Uid != 0 {
+		return errors.New(path + " doesn't belong to root")
+	}
+	// The permissions of symlinks are ignored
+	if info.Mode()&os.ModeSymlink == 0 && info.Mode().Perm()&0022 != 0 {
+		return errors.New(path + " can be written by other users than root")
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+//go:build !windows
+
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckSystemOwned(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "metadata.json")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := checkSystemOwned(file)
+	if root := os.Getuid() == 0; root != (err == nil) {
+		t.Errorf("checkSystemOwned() = %v, running as root: %v", err, root)
+	}
+
+	// Writable by everyone, it can't be trusted whoever owns it
+	if err := os.Chmod(file, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSystemOwned(file); err == nil {
+		t.Error("a file writable by other users was trusted")
+	}
+}
@@ -0,0 +1,51 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+package module
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestInstallInSystemStoreCompressed(t *testing.T) {
+	useMemFs(t)
+	one := NewStoreIdentifier("a/one/1.0.0")
+	// Another user installed it in the system store, where it was compressed once disabled
+	system := systemStore()
+	writeMetadataIn(t, system.filePath(one), one)
+	if _, err := compressStoreIn(system, one); err != nil {
+		t.Fatal(err)
+	}
+	if !system.isCompressed(one) {
+		t.Fatal("the version wasn't compressed in the system store")
+	}
+
+	err := installInSystemStore(one, false, func(dir string) error {
+		return errors.New("the version was downloaded again")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readStoreMetadata(one); err != nil {
+		t.Errorf("the version linked from the system store can't be read: %v", err)
+	}
+	if system.isCompressed(one) {
+		t.Errorf("the version is still compressed in the system store")
+	}
+}
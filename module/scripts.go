@@ -0,0 +1,139 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/trace"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"time"
+)
+
+const (
+	scriptPostInstall = "postInstall"
+	scriptPreRemove   = "preRemove"
+)
+
+// AllowScripts lets every module run its lifecycle scripts, otherwise only the verified modules of TrustedScriptAuthors can
+var AllowScripts bool
+var TrustedScriptAuthors = []string{}
+var ScriptTimeout = time.Minute
+
+func (si *StoreIdentifier) toScriptLogFilePath(script string) string {
+	return filepath.Join(scriptLogsFolder, string(si.Author), string(si.Name), string(si.Version)+"-"+script+".log")
+}
+
+func (m *Metadata) lifecycleScript(script string) string {
+	switch script {
+	case scriptPostInstall:
+		return m.Scripts.PostInstall
+	case scriptPreRemove:
+		return m.Scripts.PreRemove
+	}
+	return ""
+}
+
+// scriptsAllowed tells whether the module of identifier may run commands. The authors listed in metadata.json
+// are self-declared, so trust is granted to the author in the store identifier and only when its signature
+// was verified
+func scriptsAllowed(identifier StoreIdentifier, verified bool) bool {
+	if AllowScripts {
+		return true
+	}
+	return verified && slices.Contains(TrustedScriptAuthors, string(identifier.Author))
+}
+
+// isVerifiedStore tells whether the installed version identifier was signed by its verified author
+func isVerifiedStore(identifier StoreIdentifier) bool {
+	vault, err := GetVault()
+	if err != nil {
+		return false
+	}
+	module, ok := vault.Modules[identifier.ModuleIdentifier.toPath()]
+	return ok && module.V[identifier.Version].Verified
+}
+
+func scriptCommand(ctx context.Context, script string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/c", script)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", script)
+}
+
+// runLifecycleScript runs a script of a module in dir, the folder it is installed in, capturing its output in a log file.
+// verified tells whether the module was signed by its author, see scriptsAllowed
+func runLifecycleScript(identifier StoreIdentifier, dir string, script string, verified bool) error {
+	metadata, err := fetchLocalMetadata(filepath.Join(dir, "metadata.json"))
+	if err != nil {
+		return nil
+	}
+	command := metadata.lifecycleScript(script)
+	if command == "" {
+		return nil
+	}
+	if !scriptsAllowed(identifier, verified) {
+		log.Println("Skipping the", script, "script of", identifier.String()+", allow scripts or trust its author to run:", command)
+		return nil
+	}
+	if skip("run the %s script of %s: %s", script, identifier, command) {
+		return nil
+	}
+
+	log.Println("Running the", script, "script of", identifier.String())
+	notify(StepScript, identifier, script)
+	_, err = runModuleScript(identifier, dir, script, command)
+	return err
+}
+
+// runModuleScript runs a command of a module in dir, the folder it is installed in, capturing its output in the log file of script
+func runModuleScript(identifier StoreIdentifier, dir string, script string, command string) ([]byte, error) {
+	defer trace.Start("script", script+" "+identifier.String()).Finish()
+	ctx, cancel := context.WithTimeout(Context, ScriptTimeout)
+	defer cancel()
+
+	cmd := scriptCommand(ctx, command)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "BESPOKE_MODULE="+identifier.String(), "BESPOKE_STORE="+cmd.Dir)
+	// Don't wait forever on processes spawned by the script that keep the output open
+	cmd.WaitDelay = 5 * time.Second
+	output, err := cmd.CombinedOutput()
+
+	logPath := identifier.toScriptLogFilePath(script)
+	if err := os.MkdirAll(filepath.Dir(logPath), os.ModePerm); err == nil {
+		os.WriteFile(logPath, output, 0600)
+	}
+
+	if ctx.Err() == context.Canceled {
+		return output, errors.New("the " + script + " script of " + identifier.String() + " was cancelled")
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return output, errors.New("the " + script + " script of " + identifier.String() + " timed out after " + ScriptTimeout.String() + ", see " + logPath)
+	}
+	if err != nil {
+		return output, errors.New("the " + script + " script of " + identifier.String() + " failed (" + err.Error() + "), see " + logPath)
+	}
+	return output, nil
+}
@@ -0,0 +1,62 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+package module
+
+import (
+	"testing"
+)
+
+func TestScriptsAllowed(t *testing.T) {
+	signed := NewStoreIdentifier("a/one/1.0.0")
+	unsigned := NewStoreIdentifier("a/one/2.0.0")
+	// Verified, but only a is trusted whatever the authors listed in metadata.json
+	impostor := NewStoreIdentifier("b/one/1.0.0")
+
+	tests := []struct {
+		name       string
+		allow      bool
+		identifier StoreIdentifier
+		want       bool
+	}{
+		{"verified trusted author", false, signed, true},
+		{"unverified trusted author", false, unsigned, false},
+		{"untrusted author", false, impostor, false},
+		{"scripts allowed", true, impostor, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useMemFs(t)
+			allow, trusted := AllowScripts, TrustedScriptAuthors
+			t.Cleanup(func() { AllowScripts, TrustedScriptAuthors = allow, trusted })
+			AllowScripts, TrustedScriptAuthors = tt.allow, []string{"a"}
+
+			vault := &Vault{Modules: map[ModuleIdentifierStr]Module{
+				"a/one": {Enabled: "1.0.0", V: map[Version]Store{"1.0.0": {Installed: true, Verified: true}, "2.0.0": {Installed: true}}},
+				"b/one": {Enabled: "1.0.0", V: map[Version]Store{"1.0.0": {Installed: true, Verified: true}}},
+			}}
+			if err := SetVault(vault); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := scriptsAllowed(tt.identifier, isVerifiedStore(tt.identifier)); got != tt.want {
+				t.Errorf("scriptsAllowed(%s) = %v, want %v", tt.identifier, got, tt.want)
+			}
+		})
+	}
+}
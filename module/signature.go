@@ -0,0 +1,257 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/network"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The signature of a module is published next to its metadata.json as metadata.json.sig
+const signatureSuffix = ".sig"
+
+// RegistryAuthor is an author vouched for by a registry, whose modules must be signed with the matching key
+type RegistryAuthor struct {
+	// PublicKey is a base64 encoded ed25519 public key
+	PublicKey string `json:"publicKey"`
+}
+
+// SignedModule is what an author signs: the files of the module and where it is published
+type SignedModule struct {
+	// Sources are the metadata URLs or sources the module is published at, installs from anywhere else are refused
+	Sources []string `json:"sources,omitempty"`
+	// Commit is the commit the files were published from, for signatures made after the commit
+	Commit string `json:"commit,omitempty"`
+	// Files maps the slash separated path of every file of the module, metadata.json included, to its SHA-256
+	Files Manifest `json:"files"`
+}
+
+// signatureFile is the content of metadata.json.sig, Signature covers the JSON encoding of SignedModule
+type signatureFile struct {
+	SignedModule
+	Signature string `json:"signature"`
+}
+
+// authorPublicKey looks up the key of a verified author, only trusted registries can vouch for authors.
+// A trusted registry that can't be read may be vouching for the author, which fails the lookup
+func authorPublicKey(author string) (ed25519.PublicKey, error) {
+	for _, registry := range sortedRegistries() {
+		if !registry.Trusted {
+			continue
+		}
+		index, err := fetchRegistryIndex(registry)
+		if err != nil {
+			return nil, errors.New("can't check whether " + author + " is verified by registry " + registry.Name + ": " + err.Error())
+		}
+		info, ok := index.Authors[Author(author)]
+		if !ok || info.PublicKey == "" {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(info.PublicKey)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, errors.New("registry " + registry.Name + " has an invalid public key for " + author)
+		}
+		return key, nil
+	}
+	return nil, nil
+}
+
+// verifyMetadata checks the signature of the module when its author is verified, and that raw is the signed
+// metadata.json. The files and the source are checked once known, see SignedModule.verifyFiles and verifySource.
+// It returns nil when the author isn't verified
+func verifyMetadata(metadata *Metadata, raw []byte, fetchSignature func() ([]byte, error)) (*SignedModule, error) {
+	author := metadata.getAuthor()
+	key, err := authorPublicKey(author)
+	if err != nil || key == nil {
+		return nil, err
+	}
+
+	identifier := metadata.getStoreIdentifier()
+	encoded, err := fetchSignature()
+	if err != nil {
+		return nil, errors.New(author + " is a verified author but the signature of " + identifier.toPath() + " can't be read: " + err.Error())
+	}
+	refused := errors.New(identifier.toPath() + " isn't signed by " + author + ", refusing to install it")
+	var file signatureFile
+	if err := json.Unmarshal(encoded, &file); err != nil {
+		// Signatures written by older releases only covered metadata.json
+		return nil, errors.New(refused.Error() + " (its signature doesn't cover its files, it must be signed again with bespoke dev sign)")
+	}
+	signed, err := json.Marshal(file.SignedModule)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.StdEncoding.DecodeString(file.Signature)
+	if err != nil || !ed25519.Verify(key, signed, signature) {
+		return nil, refused
+	}
+	h := sha256.Sum256(raw)
+	if file.Files["metadata.json"] != hex.EncodeToString(h[:]) {
+		return nil, errors.New("the metadata of " + identifier.toPath() + " doesn't match its signature, refusing to install it")
+	}
+	return &file.SignedModule, nil
+}
+
+// verifySource refuses the sources the module wasn't signed for. Local paths (air-gapped installs) have no
+// source to compare, their files are still checked
+func (s *SignedModule) verifySource(source string) error {
+	if s == nil || len(s.Sources) == 0 || !strings.Contains(source, "://") && !IsGitSource(source) {
+		return nil
+	}
+	// The asset of a release is picked at install time
+	source, _, _ = strings.Cut(source, "#")
+	for _, signed := range s.Sources {
+		if signed, _, _ = strings.Cut(signed, "#"); signed == source {
+			return nil
+		}
+	}
+	return errors.New("the module is signed for " + strings.Join(s.Sources, ", ") + ", not " + source + ", refusing to install it")
+}
+
+// verifyCommit refuses a commit other than the signed one
+func (s *SignedModule) verifyCommit(commit string) error {
+	if s == nil || s.Commit == "" || s.Commit == commit {
+		return nil
+	}
+	if commit == "" {
+		return errors.New("the module is signed for commit " + s.Commit + ", which can't be checked, refusing to install it")
+	}
+	return errors.New("the module is signed for commit " + s.Commit + ", not " + commit + ", refusing to install it")
+}
+
+// verifyFiles checks the files installed in dir against their signed hashes. Signed files may be missing,
+// since the files patterns and the platforms leave some out, but none can be added or modified
+func (s *SignedModule) verifyFiles(dir string) error {
+	if s == nil || DryRun {
+		return nil
+	}
+	manifest, err := hashDir(dir)
+	if err != nil {
+		return err
+	}
+	for file, hash := range manifest {
+		if file == "metadata.json"+signatureSuffix {
+			continue
+		}
+		signed, ok := s.Files[file]
+		if !ok {
+			return errors.New(file + " isn't signed by the author, refusing to install the module")
+		}
+		if signed != hash {
+			return errors.New(file + " doesn't match its signature, refusing to install the module")
+		}
+	}
+	return nil
+}
+
+func verifyRemoteMetadata(metadataURL RemoteURL, metadata *Metadata) (*SignedModule, error) {
+	raw, err := network.GetCached(metadataURL)
+	if err != nil {
+		return nil, err
+	}
+	return verifyMetadata(metadata, raw, func() ([]byte, error) {
+		return network.GetCached(metadataURL + signatureSuffix)
+	})
+}
+
+func verifyLocalMetadata(metadataPath string, metadata *Metadata) (*SignedModule, error) {
+	raw, err := os.ReadFile(metadataPath)
+	if err != nil {
+		return nil, err
+	}
+	return verifyMetadata(metadata, raw, func() ([]byte, error) {
+		return os.ReadFile(metadataPath + signatureSuffix)
+	})
+}
+
+// GenerateSigningKey writes a new private key to keyPath and returns the public key to register in a registry
+func GenerateSigningKey(keyPath string) (string, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(keyPath, []byte(base64.StdEncoding.EncodeToString(privateKey)+"\n"), 0600); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(publicKey), nil
+}
+
+// SignModule writes metadata.json.sig, the signature of the files of moduleDir (but its .git folder) and
+// of the sources and commit it is published at, which are optional
+func SignModule(moduleDir string, keyPath string, sources []string, commit string) error {
+	encoded, err := os.ReadFile(keyPath)
+	if err != nil {
+		return err
+	}
+	privateKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil || len(privateKey) != ed25519.PrivateKeySize {
+		return errors.New(keyPath + " isn't a signing key")
+	}
+
+	module := SignedModule{Sources: sources, Commit: commit, Files: Manifest{}}
+	err = filepath.WalkDir(moduleDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(moduleDir, path)
+		if err != nil || filepath.ToSlash(rel) == "metadata.json"+signatureSuffix {
+			return err
+		}
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		module.Files[filepath.ToSlash(rel)] = hash
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if _, ok := module.Files["metadata.json"]; !ok {
+		return errors.New(moduleDir + " has no metadata.json")
+	}
+
+	signed, err := json.Marshal(module)
+	if err != nil {
+		return err
+	}
+	file := signatureFile{module, base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, signed))}
+	raw, err := json.MarshalIndent(file, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(moduleDir, "metadata.json"+signatureSuffix), append(raw, '\n'), 0644)
+}
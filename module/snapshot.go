@@ -0,0 +1,159 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/network"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Snapshot is a setup published on a marketplace: the modules to enable, at these versions
+type Snapshot struct {
+	Modules []SnapshotModule `json:"modules"`
+}
+
+type SnapshotModule struct {
+	// Module is an author/name identifier
+	Module  string  `json:"module"`
+	Version Version `json:"version"`
+}
+
+// SnapshotPlan lists the changes that make the vault match a snapshot
+type SnapshotPlan struct {
+	Registry string
+	// Install holds the versions that aren't installed yet, Enable all the versions to enable (installed or not)
+	Install []StoreIdentifier
+	Enable  []StoreIdentifier
+	// Disable holds the enabled modules that aren't part of the snapshot
+	Disable []ModuleIdentifier
+}
+
+func (p *SnapshotPlan) Empty() bool {
+	return len(p.Enable) == 0 && len(p.Disable) == 0
+}
+
+var snapshotIdRe = regexp.MustCompile(`^(?:(?<registry>[^:/]+):)?(?<id>[A-Za-z0-9_.-]+)$`)
+
+// FetchSnapshot downloads a snapshot from the trusted registries, id is [<registry>:]<snapshot id>
+func FetchSnapshot(id string) (Snapshot, Registry, error) {
+	parts := snapshotIdRe.FindStringSubmatch(id)
+	if parts == nil {
+		return Snapshot{}, Registry{}, errors.New("invalid snapshot id " + id)
+	}
+
+	for _, registry := range sortedRegistries() {
+		if parts[1] != "" && parts[1] != registry.Name || !registry.Trusted || registry.Snapshots == "" {
+			continue
+		}
+		snapshot, found, err := fetchSnapshot(registry, parts[2])
+		if err != nil {
+			return Snapshot{}, registry, err
+		}
+		if found {
+			return snapshot, registry, nil
+		}
+	}
+	return Snapshot{}, Registry{}, errors.New("no trusted registry publishes snapshot " + id)
+}
+
+func fetchSnapshot(registry Registry, id string) (Snapshot, bool, error) {
+	snapshotURL := strings.ReplaceAll(registry.Snapshots, "{id}", url.PathEscape(id))
+	res, err := network.Get(snapshotURL)
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return Snapshot{}, false, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return Snapshot{}, false, errors.New("can't fetch snapshot " + id + " from " + registry.Name + ": " + res.Status)
+	}
+
+	var snapshot Snapshot
+	if err := json.NewDecoder(res.Body).Decode(&snapshot); err != nil {
+		return Snapshot{}, false, errors.New("invalid snapshot " + id + ": " + err.Error())
+	}
+	return snapshot, true, nil
+}
+
+// PlanSnapshot compares the vault with a snapshot fetched from registry
+func PlanSnapshot(snapshot Snapshot, registry Registry) (SnapshotPlan, error) {
+	plan := SnapshotPlan{Registry: registry.Name}
+	vault, err := GetVault()
+	if err != nil {
+		return plan, err
+	}
+
+	wanted := map[ModuleIdentifierStr]bool{}
+	for _, m := range snapshot.Modules {
+		if !moduleIdentifierRe.MatchString(m.Module) || m.Version == "" {
+			return plan, errors.New("invalid snapshot entry " + m.Module + "@" + string(m.Version))
+		}
+		identifier := StoreIdentifier{NewModuleIdentifier(m.Module), m.Version}
+		wanted[identifier.ModuleIdentifier.toPath()] = true
+
+		module, ok := vault.Modules[identifier.ModuleIdentifier.toPath()]
+		if _, installed := module.V[identifier.Version]; !ok || !installed {
+			plan.Install = append(plan.Install, identifier)
+		}
+		if module.Enabled != identifier.Version {
+			plan.Enable = append(plan.Enable, identifier)
+		}
+	}
+	for _, identifier := range vault.OrderedModules() {
+		if vault.Modules[identifier].Enabled != "" && !wanted[identifier] {
+			plan.Disable = append(plan.Disable, NewModuleIdentifier(string(identifier)))
+		}
+	}
+	return plan, nil
+}
+
+// ApplySnapshot installs the missing versions of a snapshot through its registry before changing what is enabled,
+// so that a failed download leaves the setup untouched. The extra modules are disabled before the versions of the
+// snapshot are enabled, so that they don't conflict with them
+func ApplySnapshot(plan SnapshotPlan) error {
+	for _, identifier := range plan.Install {
+		ref := ModuleRef{plan.Registry, identifier}
+		notify(StepResolve, identifier, plan.Registry)
+		if err := InstallModuleRef(ref); err != nil {
+			return errors.New(identifier.String() + ": " + err.Error())
+		}
+	}
+
+	return Batch(func() error {
+		for _, identifier := range plan.Disable {
+			if err := ToggleModuleInVault(StoreIdentifier{ModuleIdentifier: identifier}); err != nil {
+				return err
+			}
+		}
+		for _, identifier := range plan.Enable {
+			if err := ToggleModuleInVault(identifier); err != nil {
+				return errors.New(identifier.String() + ": " + err.Error())
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,130 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"net/url"
+	"runtime"
+	"strings"
+)
+
+// quotePairs are the quotes surrounding pasted text: shells, chat apps and word processors add their own
+var quotePairs = [][2]string{{"\"", "\""}, {"'", "'"}, {"`", "`"}, {"<", ">"}, {"“", "”"}, {"‘", "’"}, {"«", "»"}}
+
+// CleanSource undoes what copying a source from a browser or dropping a file on the terminal adds to it:
+// surrounding whitespace and quotes, trailing punctuation, file:// URLs and escaped spaces of dropped paths,
+// and the links to pages of github.com (see NormalizeSourceURL).
+// local is set when the source is a file:// URL
+func CleanSource(source string) (cleaned string, local bool) {
+	for {
+		trimmed := strings.TrimSpace(source)
+		for _, pair := range quotePairs {
+			if len(trimmed) > len(pair[0])+len(pair[1]) && strings.HasPrefix(trimmed, pair[0]) && strings.HasSuffix(trimmed, pair[1]) {
+				trimmed = trimmed[len(pair[0]) : len(trimmed)-len(pair[1])]
+			}
+		}
+		// Sentences end with punctuation that isn't part of the link, closing brackets only belong to it when opened in it
+		// (but . and .. are part of relative paths)
+		if t := strings.TrimRight(trimmed, ".,;:!?"); t != "" && !strings.HasSuffix(t, "/") && !strings.HasSuffix(t, "\\") {
+			trimmed = t
+		}
+		if last := len(trimmed) - 1; last >= 0 {
+			for _, pair := range []string{"()", "[]", "{}"} {
+				if trimmed[last] == pair[1] && strings.Count(trimmed, pair[:1]) < strings.Count(trimmed, pair[1:]) {
+					trimmed = trimmed[:last]
+					break
+				}
+			}
+		}
+		if trimmed == source {
+			break
+		}
+		source = trimmed
+	}
+
+	if strings.HasPrefix(source, "file://") {
+		if u, err := url.Parse(source); err == nil {
+			path := u.Path
+			// file:///C:/Users/... on Windows
+			if runtime.GOOS == "windows" {
+				path = strings.TrimPrefix(path, "/")
+			}
+			return path, true
+		}
+	}
+	// Terminals escape the spaces of the paths dropped on them
+	if runtime.GOOS != "windows" && !strings.Contains(source, "://") {
+		source = strings.ReplaceAll(source, "\\ ", " ")
+	}
+
+	return NormalizeSourceURL(source), false
+}
+
+// NormalizeSourceURL turns the pages of github.com copied from the browser into the source they show:
+// files (blob/…, raw/…) into their raw.githubusercontent.com URL, folders (tree/…) and repositories into the URL
+// of their metadata.json, and releases into a gh-release:// source. Other sources are returned as is
+func NormalizeSourceURL(murl string) string {
+	if strings.HasPrefix(murl, "github.com/") || strings.HasPrefix(murl, "www.github.com/") {
+		murl = "https://" + murl
+	}
+	u, err := url.Parse(murl)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || (u.Host != "github.com" && u.Host != "www.github.com") {
+		return murl
+	}
+
+	// The line anchors, ?raw=true and ?plain=1 don't matter
+	escaped := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	if len(escaped) < 2 {
+		return murl
+	}
+	segments := make([]string, len(escaped))
+	for i, segment := range escaped {
+		if segments[i], err = url.PathUnescape(segment); err != nil {
+			return murl
+		}
+	}
+	owner, repo := segments[0], strings.TrimSuffix(segments[1], ".git")
+	raw := "https://raw.githubusercontent.com/" + escaped[0] + "/" + strings.TrimSuffix(escaped[1], ".git") + "/"
+
+	if len(segments) == 2 {
+		return raw + "HEAD/metadata.json"
+	}
+	switch segments[2] {
+	case "blob", "raw":
+		if len(segments) >= 5 {
+			return raw + strings.Join(escaped[3:], "/")
+		}
+	case "tree":
+		if len(segments) >= 4 {
+			return raw + strings.Join(escaped[3:], "/") + "/metadata.json"
+		}
+	case "releases":
+		release := releaseSourcePrefix + owner + "/" + repo
+		switch {
+		case len(segments) == 3 || (len(segments) == 4 && segments[3] == "latest"):
+			return release
+		case len(segments) >= 5 && segments[3] == "tag":
+			return release + "@" + strings.Join(segments[4:], "/")
+		case len(segments) == 6 && segments[3] == "download":
+			return release + "@" + segments[4] + "#" + segments[5]
+		}
+	}
+	return murl
+}
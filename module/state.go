@@ -0,0 +1,179 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"bespoke/query"
+	"bespoke/version"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+)
+
+type ModuleState string
+
+const (
+	StateEnabled  ModuleState = "enabled"
+	StateDisabled ModuleState = "disabled"
+	// StateBroken is a version whose files or link are missing, see ModuleStatus.Reason
+	StateBroken ModuleState = "broken"
+	// StateUpdating is a version that another bespoke process is installing
+	StateUpdating ModuleState = "updating"
+)
+
+type ModuleStatus struct {
+	Identifier StoreIdentifier `json:"-"`
+	Module     string          `json:"module"`
+	State      ModuleState     `json:"state"`
+	Reason     string          `json:"reason,omitempty"`
+	Verified   bool            `json:"verified"`
+	Explicit   bool            `json:"explicit"`
+	Priority   int             `json:"priority"`
+	// Compressed is set for the disabled versions kept as a tarball, see CompressDisabled
+	Compressed bool     `json:"compressed,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	License    string   `json:"license,omitempty"`
+	// Scope is system when the version is a link to the system store, see ScopeSystem
+	Scope Scope `json:"scope"`
+	// Permissions are the runtime permissions requested by the version, see Metadata.Permissions
+	Permissions []string `json:"permissions,omitempty"`
+	// Latest, LatestError and Source are only filled by CheckRemote
+	Latest      Version       `json:"latest,omitempty"`
+	LatestError string        `json:"latestError,omitempty"`
+	Source      *SourceHealth `json:"source,omitempty"`
+	// SourceMissing is recorded on the enabled version when its source is gone, see MissingSource
+	SourceMissing *MissingSource `json:"sourceMissing,omitempty"`
+}
+
+// Outdated reports whether CheckRemote found a version newer than this one
+func (s *ModuleStatus) Outdated() bool {
+	return s.Latest != "" && version.Compare(string(s.Latest), string(s.Identifier.Version)) > 0
+}
+
+// inspectStore checks that an installed version is usable without hashing its files (see VerifyModule for that)
+func inspectStore(module *Module, identifier StoreIdentifier) ModuleStatus {
+	status := ModuleStatus{
+		Identifier: identifier,
+		Module:     identifier.String(),
+		State:      StateDisabled,
+		Verified:   module.V[identifier.Version].Verified,
+		Explicit:   module.V[identifier.Version].Explicit,
+		Priority:   module.Priority,
+		Scope:      ScopeUser,
+	}
+	enabled := module.Enabled == identifier.Version
+	if enabled {
+		status.State = StateEnabled
+		status.SourceMissing = module.SourceMissing
+	}
+
+	broken := func(reason string) ModuleStatus {
+		status.State = StateBroken
+		status.Reason = reason
+		return status
+	}
+
+	if isInstalling(identifier) {
+		status.State = StateUpdating
+		return status
+	}
+	if isCompressed(identifier) && !enabled {
+		status.Compressed = true
+		if metadata, err := readStoreMetadata(identifier); err == nil {
+			status.Tags = metadata.Tags
+			status.License = metadata.License
+			status.Permissions = metadata.Permissions
+		}
+		return status
+	}
+	if _, err := fsys.Stat(identifier.toFilePath()); err != nil {
+		return broken("missing from the store")
+	}
+	if isSystemInstall(identifier) {
+		status.Scope = ScopeSystem
+	}
+	metadata, err := fetchLocalMetadata(filepath.Join(identifier.toFilePath(), "metadata.json"))
+	if err != nil {
+		return broken("unreadable metadata.json: " + err.Error())
+	}
+	status.Tags = metadata.Tags
+	status.License = metadata.License
+	status.Permissions = metadata.Permissions
+	for _, entry := range metadata.entryFiles() {
+		if _, err := fsys.Stat(filepath.Join(identifier.toFilePath(), filepath.FromSlash(entry))); err != nil {
+			return broken("entry " + entry + " is missing")
+		}
+	}
+	if enabled && !isLinkHealthy(identifier.ModuleIdentifier.toFilePath(), identifier.toFilePath()) {
+		return broken("the link to the store is missing or dangling, run `bespoke vault repair`")
+	}
+	return status
+}
+
+// ModuleStatuses reports the state of every installed version, in load order
+func ModuleStatuses() ([]ModuleStatus, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return nil, err
+	}
+	return vaultStatuses(vault), nil
+}
+
+func vaultStatuses(vault *Vault) []ModuleStatus {
+	statuses := []ModuleStatus{}
+	for _, moduleIdentifier := range vault.OrderedModules() {
+		module := vault.Modules[moduleIdentifier]
+		for _, version := range module.versions() {
+			identifier := StoreIdentifier{NewModuleIdentifier(string(moduleIdentifier)), version}
+			statuses = append(statuses, inspectStore(&module, identifier))
+		}
+	}
+	return statuses
+}
+
+// Record is the status as seen by filters (see package query): its JSON fields, along with the author, name
+// and version of the module and whether it is enabled
+func (s *ModuleStatus) Record() query.Record {
+	record := query.Record{}
+	if raw, err := json.Marshal(s); err == nil {
+		json.Unmarshal(raw, &record)
+	}
+	record["author"] = string(s.Identifier.Author)
+	record["name"] = string(s.Identifier.Name)
+	record["version"] = string(s.Identifier.Version)
+	record["enabled"] = s.State == StateEnabled
+	return record
+}
+
+// FilterStatuses keeps the statuses matching a filter expression, e.g. `enabled == true && tags contains "theme"`
+func FilterStatuses(statuses []ModuleStatus, filter string) ([]ModuleStatus, error) {
+	expr, err := query.Parse(filter)
+	if err != nil {
+		return nil, errors.New("invalid filter: " + err.Error())
+	}
+	matches := []ModuleStatus{}
+	for _, status := range statuses {
+		if query.Match(expr, status.Record()) {
+			matches = append(matches, status)
+		}
+	}
+	return matches, nil
+}
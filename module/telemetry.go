@@ -0,0 +1,79 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/network"
+	"encoding/json"
+	"log"
+)
+
+// Telemetry enables the anonymous install pings, it stays off unless the user opts in
+var Telemetry = false
+
+const (
+	pingInstall = "install"
+	pingUpgrade = "upgrade"
+)
+
+// installPing is the whole payload: no user, machine or Spotify identifier is ever sent
+type installPing struct {
+	Module  ModuleIdentifierStr `json:"module"`
+	Version Version             `json:"version"`
+	Event   string              `json:"event"`
+}
+
+// sendInstallPing notifies the stats endpoint of the registries listing the module that it was installed,
+// it must be called once the version was added to the vault
+func sendInstallPing(identifier StoreIdentifier) {
+	if !Telemetry || DryRun {
+		return
+	}
+
+	event := pingInstall
+	if vault, err := GetVault(); err == nil {
+		for version := range vault.getModule(identifier.ModuleIdentifier.toPath()).V {
+			if version != identifier.Version {
+				event = pingUpgrade
+				break
+			}
+		}
+	}
+	body, err := json.Marshal(installPing{identifier.ModuleIdentifier.toPath(), identifier.Version, event})
+	if err != nil {
+		return
+	}
+
+	for _, registry := range sortedRegistries() {
+		if registry.Stats == "" {
+			continue
+		}
+		index, err := fetchRegistryIndex(registry)
+		if err != nil {
+			continue
+		}
+		if _, ok := index.Modules[identifier.ModuleIdentifier.toPath()]; !ok {
+			continue
+		}
+		if err := network.Ping(registry.Stats, body); err != nil {
+			log.Println("Couldn't send install ping to", registry.Name+":", err.Error())
+		}
+	}
+}
@@ -0,0 +1,98 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+// SchemeNames returns the color schemes of a theme, sorted
+func (m *Metadata) SchemeNames() []string {
+	names := make([]string, 0, len(m.Schemes))
+	for name := range m.Schemes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// DefaultScheme is the scheme named "default", or the first one in alphabetical order
+func (m *Metadata) DefaultScheme() string {
+	if _, ok := m.Schemes["default"]; ok {
+		return "default"
+	}
+	if names := m.SchemeNames(); len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
+
+// resolveScheme falls back to the default scheme when the chosen one is unset or was dropped by the theme
+func (m *Metadata) resolveScheme(chosen string) string {
+	if _, ok := m.Schemes[chosen]; ok {
+		return chosen
+	}
+	return m.DefaultScheme()
+}
+
+// GetScheme returns the metadata of the enabled version of a theme along with its active scheme
+func GetScheme(identifier ModuleIdentifier) (Metadata, string, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return Metadata{}, "", err
+	}
+	module, ok := vault.Modules[identifier.toPath()]
+	if !ok || module.Enabled == "" {
+		return Metadata{}, "", errors.New(identifier.String() + " isn't enabled")
+	}
+	metadata, err := readStoreMetadata(StoreIdentifier{identifier, module.Enabled})
+	if err != nil {
+		return Metadata{}, "", err
+	}
+	return metadata, metadata.resolveScheme(module.Scheme), nil
+}
+
+// SetScheme picks the color scheme of an enabled theme, which the loader manifest passes on to the client
+func SetScheme(identifier ModuleIdentifier, scheme string) error {
+	before := snapshotVault()
+	metadata, _, err := GetScheme(identifier)
+	if err != nil {
+		return err
+	}
+	if len(metadata.Schemes) == 0 {
+		return errors.New(identifier.String() + " has no color schemes")
+	}
+	if _, ok := metadata.Schemes[scheme]; !ok {
+		return errors.New(identifier.String() + " has no scheme " + scheme + ", pick one of " + strings.Join(metadata.SchemeNames(), ", "))
+	}
+
+	err = MutateVault(func(vault *Vault) bool {
+		module := vault.Modules[identifier.toPath()]
+		module.Scheme = scheme
+		vault.Modules[identifier.toPath()] = module
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return record(OpScheme, identifier.String(), before)
+}
@@ -0,0 +1,110 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"errors"
+	"log"
+	"strings"
+)
+
+// Installs from plain http:// sources are refused unless AllowHTTP is set, as their metadata and files
+// can be tampered with on the way
+var AllowHTTP = false
+
+// KnownHosts are the code hosts modules are commonly published on
+var KnownHosts = []string{"raw.githubusercontent.com", "github.com", "gitlab.com", "codeberg.org"}
+
+type TrustLevel string
+
+const (
+	TrustOfficial TrustLevel = "official registry"
+	TrustKnown    TrustLevel = "known host"
+	TrustUnknown  TrustLevel = "unknown"
+)
+
+type SourceTrust struct {
+	Host  string     `json:"host"`
+	Level TrustLevel `json:"level"`
+	// Registry is the trusted registry listing the source
+	Registry string `json:"registry,omitempty"`
+	Insecure bool   `json:"insecure"`
+}
+
+func (t SourceTrust) String() string {
+	host := t.Host
+	if host == "" {
+		host = "local files"
+	}
+	details := []string{string(t.Level)}
+	if t.Registry != "" {
+		details[0] += " " + t.Registry
+	}
+	if t.Insecure {
+		details = append(details, "plain http")
+	}
+	return host + " (" + strings.Join(details, ", ") + ")"
+}
+
+func isPlainHTTP(murl string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimPrefix(murl, gitSourcePrefix)), "http://")
+}
+
+// ClassifySource tells whether a metadata URL (or git source) is listed by a trusted registry,
+// comes from a known code host or from anywhere else
+func ClassifySource(murl string) SourceTrust {
+	trust := SourceTrust{Host: sourceHost(murl), Level: TrustUnknown, Insecure: isPlainHTTP(murl)}
+
+	for _, registry := range sortedRegistries() {
+		if !registry.Trusted {
+			continue
+		}
+		index, err := fetchRegistryIndex(registry)
+		if err != nil {
+			continue
+		}
+		for _, entry := range index.Modules {
+			for _, metadataURL := range entry.Versions {
+				if metadataURL == murl {
+					trust.Level = TrustOfficial
+					trust.Registry = registry.Name
+					return trust
+				}
+			}
+		}
+	}
+
+	for _, host := range KnownHosts {
+		if trust.Host != "" && strings.EqualFold(trust.Host, host) {
+			trust.Level = TrustKnown
+			break
+		}
+	}
+	return trust
+}
+
+// checkSourceTrust refuses plain http sources unless allowed, and shows where the module comes from
+func checkSourceTrust(murl string) error {
+	if isPlainHTTP(murl) && !AllowHTTP {
+		return errors.New(murl + " isn't served over https, allow http to install from it anyway")
+	}
+	log.Println("Installing from", ClassifySource(murl))
+	return nil
+}
@@ -0,0 +1,292 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	e "bespoke/errors"
+	"bespoke/fsys"
+	"bespoke/link"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TrashMaxAge is how long deleted versions are kept for undo, 0 keeps them until the cache is cleared
+var TrashMaxAge = 7 * 24 * time.Hour
+
+func (si *StoreIdentifier) toTrashFilePath() string {
+	return filepath.Join(trashFolder, string(si.Author), string(si.Name), string(si.Version))
+}
+
+func move(src string, dest string) error {
+	if skip("move %s to %s", src, dest) {
+		return nil
+	}
+	if err := fsys.MkdirAll(filepath.Dir(dest), os.ModePerm); err != nil {
+		return err
+	}
+	if err := fsys.RemoveAll(dest); err != nil {
+		return err
+	}
+	if err := fsys.Rename(src, dest); err == nil {
+		return nil
+	}
+	// src and dest may be on different devices
+	if err := link.CopyDir(src, dest); err != nil {
+		return err
+	}
+	return fsys.RemoveAll(src)
+}
+
+// trashModuleInStore moves the store folder of identifier to the trash, from which undo restores it, and prunes
+// the versions trashed more than TrashMaxAge ago
+func trashModuleInStore(identifier StoreIdentifier) error {
+	if err := deleteManifest(identifier); err != nil {
+		return err
+	}
+	defer pruneTrash()
+
+	src, dest := identifier.toFilePath(), identifier.toTrashFilePath()
+	if isCompressed(identifier) {
+		src, dest = identifier.toBlobFilePath(), dest+blobExtension
+	} else if _, err := fsys.Lstat(src); err != nil {
+		return nil
+	}
+	if err := move(src, dest); err != nil || DryRun {
+		return err
+	}
+	// The age of a trashed version is that of its trashing, not of its files
+	now := time.Now()
+	return fsys.Chtimes(dest, now, now)
+}
+
+// pruneTrash removes the versions trashed more than TrashMaxAge ago, which can't be restored by undo anymore
+func pruneTrash() {
+	if TrashMaxAge == 0 || DryRun {
+		return
+	}
+	trashed, _ := fsys.Glob(filepath.Join(trashFolder, "*", "*", "*"))
+	for _, version := range trashed {
+		if fi, err := fsys.Lstat(version); err == nil && time.Since(fi.ModTime()) > TrashMaxAge {
+			fsys.RemoveAll(version)
+		}
+	}
+}
+
+func restoreModuleInStore(identifier StoreIdentifier) error {
+	if _, err := fsys.Lstat(identifier.toTrashFilePath() + blobExtension); err == nil {
+		if err := move(identifier.toTrashFilePath()+blobExtension, identifier.toBlobFilePath()); err != nil {
+			return err
+		}
+		return writeManifest(identifier)
+	}
+	if _, err := fsys.Lstat(identifier.toTrashFilePath()); err != nil {
+		return errors.New(identifier.String() + " isn't in the cache anymore")
+	}
+	if err := move(identifier.toTrashFilePath(), identifier.toFilePath()); err != nil {
+		return err
+	}
+	if fi, err := fsys.Lstat(identifier.toFilePath()); err == nil && fi.IsDir() {
+		return writeManifest(identifier)
+	}
+	return nil
+}
+
+// UndoableEntries returns the journal entries that can still be undone, most recent first
+func UndoableEntries() ([]JournalEntry, error) {
+	entries, err := ReadJournal()
+	if err != nil {
+		return nil, err
+	}
+
+	undone := map[string]bool{}
+	undoable := []JournalEntry{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.Operation == OpUndo {
+			undone[entry.Identifier] = true
+			continue
+		}
+		if undone[entry.ID] {
+			continue
+		}
+		if _, err := fsys.Stat(entry.snapshotPath()); err != nil {
+			continue
+		}
+		undoable = append(undoable, entry)
+	}
+	return undoable, nil
+}
+
+func readSnapshot(entry *JournalEntry) (*Vault, error) {
+	file, err := fsys.Open(entry.snapshotPath())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var vault Vault
+	err = json.NewDecoder(file).Decode(&vault)
+	if vault.Modules == nil {
+		vault.Modules = map[ModuleIdentifierStr]Module{}
+	}
+	return &vault, err
+}
+
+// Undo reverts the journal entry with the given id, or the most recent one if id is empty
+func Undo(id string) (JournalEntry, error) {
+	entries, err := UndoableEntries()
+	if err != nil {
+		return JournalEntry{}, err
+	}
+
+	i := 0
+	if id != "" {
+		i = slices.IndexFunc(entries, func(entry JournalEntry) bool { return entry.ID == id })
+	}
+	if i < 0 || i >= len(entries) {
+		return JournalEntry{}, errors.New("nothing to undo")
+	}
+	entry := entries[i]
+
+	snapshot, err := readSnapshot(&entry)
+	if err != nil {
+		return entry, err
+	}
+
+	before := snapshotVault()
+	withoutRecording(func() {
+		err = revert(&entry, snapshot)
+	})
+	if err != nil {
+		return entry, err
+	}
+
+	return entry, record(OpUndo, entry.ID, before)
+}
+
+// restoreConfigSnapshot writes back the config file replaced by the operation of entry, if it replaced it
+func restoreConfigSnapshot(entry *JournalEntry) error {
+	config, err := fsys.ReadFile(entry.configSnapshotPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if skip("restore %s as it was before %s", ConfigFile, entry.ID) {
+		return nil
+	}
+	return writeVaultFile(ConfigFile, config)
+}
+
+func moduleIdentifierOf(identifier string) ModuleIdentifier {
+	parts := strings.SplitN(identifier, "/", 3)
+	return NewModuleIdentifier(parts[0] + "/" + parts[1])
+}
+
+func revert(entry *JournalEntry, snapshot *Vault) error {
+	switch entry.Operation {
+	case OpInstall:
+		return DeleteModule(NewStoreIdentifier(entry.Identifier))
+
+	case OpEnable, OpDisable:
+		moduleIdentifier := moduleIdentifierOf(entry.Identifier)
+		previous := snapshot.Modules[moduleIdentifier.toPath()].Enabled
+		return ToggleModuleInVault(StoreIdentifier{moduleIdentifier, previous})
+
+	case OpRemove:
+		identifier := NewStoreIdentifier(entry.Identifier)
+		previous := snapshot.Modules[identifier.ModuleIdentifier.toPath()]
+		store, ok := previous.V[identifier.Version]
+		if !ok {
+			return errors.New(identifier.String() + " is missing from the snapshot")
+		}
+		if err := restoreModuleInStore(identifier); err != nil {
+			return err
+		}
+		err := MutateVault(func(vault *Vault) bool {
+			return vault.setStore(identifier, &store)
+		})
+		if err != nil || previous.Enabled != identifier.Version {
+			return err
+		}
+		return ToggleModuleInVault(identifier)
+
+	case OpUpgrade:
+		identifier := NewStoreIdentifier(entry.Identifier)
+		previous := snapshot.Modules[identifier.ModuleIdentifier.toPath()]
+		if err := ToggleModuleInVault(StoreIdentifier{identifier.ModuleIdentifier, previous.Enabled}); err != nil {
+			return err
+		}
+		if _, ok := previous.V[identifier.Version]; ok {
+			return nil
+		}
+		return DeleteModule(identifier)
+
+	case OpRename:
+		return revertRename(NewStoreIdentifier(entry.Identifier), snapshot)
+
+	case OpOrder:
+		return MutateVault(func(vault *Vault) bool {
+			for identifier, module := range vault.Modules {
+				if previous, ok := snapshot.Modules[identifier]; ok {
+					module.Priority = previous.Priority
+					vault.Modules[identifier] = module
+				}
+			}
+			return true
+		})
+
+	case OpMark:
+		identifier := NewStoreIdentifier(entry.Identifier)
+		store, ok := snapshot.Modules[identifier.ModuleIdentifier.toPath()].V[identifier.Version]
+		if !ok {
+			return errors.New(identifier.String() + " is missing from the snapshot")
+		}
+		return MarkModule(identifier, store.Explicit)
+
+	case OpScheme:
+		identifier := moduleIdentifierOf(entry.Identifier)
+		return MutateVault(func(vault *Vault) bool {
+			module, ok := vault.Modules[identifier.toPath()]
+			if ok {
+				module.Scheme = snapshot.Modules[identifier.toPath()].Scheme
+				vault.Modules[identifier.toPath()] = module
+			}
+			return ok
+		})
+
+	case OpRepair, OpRestore:
+		if err := SetVault(snapshot); err != nil {
+			return err
+		}
+		if _, err := repairSymlinks(snapshot, false); err != nil {
+			return err
+		}
+		return restoreConfigSnapshot(entry)
+	}
+
+	return e.ErrUnsupportedOperation
+}
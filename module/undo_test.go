@@ -0,0 +1,51 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"testing"
+	"time"
+)
+
+func TestTrashRetention(t *testing.T) {
+	useMemFs(t)
+	old, recent := NewStoreIdentifier("a/one/1.0.0"), NewStoreIdentifier("a/one/2.0.0")
+	writeStoreMetadata(t, old)
+	writeStoreMetadata(t, recent)
+
+	if err := trashModuleInStore(old); err != nil {
+		t.Fatal(err)
+	}
+	longAgo := time.Now().Add(-TrashMaxAge - time.Hour)
+	if err := fsys.Chtimes(old.toTrashFilePath(), longAgo, longAgo); err != nil {
+		t.Fatal(err)
+	}
+	if err := trashModuleInStore(recent); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fsys.Lstat(old.toTrashFilePath()); err == nil {
+		t.Errorf("%s was kept in the trash past its retention", old)
+	}
+	if _, err := fsys.Lstat(recent.toTrashFilePath()); err != nil {
+		t.Errorf("%s was removed from the trash: %v", recent, err)
+	}
+}
@@ -0,0 +1,220 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/archive"
+	"bespoke/network"
+	"bespoke/version"
+	"errors"
+	"slices"
+)
+
+type Upgrade struct {
+	Module      ModuleIdentifier `json:"module"`
+	From        Version          `json:"from"`
+	To          Version          `json:"to"`
+	MetadataURL RemoteURL        `json:"metadataURL"`
+}
+
+// latestVersion looks up the newest version of a module in the overrides and registries, then its remotes,
+// and finally refetches the metadata URL it was installed from (which may track a branch)
+func latestVersion(identifier ModuleIdentifier, module *Module) (Version, RemoteURL, error) {
+	// Air-gapped installs have nowhere to look for upgrades, the installed version is the latest one
+	if isLocalVersion(module, module.Enabled) {
+		return module.Enabled, "", nil
+	}
+	// Cached indexes may be stale, the latest version is unknown rather than possibly wrong
+	if network.IsOffline() {
+		return "", "", network.ErrOffline
+	}
+	ref := ModuleRef{StoreIdentifier: StoreIdentifier{ModuleIdentifier: identifier}}
+	for _, resolve := range []func(ModuleRef) (RemoteURL, Version, error){resolveFromOverrides, resolveFromRegistries} {
+		metadataURL, version, err := resolve(ref)
+		if !errors.Is(err, ErrUnresolved) {
+			return version, metadataURL, err
+		}
+	}
+
+	for _, remote := range module.Remotes {
+		index, err := fetchRepoIndex(remote)
+		if err != nil {
+			continue
+		}
+		latest := index.latestVersion()
+		if metadataURL, ok := index.Versions[latest]; ok {
+			return latest, metadataURL, nil
+		}
+	}
+
+	if store, ok := module.V[module.Enabled]; ok {
+		for _, metadataURL := range store.Metadatas {
+			if IsGitSource(metadataURL) || IsReleaseSource(metadataURL) {
+				continue
+			}
+			metadata, resolved, err := fetchRemoteMetadata(metadataURL)
+			if err != nil {
+				return "", "", err
+			}
+			return Version(metadata.Version), resolved, nil
+		}
+	}
+
+	return "", "", errors.New("can't find where " + identifier.String() + " is published")
+}
+
+// CheckUpgrades lists the enabled modules (all of them when identifiers is empty) that have a newer version available
+func CheckUpgrades(identifiers []ModuleIdentifier) ([]Upgrade, []error) {
+	vault, err := GetVault()
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	if len(identifiers) == 0 {
+		for _, identifier := range vault.OrderedModules() {
+			identifiers = append(identifiers, NewModuleIdentifier(string(identifier)))
+		}
+	}
+
+	// The modules are checked concurrently, the network package bounds the requests in flight
+	type check struct {
+		upgrade *Upgrade
+		err     error
+		missing *MissingSource
+		known   bool
+	}
+	checks := make([]check, len(identifiers))
+	jobs := []func(){}
+	for i, identifier := range identifiers {
+		module, ok := vault.Modules[identifier.toPath()]
+		// Linked working copies are upgraded by their author
+		if !ok || module.Enabled == "" || module.Enabled == DevVersion {
+			continue
+		}
+
+		c := &checks[i]
+		jobs = append(jobs, func() {
+			latest, metadataURL, err := latestVersion(identifier, &module)
+			if err != nil && network.IsOffline() {
+				c.err = errors.New(identifier.String() + ": latest version unknown (offline)")
+				return
+			}
+			if err != nil {
+				// Pinging the source tells a deleted one apart from a transient failure
+				c.missing, c.known = pingSource(&module)
+				c.err = errors.New(identifier.String() + ": " + err.Error())
+				return
+			}
+			c.known = true
+			// A registry lagging behind a pre-release (or a local build) doesn't make it outdated
+			if version.Compare(string(latest), string(module.Enabled)) > 0 {
+				c.upgrade = &Upgrade{identifier, module.Enabled, latest, metadataURL}
+			}
+		})
+	}
+	runJobs(jobs, RemoteWorkers)
+
+	upgrades := []Upgrade{}
+	errs := []error{}
+	health := map[ModuleIdentifierStr]*MissingSource{}
+	for i, c := range checks {
+		if c.err != nil {
+			errs = append(errs, c.err)
+		}
+		if c.known {
+			health[identifiers[i].toPath()] = c.missing
+		}
+		if c.upgrade != nil {
+			upgrades = append(upgrades, *c.upgrade)
+		}
+	}
+
+	if err := recordSourceHealth(health); err != nil {
+		errs = append(errs, err)
+	}
+
+	slices.SortFunc(upgrades, func(a, b Upgrade) int {
+		if a.Module.String() < b.Module.String() {
+			return -1
+		}
+		return 1
+	})
+	return upgrades, errs
+}
+
+// ApplyUpgrade installs the new version (unless already installed) and enables it in place of the old one
+func ApplyUpgrade(upgrade Upgrade) error {
+	before := snapshotVault()
+
+	var to StoreIdentifier
+	var err error
+	withoutRecording(func() {
+		to, err = applyUpgrade(upgrade)
+	})
+	if err != nil {
+		return err
+	}
+
+	if to.ModuleIdentifier != upgrade.Module {
+		return record(OpRename, to.String(), before)
+	}
+	return record(OpUpgrade, to.String(), before)
+}
+
+func applyUpgrade(upgrade Upgrade) (StoreIdentifier, error) {
+	to := StoreIdentifier{upgrade.Module, upgrade.To}
+	vault, err := GetVault()
+	if err != nil {
+		return to, err
+	}
+
+	if _, ok := vault.getModule(upgrade.Module.toPath()).V[upgrade.To]; !ok {
+		notify(StepResolve, to, upgrade.MetadataURL)
+		metadata, resolved, err := fetchRemoteMetadata(upgrade.MetadataURL)
+		if err != nil {
+			return to, err
+		}
+		if metadata.Version != string(upgrade.To) {
+			return to, errors.New("metadata of " + upgrade.MetadataURL + " is for version " + metadata.Version)
+		}
+		if renamed := metadata.getModuleIdentifier(); renamed != upgrade.Module {
+			if !metadata.renamedFrom(upgrade.Module) {
+				return to, errors.New("metadata of " + upgrade.MetadataURL + " is for " + renamed.String())
+			}
+			return renameModule(upgrade, resolved, metadata)
+		}
+		// Only the files changed since the installed version are fetched when possible
+		from := StoreIdentifier{upgrade.Module, upgrade.From}
+		download := func(metadataURL RemoteURL, to StoreIdentifier, dest string, filter archive.Filter) error {
+			return upgradeModuleInStore(metadataURL, from, to, dest, filter)
+		}
+		if err := installModuleRemoteWith(upgrade.MetadataURL, resolved, metadata, download); err != nil {
+			return to, err
+		}
+		// The new version replaces a dependency
+		if store, ok := vault.getModule(upgrade.Module.toPath()).V[upgrade.From]; ok && !store.Explicit {
+			if err := MarkModule(to, false); err != nil {
+				return to, err
+			}
+		}
+	}
+
+	return to, ToggleModuleInVault(to)
+}
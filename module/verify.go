@@ -0,0 +1,213 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/fsys"
+	"bespoke/trace"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Manifest maps every file of a store (relative slash path) to its sha256 hash
+type Manifest map[string]string
+
+type VerifyReport struct {
+	Identifier StoreIdentifier
+	Modified   []string
+	Missing    []string
+	Extra      []string
+}
+
+func (r *VerifyReport) Ok() bool {
+	return len(r.Modified) == 0 && len(r.Missing) == 0 && len(r.Extra) == 0
+}
+
+func (si *StoreIdentifier) toManifestFilePath() string {
+	return filepath.Join(manifestsFolder, string(si.Author), string(si.Name), string(si.Version)+".json")
+}
+
+func hashStore(identifier StoreIdentifier) (Manifest, error) {
+	if isCompressed(identifier) {
+		return hashBlob(identifier)
+	}
+	root, err := fsys.EvalSymlinks(identifier.toFilePath())
+	if err != nil {
+		return nil, err
+	}
+	return hashDir(root)
+}
+
+// hashDir hashes every file under root, keyed by its slash separated path relative to root
+func hashDir(root string) (Manifest, error) {
+	manifest := Manifest{}
+	err := fsys.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+		manifest[filepath.ToSlash(rel)] = hash
+		return nil
+	})
+	return manifest, err
+}
+
+func hashFile(path string) (string, error) {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeManifest(identifier StoreIdentifier) error {
+	return saveManifest(identifier, func() (Manifest, error) { return hashStore(identifier) })
+}
+
+// writeManifestOf records the files of identifier as found in dir, the folder it was just installed in
+func writeManifestOf(identifier StoreIdentifier, dir string) error {
+	return saveManifest(identifier, func() (Manifest, error) { return hashDir(dir) })
+}
+
+func saveManifest(identifier StoreIdentifier, hash func() (Manifest, error)) error {
+	if skip("write %s", identifier.toManifestFilePath()) {
+		return nil
+	}
+	span := trace.Start("hash files", identifier.String())
+	manifest, err := hash()
+	span.Finish()
+	if err != nil {
+		return err
+	}
+	manifestJson, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	manifestPath := identifier.toManifestFilePath()
+	if err := fsys.MkdirAll(filepath.Dir(manifestPath), os.ModePerm); err != nil {
+		return err
+	}
+	return fsys.WriteFile(manifestPath, manifestJson, 0644)
+}
+
+func readManifest(identifier StoreIdentifier) (Manifest, error) {
+	file, err := fsys.Open(identifier.toManifestFilePath())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var manifest Manifest
+	err = json.NewDecoder(file).Decode(&manifest)
+	return manifest, err
+}
+
+func deleteManifest(identifier StoreIdentifier) error {
+	if _, err := fsys.Stat(identifier.toManifestFilePath()); err == nil && skip("remove %s", identifier.toManifestFilePath()) {
+		return nil
+	}
+	return fsys.RemoveAll(identifier.toManifestFilePath())
+}
+
+func VerifyModule(identifier StoreIdentifier) (VerifyReport, error) {
+	report := VerifyReport{Identifier: identifier}
+
+	expected, err := readManifest(identifier)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return report, errors.New("no manifest recorded for " + identifier.toPath() + " (local installs aren't tracked)")
+		}
+		return report, err
+	}
+
+	actual, err := hashStore(identifier)
+	if err != nil {
+		return report, err
+	}
+
+	for file, hash := range expected {
+		actualHash, ok := actual[file]
+		if !ok {
+			report.Missing = append(report.Missing, file)
+		} else if actualHash != hash {
+			report.Modified = append(report.Modified, file)
+		}
+	}
+	for file := range actual {
+		if _, ok := expected[file]; !ok {
+			report.Extra = append(report.Extra, file)
+		}
+	}
+
+	slices.Sort(report.Modified)
+	slices.Sort(report.Missing)
+	slices.Sort(report.Extra)
+	return report, nil
+}
+
+func VerifyAll() ([]VerifyReport, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return nil, err
+	}
+
+	reports := []VerifyReport{}
+	for moduleIdentifierStr, module := range vault.Modules {
+		for version := range module.V {
+			identifier := StoreIdentifier{NewModuleIdentifier(string(moduleIdentifierStr)), version}
+			if _, err := fsys.Stat(identifier.toManifestFilePath()); err != nil {
+				continue
+			}
+			report, err := VerifyModule(identifier)
+			if err != nil {
+				return reports, err
+			}
+			reports = append(reports, report)
+		}
+	}
+	slices.SortFunc(reports, func(a, b VerifyReport) int {
+		if a.Identifier.toPath() < b.Identifier.toPath() {
+			return -1
+		}
+		return 1
+	})
+	return reports, nil
+}
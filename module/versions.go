@@ -0,0 +1,91 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"bespoke/version"
+	"errors"
+	"strings"
+)
+
+// ResolveInstalledVersion picks the installed version of a module designated by spec: an exact version, latest
+// (or latest-installed) for the highest one, previous for the highest one below the enabled version, or a range
+func ResolveInstalledVersion(identifier ModuleIdentifier, spec string) (Version, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return "", err
+	}
+	module, ok := vault.Modules[identifier.toPath()]
+	if !ok || len(module.V) == 0 {
+		return "", errors.New("no version of " + identifier.String() + " is installed")
+	}
+	if _, ok := module.V[Version(spec)]; ok {
+		return Version(spec), nil
+	}
+
+	installed := module.versions()
+	candidates := []Version{}
+	switch spec {
+	case "latest", "latest-installed":
+		candidates = installed
+	case "previous":
+		if module.Enabled == "" {
+			return "", errors.New(identifier.String() + " isn't enabled, there is no previous version")
+		}
+		for _, v := range installed {
+			if version.Compare(string(v), string(module.Enabled)) < 0 {
+				candidates = append(candidates, v)
+			}
+		}
+	default:
+		constraint, err := version.ParseConstraint(spec)
+		if err != nil {
+			return "", noMatchingVersion(identifier, spec, installed)
+		}
+		for _, v := range installed {
+			if constraint.Check(string(v)) {
+				candidates = append(candidates, v)
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return "", noMatchingVersion(identifier, spec, installed)
+	}
+	return candidates[len(candidates)-1], nil
+}
+
+// versions lists the installed versions of a module from the oldest to the newest
+func (m *Module) versions() []Version {
+	versions := make([]Version, 0, len(m.V))
+	for v := range m.V {
+		versions = append(versions, v)
+	}
+	version.Sort(versions)
+	return versions
+}
+
+func noMatchingVersion(identifier ModuleIdentifier, spec string, installed []Version) error {
+	suggestions := make([]string, len(installed))
+	for i, v := range installed {
+		suggestions[i] = string(v)
+	}
+	return errors.New("no installed version of " + identifier.String() + " matches " + spec + ", installed versions: " + strings.Join(suggestions, ", "))
+}
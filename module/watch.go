@@ -0,0 +1,78 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package module
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+)
+
+// VaultState is what the daemon serves, built from a single read of the vault so that the enabled modules,
+// their statuses and the loader manifest always agree
+type VaultState struct {
+	Enabled  []StoreIdentifier
+	Manifest LoaderManifest
+	// Revision identifies the content of the vault, it only changes when the vault does
+	Revision string
+	vault    *Vault
+}
+
+// Statuses inspects the stores of the versions in the vault. They are inspected on every call: an install
+// marker, a lock or files going missing change the state of a version without the vault changing
+func (s *VaultState) Statuses() []ModuleStatus {
+	return vaultStatuses(s.vault)
+}
+
+func LoadVaultState() (*VaultState, error) {
+	vault, err := GetVault()
+	if err != nil {
+		return nil, err
+	}
+	vaultJson, err := json.Marshal(vault)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(vaultJson)
+
+	return &VaultState{
+		Enabled:  enabledModules(vault),
+		Manifest: BuildLoaderManifest(vault),
+		Revision: hex.EncodeToString(sum[:8]),
+		vault:    vault,
+	}, nil
+}
+
+// VaultWatchPaths are the folders changes to the vault show up in: the folder holding the modules folder, which
+// is replaced by a link to a new generation on every change, and the modules folder itself (for older installs
+// where it is a plain folder)
+func VaultWatchPaths() []string {
+	return []string{filepath.Dir(modulesFolder), modulesFolder}
+}
+
+// IsVaultChange tells whether a file event under VaultWatchPaths can change the vault
+func IsVaultChange(name string) bool {
+	switch name {
+	case modulesFolder, vaultPath, filepath.Join(modulesFolder, loaderManifestName):
+		return true
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+package module
+
+import (
+	"bespoke/fsys"
+	"path/filepath"
+	"testing"
+)
+
+func TestVaultStateStatuses(t *testing.T) {
+	useMemFs(t)
+	one := NewStoreIdentifier("a/one/1.0.0")
+	writeStoreMetadata(t, one)
+	vault := &Vault{Modules: map[ModuleIdentifierStr]Module{
+		"a/one": {V: map[Version]Store{"1.0.0": {Installed: true}}},
+	}}
+	if err := SetVault(vault); err != nil {
+		t.Fatal(err)
+	}
+	state, err := LoadVaultState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses := state.Statuses(); len(statuses) != 1 || statuses[0].State != StateDisabled {
+		t.Fatalf("Statuses() = %+v, want a/one disabled", statuses)
+	}
+
+	// Another process starts installing it again, the vault doesn't change
+	if err := fsys.MkdirAll(filepath.Dir(one.toInstallMarkerFilePath()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.WriteFile(one.toInstallMarkerFilePath(), []byte("1"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if statuses := state.Statuses(); statuses[0].State != StateUpdating {
+		t.Errorf("Statuses() = %s after the install marker was written, want %s", statuses[0].State, StateUpdating)
+	}
+
+	fsys.Remove(one.toInstallMarkerFilePath())
+	if err := fsys.RemoveAll(one.toFilePath()); err != nil {
+		t.Fatal(err)
+	}
+	if statuses := state.Statuses(); statuses[0].State != StateBroken {
+		t.Errorf("Statuses() = %s after the store folder was removed, want %s", statuses[0].State, StateBroken)
+	}
+}
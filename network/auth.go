@@ -0,0 +1,171 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Tokens maps a host to the token used to authenticate requests made to it
+var Tokens = map[string]string{}
+
+// RegistryHosts are the hosts of the configured registries, which may use the default entry of the netrc
+// like the GitHub hosts. Other hosts only get the credentials of their own machine entry
+var RegistryHosts = []string{}
+
+var githubHosts = []string{"github.com", "api.github.com", "raw.githubusercontent.com", "codeload.github.com", "uploads.github.com"}
+
+func isGithubHost(host string) bool {
+	for _, h := range githubHosts {
+		if host == h {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenFor looks up the token for host in the config, then the environment, then the user's netrc
+func TokenFor(host string) string {
+	host = strings.ToLower(host)
+	if isGithubHost(host) {
+		if token, ok := Tokens["github.com"]; ok {
+			return token
+		}
+	}
+	if token, ok := Tokens[host]; ok {
+		return token
+	}
+
+	envHost := strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(host))
+	if token := os.Getenv("BESPOKE_TOKEN_" + envHost); token != "" {
+		return token
+	}
+	if isGithubHost(host) {
+		for _, env := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+			if token := os.Getenv(env); token != "" {
+				return token
+			}
+		}
+		host = "github.com"
+	}
+
+	return netrcPassword(host)
+}
+
+func netrcPath() string {
+	if p := os.Getenv("NETRC"); p != "" {
+		return p
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	for _, name := range []string{".netrc", "_netrc"} {
+		p := filepath.Join(home, name)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+func netrcPassword(host string) string {
+	p := netrcPath()
+	if p == "" {
+		return ""
+	}
+	raw, err := os.ReadFile(p)
+	if err != nil {
+		return ""
+	}
+
+	passwords := map[string]string{}
+	machine := ""
+	fields := strings.Fields(string(raw))
+	for i := 0; i+1 < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			i++
+			machine = strings.ToLower(fields[i])
+		case "default":
+			machine = "default"
+		case "password":
+			i++
+			passwords[machine] = fields[i]
+		}
+	}
+
+	if password, ok := passwords[host]; ok {
+		return password
+	}
+	// The default entry is meant for the user's own services, it must not leak to any host a module points to
+	if isGithubHost(host) || slices.Contains(RegistryHosts, host) {
+		return passwords["default"]
+	}
+	return ""
+}
+
+type authTransport struct {
+	base http.RoundTripper
+}
+
+// sameAuthority tells whether a token for one host may follow a redirect to the other, the GitHub hosts
+// sharing the token of github.com
+func sameAuthority(from string, to string) bool {
+	from, to = strings.ToLower(from), strings.ToLower(to)
+	return from == to || isGithubHost(from) && isGithubHost(to)
+}
+
+// mayAuthenticate tells whether a token can be attached to req: it must be sent over https, and every
+// redirect that led to it must have stayed on the same host
+func mayAuthenticate(req *http.Request) bool {
+	if req.URL.Scheme != "https" {
+		return false
+	}
+	for r := req; r.Response != nil && r.Response.Request != nil; r = r.Response.Request {
+		if !sameAuthority(r.Response.Request.URL.Hostname(), r.URL.Hostname()) {
+			return false
+		}
+	}
+	return true
+}
+
+// Authenticates tells whether the requests made to rawURL carry a token
+func Authenticates(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.Scheme == "https" && TokenFor(u.Hostname()) != ""
+}
+
+// RoundTrip is also invoked for every redirect, which lets private archive downloads
+// keep their credentials when github.com redirects to codeload.github.com
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") == "" && mayAuthenticate(req) {
+		if token := TokenFor(req.URL.Hostname()); token != "" {
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,114 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+package network
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNetrcPassword(t *testing.T) {
+	netrc := filepath.Join(t.TempDir(), ".netrc")
+	raw := "machine example.com login me password mine\ndefault login me password fallback\n"
+	if err := os.WriteFile(netrc, []byte(raw), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NETRC", netrc)
+	hosts := RegistryHosts
+	t.Cleanup(func() { RegistryHosts = hosts })
+	RegistryHosts = []string{"registry.example.org"}
+
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"example.com", "mine"},
+		{"github.com", "fallback"},
+		{"registry.example.org", "fallback"},
+		{"attacker.example.net", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := netrcPassword(tt.host); got != tt.want {
+				t.Errorf("netrcPassword(%q) = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+type recordTransport struct {
+	authorization string
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.authorization = req.Header.Get("Authorization")
+	return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+}
+
+// redirected builds the request the client sends when following the redirects of urls, in order
+func redirected(t *testing.T, urls ...string) *http.Request {
+	t.Helper()
+	var req *http.Request
+	for _, u := range urls {
+		next, err := http.NewRequest(http.MethodGet, u, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req != nil {
+			next.Response = &http.Response{StatusCode: http.StatusFound, Request: req}
+		}
+		req = next
+	}
+	return req
+}
+
+func TestAuthTransport(t *testing.T) {
+	tokens := Tokens
+	t.Cleanup(func() { Tokens = tokens })
+	Tokens = map[string]string{"github.com": "gh", "example.com": "ex"}
+	t.Setenv("NETRC", filepath.Join(t.TempDir(), "missing"))
+
+	tests := []struct {
+		name string
+		urls []string
+		want string
+	}{
+		{"github", []string{"https://github.com/a/b"}, "Bearer gh"},
+		{"plain http", []string{"http://example.com/a"}, ""},
+		{"github to codeload", []string{"https://github.com/a/b/archive/x.zip", "https://codeload.github.com/a/b/zip/x"}, "Bearer gh"},
+		{"same host", []string{"https://example.com/a", "https://example.com/b"}, "Bearer ex"},
+		{"cross host", []string{"https://example.com/a", "https://github.com/a/b"}, ""},
+		{"back to the first host", []string{"https://example.com/a", "https://elsewhere.example.net/b", "https://example.com/c"}, ""},
+		{"downgrade", []string{"https://example.com/a", "http://example.com/b"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := &recordTransport{}
+			transport := &authTransport{base}
+			if _, err := transport.RoundTrip(redirected(t, tt.urls...)); err != nil {
+				t.Fatal(err)
+			}
+			if base.authorization != tt.want {
+				t.Errorf("Authorization = %q, want %q", base.authorization, tt.want)
+			}
+		})
+	}
+}
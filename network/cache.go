@@ -0,0 +1,195 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"bespoke/paths"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cacheFolder follows paths.CachePath, which changes with the workspace
+func cacheFolder() string {
+	return filepath.Join(paths.CachePath, "http")
+}
+
+// CacheTTL is how long a cached response is served without asking the server whether it changed
+var CacheTTL time.Duration
+
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag"`
+	LastModified string    `json:"lastModified"`
+	Fetched      time.Time `json:"fetched"`
+	// Resolved is the URL the request ended up at after following redirects
+	Resolved string `json:"resolved,omitempty"`
+}
+
+func cacheFilePaths(url string) (entryPath string, bodyPath string) {
+	sum := sha256.Sum256([]byte(url))
+	name := filepath.Join(cacheFolder(), hex.EncodeToString(sum[:]))
+	return name + ".json", name + ".body"
+}
+
+func readCache(url string) (cacheEntry, []byte, bool) {
+	entryPath, bodyPath := cacheFilePaths(url)
+	var entry cacheEntry
+	raw, err := os.ReadFile(entryPath)
+	if err != nil || json.Unmarshal(raw, &entry) != nil || entry.URL != url {
+		return entry, nil, false
+	}
+	body, err := os.ReadFile(bodyPath)
+	if err != nil {
+		return entry, nil, false
+	}
+	return entry, body, true
+}
+
+func writeCache(entry cacheEntry, body []byte) error {
+	entryPath, bodyPath := cacheFilePaths(entry.URL)
+	entryJson, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(cacheFolder(), os.ModePerm); err != nil {
+		return err
+	}
+	if body != nil {
+		if err := writeFileAtomic(bodyPath, body); err != nil {
+			return err
+		}
+	}
+	return writeFileAtomic(entryPath, entryJson)
+}
+
+// writeFileAtomic replaces a file in a single rename, so that concurrent requests never read a partial response
+func writeFileAtomic(name string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err := os.Rename(file.Name(), name); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	return nil
+}
+
+// ReadCached returns the cached response to url, and when it was fetched, without sending any request
+func ReadCached(url string) ([]byte, time.Time, bool) {
+	entry, body, cached := readCache(url)
+	return body, entry.Fetched, cached
+}
+
+// GetCached fetches a small document, revalidating the previous response with its ETag or Last-Modified date
+// so that unchanged documents aren't downloaded again (and don't count against GitHub's rate limits)
+func GetCached(url string) ([]byte, error) {
+	body, _, err := getCached(url, false)
+	return body, err
+}
+
+// GetCachedResolved is GetCached that also returns the URL the document was served from after redirects.
+// Documents that aren't cached yet are probed with a HEAD request first, to fail fast on missing documents and HTML pages
+func GetCachedResolved(url string) ([]byte, string, error) {
+	return getCached(url, true)
+}
+
+func getCached(url string, probeFirst bool) ([]byte, string, error) {
+	entry, body, cached := readCache(url)
+	if cached && entry.Resolved == "" {
+		entry.Resolved = url
+	}
+	if cached && (time.Since(entry.Fetched) < CacheTTL || IsOffline()) {
+		return body, entry.Resolved, nil
+	}
+	if !cached && probeFirst {
+		if err := probe(url); err != nil {
+			return nil, "", err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(Context, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if cached {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	res, err := Do(req)
+	if err != nil {
+		if cached && IsOffline() {
+			return body, entry.Resolved, nil
+		}
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	resolved := res.Request.URL.String()
+	if cached && res.StatusCode == http.StatusNotModified {
+		entry.Fetched = time.Now()
+		entry.Resolved = resolved
+		writeCache(entry, nil)
+		return body, resolved, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, "", NewStatusError(res)
+	}
+	if err := checkContentType(res); err != nil {
+		return nil, "", err
+	}
+
+	body, err = io.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	entry = cacheEntry{
+		URL:          url,
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+		Fetched:      time.Now(),
+		Resolved:     resolved,
+	}
+	if entry.ETag != "" || entry.LastModified != "" {
+		writeCache(entry, body)
+	}
+	return body, resolved, nil
+}
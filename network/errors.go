@@ -0,0 +1,134 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"crypto/x509"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// StatusError is returned for responses with an unexpected status
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	// Reset is when the rate limit of the host is lifted, zero when the host doesn't tell
+	Reset time.Time
+	// rateLimited is set for 403 responses telling that the rate limit was exceeded (as GitHub does)
+	rateLimited bool
+}
+
+// NewStatusError describes the unexpected status of res, with the rate limit it hit if any
+func NewStatusError(res *http.Response) *StatusError {
+	err := &StatusError{
+		URL:        res.Request.URL.String(),
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+	}
+	if res.Header.Get("X-RateLimit-Remaining") == "0" {
+		err.rateLimited = true
+		if reset, e := strconv.ParseInt(res.Header.Get("X-RateLimit-Reset"), 10, 64); e == nil {
+			err.Reset = time.Unix(reset, 0)
+		}
+	}
+	if seconds, e := strconv.Atoi(res.Header.Get("Retry-After")); e == nil {
+		err.Reset = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	return err
+}
+
+// RateLimited tells whether the host refused the request because too many were made
+func (e *StatusError) RateLimited() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.rateLimited
+}
+
+func (e *StatusError) Error() string {
+	message := e.URL + " returned " + e.Status
+	host := ""
+	if u, err := url.Parse(e.URL); err == nil {
+		host = u.Hostname()
+	}
+
+	switch {
+	case e.RateLimited():
+		message += ", " + host + " is rate limiting requests"
+		if !e.Reset.IsZero() {
+			message += " until " + e.Reset.Local().Format(time.TimeOnly)
+		}
+		if TokenFor(host) == "" {
+			message += ", authenticated requests get higher limits: bespoke config set tokens." + host + " <token>"
+		}
+	case e.StatusCode == http.StatusUnauthorized, e.StatusCode == http.StatusForbidden:
+		if TokenFor(host) == "" {
+			message += ", set a token if the document is private: bespoke config set tokens." + host + " <token>"
+		} else {
+			message += ", check that the token set for " + host + " is valid and grants access to it"
+		}
+	}
+	return message
+}
+
+// ErrHTML is returned for HTML pages served instead of a document, such as login or error pages
+var ErrHTML = errors.New("an HTML page was served instead of the document")
+
+func checkContentType(res *http.Response) error {
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType == "text/html" {
+		return errors.New(res.Request.URL.String() + ": " + ErrHTML.Error() + ", check the URL")
+	}
+	return nil
+}
+
+// probe fails fast on documents that are missing or served as HTML pages, without downloading them.
+// Hosts that don't support HEAD requests are given the benefit of the doubt
+func probe(url string) error {
+	res, err := Head(url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusMethodNotAllowed, res.StatusCode == http.StatusNotImplemented:
+		return nil
+	case res.StatusCode != http.StatusOK:
+		return NewStatusError(res)
+	}
+	return checkContentType(res)
+}
+
+// describeError tells apart the failures to resolve a host, which usually mean a typo or being offline
+func describeError(err error) error {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return errors.New("can't resolve " + dnsErr.Name + ", check the URL and your connection (" + err.Error() + ")")
+	}
+	var authorityErr x509.UnknownAuthorityError
+	if errors.As(err, &authorityErr) && CACert == "" {
+		return errors.New(err.Error() + ", if a proxy intercepts TLS trust its certificate with --cacert <bundle.pem> (or the cacert setting)")
+	}
+	return err
+}
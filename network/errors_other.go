@@ -0,0 +1,30 @@
+//go:build !windows
+
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import "syscall"
+
+// connectionErrors are the failures to connect or keep a connection that a later attempt may not hit
+var connectionErrors = []error{syscall.ECONNRESET, syscall.ECONNREFUSED}
+
+// networkDownErrors are the failures to connect that come from the machine having no network
+var networkDownErrors = []error{syscall.ENETUNREACH, syscall.ENETDOWN}
@@ -0,0 +1,131 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// MaxRequests bounds the requests in flight at once across all hosts, 0 for no limit
+	MaxRequests = 16
+	// MaxHostRequests bounds the requests in flight at once to a single host, 0 for no limit
+	MaxHostRequests = 4
+	// MaxRetryAfter caps how long requests to a host wait when it answers 429 with a Retry-After header
+	MaxRetryAfter = time.Minute
+)
+
+// limitTransport queues the requests exceeding MaxRequests or MaxHostRequests, and holds back those to a host
+// that asked to retry later. A slot is held until the body of the response is closed or read to the end
+type limitTransport struct {
+	base   http.RoundTripper
+	global chan struct{}
+
+	mu     sync.Mutex
+	hosts  map[string]chan struct{}
+	paused map[string]time.Time
+}
+
+func newLimitTransport(base http.RoundTripper) *limitTransport {
+	t := &limitTransport{base: base, hosts: map[string]chan struct{}{}, paused: map[string]time.Time{}}
+	if MaxRequests > 0 {
+		t.global = make(chan struct{}, MaxRequests)
+	}
+	return t
+}
+
+func (t *limitTransport) host(host string) (chan struct{}, time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	slots, ok := t.hosts[host]
+	if !ok && MaxHostRequests > 0 {
+		slots = make(chan struct{}, MaxHostRequests)
+		t.hosts[host] = slots
+	}
+	return slots, t.paused[host]
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	slots, paused := t.host(host)
+	if wait := time.Until(paused); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+
+	release := func() {}
+	for _, slot := range []chan struct{}{t.global, slots} {
+		if slot == nil {
+			continue
+		}
+		select {
+		case slot <- struct{}{}:
+		case <-req.Context().Done():
+			release()
+			return nil, req.Context().Err()
+		}
+		previous := release
+		release = func() {
+			<-slot
+			previous()
+		}
+	}
+
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	if res.StatusCode == http.StatusTooManyRequests {
+		if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+			t.mu.Lock()
+			t.paused[host] = time.Now().Add(min(time.Duration(seconds)*time.Second, MaxRetryAfter))
+			t.mu.Unlock()
+		}
+	}
+	res.Body = &releasingBody{ReadCloser: res.Body, release: sync.OnceFunc(release)}
+	return res, nil
+}
+
+// releasingBody gives the slot of a request back once its response is consumed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.release()
+	}
+	return n, err
+}
+
+func (b *releasingBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,168 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"bespoke/trace"
+	"bytes"
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ConnectTimeout = 10 * time.Second
+	ReadTimeout    = 30 * time.Second
+	Timeout        = 5 * time.Minute
+	Retries        = 3
+	RetryBaseDelay = 500 * time.Millisecond
+)
+
+var Client = &http.Client{}
+
+// Failures counts the requests that failed or were answered with an error status since the process started
+var Failures atomic.Int64
+
+// Context cancels the requests in flight when it is done, the CLI cancels it on Ctrl-C
+var Context = context.Background()
+
+func init() {
+	Configure()
+}
+
+// Configure applies the current timeouts, proxy and TLS settings to the shared client
+func Configure() error {
+	proxy, err := proxyFunc()
+	if err != nil {
+		return err
+	}
+	tlsConfig, err := tlsConfig()
+	if err != nil {
+		return err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   ConnectTimeout,
+		ResponseHeaderTimeout: ReadTimeout,
+		IdleConnTimeout:       90 * time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+	Client.Transport = &authTransport{newLimitTransport(transport)}
+	Client.Timeout = Timeout
+	anonymousClient.Transport = transport.Clone()
+	return nil
+}
+
+func Get(url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(Context, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return Do(req)
+}
+
+func Head(url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(Context, http.MethodHead, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return Do(req)
+}
+
+// anonymousClient skips authTransport and retries, so that pings never carry credentials
+var anonymousClient = &http.Client{Timeout: 5 * time.Second}
+
+// Ping posts a JSON body without credentials or cookies, it is best-effort and not retried
+func Ping(url string, body []byte) error {
+	if IsOffline() {
+		return ErrOffline
+	}
+	res, err := anonymousClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return errors.New("unexpected status " + res.Status)
+	}
+	return nil
+}
+
+// Do sends a body-less request with the shared client, retrying transient failures with jittered exponential backoff
+func Do(req *http.Request) (*http.Response, error) {
+	defer trace.Start("http", req.Method+" "+req.URL.String()).Finish()
+	if IsOffline() {
+		return nil, ErrOffline
+	}
+	var res *http.Response
+	var err error
+	for attempt := 0; ; attempt++ {
+		res, err = Client.Do(req)
+		if err != nil && markUnreachable(req.URL.Hostname(), err) {
+			Failures.Add(1)
+			return nil, ErrOffline
+		}
+		if attempt >= Retries || req.Context().Err() != nil || !isTransient(res, err) {
+			if err != nil || res.StatusCode >= 400 {
+				Failures.Add(1)
+			}
+			return res, describeError(err)
+		}
+		if res != nil {
+			res.Body.Close()
+		}
+		select {
+		case <-time.After(backoff(attempt)):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+func backoff(attempt int) time.Duration {
+	delay := RetryBaseDelay << attempt
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// isTransient tells whether a request may succeed when retried: it timed out, its connection was reset or refused,
+// or the server failed or asked to slow down. Other errors, such as unknown hosts or invalid certificates, would
+// fail again
+func isTransient(res *http.Response, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return true
+		}
+		return slices.ContainsFunc(connectionErrors, func(target error) bool { return errors.Is(err, target) })
+	}
+	return res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+}
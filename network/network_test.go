@@ -0,0 +1,67 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsTransient(t *testing.T) {
+	opError := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", err)}}
+	}
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		want   bool
+	}{
+		{"timeout", 0, &url.Error{Op: "Get", URL: "https://example.com", Err: timeoutError{}}, true},
+		{"refused", 0, opError(connectionErrors[1]), true},
+		{"reset", 0, opError(connectionErrors[0]), true},
+		{"unknown host", 0, &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}, false},
+		{"invalid certificate", 0, &url.Error{Op: "Get", URL: "https://example.com", Err: x509.UnknownAuthorityError{}}, false},
+		{"other error", 0, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("unsupported")}, false},
+		{"server error", http.StatusBadGateway, nil, true},
+		{"rate limited", http.StatusTooManyRequests, nil, true},
+		{"not found", http.StatusNotFound, nil, false},
+	}
+	for _, test := range tests {
+		var res *http.Response
+		if test.err == nil {
+			res = &http.Response{StatusCode: test.status}
+		}
+		if got := isTransient(res, test.err); got != test.want {
+			t.Errorf("%s: isTransient() = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
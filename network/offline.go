@@ -0,0 +1,106 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ErrOffline is returned instead of sending requests while offline
+var ErrOffline = errors.New("offline")
+
+// Offline makes every request fail right away with ErrOffline, cached documents are served however old they are
+var Offline bool
+
+// DetectOffline switches to offline mode for OfflineRetry when a request finds the network unreachable,
+// so that the following requests fail fast instead of waiting for their timeouts
+var DetectOffline = true
+
+var OfflineRetry = time.Minute
+
+// OnOffline is called when the network is found unreachable
+var OnOffline = func() {}
+
+// unreachableAt is when the network was last found unreachable, in Unix nanoseconds
+var unreachableAt atomic.Int64
+
+// unreachableHost is the last host that couldn't be reached, at unreachableHostAt
+var (
+	unreachableMu     sync.Mutex
+	unreachableHost   string
+	unreachableHostAt time.Time
+)
+
+// IsOffline reports whether requests are currently skipped
+func IsOffline() bool {
+	if Offline {
+		return true
+	}
+	at := unreachableAt.Load()
+	return at != 0 && time.Since(time.Unix(0, at)) < OfflineRetry
+}
+
+// markUnreachable switches to offline mode after a request failed because the machine has no network, or after
+// requests failed to reach two different hosts
+func markUnreachable(host string, err error) bool {
+	if !DetectOffline {
+		return false
+	}
+	if !isNetworkDown(err) && !(isUnreachable(err) && unreachableElsewhere(host)) {
+		return false
+	}
+	if !IsOffline() {
+		unreachableAt.Store(time.Now().UnixNano())
+		OnOffline()
+	}
+	return true
+}
+
+// isNetworkDown tells whether err comes from the machine having no network at all, rather than from a single host
+func isNetworkDown(err error) bool {
+	return slices.ContainsFunc(networkDownErrors, func(target error) bool { return errors.Is(err, target) })
+}
+
+// unreachableElsewhere records that host couldn't be reached, and tells whether another host couldn't be either
+// within OfflineRetry: a single server being down doesn't mean the network is
+func unreachableElsewhere(host string) bool {
+	unreachableMu.Lock()
+	defer unreachableMu.Unlock()
+	elsewhere := unreachableHost != "" && unreachableHost != host && time.Since(unreachableHostAt) < OfflineRetry
+	unreachableHost, unreachableHostAt = host, time.Now()
+	return elsewhere
+}
+
+// isUnreachable tells the failures to reach a host, which may be down or the network with it, from the others.
+// Unknown hosts are usually typos and refused connections come from a reachable host
+func isUnreachable(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return !dnsErr.IsNotFound
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial" && !errors.Is(err, syscall.ECONNREFUSED)
+}
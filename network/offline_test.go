@@ -0,0 +1,56 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"net"
+	"os"
+	"testing"
+)
+
+func TestMarkUnreachable(t *testing.T) {
+	t.Cleanup(func() {
+		unreachableAt.Store(0)
+		unreachableHost = ""
+	})
+	dialError := func(err error) error {
+		return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", err)}
+	}
+	timeout := &net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}
+
+	if markUnreachable("github.com", timeout) || IsOffline() {
+		t.Fatal("a single host timing out switched to offline mode")
+	}
+	if markUnreachable("github.com", timeout) || IsOffline() {
+		t.Fatal("the same host timing out again switched to offline mode")
+	}
+	if markUnreachable("example.com", dialError(connectionErrors[1])) {
+		t.Fatal("a refused connection counted as unreachable")
+	}
+	if !markUnreachable("example.com", timeout) || !IsOffline() {
+		t.Fatal("two hosts timing out didn't switch to offline mode")
+	}
+
+	unreachableAt.Store(0)
+	unreachableHost = ""
+	if !markUnreachable("github.com", dialError(networkDownErrors[0])) || !IsOffline() {
+		t.Fatal("having no network didn't switch to offline mode")
+	}
+}
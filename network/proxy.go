@@ -0,0 +1,102 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+var (
+	// Proxy is used for every request when set (e.g. http://proxy.corp:3128). Otherwise HTTP_PROXY, HTTPS_PROXY and
+	// NO_PROXY apply, then the proxy of the system settings on Windows and macOS
+	Proxy string
+	// CACert is a PEM bundle trusted along with the system roots, for proxies that intercept TLS
+	CACert string
+	// Insecure skips the verification of TLS certificates. It exposes every download to tampering and is only
+	// meant to get past a broken certificate chain until CACert is set up
+	Insecure bool
+)
+
+// proxyFunc picks the proxy of each request, see Proxy
+func proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	config := httpproxy.FromEnvironment()
+	if Proxy != "" {
+		if _, err := url.Parse(Proxy); err != nil {
+			return nil, errors.New("invalid proxy " + Proxy + ": " + err.Error())
+		}
+		config.HTTPProxy = Proxy
+		config.HTTPSProxy = Proxy
+	} else if config.HTTPProxy == "" && config.HTTPSProxy == "" {
+		config.HTTPProxy, config.HTTPSProxy, config.NoProxy = systemProxy()
+	}
+
+	proxy := config.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}, nil
+}
+
+// tlsConfig adds CACert to the system roots
+func tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: Insecure}
+	if CACert == "" {
+		return config, nil
+	}
+
+	pem, err := os.ReadFile(CACert)
+	if err != nil {
+		return nil, errors.New("can't read the CA bundle: " + err.Error())
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no PEM certificate found in " + CACert)
+	}
+	config.RootCAs = roots
+	return config, nil
+}
+
+// GitEnv passes the proxy and TLS settings on to git, which doesn't go through the shared client
+func GitEnv() []string {
+	env := os.Environ()
+	if Proxy != "" {
+		env = append(env, "HTTP_PROXY="+Proxy, "HTTPS_PROXY="+Proxy, "http_proxy="+Proxy, "https_proxy="+Proxy)
+	} else if config := httpproxy.FromEnvironment(); config.HTTPProxy == "" && config.HTTPSProxy == "" {
+		if httpProxy, httpsProxy, noProxy := systemProxy(); httpProxy != "" || httpsProxy != "" {
+			env = append(env, "http_proxy="+httpProxy, "https_proxy="+httpsProxy, "no_proxy="+noProxy)
+		}
+	}
+	if CACert != "" {
+		env = append(env, "GIT_SSL_CAINFO="+CACert)
+	}
+	if Insecure {
+		env = append(env, "GIT_SSL_NO_VERIFY=true")
+	}
+	return env
+}
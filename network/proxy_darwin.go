@@ -0,0 +1,74 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"strings"
+)
+
+// systemProxy reads the proxy of the network settings as printed by scutil:
+//
+//	<dictionary> {
+//	  ExceptionsList : <array> {
+//	    0 : *.local
+//	  }
+//	  HTTPEnable : 1
+//	  HTTPPort : 3128
+//	  HTTPProxy : proxy.corp
+//	}
+func systemProxy() (httpProxy string, httpsProxy string, noProxy string) {
+	out, err := exec.Command("scutil", "--proxy").Output()
+	if err != nil {
+		return "", "", ""
+	}
+
+	settings := map[string]string{}
+	bypass := []string{}
+	inExceptions := false
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		key, value, ok := strings.Cut(line, " : ")
+		switch {
+		case key == "ExceptionsList":
+			inExceptions = true
+		case line == "}":
+			inExceptions = false
+		case ok && inExceptions:
+			bypass = append(bypass, strings.TrimPrefix(value, "*"))
+		case ok:
+			settings[key] = value
+		}
+	}
+
+	address := func(protocol string) string {
+		if settings[protocol+"Enable"] != "1" || settings[protocol+"Proxy"] == "" {
+			return ""
+		}
+		if port := settings[protocol+"Port"]; port != "" {
+			return settings[protocol+"Proxy"] + ":" + port
+		}
+		return settings[protocol+"Proxy"]
+	}
+	return address("HTTP"), address("HTTPS"), strings.Join(bypass, ",")
+}
@@ -0,0 +1,70 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package network
+
+import (
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// systemProxy reads the proxy of the Internet Options, which is either a single host:port or a list of
+// protocol=host:port entries
+func systemProxy() (httpProxy string, httpsProxy string, noProxy string) {
+	key, err := registry.OpenKey(registry.CURRENT_USER, `Software\Microsoft\Windows\CurrentVersion\Internet Settings`, registry.QUERY_VALUE)
+	if err != nil {
+		return "", "", ""
+	}
+	defer key.Close()
+
+	if enabled, _, err := key.GetIntegerValue("ProxyEnable"); err != nil || enabled == 0 {
+		return "", "", ""
+	}
+	server, _, err := key.GetStringValue("ProxyServer")
+	if err != nil || server == "" {
+		return "", "", ""
+	}
+
+	if !strings.Contains(server, "=") {
+		httpProxy, httpsProxy = server, server
+	}
+	for _, entry := range strings.Split(server, ";") {
+		protocol, address, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(protocol) {
+		case "http":
+			httpProxy = address
+		case "https":
+			httpsProxy = address
+		}
+	}
+
+	// <local> bypasses the host names without a dot
+	override, _, _ := key.GetStringValue("ProxyOverride")
+	bypass := []string{}
+	for _, entry := range strings.Split(override, ";") {
+		if entry = strings.TrimSpace(entry); entry != "" && entry != "<local>" {
+			bypass = append(bypass, strings.TrimPrefix(entry, "*"))
+		}
+	}
+	return httpProxy, httpsProxy, strings.Join(bypass, ",")
+}
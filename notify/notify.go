@@ -0,0 +1,48 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package notify
+
+import "os/exec"
+
+// Enabled toggles OS notifications for background operations
+var Enabled = true
+
+func Notify(title string, message string) error {
+	if !Enabled {
+		return nil
+	}
+	return send(title, message)
+}
+
+// Ask shows a yes/no dialog defaulting to no, it is shown even when notifications are disabled
+func Ask(title string, question string) (bool, error) {
+	return ask(title, question)
+}
+
+// answered reads the exit status of a dialog: closing or cancelling it answers no
+func answered(err error) (bool, error) {
+	if err == nil {
+		return true, nil
+	}
+	if _, ok := err.(*exec.ExitError); ok {
+		return false, nil
+	}
+	return false, err
+}
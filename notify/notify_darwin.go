@@ -0,0 +1,38 @@
+//go:build darwin
+
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package notify
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+func send(title string, message string) error {
+	script := "display notification " + strconv.Quote(message) + " with title " + strconv.Quote(title)
+	return exec.Command("osascript", "-e", script).Run()
+}
+
+func ask(title string, question string) (bool, error) {
+	script := "display dialog " + strconv.Quote(question) + " with title " + strconv.Quote(title) +
+		` buttons {"Cancel", "OK"} default button "Cancel" cancel button "Cancel" with icon caution`
+	return answered(exec.Command("osascript", "-e", script).Run())
+}
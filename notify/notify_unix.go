@@ -0,0 +1,41 @@
+//go:build unix && !darwin
+
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package notify
+
+import (
+	"errors"
+	"os/exec"
+)
+
+func send(title string, message string) error {
+	return exec.Command("notify-send", "--app-name=bespoke", title, message).Run()
+}
+
+func ask(title string, question string) (bool, error) {
+	if _, err := exec.LookPath("zenity"); err == nil {
+		return answered(exec.Command("zenity", "--question", "--default-cancel", "--title="+title, "--text="+question).Run())
+	}
+	if _, err := exec.LookPath("kdialog"); err == nil {
+		return answered(exec.Command("kdialog", "--title", title, "--warningyesno", question).Run())
+	}
+	return false, errors.New("neither zenity nor kdialog is installed")
+}
@@ -0,0 +1,61 @@
+//go:build windows
+
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package notify
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// powershell runs script with title and text in the environment, as $env:BESPOKE_TITLE and $env:BESPOKE_TEXT.
+// They are never spliced into the script, whose quotes PowerShell also ends at typographic quotes
+func powershell(script string, title string, text string) *exec.Cmd {
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-WindowStyle", "Hidden", "-Command", script)
+	// Environment variables end at the first NUL
+	cmd.Env = append(os.Environ(),
+		"BESPOKE_TITLE="+strings.ReplaceAll(title, "\x00", ""),
+		"BESPOKE_TEXT="+strings.ReplaceAll(text, "\x00", ""),
+	)
+	return cmd
+}
+
+func send(title string, message string) error {
+	script := `Add-Type -AssemblyName System.Windows.Forms;` +
+		`$n = New-Object System.Windows.Forms.NotifyIcon;` +
+		`$n.Icon = [System.Drawing.SystemIcons]::Information;` +
+		`$n.Visible = $true;` +
+		`$n.ShowBalloonTip(5000, $env:BESPOKE_TITLE, $env:BESPOKE_TEXT, 'Info');` +
+		`Start-Sleep -Seconds 6;` +
+		`$n.Dispose()`
+	return powershell(script, title, message).Start()
+}
+
+func ask(title string, question string) (bool, error) {
+	script := `Add-Type -AssemblyName System.Windows.Forms;` +
+		`[System.Windows.Forms.MessageBox]::Show($env:BESPOKE_TEXT, $env:BESPOKE_TITLE, 'YesNo', 'Warning', 'Button2')`
+	out, err := powershell(script, title, question).Output()
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(string(out)) == "Yes", nil
+}
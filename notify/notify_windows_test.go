@@ -0,0 +1,39 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package notify
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPowershellKeepsTextOutOfScript(t *testing.T) {
+	hostile := "a/b’; Remove-Item -Recurse ~; ‘"
+	cmd := powershell("Write-Output $env:BESPOKE_TEXT", "bespoke", hostile)
+	for _, arg := range cmd.Args {
+		if strings.Contains(arg, "Remove-Item") {
+			t.Errorf("the text was spliced into the command line: %q", arg)
+		}
+	}
+	if !slices.Contains(cmd.Env, "BESPOKE_TEXT="+hostile) {
+		t.Errorf("the text isn't passed in the environment")
+	}
+}
@@ -20,17 +20,75 @@
 package paths
 
 import (
+	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/adrg/xdg"
 )
 
+// SandboxPath (BESPOKE_SANDBOX) holds every folder bespoke uses, Spotify's included, to try commands
+// or run tests without touching the real installs
+var SandboxPath = sandboxPath()
+
+// Each folder can be relocated with an environment variable, for packagers and test isolation
 var (
-	ConfigPath = filepath.Join(xdg.ConfigHome, "bespoke")
+	ConfigPath = envPath("BESPOKE_CONFIG", sandboxed("config", filepath.Join(xdg.ConfigHome, "bespoke")))
+	CachePath  = envPath("BESPOKE_CACHE", sandboxed("cache", filepath.Join(xdg.CacheHome, "bespoke")))
+	StatePath  = envPath("BESPOKE_STATE", sandboxed("state", filepath.Join(xdg.StateHome, "bespoke")))
+	LogPath    = envPath("BESPOKE_LOG", sandboxed("log", GetPlatformLogPath()))
+	// SystemPath is shared by the users of the machine, for modules installed with --scope system
+	SystemPath = envPath("BESPOKE_SYSTEM", sandboxed("system", GetPlatformSystemPath()))
+)
+
+// SystemPolicyPath is the policy admins deploy on managed machines. Unlike the other folders, neither the
+// environment, the sandbox nor the config relocate it, users can't opt out of it
+var SystemPolicyPath = GetPlatformPolicyPath()
+
+func sandboxPath() string {
+	path := envPath("BESPOKE_SANDBOX", "")
+	if path == "" {
+		return ""
+	}
+	// Links into the store need absolute targets
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+func sandboxed(name string, fallback string) string {
+	if SandboxPath == "" {
+		return fallback
+	}
+	return filepath.Join(SandboxPath, name)
+}
+
+func envPath(key string, fallback string) string {
+	if path := os.Getenv(key); path != "" {
+		return filepath.Clean(path)
+	}
+	return fallback
+}
+
+// Finding Spotify stats every known install location, it's only done once and only when a command needs it
+var (
+	platformSpotifyPath       = sync.OnceValue(GetPlatformDefaultSpotifyPath)
+	platformSpotifyConfigPath = sync.OnceValue(GetPlatformSpotifyConfigPath)
 )
 
 func GetSpotifyPath() string {
-	return GetPlatformDefaultSpotifyPath()
+	if SandboxPath != "" {
+		return sandboxed("spotify", "")
+	}
+	return platformSpotifyPath()
+}
+
+func GetSpotifyConfigPath() string {
+	if SandboxPath != "" {
+		return sandboxed("spotify-config", "")
+	}
+	return platformSpotifyConfigPath()
 }
 
 func GetSpotifyAppsPath(spotifyPath string) string {
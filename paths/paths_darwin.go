@@ -23,12 +23,35 @@ package paths
 
 import (
 	"path/filepath"
+
+	"github.com/adrg/xdg"
 )
 
 func GetPlatformDefaultSpotifyPath() string {
 	return "/Applications/Spotify.app/Contents/Resources"
 }
 
+func GetPlatformLogPath() string {
+	return filepath.Join(xdg.Home, "Library", "Logs", "bespoke")
+}
+
+func GetPlatformSystemPath() string {
+	return "/Library/Application Support/bespoke"
+}
+
+func GetPlatformPolicyPath() string {
+	return "/Library/Application Support/bespoke/policy.json"
+}
+
 func GetSpotifyExecPath(spotifyPath string) string {
 	return filepath.Join(spotifyPath, "spotify.exe")
 }
+
+func GetPlatformSpotifyConfigPath() string {
+	return filepath.Join(xdg.ConfigHome, "Spotify")
+}
+
+// GetSpicetifyConfigPath is where spicetify keeps its config, extensions and themes
+func GetSpicetifyConfigPath() string {
+	return filepath.Join(xdg.Home, ".config", "spicetify")
+}
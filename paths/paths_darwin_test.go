@@ -0,0 +1,49 @@
+//go:build darwin
+
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package paths
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/adrg/xdg"
+)
+
+func TestPlatformPaths(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"spotify", GetPlatformDefaultSpotifyPath(), "/Applications/Spotify.app/Contents/Resources"},
+		{"log", GetPlatformLogPath(), filepath.Join(xdg.Home, "Library", "Logs", "bespoke")},
+		{"system", GetPlatformSystemPath(), "/Library/Application Support/bespoke"},
+		{"policy", GetPlatformPolicyPath(), "/Library/Application Support/bespoke/policy.json"},
+		{"spotify config", GetPlatformSpotifyConfigPath(), filepath.Join(xdg.ConfigHome, "Spotify")},
+		{"spicetify", GetSpicetifyConfigPath(), filepath.Join(xdg.Home, ".config", "spicetify")},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
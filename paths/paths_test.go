@@ -0,0 +1,172 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package paths
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvPath(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"unset", "", "fallback"},
+		{"set", filepath.Join("custom", "config"), filepath.Join("custom", "config")},
+		{"cleaned", filepath.Join("custom", "..", "config") + string(filepath.Separator), "config"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("BESPOKE_TEST_PATH", tt.value)
+			if got := envPath("BESPOKE_TEST_PATH", "fallback"); got != tt.want {
+				t.Errorf("envPath = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSandboxed(t *testing.T) {
+	previous := SandboxPath
+	t.Cleanup(func() { SandboxPath = previous })
+
+	SandboxPath = ""
+	if got := sandboxed("config", "fallback"); got != "fallback" {
+		t.Errorf("sandboxed without a sandbox = %q, want the fallback", got)
+	}
+	SandboxPath = filepath.Join(os.TempDir(), "sandbox")
+	if got, want := sandboxed("config", "fallback"), filepath.Join(SandboxPath, "config"); got != want {
+		t.Errorf("sandboxed = %q, want %q", got, want)
+	}
+	if got, want := GetSpotifyPath(), filepath.Join(SandboxPath, "spotify"); got != want {
+		t.Errorf("GetSpotifyPath = %q, want %q", got, want)
+	}
+}
+
+// TestHelperPaths prints the folders resolved at startup, it is run by TestOverrides in a process of its own
+// since the folders are resolved once, when the package is initialized
+func TestHelperPaths(t *testing.T) {
+	if os.Getenv("BESPOKE_TEST_HELPER") != "1" {
+		t.Skip("only run by TestOverrides")
+	}
+	os.Stdout.WriteString(strings.Join([]string{ConfigPath, CachePath, StatePath, LogPath, SystemPath}, "\n") + "\n")
+}
+
+func TestOverrides(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		env  map[string]string
+		// want maps the index of the folder in the helper output to its expected value
+		want map[int]string
+	}{
+		{"config", map[string]string{"BESPOKE_CONFIG": filepath.Join(dir, "c")}, map[int]string{0: filepath.Join(dir, "c")}},
+		{"cache", map[string]string{"BESPOKE_CACHE": filepath.Join(dir, "k")}, map[int]string{1: filepath.Join(dir, "k")}},
+		{"sandbox", map[string]string{"BESPOKE_SANDBOX": dir}, map[int]string{
+			0: filepath.Join(dir, "config"), 1: filepath.Join(dir, "cache"), 2: filepath.Join(dir, "state"),
+			3: filepath.Join(dir, "log"), 4: filepath.Join(dir, "system"),
+		}},
+		{"override wins over the sandbox", map[string]string{"BESPOKE_SANDBOX": dir, "BESPOKE_CONFIG": filepath.Join(dir, "c")}, map[int]string{
+			0: filepath.Join(dir, "c"), 1: filepath.Join(dir, "cache"),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestHelperPaths$")
+			env := []string{"BESPOKE_TEST_HELPER=1"}
+			for _, kv := range os.Environ() {
+				if !strings.HasPrefix(kv, "BESPOKE_") {
+					env = append(env, kv)
+				}
+			}
+			for key, value := range tt.env {
+				env = append(env, key+"="+value)
+			}
+			cmd.Env = env
+			out, err := cmd.Output()
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(string(out), "\n")
+			if len(lines) < 5 {
+				t.Fatalf("unexpected helper output %q", out)
+			}
+			for i, want := range tt.want {
+				if lines[i] != want {
+					t.Errorf("folder %d = %q, want %q", i, lines[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestDetectSandbox(t *testing.T) {
+	tests := []struct {
+		path string
+		want Sandbox
+	}{
+		{"/opt/spotify/", SandboxNone},
+		{"/var/lib/flatpak/app/" + FlatpakAppID + "/current/active/files/extra/share/spotify", SandboxFlatpak},
+		{"/var/lib/flatpak/app/org.other.App/current", SandboxNone},
+		{"/snap/spotify/current/usr/share/spotify", SandboxSnap},
+	}
+	for _, tt := range tests {
+		if got := DetectSandbox(tt.path); got != tt.want {
+			t.Errorf("DetectSandbox(%q) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestUseWorkspace(t *testing.T) {
+	base := t.TempDir()
+	previous := []string{BaseConfigPath, BaseCachePath, BaseStatePath, BaseLogPath, ConfigPath, CachePath, StatePath, LogPath, Workspace}
+	t.Cleanup(func() {
+		BaseConfigPath, BaseCachePath, BaseStatePath, BaseLogPath = previous[0], previous[1], previous[2], previous[3]
+		ConfigPath, CachePath, StatePath, LogPath, Workspace = previous[4], previous[5], previous[6], previous[7], previous[8]
+	})
+	BaseConfigPath, BaseCachePath = filepath.Join(base, "config"), filepath.Join(base, "cache")
+	BaseStatePath, BaseLogPath = filepath.Join(base, "state"), filepath.Join(base, "log")
+
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"", BaseConfigPath, false},
+		{DefaultWorkspace, BaseConfigPath, false},
+		{"work", filepath.Join(BaseConfigPath, "workspaces", "work"), false},
+		{"../escape", "", true},
+		{".hidden", "", true},
+	}
+	for _, tt := range tests {
+		err := UseWorkspace(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("UseWorkspace(%q) = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && ConfigPath != tt.want {
+			t.Errorf("UseWorkspace(%q): ConfigPath = %q, want %q", tt.name, ConfigPath, tt.want)
+		}
+	}
+}
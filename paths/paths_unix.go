@@ -1,4 +1,4 @@
-//go:build unix
+//go:build unix && !darwin
 
 /* Copyright © 2024
  *      Delusoire <deluso7re@outlook.com>
@@ -22,13 +22,54 @@
 package paths
 
 import (
+	"os"
 	"path/filepath"
+
+	"github.com/adrg/xdg"
 )
 
 func GetPlatformDefaultSpotifyPath() string {
-	return "/opt/spotify/" // aur
+	candidates := []string{
+		"/opt/spotify/", // aur
+		filepath.Join(xdg.DataHome, "flatpak/app", FlatpakAppID, "current/active/files/extra/share/spotify"),
+		filepath.Join("/var/lib/flatpak/app", FlatpakAppID, "current/active/files/extra/share/spotify"),
+		"/snap/spotify/current/usr/share/spotify",
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return candidates[0]
+}
+
+func GetPlatformLogPath() string {
+	return filepath.Join(xdg.StateHome, "bespoke", "logs")
+}
+
+func GetPlatformSystemPath() string {
+	return "/usr/local/share/bespoke"
+}
+
+func GetPlatformPolicyPath() string {
+	return "/etc/bespoke/policy.json"
 }
 
 func GetSpotifyExecPath(spotifyPath string) string {
 	return filepath.Join(spotifyPath, "spotify")
 }
+
+func GetPlatformSpotifyConfigPath() string {
+	switch DetectSandbox(platformSpotifyPath()) {
+	case SandboxFlatpak:
+		return filepath.Join(xdg.Home, ".var/app", FlatpakAppID, "config/spotify")
+	case SandboxSnap:
+		return filepath.Join(xdg.Home, "snap/spotify/current/.config/spotify")
+	}
+	return filepath.Join(xdg.ConfigHome, "spotify")
+}
+
+// GetSpicetifyConfigPath is where spicetify keeps its config, extensions and themes
+func GetSpicetifyConfigPath() string {
+	return filepath.Join(xdg.ConfigHome, "spicetify")
+}
@@ -22,19 +22,47 @@
 package paths
 
 import (
+	"os"
 	"path/filepath"
 
 	"github.com/adrg/xdg"
+	"golang.org/x/sys/windows"
 )
 
 func GetPlatformDefaultSpotifyPath() string {
 	return filepath.Join(xdg.DataDirs[0], "Spotify")
 }
 
+func GetPlatformLogPath() string {
+	return filepath.Join(xdg.StateHome, "bespoke", "logs")
+}
+
+func GetPlatformSystemPath() string {
+	programData := os.Getenv("ProgramData")
+	if programData == "" {
+		programData = `C:\ProgramData`
+	}
+	return filepath.Join(programData, "bespoke")
+}
+
+// GetPlatformPolicyPath asks Windows for ProgramData rather than reading the environment, which users control
+func GetPlatformPolicyPath() string {
+	programData, err := windows.KnownFolderPath(windows.FOLDERID_ProgramData, 0)
+	if err != nil {
+		programData = `C:\ProgramData`
+	}
+	return filepath.Join(programData, "bespoke", "policy.json")
+}
+
 func GetSpotifyExecPath(spotifyPath string) string {
 	return filepath.Join(spotifyPath, "spotify.exe")
 }
 
-func GetSpotifyConfigPath() string {
+func GetPlatformSpotifyConfigPath() string {
 	return filepath.Join(xdg.ConfigHome, "Spotify")
 }
+
+// GetSpicetifyConfigPath is where spicetify keeps its config, extensions and themes
+func GetSpicetifyConfigPath() string {
+	return filepath.Join(xdg.DataDirs[0], "spicetify")
+}
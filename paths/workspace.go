@@ -0,0 +1,112 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package paths
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// DefaultWorkspace lives directly in the base folders, where bespoke kept its files before workspaces
+const DefaultWorkspace = "default"
+
+var (
+	BaseConfigPath = ConfigPath
+	BaseCachePath  = CachePath
+	BaseStatePath  = StatePath
+	BaseLogPath    = LogPath
+	Workspace      = DefaultWorkspace
+)
+
+var workspaceNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+func ValidateWorkspace(name string) error {
+	if !workspaceNameRe.MatchString(name) {
+		return fmt.Errorf("invalid workspace name %q: use letters, digits, dots, dashes and underscores", name)
+	}
+	return nil
+}
+
+const workspacesFolderName = "workspaces"
+
+func workspacesFolder(base string) string {
+	return filepath.Join(base, workspacesFolderName)
+}
+
+func workspacePath(base string, name string) string {
+	if name == DefaultWorkspace {
+		return base
+	}
+	return filepath.Join(workspacesFolder(base), name)
+}
+
+func WorkspaceConfigPath(name string) string {
+	return workspacePath(BaseConfigPath, name)
+}
+
+// WorkspacePaths lists every folder of the named workspace
+func WorkspacePaths(name string) []string {
+	return []string{
+		workspacePath(BaseConfigPath, name),
+		workspacePath(BaseCachePath, name),
+		workspacePath(BaseStatePath, name),
+		workspacePath(BaseLogPath, name),
+	}
+}
+
+// UseWorkspace points ConfigPath, CachePath, StatePath and LogPath to the folders of the named workspace
+func UseWorkspace(name string) error {
+	if name == "" {
+		name = DefaultWorkspace
+	}
+	if err := ValidateWorkspace(name); err != nil {
+		return err
+	}
+	Workspace = name
+	ConfigPath = workspacePath(BaseConfigPath, name)
+	CachePath = workspacePath(BaseCachePath, name)
+	StatePath = workspacePath(BaseStatePath, name)
+	LogPath = workspacePath(BaseLogPath, name)
+	return nil
+}
+
+func ListWorkspaces() ([]string, error) {
+	workspaces := []string{DefaultWorkspace}
+	entries, err := os.ReadDir(workspacesFolder(BaseConfigPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return workspaces, nil
+		}
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != DefaultWorkspace && ValidateWorkspace(entry.Name()) == nil {
+			workspaces = append(workspaces, entry.Name())
+		}
+	}
+	return workspaces, nil
+}
+
+// IsWorkspacesFolder reports whether name is the child of the default workspace folders holding the other workspaces
+func IsWorkspacesFolder(name string) bool {
+	return Workspace == DefaultWorkspace && name == workspacesFolderName
+}
@@ -0,0 +1,432 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package query evaluates filter expressions such as `enabled == true && tags contains "theme"` against records
+// decoded from JSON. Bare words are field paths (dotted for nested objects), strings are double-quoted
+package query
+
+import (
+	"bespoke/version"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type Record = map[string]any
+
+type Expr interface {
+	eval(r Record) any
+}
+
+// Match reports whether the record satisfies the expression
+func Match(e Expr, r Record) bool {
+	return truthy(e.eval(r))
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokString
+	tokNumber
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var operators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!"}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-.", r)
+}
+
+func tokenize(expr string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokLParen, "(", i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokRParen, ")", i})
+			i++
+		case r == '"':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) {
+					j++
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, errors.New("unterminated string at position " + strconv.Itoa(i+1))
+			}
+			tokens = append(tokens, token{tokString, sb.String(), i})
+			i = j + 1
+		case isWordRune(r):
+			j := i
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+			text := string(runes[i:j])
+			kind := tokWord
+			if _, err := strconv.ParseFloat(text, 64); err == nil {
+				kind = tokNumber
+			}
+			tokens = append(tokens, token{kind, text, i})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(string(runes[i:]), candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, errors.New("unexpected " + strconv.QuoteRune(r) + " at position " + strconv.Itoa(i+1))
+			}
+			tokens = append(tokens, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{tokEOF, "", len(runes)}), nil
+}
+
+type parser struct {
+	tokens []token
+	i      int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) errorAt(t token, message string) error {
+	if t.kind == tokEOF {
+		return errors.New(message + " at the end of the filter")
+	}
+	return errors.New(message + " at position " + strconv.Itoa(t.pos+1) + " (" + t.text + ")")
+}
+
+// Parse compiles a filter expression. Comparisons are ==, !=, <, <=, >, >= and contains (for lists and strings),
+// combined with &&, || and !, and grouped with parentheses. A field on its own tests that it is set and not false
+func Parse(expr string) (Expr, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorAt(t, "unexpected token")
+	}
+	return e, nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp && p.peek().text == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = or{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp && p.peek().text == "&&" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = and{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if t := p.peek(); t.kind == tokOp && t.text == "!" {
+		p.next()
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return not{e}, nil
+	}
+	if p.peek().kind == tokLParen {
+		p.next()
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != tokRParen {
+			return nil, p.errorAt(t, "expected )")
+		}
+		return e, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Expr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	isComparison := t.kind == tokOp && t.text != "&&" && t.text != "||" && t.text != "!"
+	if !isComparison && !(t.kind == tokWord && t.text == "contains") {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return comparison{t.text, left, right}, nil
+}
+
+func (p *parser) parseOperand() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return literal{value: t.text}, nil
+	case tokNumber:
+		n, _ := strconv.ParseFloat(t.text, 64)
+		return literal{n, t.text}, nil
+	case tokWord:
+		switch t.text {
+		case "true":
+			return literal{value: true}, nil
+		case "false":
+			return literal{value: false}, nil
+		case "null":
+			return literal{value: nil}, nil
+		case "contains":
+			return nil, p.errorAt(t, "expected a field or a value")
+		}
+		// Fields can't start with a digit, so that versions don't need quotes
+		if unicode.IsDigit([]rune(t.text)[0]) {
+			return literal{value: t.text}, nil
+		}
+		return field(strings.Split(t.text, ".")), nil
+	}
+	return nil, p.errorAt(t, "expected a field or a value")
+}
+
+// literal is a value of the filter, numbers keep their text so that they can be compared as versions (1.10)
+type literal struct {
+	value any
+	text  string
+}
+type field []string
+type not struct{ e Expr }
+type and struct{ left, right Expr }
+type or struct{ left, right Expr }
+type comparison struct {
+	op          string
+	left, right Expr
+}
+
+func (l literal) eval(r Record) any { return l.value }
+func (n not) eval(r Record) any     { return !truthy(n.e.eval(r)) }
+func (a and) eval(r Record) any     { return truthy(a.left.eval(r)) && truthy(a.right.eval(r)) }
+func (o or) eval(r Record) any      { return truthy(o.left.eval(r)) || truthy(o.right.eval(r)) }
+
+func (f field) eval(r Record) any {
+	var value any = r
+	for _, key := range f {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+func (c comparison) eval(r Record) any {
+	left, right := normalize(c.left.eval(r)), normalize(c.right.eval(r))
+	// Versions are ordered by precedence rather than as strings, 1.9.0 comes before 1.10.0
+	if a, ok := versionOperand(c.left, left); ok {
+		if b, ok := versionOperand(c.right, right); ok {
+			if c.op == "contains" {
+				return strings.Contains(a, b)
+			}
+			return compareResult(c.op, version.Compare(a, b))
+		}
+	}
+	switch c.op {
+	case "==":
+		return equal(left, right)
+	case "!=":
+		return !equal(left, right)
+	case "contains":
+		switch l := left.(type) {
+		case []any:
+			for _, item := range l {
+				if equal(normalize(item), right) {
+					return true
+				}
+			}
+			return false
+		case string:
+			s, ok := right.(string)
+			return ok && strings.Contains(l, s)
+		}
+		return false
+	}
+
+	order, ok := compare(left, right)
+	if !ok {
+		return false
+	}
+	return compareResult(c.op, order)
+}
+
+// versionOperand returns the operand as a version when it is a string or a number literal that parses as one,
+// numbers of the records stay numbers
+func versionOperand(e Expr, v any) (string, bool) {
+	text, ok := v.(string)
+	if l, isLiteral := e.(literal); isLiteral && l.text != "" {
+		text, ok = l.text, true
+	}
+	if !ok {
+		return "", false
+	}
+	if _, ok := version.Parse(text); !ok {
+		return "", false
+	}
+	return text, true
+}
+
+func compareResult(op string, order int) bool {
+	switch op {
+	case "==":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	}
+	return false
+}
+
+// normalize turns the numbers of decoded records and of literals into float64
+func normalize(v any) any {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+	return v
+}
+
+func equal(a any, b any) bool {
+	switch a.(type) {
+	case []any, map[string]any:
+		return false
+	}
+	switch b.(type) {
+	case []any, map[string]any:
+		return false
+	}
+	return a == b
+}
+
+func compare(a any, b any) (int, bool) {
+	switch l := a.(type) {
+	case float64:
+		if r, ok := b.(float64); ok {
+			switch {
+			case l < r:
+				return -1, true
+			case l > r:
+				return 1, true
+			}
+			return 0, true
+		}
+	case string:
+		if r, ok := b.(string); ok {
+			return strings.Compare(l, r), true
+		}
+	}
+	return 0, false
+}
+
+func truthy(v any) bool {
+	switch value := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return value
+	case string:
+		return value != ""
+	case float64:
+		return value != 0
+	case []any:
+		return len(value) > 0
+	case map[string]any:
+		return len(value) > 0
+	}
+	return fmt.Sprint(v) != ""
+}
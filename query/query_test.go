@@ -0,0 +1,128 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package query
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func record(t *testing.T, raw string) Record {
+	t.Helper()
+	r := Record{}
+	if err := json.Unmarshal([]byte(raw), &r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{`name == "theme`, "unterminated string at position 9"},
+		{`name == `, "expected a field or a value at the end of the filter"},
+		{`(enabled`, "expected ) at the end of the filter"},
+		{`enabled)`, "unexpected token at position 8 ())"},
+		{`name @ "x"`, "unexpected '@' at position 6"},
+		{`tags contains contains`, "expected a field or a value at position 15 (contains)"},
+		{`&& enabled`, "expected a field or a value at position 1 (&&)"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.expr)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("Parse(%q) = %v, want %q", tt.expr, err, tt.err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	r := record(t, `{
+		"name": "theme-dark", "version": "1.10.0", "enabled": true, "priority": 3,
+		"tags": ["theme", "dark"], "remote": {"host": "github.com"}, "empty": "", "missing": null
+	}`)
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`enabled`, true},
+		{`!enabled`, false},
+		{`empty`, false},
+		{`missing`, false},
+		{`unknown`, false},
+		{`name == "theme-dark"`, true},
+		{`name != "theme-dark"`, false},
+		{`priority == 3`, true},
+		{`priority > 2 && priority <= 3`, true},
+		{`priority < 2 || enabled`, true},
+		{`!(priority < 2 || enabled)`, false},
+		{`tags contains "theme"`, true},
+		{`tags contains "light"`, false},
+		{`name contains "dark"`, true},
+		{`remote.host == "github.com"`, true},
+		{`remote.missing.host == null`, true},
+		{`tags == "theme"`, false},
+		{`name < 3`, false},
+		{`enabled && name == "theme-dark" || false`, true},
+		{`false || enabled && false`, false},
+		{`name == "a \"quoted\" name"`, false},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := Match(e, r); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestMatchVersions(t *testing.T) {
+	tests := []struct {
+		version string
+		expr    string
+		want    bool
+	}{
+		{"1.10.0", `version > "1.9.0"`, true},
+		{"1.10.0", `version > 1.9.0`, true},
+		{"1.10.0", `version > 1.9`, true},
+		{"1.10.0", `version < 1.9`, false},
+		{"1.10.0", `version >= 1.10.0`, true},
+		{"1.10.0", `version == 1.10.0`, true},
+		{"1.10.0", `version != 1.10.0`, false},
+		{"2.0.0-beta.1", `version < 2.0.0`, true},
+		{"2.0.0-beta.10", `version > 2.0.0-beta.9`, true},
+		{"1.10.0", `version contains 1.10`, true},
+		{"nightly", `version == "nightly"`, true},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := Match(e, Record{"version": tt.version}); got != tt.want {
+			t.Errorf("Match(%q) with version %s = %v, want %v", tt.expr, tt.version, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package schedule
+
+import (
+	"strings"
+	"time"
+)
+
+const Name = "bespoke-update"
+
+// Job describes commands run periodically by the platform scheduler
+type Job struct {
+	Executable string
+	Commands   [][]string
+	Interval   time.Duration
+}
+
+func shellQuote(arg string) string {
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// shellScript chains the job commands for sh
+func (j *Job) shellScript() string {
+	commands := []string{}
+	for _, args := range j.Commands {
+		quoted := []string{shellQuote(j.Executable)}
+		for _, arg := range args {
+			quoted = append(quoted, shellQuote(arg))
+		}
+		commands = append(commands, strings.Join(quoted, " "))
+	}
+	return strings.Join(commands, "; ")
+}
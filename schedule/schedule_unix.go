@@ -0,0 +1,81 @@
+//go:build unix && !darwin
+
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package schedule
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/adrg/xdg"
+)
+
+var unitsFolder = filepath.Join(xdg.ConfigHome, "systemd", "user")
+
+func systemctl(args ...string) error {
+	cmd := exec.Command("systemctl", append([]string{"--user"}, args...)...)
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func Install(job Job) error {
+	service := "[Unit]\n" +
+		"Description=Update bespoke modules and re-patch Spotify\n\n" +
+		"[Service]\n" +
+		"Type=oneshot\n" +
+		"ExecStart=/bin/sh -c " + strconv.Quote(job.shellScript()) + "\n"
+
+	timer := "[Unit]\n" +
+		"Description=Periodically update bespoke\n\n" +
+		"[Timer]\n" +
+		"OnBootSec=5min\n" +
+		"OnUnitActiveSec=" + strconv.Itoa(int(job.Interval.Seconds())) + "s\n" +
+		"Persistent=true\n\n" +
+		"[Install]\n" +
+		"WantedBy=timers.target\n"
+
+	if err := os.MkdirAll(unitsFolder, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(unitsFolder, Name+".service"), []byte(service), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(unitsFolder, Name+".timer"), []byte(timer), 0644); err != nil {
+		return err
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl("enable", "--now", Name+".timer")
+}
+
+func Remove() error {
+	systemctl("disable", "--now", Name+".timer")
+	for _, unit := range []string{Name + ".service", Name + ".timer"} {
+		if err := os.Remove(filepath.Join(unitsFolder, unit)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return systemctl("daemon-reload")
+}
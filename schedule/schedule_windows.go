@@ -0,0 +1,70 @@
+//go:build windows
+
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package schedule
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func schtasks(args ...string) error {
+	cmd := exec.Command("schtasks", args...)
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func Install(job Job) error {
+	commands := []string{}
+	for _, args := range job.Commands {
+		commands = append(commands, `"`+job.Executable+`" `+strings.Join(args, " "))
+	}
+	tr := `cmd /c ` + strings.Join(commands, " & ")
+
+	schedule, modifier, err := frequency(job.Interval)
+	if err != nil {
+		return err
+	}
+
+	return schtasks("/Create", "/F", "/TN", Name, "/SC", schedule, "/MO", strconv.Itoa(modifier), "/TR", tr)
+}
+
+// frequency converts interval to the schedule and modifier of schtasks, which runs hourly tasks
+// at most every 23 hours and daily ones every whole number of days
+func frequency(interval time.Duration) (string, int, error) {
+	switch {
+	case interval < time.Hour:
+		return "MINUTE", max(int(interval.Minutes()), 1), nil
+	case interval < 24*time.Hour:
+		return "HOURLY", int(interval.Hours()), nil
+	case interval%(24*time.Hour) == 0:
+		return "DAILY", int(interval.Hours()) / 24, nil
+	}
+	return "", 0, errors.New("intervals over a day must be a whole number of days on Windows, got " + interval.String())
+}
+
+func Remove() error {
+	return schtasks("/Delete", "/F", "/TN", Name)
+}
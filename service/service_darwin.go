@@ -0,0 +1,99 @@
+//go:build darwin
+
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package service
+
+import (
+	"encoding/xml"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/adrg/xdg"
+)
+
+const label = "app.bespoke.daemon"
+
+var plistPath = filepath.Join(xdg.Home, "Library", "LaunchAgents", label+".plist")
+
+func escape(s string) string {
+	b := strings.Builder{}
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func Install(service Service) error {
+	arguments := ""
+	for _, arg := range append([]string{service.Executable}, service.Args...) {
+		arguments += "\t\t<string>" + escape(arg) + "</string>\n"
+	}
+
+	plist := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>` + label + `</string>
+	<key>ProgramArguments</key>
+	<array>
+` + arguments + `	</array>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>StandardOutPath</key>
+	<string>` + escape(service.LogPath) + `</string>
+	<key>StandardErrorPath</key>
+	<string>` + escape(service.LogPath) + `</string>
+</dict>
+</plist>
+`
+
+	if err := os.MkdirAll(filepath.Dir(plistPath), 0755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(service.LogPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(plistPath, []byte(plist), 0644); err != nil {
+		return err
+	}
+
+	exec.Command("launchctl", "unload", plistPath).Run()
+	return exec.Command("launchctl", "load", "-w", plistPath).Run()
+}
+
+func Remove() error {
+	exec.Command("launchctl", "unload", "-w", plistPath).Run()
+	if err := os.Remove(plistPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func Installed() bool {
+	_, err := os.Stat(plistPath)
+	return err == nil
+}
@@ -0,0 +1,92 @@
+//go:build unix && !darwin
+
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package service
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/adrg/xdg"
+)
+
+var unitPath = filepath.Join(xdg.ConfigHome, "systemd", "user", Name+".service")
+
+func systemctl(args ...string) error {
+	cmd := exec.Command("systemctl", append([]string{"--user"}, args...)...)
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// quote escapes arg for the command lines of systemd units, which expand specifiers such as %h and
+// environment variables besides handling quotes and backslashes
+func quote(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "%", "%%", "$", "$$").Replace(arg) + `"`
+}
+
+func Install(service Service) error {
+	command := []string{quote(service.Executable)}
+	for _, arg := range service.Args {
+		command = append(command, quote(arg))
+	}
+	logPath := strings.ReplaceAll(service.LogPath, "%", "%%")
+
+	unit := "[Unit]\n" +
+		"Description=bespoke daemon serving the enabled modules to Spotify\n\n" +
+		"[Service]\n" +
+		"ExecStart=" + strings.Join(command, " ") + "\n" +
+		"Restart=on-failure\n" +
+		"RestartSec=5s\n" +
+		"StandardOutput=append:" + logPath + "\n" +
+		"StandardError=append:" + logPath + "\n\n" +
+		"[Install]\n" +
+		"WantedBy=default.target\n"
+
+	if err := os.MkdirAll(filepath.Dir(unitPath), 0755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(service.LogPath), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(unitPath, []byte(unit), 0644); err != nil {
+		return err
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl("enable", "--now", Name+".service")
+}
+
+func Remove() error {
+	systemctl("disable", "--now", Name+".service")
+	if err := os.Remove(unitPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return systemctl("daemon-reload")
+}
+
+func Installed() bool {
+	_, err := os.Stat(unitPath)
+	return err == nil
+}
@@ -0,0 +1,118 @@
+//go:build windows
+
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package service
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// The command is started with the session from the Run key of the user, which unlike a logon task doesn't take
+// admin rights
+const runKey = `Software\Microsoft\Windows\CurrentVersion\Run`
+
+// quote wraps arg in double quotes, inside which cmd doesn't interpret its operators
+func quote(arg string) (string, error) {
+	if strings.Contains(arg, `"`) {
+		return "", errors.New("can't pass " + arg + " to cmd: it contains a double quote")
+	}
+	return `"` + arg + `"`, nil
+}
+
+// commandLine runs service through cmd to append its output to its log file
+func commandLine(service Service) (string, error) {
+	command := []string{}
+	for _, arg := range append([]string{service.Executable}, service.Args...) {
+		quoted, err := quote(arg)
+		if err != nil {
+			return "", err
+		}
+		command = append(command, quoted)
+	}
+	log, err := quote(service.LogPath)
+	if err != nil {
+		return "", err
+	}
+	// cmd /c strips the outer quotes and runs the rest as is
+	return `cmd /c "` + strings.Join(command, " ") + ` >> ` + log + ` 2>&1"`, nil
+}
+
+func Install(service Service) error {
+	if err := os.MkdirAll(filepath.Dir(service.LogPath), 0755); err != nil {
+		return err
+	}
+	line, err := commandLine(service)
+	if err != nil {
+		return err
+	}
+
+	key, _, err := registry.CreateKey(registry.CURRENT_USER, runKey, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	if err := key.SetStringValue(Name, line); err != nil {
+		return err
+	}
+
+	// Start it right away rather than at the next logon
+	cmd := exec.Command(filepath.Join(os.Getenv("SystemRoot"), "System32", "cmd.exe"))
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CmdLine:       line,
+		HideWindow:    true,
+		CreationFlags: windows.CREATE_NEW_PROCESS_GROUP | windows.DETACHED_PROCESS,
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// Remove stops starting the command with the session, a running daemon stops on its own once disabled
+func Remove() error {
+	key, err := registry.OpenKey(registry.CURRENT_USER, runKey, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+	if err := key.DeleteValue(Name); err != nil && err != registry.ErrNotExist {
+		return err
+	}
+	return nil
+}
+
+func Installed() bool {
+	key, err := registry.OpenKey(registry.CURRENT_USER, runKey, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	defer key.Close()
+	_, _, err = key.GetStringValue(Name)
+	return err == nil
+}
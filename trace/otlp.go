@@ -0,0 +1,118 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+}
+
+// WriteOTLP writes the spans as an OTLP/JSON export request, all in a single trace
+func WriteOTLP(w io.Writer) error {
+	traceID := randomID(16)
+	spans := []otlpSpan{}
+	var walk func(span *Span)
+	walk = func(span *Span) {
+		s := otlpSpan{
+			TraceID:           traceID,
+			SpanID:            span.id,
+			Name:              span.Name,
+			Kind:              1, // internal
+			StartTimeUnixNano: strconv.FormatInt(span.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.End.UnixNano(), 10),
+		}
+		if span.parent != nil {
+			s.ParentSpanID = span.parent.id
+		}
+		if span.Detail != "" {
+			s.Attributes = []otlpAttribute{{"bespoke.detail", otlpValue{span.Detail}}}
+		}
+		spans = append(spans, s)
+		for _, child := range span.Children {
+			walk(child)
+		}
+	}
+	for _, span := range Spans() {
+		walk(span)
+	}
+
+	request := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": []otlpAttribute{{"service.name", otlpValue{"bespoke"}}},
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]string{"name": "bespoke"},
+				"spans": spans,
+			}},
+		}},
+	}
+	return json.NewEncoder(w).Encode(request)
+}
+
+// ExportOTLP writes the spans to a file, or posts them to an OTLP/HTTP collector when target is an
+// http(s) URL (e.g. http://localhost:4318/v1/traces)
+func ExportOTLP(target string) error {
+	var buf bytes.Buffer
+	if err := WriteOTLP(&buf); err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+		return os.WriteFile(target, buf.Bytes(), 0644)
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	res, err := client.Post(target, "application/json", &buf)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return errors.New("the collector at " + target + " answered " + res.Status)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package trace times the steps of a command (metadata fetches, downloads, extraction, vault writes...) to find
+// out why it is slow, as a summary tree or an OTLP export
+package trace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Enabled turns the recording of spans on, Start does nothing otherwise
+var Enabled bool
+
+// Span is a timed step, spans started before it ends are its children
+type Span struct {
+	Name string
+	// Detail tells apart spans of the same name, such as the URL of a request
+	Detail   string
+	Start    time.Time
+	End      time.Time
+	Children []*Span
+	parent   *Span
+	id       string
+}
+
+var (
+	mu      sync.Mutex
+	roots   []*Span
+	current *Span
+)
+
+// Start opens a span as a child of the innermost open span. Spans started from other goroutines are
+// attributed to it as well, which is good enough for the few steps bespoke runs concurrently
+func Start(name string, detail string) *Span {
+	if !Enabled {
+		return nil
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	span := &Span{Name: name, Detail: detail, Start: time.Now(), parent: current, id: randomID(8)}
+	if current != nil {
+		current.Children = append(current.Children, span)
+	} else {
+		roots = append(roots, span)
+	}
+	current = span
+	return span
+}
+
+// Finish ends the span, spans that are still open below it end with it
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	s.finish(time.Now())
+	// The innermost open span is s or one of its children unless spans were finished out of order
+	for span := current; span != nil; span = span.parent {
+		if span == s {
+			current = s.parent
+			break
+		}
+	}
+}
+
+func (s *Span) finish(now time.Time) {
+	if !s.End.IsZero() {
+		return
+	}
+	s.End = now
+	for _, child := range s.Children {
+		child.finish(now)
+	}
+}
+
+func (s *Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+func randomID(n int) string {
+	id := make([]byte, n)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Spans returns the top-level spans recorded so far, ending the ones still open
+func Spans() []*Span {
+	mu.Lock()
+	defer mu.Unlock()
+	now := time.Now()
+	for _, root := range roots {
+		root.finish(now)
+	}
+	current = nil
+	return roots
+}
+
+const barWidth = 20
+
+// Summary prints the spans as a tree, each with its duration, its share of the whole trace and a bar
+// positioned where it ran, so that the slow and sequential steps stand out
+func Summary(w io.Writer) {
+	spans := Spans()
+	if len(spans) == 0 {
+		return
+	}
+	start, end := spans[0].Start, spans[0].End
+	for _, span := range spans {
+		if span.End.After(end) {
+			end = span.End
+		}
+	}
+	total := end.Sub(start)
+
+	var walk func(span *Span, depth int)
+	walk = func(span *Span, depth int) {
+		share := 1.0
+		offset, width := 0, barWidth
+		if total > 0 {
+			share = float64(span.Duration()) / float64(total)
+			offset = int(float64(span.Start.Sub(start)) / float64(total) * barWidth)
+			width = max(1, int(share*barWidth))
+			width = min(width, barWidth-offset)
+		}
+		bar := strings.Repeat(" ", offset) + strings.Repeat("█", width) + strings.Repeat(" ", max(0, barWidth-offset-width))
+		label := span.Name
+		if span.Detail != "" {
+			label += " " + span.Detail
+		}
+		fmt.Fprintf(w, "%9s %4.0f%% |%s| %s%s\n", span.Duration().Round(time.Millisecond), share*100, bar, strings.Repeat("  ", depth), label)
+		for _, child := range span.Children {
+			walk(child, depth+1)
+		}
+	}
+	for _, span := range spans {
+		walk(span, 0)
+	}
+}
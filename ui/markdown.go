@@ -0,0 +1,104 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ui
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	headingRe = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	bulletRe  = regexp.MustCompile(`^(\s*)[-*+]\s+(.*)$`)
+	numberRe  = regexp.MustCompile(`^(\s*)(\d+)[.)]\s+(.*)$`)
+	ruleRe    = regexp.MustCompile(`^\s*(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	imageRe   = regexp.MustCompile(`!\[([^\]]*)\]\(([^)]*)\)`)
+	linkRe    = regexp.MustCompile(`\[([^\]]+)\]\(([^)]*)\)`)
+	codeRe    = regexp.MustCompile("`([^`]+)`")
+	strongRe  = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	htmlRe    = regexp.MustCompile(`<[^>]+>`)
+)
+
+// RenderMarkdown formats markdown for the terminal: styled headings, emphasis and code, bullets and links
+// followed by their URL. Tables and HTML blocks are printed as they are, minus the tags
+func RenderMarkdown(markdown string) string {
+	var b strings.Builder
+	fenced := false
+	for _, line := range strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			b.WriteString("    " + Yellow(line) + "\n")
+			continue
+		}
+
+		switch {
+		case headingRe.MatchString(line):
+			m := headingRe.FindStringSubmatch(line)
+			heading := renderInline(m[2])
+			if len(m[1]) == 1 {
+				heading = strings.ToUpper(heading)
+			}
+			b.WriteString(Bold(Cyan(heading)) + "\n")
+		case ruleRe.MatchString(line):
+			b.WriteString(Dim(strings.Repeat("─", 40)) + "\n")
+		case bulletRe.MatchString(line):
+			m := bulletRe.FindStringSubmatch(line)
+			b.WriteString(m[1] + "  • " + renderInline(m[2]) + "\n")
+		case numberRe.MatchString(line):
+			m := numberRe.FindStringSubmatch(line)
+			b.WriteString(m[1] + "  " + m[2] + ". " + renderInline(m[3]) + "\n")
+		case strings.HasPrefix(line, ">"):
+			b.WriteString(Dim("│ ") + renderInline(strings.TrimSpace(strings.TrimPrefix(line, ">"))) + "\n")
+		default:
+			b.WriteString(renderInline(line) + "\n")
+		}
+	}
+	return b.String()
+}
+
+func renderInline(s string) string {
+	s = htmlRe.ReplaceAllString(s, "")
+	// Code spans are styled last so that their content isn't mistaken for emphasis or links
+	spans := []string{}
+	s = codeRe.ReplaceAllStringFunc(s, func(span string) string {
+		spans = append(spans, span[1:len(span)-1])
+		return "\x00"
+	})
+	s = imageRe.ReplaceAllStringFunc(s, func(image string) string {
+		return Dim("[image: " + imageRe.FindStringSubmatch(image)[1] + "]")
+	})
+	s = linkRe.ReplaceAllStringFunc(s, func(link string) string {
+		m := linkRe.FindStringSubmatch(link)
+		if m[1] == m[2] {
+			return Cyan(m[1])
+		}
+		return m[1] + " " + Dim("("+m[2]+")")
+	})
+	s = strongRe.ReplaceAllStringFunc(s, func(strong string) string {
+		return Bold(strong[2 : len(strong)-2])
+	})
+	for _, span := range spans {
+		s = strings.Replace(s, "\x00", Yellow(span), 1)
+	}
+	return s
+}
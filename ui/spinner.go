@@ -0,0 +1,71 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var frames = []string{"|", "/", "-", "\\"}
+
+// Spinner shows progress of a silent operation on stderr, or nothing when stderr isn't a terminal
+type Spinner struct {
+	message string
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func Spin(message string) *Spinner {
+	s := &Spinner{message: message}
+	if !IsTerminal(os.Stderr) {
+		return s
+	}
+
+	s.done = make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			fmt.Fprintf(os.Stderr, "\r%s %s", frames[i%len(frames)], s.message)
+			select {
+			case <-s.done:
+				fmt.Fprint(os.Stderr, "\r"+strings.Repeat(" ", width(s.message)+2)+"\r")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s
+}
+
+func (s *Spinner) Stop() {
+	if s.done == nil {
+		return
+	}
+	close(s.done)
+	s.wg.Wait()
+	s.done = nil
+}
@@ -0,0 +1,97 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ui
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StatusLine shows the latest status of a long operation on the last line of stderr, or nothing when stderr
+// isn't a terminal. Output written through Wrap is printed above it
+type StatusLine struct {
+	mu      sync.Mutex
+	shown   string
+	updated time.Time
+}
+
+func NewStatusLine() *StatusLine {
+	if !IsTerminal(os.Stderr) {
+		return nil
+	}
+	return &StatusLine{}
+}
+
+// Update replaces the status, updates less than 100ms apart are dropped to not flood the terminal
+func (s *StatusLine) Update(status string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shown != "" && time.Since(s.updated) < 100*time.Millisecond {
+		return
+	}
+	s.clear()
+	fmt.Fprint(os.Stderr, status)
+	s.shown = status
+	s.updated = time.Now()
+}
+
+func (s *StatusLine) Clear() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clear()
+}
+
+func (s *StatusLine) clear() {
+	if s.shown == "" {
+		return
+	}
+	fmt.Fprint(os.Stderr, "\r"+strings.Repeat(" ", width(s.shown))+"\r")
+	s.shown = ""
+}
+
+type statusWriter struct {
+	s *StatusLine
+	w io.Writer
+}
+
+func (sw statusWriter) Write(p []byte) (int, error) {
+	sw.s.mu.Lock()
+	defer sw.s.mu.Unlock()
+	sw.s.clear()
+	return sw.w.Write(p)
+}
+
+// Wrap returns a writer that clears the status before writing to w, for logs not to be printed after it
+func (s *StatusLine) Wrap(w io.Writer) io.Writer {
+	if s == nil {
+		return w
+	}
+	return statusWriter{s, w}
+}
@@ -0,0 +1,55 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package ui
+
+import (
+	"os"
+)
+
+// Color toggles ANSI styling of stdout
+var Color = true
+
+// Configure disables styling when asked to (--no-color, NO_COLOR) or when stdout isn't a terminal
+func Configure(noColor bool) {
+	Color = !noColor &&
+		os.Getenv("NO_COLOR") == "" &&
+		os.Getenv("TERM") != "dumb" &&
+		IsTerminal(os.Stdout) &&
+		enableVirtualTerminal(os.Stdout)
+}
+
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func style(code string, s string) string {
+	if !Color {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+func Bold(s string) string   { return style("1", s) }
+func Dim(s string) string    { return style("2", s) }
+func Red(s string) string    { return style("31", s) }
+func Green(s string) string  { return style("32", s) }
+func Yellow(s string) string { return style("33", s) }
+func Cyan(s string) string   { return style("36", s) }
@@ -27,3 +27,8 @@ import e "bespoke/errors"
 func RegisterURIScheme() error {
 	return e.ErrUnsupportedOperation
 }
+
+// TODO
+func UnregisterURIScheme() error {
+	return e.ErrUnsupportedOperation
+}
@@ -1,4 +1,4 @@
-//go:build unix
+//go:build unix && !darwin
 
 /* Copyright © 2024
  *      Delusoire <deluso7re@outlook.com>
@@ -27,3 +27,8 @@ import e "bespoke/errors"
 func RegisterURIScheme() error {
 	return e.ErrUnsupportedOperation
 }
+
+// TODO
+func UnregisterURIScheme() error {
+	return e.ErrUnsupportedOperation
+}
@@ -51,7 +51,7 @@ func RegisterURIScheme() error {
 	if err != nil {
 		return err
 	}
-	bin := filepath.Join(paths.ConfigPath, "bin", "bespoke.exe")
+	bin := filepath.Join(paths.BaseConfigPath, "bin", "bespoke.exe")
 
 	if err := copyExeToBin(bin); err != nil {
 		return err
@@ -61,6 +61,21 @@ func RegisterURIScheme() error {
 	return key.SetStringValue("", cmd)
 }
 
+func UnregisterURIScheme() error {
+	keys := []string{
+		`Software\Classes\bespoke\shell\open\command`,
+		`Software\Classes\bespoke\shell\open`,
+		`Software\Classes\bespoke\shell`,
+		`Software\Classes\bespoke`,
+	}
+	for _, key := range keys {
+		if err := registry.DeleteKey(registry.CURRENT_USER, key); err != nil && err != registry.ErrNotExist {
+			return err
+		}
+	}
+	return nil
+}
+
 func copyExeToBin(bin string) error {
 	exe, err := os.Executable()
 	if err != nil {
@@ -0,0 +1,186 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package version
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// bound is a single comparison of a constraint, e.g. ">=1.2.0"
+type bound struct {
+	op      string
+	version Semver
+}
+
+func (b bound) matches(v Semver) bool {
+	c := v.Compare(b.version)
+	switch b.op {
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return c == 0
+}
+
+// Constraint is a range of versions: space separated bounds which must all hold, comparisons (>=1.2.0, <2),
+// caret (^1.2) and tilde (~1.2) ranges and partial versions (1, 1.2, 1.x) standing for every version they prefix.
+// Alternatives are separated by || (^1 || ^2)
+type Constraint struct {
+	sets [][]bound
+	// alternatives holds the text of each set of bounds
+	alternatives []string
+}
+
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{}
+	for _, alternative := range strings.Split(s, "||") {
+		bounds, err := parseBounds(alternative)
+		if err != nil {
+			return Constraint{}, errors.New("invalid version constraint " + s)
+		}
+		c.sets = append(c.sets, bounds)
+		c.alternatives = append(c.alternatives, strings.Join(strings.Fields(alternative), " "))
+	}
+	return c, nil
+}
+
+// Intersect returns the constraint satisfied by the versions satisfying both a and b
+func Intersect(a Constraint, b Constraint) Constraint {
+	c := Constraint{}
+	for i, x := range a.sets {
+		for j, y := range b.sets {
+			c.sets = append(c.sets, slices.Concat(x, y))
+			c.alternatives = append(c.alternatives, joinBounds(a.alternatives[i], b.alternatives[j]))
+		}
+	}
+	return c
+}
+
+func joinBounds(a string, b string) string {
+	parts := []string{}
+	for _, bounds := range []string{a, b} {
+		if bounds != "" && bounds != "*" {
+			parts = append(parts, bounds)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func parseBounds(s string) ([]bound, error) {
+	bounds := []bound{}
+	for _, part := range strings.Fields(s) {
+		op := ""
+		for _, prefix := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+			if rest, ok := strings.CutPrefix(part, prefix); ok {
+				op, part = prefix, rest
+				break
+			}
+		}
+
+		part, _, _ = strings.Cut(strings.TrimPrefix(part, "v"), "+")
+		part, pre, hasPre := strings.Cut(part, "-")
+		numbers := [3]int{}
+		given := 0
+		for _, component := range strings.Split(part, ".") {
+			if component == "x" || component == "X" || component == "*" {
+				break
+			}
+			n, err := strconv.Atoi(component)
+			if !isNumeric(component) || err != nil || given == 3 {
+				return nil, errors.New("invalid bound " + part)
+			}
+			numbers[given] = n
+			given++
+		}
+		if given == 0 && op != "" || hasPre && given != 3 {
+			return nil, errors.New("invalid bound " + part)
+		}
+		lower := Semver{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}
+		if hasPre {
+			lower.Prerelease = strings.Split(pre, ".")
+		}
+
+		// The first version past the range, with the component at i incremented
+		upper := func(i int) bound {
+			next := numbers
+			next[i]++
+			for j := i + 1; j < 3; j++ {
+				next[j] = 0
+			}
+			return bound{"<", Semver{Major: next[0], Minor: next[1], Patch: next[2]}}
+		}
+
+		switch op {
+		case "^":
+			// Changes to the leftmost non-zero component may break compatibility
+			i := 0
+			for i < given-1 && numbers[i] == 0 {
+				i++
+			}
+			bounds = append(bounds, bound{">=", lower}, upper(i))
+		case "~":
+			bounds = append(bounds, bound{">=", lower}, upper(min(given, 2)-1))
+		case "", "=":
+			if given == 3 {
+				bounds = append(bounds, bound{"=", lower})
+			} else if given > 0 {
+				bounds = append(bounds, bound{">=", lower}, upper(given-1))
+			}
+		default:
+			bounds = append(bounds, bound{op, lower})
+		}
+	}
+	return bounds, nil
+}
+
+// Check reports whether a version satisfies the constraint. Like npm, pre-releases only match the alternatives
+// with a bound naming a pre-release of the same major.minor.patch
+func (c Constraint) Check(s string) bool {
+	v, ok := Parse(s)
+	if !ok {
+		return false
+	}
+	for _, bounds := range c.sets {
+		if len(v.Prerelease) > 0 && !slices.ContainsFunc(bounds, func(b bound) bool {
+			return len(b.version.Prerelease) > 0 && slices.Equal(b.version.core(), v.core())
+		}) {
+			continue
+		}
+		if !slices.ContainsFunc(bounds, func(b bound) bool { return !b.matches(v) }) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Constraint) String() string {
+	if len(c.alternatives) == 1 && c.alternatives[0] == "" {
+		return "*"
+	}
+	return strings.Join(c.alternatives, " || ")
+}
@@ -0,0 +1,206 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+// Package version parses and orders module versions, which are semver versions (1.2.3-beta.1+build)
+// as long as authors follow the convention, and arbitrary strings otherwise
+package version
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Semver is a version in the major.minor.patch[-prerelease][+build] form,
+// a leading v and missing minor or patch components (1, 1.2) are accepted
+type Semver struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease []string
+	Build      string
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func Parse(s string) (Semver, bool) {
+	s, build, _ := strings.Cut(strings.TrimPrefix(s, "v"), "+")
+	core, pre, hasPre := strings.Cut(s, "-")
+	components := strings.Split(core, ".")
+	if len(components) > 3 {
+		return Semver{}, false
+	}
+	numbers := [3]int{}
+	for i, component := range components {
+		n, err := strconv.Atoi(component)
+		if !isNumeric(component) || err != nil {
+			return Semver{}, false
+		}
+		numbers[i] = n
+	}
+
+	v := Semver{Major: numbers[0], Minor: numbers[1], Patch: numbers[2], Build: build}
+	if hasPre {
+		v.Prerelease = strings.Split(pre, ".")
+		if slices.Contains(v.Prerelease, "") {
+			return Semver{}, false
+		}
+	}
+	return v, true
+}
+
+func (v Semver) String() string {
+	s := strconv.Itoa(v.Major) + "." + strconv.Itoa(v.Minor) + "." + strconv.Itoa(v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+func (v Semver) core() []int {
+	return []int{v.Major, v.Minor, v.Patch}
+}
+
+// Compare orders versions by semver precedence, the build metadata is ignored
+func (v Semver) Compare(other Semver) int {
+	if c := slices.Compare(v.core(), other.core()); c != 0 {
+		return c
+	}
+	// A pre-release comes before the release it precedes
+	switch {
+	case len(v.Prerelease) == 0 && len(other.Prerelease) == 0:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(other.Prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.Prerelease) && i < len(other.Prerelease); i++ {
+		if c := compareIdentifiers(v.Prerelease[i], other.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return len(v.Prerelease) - len(other.Prerelease)
+}
+
+// compareIdentifiers orders numeric identifiers numerically, before the alphanumeric ones which are compared as strings
+func compareIdentifiers(a string, b string) int {
+	numericA, numericB := isNumeric(a), isNumeric(b)
+	switch {
+	case numericA && numericB:
+		return compareNumbers(a, b)
+	case numericA:
+		return -1
+	case numericB:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// compareNumbers compares strings of digits of any length
+func compareNumbers(a string, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return strings.Compare(a, b)
+}
+
+// compareNatural compares strings with their runs of digits compared as numbers (build-9 < build-10)
+func compareNatural(a string, b string) int {
+	for a != "" && b != "" {
+		chunkA, chunkB := nextChunk(a), nextChunk(b)
+		a, b = a[len(chunkA):], b[len(chunkB):]
+		var c int
+		if isNumeric(chunkA) && isNumeric(chunkB) {
+			c = compareNumbers(chunkA, chunkB)
+		} else {
+			c = strings.Compare(chunkA, chunkB)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// nextChunk returns the leading run of digits or of other characters of s
+func nextChunk(s string) string {
+	digit := isNumeric(s[:1])
+	i := 1
+	for i < len(s) && isNumeric(s[i:i+1]) == digit {
+		i++
+	}
+	return s[:i]
+}
+
+// Compare orders semver versions by precedence, after the other versions which are compared naturally
+// (runs of digits compare as numbers). Versions of equal precedence (1.2 and 1.2.0, 1.0.0+a and 1.0.0+b)
+// are ordered by their strings, so that two different versions never compare as equal
+func Compare(a string, b string) int {
+	va, okA := Parse(a)
+	vb, okB := Parse(b)
+	switch {
+	case okA && okB:
+		if c := va.Compare(vb); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	case okA:
+		return 1
+	case okB:
+		return -1
+	}
+	if c := compareNatural(a, b); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+// Sort sorts versions from the oldest to the newest
+func Sort[S ~[]E, E ~string](versions S) {
+	slices.SortFunc(versions, func(a E, b E) int {
+		return Compare(string(a), string(b))
+	})
+}
+
+// Latest returns the newest of versions, the empty string when there are none
+func Latest[S ~[]E, E ~string](versions S) E {
+	var latest E
+	for i, version := range versions {
+		if i == 0 || Compare(string(version), string(latest)) > 0 {
+			latest = version
+		}
+	}
+	return latest
+}
@@ -0,0 +1,231 @@
+/* Copyright © 2024
+ *      Delusoire <deluso7re@outlook.com>
+ *
+ * This file is part of bespoke/cli.
+ *
+ * bespoke/cli is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * bespoke/cli is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with bespoke/cli. If not, see <https://www.gnu.org/licenses/>.
+ */
+
+package version
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+		ok   bool
+	}{
+		{"1.2.3", "1.2.3", true},
+		{"v1.2.3", "1.2.3", true},
+		{"1", "1.0.0", true},
+		{"1.2", "1.2.0", true},
+		{"1.2.3-beta.1+build.5", "1.2.3-beta.1+build.5", true},
+		{"1.2.3-", "", false},
+		{"1.2.3-beta..1", "", false},
+		{"1.2.3.4", "", false},
+		{"1.x", "", false},
+		{"nightly", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		v, ok := Parse(tt.s)
+		if ok != tt.ok {
+			t.Errorf("Parse(%q) ok = %v, want %v", tt.s, ok, tt.ok)
+			continue
+		}
+		if ok && v.String() != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.s, v, tt.want)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.9.0", "1.10.0", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"1.2.3", "1.2.3", 0},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-rc.1", "1.0.0-beta.11", 1},
+		{"1.0.0+a", "1.0.0+b", -1},
+		{"1.2", "1.2.0", -1},
+		{"nightly", "0.0.1", -1},
+		{"build-9", "build-10", -1},
+	}
+	sign := func(n int) int {
+		switch {
+		case n < 0:
+			return -1
+		case n > 0:
+			return 1
+		}
+		return 0
+	}
+	for _, tt := range tests {
+		if got := sign(Compare(tt.a, tt.b)); got != tt.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSortAndLatest(t *testing.T) {
+	versions := []string{"1.10.0", "2.0.0-beta.1", "nightly", "1.9.0", "2.0.0", "0.1.0"}
+	Sort(versions)
+	want := []string{"nightly", "0.1.0", "1.9.0", "1.10.0", "2.0.0-beta.1", "2.0.0"}
+	if !slices.Equal(versions, want) {
+		t.Errorf("Sort = %v, want %v", versions, want)
+	}
+
+	tests := []struct {
+		versions []string
+		want     string
+	}{
+		{nil, ""},
+		{[]string{"1.0.0"}, "1.0.0"},
+		{[]string{"1.9.0", "1.10.0", "1.2.0"}, "1.10.0"},
+		{[]string{"2.0.0-rc.1", "1.9.9"}, "2.0.0-rc.1"},
+		{[]string{"2.0.0", "2.0.0-rc.1"}, "2.0.0"},
+		{[]string{"main", "0.0.1"}, "0.0.1"},
+	}
+	for _, tt := range tests {
+		if got := Latest(tt.versions); got != tt.want {
+			t.Errorf("Latest(%v) = %q, want %q", tt.versions, got, tt.want)
+		}
+	}
+}
+
+func TestParseConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matches    []string
+		rejects    []string
+	}{
+		{"", []string{"0.0.1", "1.2.3", "99.0.0"}, []string{"1.0.0-beta.1", "nightly"}},
+		{"*", []string{"1.2.3"}, nil},
+		{"1.2.3", []string{"1.2.3"}, []string{"1.2.4"}},
+		{"=1.2.3", []string{"1.2.3"}, []string{"1.2.2"}},
+		{"1.2", []string{"1.2.0", "1.2.9"}, []string{"1.3.0", "1.1.9"}},
+		{"1.x", []string{"1.0.0", "1.99.0"}, []string{"2.0.0"}},
+		{"^1.2", []string{"1.2.0", "1.9.9"}, []string{"2.0.0", "1.1.0"}},
+		{"^0.2.3", []string{"0.2.3", "0.2.9"}, []string{"0.3.0"}},
+		{"^0.0.3", []string{"0.0.3"}, []string{"0.0.4"}},
+		{"~1.4", []string{"1.4.0", "1.4.7"}, []string{"1.5.0"}},
+		{"~1.4.2", []string{"1.4.2", "1.4.9"}, []string{"1.4.1", "1.5.0"}},
+		{"~1", []string{"1.0.0", "1.9.0"}, []string{"2.0.0"}},
+		{">=1.2 <2", []string{"1.2.0", "1.99.99"}, []string{"1.1.9", "2.0.0"}},
+		{">1.2.3", []string{"1.2.4"}, []string{"1.2.3"}},
+		{"<=1.2.3", []string{"1.2.3", "0.1.0"}, []string{"1.2.4"}},
+		{"^1 || ^3", []string{"1.5.0", "3.0.1"}, []string{"2.0.0"}},
+		{"v1.2.3", []string{"1.2.3"}, nil},
+	}
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Errorf("ParseConstraint(%q): %v", tt.constraint, err)
+			continue
+		}
+		for _, v := range tt.matches {
+			if !c.Check(v) {
+				t.Errorf("%q doesn't match %s", tt.constraint, v)
+			}
+		}
+		for _, v := range tt.rejects {
+			if c.Check(v) {
+				t.Errorf("%q matches %s", tt.constraint, v)
+			}
+		}
+	}
+}
+
+func TestParseConstraintErrors(t *testing.T) {
+	for _, constraint := range []string{">=", "^", "1.2.3.4", "1.a", ">=x", "1.2-beta", "^1 || >="} {
+		if _, err := ParseConstraint(constraint); err == nil {
+			t.Errorf("ParseConstraint(%q) didn't fail", constraint)
+		}
+	}
+}
+
+func TestPrereleases(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"^1.0.0", "1.1.0-beta.1", false},
+		{">=1.0.0-beta.1", "1.0.0-beta.2", true},
+		{">=1.0.0-beta.1", "1.0.0", true},
+		{">=1.0.0-beta.1", "1.1.0-beta.1", false},
+		{"^1.0.0-rc.1", "1.0.0-rc.2", true},
+		{"^1.0.0-rc.1", "1.0.0-beta.9", false},
+		{"1.0.0-rc.1", "1.0.0-rc.1", true},
+		{"<2.0.0", "2.0.0-beta.1", false},
+	}
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Check(tt.version); got != tt.want {
+			t.Errorf("%q matches %s = %v, want %v", tt.constraint, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestIntersect(t *testing.T) {
+	tests := []struct {
+		a, b    string
+		text    string
+		matches []string
+		rejects []string
+	}{
+		{"^1.2", "<1.5", "^1.2 <1.5", []string{"1.2.0", "1.4.9"}, []string{"1.5.0", "1.1.0"}},
+		{"*", "~2.1", "~2.1", []string{"2.1.3"}, []string{"2.2.0"}},
+		{"", "", "*", []string{"0.1.0"}, nil},
+		{"^1 || ^2", ">=1.5", "^1 >=1.5 || ^2 >=1.5", []string{"1.5.0", "2.0.0"}, []string{"1.4.0", "3.0.0"}},
+		{"^1", "^2", "^1 ^2", nil, []string{"1.5.0", "2.0.0"}},
+	}
+	for _, tt := range tests {
+		a, err := ParseConstraint(tt.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ParseConstraint(tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := Intersect(a, b)
+		if c.String() != tt.text {
+			t.Errorf("Intersect(%q, %q) = %q, want %q", tt.a, tt.b, c, tt.text)
+		}
+		for _, v := range tt.matches {
+			if !c.Check(v) {
+				t.Errorf("Intersect(%q, %q) doesn't match %s", tt.a, tt.b, v)
+			}
+		}
+		for _, v := range tt.rejects {
+			if c.Check(v) {
+				t.Errorf("Intersect(%q, %q) matches %s", tt.a, tt.b, v)
+			}
+		}
+	}
+}